@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/avGenie/go-loyalty-system/internal/app/accrualmock"
+	"github.com/avGenie/go-loyalty-system/internal/app/config"
+	"github.com/avGenie/go-loyalty-system/internal/app/logger"
+	"go.uber.org/zap"
+)
+
+func main() {
+	mockConfig := accrualmock.InitConfig()
+
+	err := logger.Initialize(config.Config{LogLevel: mockConfig.LogLevel})
+	if err != nil {
+		panic(err)
+	}
+
+	var script accrualmock.Script
+	if len(mockConfig.ScriptPath) != 0 {
+		script, err = accrualmock.LoadScript(mockConfig.ScriptPath)
+		if err != nil {
+			zap.L().Fatal("failed to load script", zap.Error(err))
+		}
+	}
+
+	simulator, err := accrualmock.NewSimulator(mockConfig, script)
+	if err != nil {
+		zap.L().Fatal("failed to init simulator", zap.Error(err))
+	}
+
+	server := &http.Server{
+		Addr:    mockConfig.NetAddr,
+		Handler: accrualmock.NewHandler(simulator, mockConfig).Router(),
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer cancel()
+
+	go func() {
+		zap.L().Info("start accrual mock server", zap.String("address", mockConfig.NetAddr))
+
+		err := server.ListenAndServe()
+		if err != nil && err != http.ErrServerClosed {
+			zap.L().Fatal("fatal error while starting server", zap.Error(err))
+		}
+	}()
+
+	<-ctx.Done()
+
+	err = server.Shutdown(context.Background())
+	if err != nil {
+		zap.L().Error("error while shutting down server", zap.Error(err))
+	}
+}
@@ -4,6 +4,9 @@ import (
 	"github.com/avGenie/go-loyalty-system/internal/app/config"
 	"github.com/avGenie/go-loyalty-system/internal/app/logger"
 	storage "github.com/avGenie/go-loyalty-system/internal/app/storage/api"
+	"github.com/avGenie/go-loyalty-system/internal/app/usecase/accrual"
+	"github.com/avGenie/go-loyalty-system/internal/app/usecase/crypto"
+	"github.com/avGenie/go-loyalty-system/internal/app/validator"
 	http_server "github.com/avGenie/go-loyalty-system/internal/app/controller/http/server"
 	"go.uber.org/zap"
 )
@@ -16,14 +19,36 @@ func main() {
 		panic(err)
 	}
 
+	keyRing, err := crypto.LoadKeyRing(config)
+	if err != nil {
+		zap.L().Fatal("failed to load jwt keys", zap.Error(err))
+	}
+	crypto.SetKeyRing(keyRing)
+
+	passwordHasher, err := crypto.LoadPasswordHasher(config)
+	if err != nil {
+		zap.L().Fatal("failed to init password hasher", zap.Error(err))
+	}
+	crypto.SetPasswordHasher(passwordHasher)
+
+	policy, err := validator.NewCredentialsPolicy(config)
+	if err != nil {
+		zap.L().Fatal("failed to load credentials policy", zap.Error(err))
+	}
+
 	storage, err := storage.InitStorage(config)
 	if err != nil {
 		zap.L().Fatal("failed to init storage", zap.Error(err))
 	}
 	defer storage.Close()
 
+	accrual, err := accrual.New(config)
+	if err != nil {
+		zap.L().Fatal("failed to init accrual client", zap.Error(err))
+	}
+
 	zap.L().Info("start gophermart server")
 
-	server := http_server.New(config, storage)
+	server := http_server.New(config, storage, accrual, policy)
 	server.StartHTTPServer()
 }
@@ -0,0 +1,37 @@
+package accrualmock
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/caarlos0/env/v10"
+)
+
+type Config struct {
+	NetAddr  string `env:"RUN_ADDRESS"`
+	LogLevel string `env:"LOG_LEVEL"`
+
+	ScriptPath      string        `env:"ACCRUAL_MOCK_SCRIPT"`
+	RPS             int           `env:"ACCRUAL_MOCK_RPS"`
+	RetryAfter      time.Duration `env:"ACCRUAL_MOCK_RETRY_AFTER"`
+	ProcessingAfter time.Duration `env:"ACCRUAL_MOCK_PROCESSING_AFTER"`
+	ProcessedAfter  time.Duration `env:"ACCRUAL_MOCK_PROCESSED_AFTER"`
+}
+
+func InitConfig() (config Config) {
+	flag.StringVar(&config.NetAddr, "a", "localhost:8081", "net address host:port")
+	flag.StringVar(&config.LogLevel, "l", "info", "log level")
+	flag.StringVar(&config.ScriptPath, "script", "", "JSON file with goods rules, orders and the answer for unknown orders")
+	flag.IntVar(&config.RPS, "rps", 0, "max requests per second before answering 429, 0 disables the limit")
+	flag.DurationVar(&config.RetryAfter, "retry-after", time.Minute, "Retry-After value of 429 answers")
+	flag.DurationVar(&config.ProcessingAfter, "processing-after", time.Second, "time an order stays REGISTERED")
+	flag.DurationVar(&config.ProcessedAfter, "processed-after", 2*time.Second, "time an order stays PROCESSING before it gets the final status")
+	flag.Parse()
+
+	if err := env.Parse(&config); err != nil {
+		panic(fmt.Errorf("error while parsing config: %w", err))
+	}
+
+	return
+}
@@ -0,0 +1,162 @@
+package accrualmock
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+type Handler struct {
+	simulator *Simulator
+	limiter   *limiter
+}
+
+func NewHandler(simulator *Simulator, config Config) *Handler {
+	return &Handler{
+		simulator: simulator,
+		limiter:   newLimiter(config.RPS, config.RetryAfter),
+	}
+}
+
+func (h *Handler) Router() *chi.Mux {
+	r := chi.NewRouter()
+
+	r.Use(h.limiter.Middleware)
+
+	r.Get("/api/orders/{number}", h.GetOrder())
+	r.Post("/api/orders", h.RegisterOrder())
+	r.Post("/api/goods", h.RegisterGoods())
+
+	return r
+}
+
+func (h *Handler) GetOrder() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		order, err := h.simulator.GetOrder(chi.URLParam(r, "number"))
+		if err != nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		out, err := json.Marshal(order)
+		if err != nil {
+			zap.L().Error("error while marshalling order", zap.Error(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(out)
+	}
+}
+
+func (h *Handler) RegisterOrder() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var request OrderRequest
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		err = h.simulator.RegisterOrder(request)
+		if err != nil {
+			writeRegistrationError(w, err, ErrOrderExists)
+			return
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+func (h *Handler) RegisterGoods() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var rule GoodsRule
+		err := json.NewDecoder(r.Body).Decode(&rule)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		err = h.simulator.RegisterGoods(rule)
+		if err != nil {
+			writeRegistrationError(w, err, ErrRuleExists)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func writeRegistrationError(w http.ResponseWriter, err, conflictErr error) {
+	zap.L().Info("registration rejected", zap.Error(err))
+
+	if errors.Is(err, conflictErr) {
+		w.WriteHeader(http.StatusConflict)
+		return
+	}
+
+	w.WriteHeader(http.StatusBadRequest)
+}
+
+// limiter counts requests in one second windows.
+type limiter struct {
+	rps        int
+	retryAfter time.Duration
+
+	mu          sync.Mutex
+	windowStart time.Time
+	count       int
+}
+
+func newLimiter(rps int, retryAfter time.Duration) *limiter {
+	return &limiter{
+		rps:        rps,
+		retryAfter: retryAfter,
+	}
+}
+
+func (l *limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if l.allow() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Retry-After", strconv.Itoa(int(l.retryAfter.Seconds())))
+		w.WriteHeader(http.StatusTooManyRequests)
+		fmt.Fprintf(w, "No more than %d requests per second allowed", l.rps)
+	})
+}
+
+func (l *limiter) allow() bool {
+	if l.rps <= 0 {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.windowStart) >= time.Second {
+		l.windowStart = now
+		l.count = 0
+	}
+
+	if l.count >= l.rps {
+		return false
+	}
+
+	l.count++
+
+	return true
+}
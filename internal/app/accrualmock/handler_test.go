@@ -0,0 +1,136 @@
+package accrualmock
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/avGenie/go-loyalty-system/internal/app/money"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testRequest(t *testing.T, server *httptest.Server, method, path, body string) (*http.Response, string) {
+	request, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+	require.NoError(t, err)
+
+	response, err := server.Client().Do(request)
+	require.NoError(t, err)
+	defer response.Body.Close()
+
+	responseBody, err := io.ReadAll(response.Body)
+	require.NoError(t, err)
+
+	return response, string(responseBody)
+}
+
+func TestOrderLifecycle(t *testing.T) {
+	config := Config{
+		ProcessingAfter: time.Second,
+		ProcessedAfter:  time.Second,
+	}
+	accrual := money.Points(50000)
+	script := Script{
+		Orders: []ScriptedOrder{
+			{OrderRequest: OrderRequest{Number: "2377225624"}, Status: StatusInvalid},
+			{OrderRequest: OrderRequest{Number: "735584316112"}, Accrual: &accrual},
+		},
+	}
+
+	simulator, err := NewSimulator(config, script)
+	require.NoError(t, err)
+
+	now := time.Now()
+	simulator.now = func() time.Time { return now }
+
+	server := httptest.NewServer(NewHandler(simulator, config).Router())
+	defer server.Close()
+
+	response, _ := testRequest(t, server, http.MethodPost, "/api/goods", `{"match": "Bork", "reward": 10, "reward_type": "%"}`)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+
+	response, _ = testRequest(t, server, http.MethodPost, "/api/goods", `{"match": "Bork", "reward": 5, "reward_type": "pt"}`)
+	assert.Equal(t, http.StatusConflict, response.StatusCode)
+
+	response, _ = testRequest(t, server, http.MethodPost, "/api/goods", `{"match": "Tefal", "reward": 5, "reward_type": "$"}`)
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+
+	response, _ = testRequest(t, server, http.MethodPost, "/api/orders", `{"order": "12345678903", "goods": [{"description": "Чайник Bork", "price": 7000}]}`)
+	assert.Equal(t, http.StatusAccepted, response.StatusCode)
+
+	response, _ = testRequest(t, server, http.MethodPost, "/api/orders", `{"order": "12345678903", "goods": []}`)
+	assert.Equal(t, http.StatusConflict, response.StatusCode)
+
+	response, _ = testRequest(t, server, http.MethodPost, "/api/orders", `{"order": "1234", "goods": []}`)
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+
+	response, body := testRequest(t, server, http.MethodGet, "/api/orders/12345678903", "")
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.JSONEq(t, `{"order": "12345678903", "status": "REGISTERED"}`, body)
+
+	now = now.Add(time.Second)
+	_, body = testRequest(t, server, http.MethodGet, "/api/orders/12345678903", "")
+	assert.JSONEq(t, `{"order": "12345678903", "status": "PROCESSING"}`, body)
+
+	now = now.Add(time.Second)
+	_, body = testRequest(t, server, http.MethodGet, "/api/orders/12345678903", "")
+	assert.JSONEq(t, `{"order": "12345678903", "status": "PROCESSED", "accrual": 700}`, body)
+
+	_, body = testRequest(t, server, http.MethodGet, "/api/orders/2377225624", "")
+	assert.JSONEq(t, `{"order": "2377225624", "status": "INVALID"}`, body)
+
+	_, body = testRequest(t, server, http.MethodGet, "/api/orders/735584316112", "")
+	assert.JSONEq(t, `{"order": "735584316112", "status": "PROCESSED", "accrual": 500}`, body)
+
+	response, body = testRequest(t, server, http.MethodGet, "/api/orders/9278923470", "")
+	assert.Equal(t, http.StatusNoContent, response.StatusCode)
+	assert.Empty(t, body)
+}
+
+func TestUnknownOrders(t *testing.T) {
+	accrual := money.Points(10000)
+	simulator, err := NewSimulator(Config{}, Script{
+		Unknown: &Outcome{Status: StatusProcessed, Accrual: &accrual},
+	})
+	require.NoError(t, err)
+
+	server := httptest.NewServer(NewHandler(simulator, Config{}).Router())
+	defer server.Close()
+
+	response, body := testRequest(t, server, http.MethodGet, "/api/orders/9278923470", "")
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.JSONEq(t, `{"order": "9278923470", "status": "PROCESSED", "accrual": 100}`, body)
+
+	response, _ = testRequest(t, server, http.MethodGet, "/api/orders/1234", "")
+	assert.Equal(t, http.StatusNoContent, response.StatusCode)
+
+	_, err = NewSimulator(Config{}, Script{
+		Unknown: &Outcome{Status: StatusProcessing},
+	})
+	assert.ErrorIs(t, err, ErrInvalidStatus)
+}
+
+func TestRateLimit(t *testing.T) {
+	config := Config{
+		RPS:        2,
+		RetryAfter: 30 * time.Second,
+	}
+	simulator, err := NewSimulator(config, Script{})
+	require.NoError(t, err)
+
+	server := httptest.NewServer(NewHandler(simulator, config).Router())
+	defer server.Close()
+
+	for i := 0; i < config.RPS; i++ {
+		response, _ := testRequest(t, server, http.MethodGet, "/api/orders/12345678903", "")
+		assert.Equal(t, http.StatusNoContent, response.StatusCode)
+	}
+
+	response, body := testRequest(t, server, http.MethodGet, "/api/orders/12345678903", "")
+	assert.Equal(t, http.StatusTooManyRequests, response.StatusCode)
+	assert.Equal(t, "30", response.Header.Get("Retry-After"))
+	assert.Equal(t, "No more than 2 requests per second allowed", body)
+}
@@ -0,0 +1,96 @@
+package accrualmock
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/avGenie/go-loyalty-system/internal/app/money"
+)
+
+type RewardType string
+
+const (
+	RewardPercent RewardType = `%`
+	RewardPoints  RewardType = `pt`
+)
+
+var (
+	ErrInvalidRule   = errors.New("invalid goods rule")
+	ErrInvalidOrder  = errors.New("invalid order")
+	ErrInvalidStatus = errors.New("scripted status must be INVALID or PROCESSED")
+)
+
+type GoodsRule struct {
+	Match      string       `json:"match"`
+	Reward     money.Points `json:"reward"`
+	RewardType RewardType   `json:"reward_type"`
+}
+
+type Good struct {
+	Description string       `json:"description"`
+	Price       money.Points `json:"price"`
+}
+
+type OrderRequest struct {
+	Number string `json:"order"`
+	Goods  []Good `json:"goods"`
+}
+
+// ScriptedOrder is an order registered on start. Status and Accrual, when
+// set, replace the accrual calculated from the goods rules.
+type ScriptedOrder struct {
+	OrderRequest
+
+	Status  Status        `json:"status,omitempty"`
+	Accrual *money.Points `json:"accrual,omitempty"`
+}
+
+// Outcome is the final state of an order that appears in the system on the
+// first request.
+type Outcome struct {
+	Status  Status        `json:"status"`
+	Accrual *money.Points `json:"accrual,omitempty"`
+}
+
+type Script struct {
+	Goods   []GoodsRule     `json:"goods"`
+	Orders  []ScriptedOrder `json:"orders"`
+	Unknown *Outcome        `json:"unknown,omitempty"`
+}
+
+func LoadScript(path string) (Script, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Script{}, fmt.Errorf("cannot read script file: %w", err)
+	}
+
+	var script Script
+	err = json.Unmarshal(data, &script)
+	if err != nil {
+		return Script{}, fmt.Errorf("cannot decode script file: %w", err)
+	}
+
+	return script, nil
+}
+
+func (r GoodsRule) Validate() error {
+	if len(r.Match) == 0 || !r.Reward.IsPositive() {
+		return ErrInvalidRule
+	}
+
+	if RewardPercent != r.RewardType && RewardPoints != r.RewardType {
+		return fmt.Errorf("%w: unknown reward type %q", ErrInvalidRule, r.RewardType)
+	}
+
+	return nil
+}
+
+func (o Outcome) Validate() error {
+	if StatusInvalid != o.Status && StatusProcessed != o.Status {
+		return ErrInvalidStatus
+	}
+
+	return nil
+}
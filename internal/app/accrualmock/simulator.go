@@ -0,0 +1,241 @@
+package accrualmock
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/avGenie/go-loyalty-system/internal/app/entity"
+	"github.com/avGenie/go-loyalty-system/internal/app/money"
+	"github.com/avGenie/go-loyalty-system/internal/app/usecase/validator"
+)
+
+type Status string
+
+const (
+	StatusRegistered Status = `REGISTERED`
+	StatusInvalid    Status = `INVALID`
+	StatusProcessing Status = `PROCESSING`
+	StatusProcessed  Status = `PROCESSED`
+)
+
+var (
+	ErrRuleExists    = errors.New("goods rule already exists")
+	ErrOrderExists   = errors.New("order already registered")
+	ErrOrderNotFound = errors.New("order is not registered")
+)
+
+type OrderResponse struct {
+	Number  string        `json:"order"`
+	Status  Status        `json:"status"`
+	Accrual *money.Points `json:"accrual,omitempty"`
+}
+
+type order struct {
+	registeredAt time.Time
+	outcome      Outcome
+}
+
+// Simulator keeps the registered goods rules and orders. An order is
+// REGISTERED right after registration, PROCESSING after ProcessingAfter and
+// gets its final status after ProcessedAfter more.
+type Simulator struct {
+	processingAfter time.Duration
+	processedAfter  time.Duration
+	unknown         *Outcome
+	now             func() time.Time
+
+	mu     sync.RWMutex
+	rules  []GoodsRule
+	orders map[string]order
+}
+
+func NewSimulator(config Config, script Script) (*Simulator, error) {
+	simulator := &Simulator{
+		processingAfter: config.ProcessingAfter,
+		processedAfter:  config.ProcessedAfter,
+		now:             time.Now,
+		orders:          make(map[string]order),
+	}
+
+	for _, rule := range script.Goods {
+		err := simulator.RegisterGoods(rule)
+		if err != nil {
+			return nil, fmt.Errorf("cannot register scripted goods rule %q: %w", rule.Match, err)
+		}
+	}
+
+	for _, scripted := range script.Orders {
+		err := simulator.registerScriptedOrder(scripted)
+		if err != nil {
+			return nil, fmt.Errorf("cannot register scripted order %q: %w", scripted.Number, err)
+		}
+	}
+
+	if script.Unknown != nil {
+		err := script.Unknown.Validate()
+		if err != nil {
+			return nil, fmt.Errorf("invalid outcome for unknown orders: %w", err)
+		}
+		simulator.unknown = script.Unknown
+	}
+
+	return simulator, nil
+}
+
+func (s *Simulator) RegisterGoods(rule GoodsRule) error {
+	err := rule.Validate()
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.rules {
+		if existing.Match == rule.Match {
+			return ErrRuleExists
+		}
+	}
+
+	s.rules = append(s.rules, rule)
+
+	return nil
+}
+
+func (s *Simulator) RegisterOrder(request OrderRequest) error {
+	err := validateOrderRequest(request)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.addOrder(request.Number, Outcome{
+		Status:  StatusProcessed,
+		Accrual: s.calculateAccrual(request.Goods),
+	})
+}
+
+func (s *Simulator) GetOrder(number string) (OrderResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	storedOrder, ok := s.orders[number]
+	if !ok {
+		if s.unknown == nil || !validator.OrderNumberValidation(entity.OrderNumber(number)) {
+			return OrderResponse{}, ErrOrderNotFound
+		}
+
+		storedOrder = order{
+			registeredAt: s.now(),
+			outcome:      *s.unknown,
+		}
+		s.orders[number] = storedOrder
+	}
+
+	response := OrderResponse{
+		Number: number,
+	}
+
+	elapsed := s.now().Sub(storedOrder.registeredAt)
+	switch {
+	case elapsed < s.processingAfter:
+		response.Status = StatusRegistered
+	case elapsed < s.processingAfter+s.processedAfter:
+		response.Status = StatusProcessing
+	default:
+		response.Status = storedOrder.outcome.Status
+		if StatusProcessed == response.Status {
+			response.Accrual = storedOrder.outcome.Accrual
+		}
+	}
+
+	return response, nil
+}
+
+func (s *Simulator) registerScriptedOrder(scripted ScriptedOrder) error {
+	err := validateOrderRequest(scripted.OrderRequest)
+	if err != nil {
+		return err
+	}
+
+	outcome := Outcome{
+		Status:  StatusProcessed,
+		Accrual: scripted.Accrual,
+	}
+	if len(scripted.Status) != 0 {
+		outcome.Status = scripted.Status
+	}
+	if scripted.Accrual == nil {
+		outcome.Accrual = s.calculateAccrual(scripted.Goods)
+	}
+
+	err = outcome.Validate()
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.addOrder(scripted.Number, outcome)
+}
+
+func (s *Simulator) addOrder(number string, outcome Outcome) error {
+	if _, ok := s.orders[number]; ok {
+		return ErrOrderExists
+	}
+
+	s.orders[number] = order{
+		registeredAt: s.now(),
+		outcome:      outcome,
+	}
+
+	return nil
+}
+
+// calculateAccrual rewards every good by the first rule matching its description.
+func (s *Simulator) calculateAccrual(goods []Good) *money.Points {
+	var accrual money.Points
+	matched := false
+	for _, good := range goods {
+		for _, rule := range s.rules {
+			if !strings.Contains(good.Description, rule.Match) {
+				continue
+			}
+
+			matched = true
+			if RewardPercent == rule.RewardType {
+				accrual += good.Price * rule.Reward / (100 * 100)
+			} else {
+				accrual += rule.Reward
+			}
+
+			break
+		}
+	}
+
+	if !matched {
+		return nil
+	}
+
+	return &accrual
+}
+
+func validateOrderRequest(request OrderRequest) error {
+	if !validator.OrderNumberValidation(entity.OrderNumber(request.Number)) {
+		return fmt.Errorf("%w: number %q fails Luhn check", ErrInvalidOrder, request.Number)
+	}
+
+	for _, good := range request.Goods {
+		if len(good.Description) == 0 || good.Price < 0 {
+			return fmt.Errorf("%w: invalid goods", ErrInvalidOrder)
+		}
+	}
+
+	return nil
+}
@@ -3,6 +3,9 @@ package config
 import (
 	"flag"
 	"fmt"
+	"net/netip"
+	"strings"
+	"time"
 
 	"github.com/caarlos0/env/v10"
 )
@@ -12,18 +15,175 @@ type Config struct {
 	DBConnect   string `env:"DATABASE_URI"`
 	AccrualAddr string `env:"ACCRUAL_SYSTEM_ADDRESS"`
 	LogLevel    string `env:"LOG_LEVEL"`
+
+	JWTSecret          string        `env:"JWT_SECRET"`
+	JWTKeysFile        string        `env:"JWT_KEYS_FILE"`
+	JWTPrivateKeyFile  string        `env:"JWT_PRIVATE_KEY_FILE"`
+	JWTRandomSecret    bool          `env:"JWT_RANDOM_SECRET"`
+	JWTTokenTTL        time.Duration `env:"JWT_TOKEN_TTL"`
+	JWTRefreshTokenTTL time.Duration `env:"JWT_REFRESH_TOKEN_TTL"`
+
+	LoginMinLength        int    `env:"LOGIN_MIN_LENGTH"`
+	LoginMaxLength        int    `env:"LOGIN_MAX_LENGTH"`
+	LoginCharset          string `env:"LOGIN_CHARSET"`
+	PasswordMinLength     int    `env:"PASSWORD_MIN_LENGTH"`
+	PasswordMaxLength     int    `env:"PASSWORD_MAX_LENGTH"`
+	PasswordClasses       int    `env:"PASSWORD_CLASSES"`
+	PasswordBlocklistFile string `env:"PASSWORD_BLOCKLIST_FILE"`
+
+	PasswordHashAlgorithm string `env:"PASSWORD_HASH_ALGORITHM"`
+	BcryptCost            int    `env:"BCRYPT_COST"`
+	Argon2Time            uint   `env:"ARGON2_TIME"`
+	Argon2Memory          uint   `env:"ARGON2_MEMORY"`
+	Argon2Threads         uint   `env:"ARGON2_THREADS"`
+
+	LoginFailureWindow time.Duration `env:"LOGIN_FAILURE_WINDOW"`
+	LoginFreeAttempts  int           `env:"LOGIN_FREE_ATTEMPTS"`
+	LoginDelayBase     time.Duration `env:"LOGIN_DELAY_BASE"`
+	LoginDelayMax      time.Duration `env:"LOGIN_DELAY_MAX"`
+	LoginMaxFailures   int           `env:"LOGIN_MAX_FAILURES"`
+	LoginLockout       time.Duration `env:"LOGIN_LOCKOUT"`
+
+	TrustedProxies string `env:"TRUSTED_PROXIES"`
+	// TrustedProxyPrefixes is parsed from TrustedProxies
+	TrustedProxyPrefixes []netip.Prefix
+
+	AdminLogins string `env:"ADMIN_LOGINS"`
+	// AdminLoginList is parsed from AdminLogins
+	AdminLoginList []string
+
+	AccrualAPIPath string        `env:"ACCRUAL_API_PATH"`
+	AccrualTimeout time.Duration `env:"ACCRUAL_TIMEOUT"`
+	AccrualProxy   string        `env:"ACCRUAL_PROXY"`
+	AccrualTLSCert string        `env:"ACCRUAL_TLS_CERT"`
+	AccrualTLSKey  string        `env:"ACCRUAL_TLS_KEY"`
+	AccrualTLSCA   string        `env:"ACCRUAL_TLS_CA"`
+
+	AccrualLeaseTimeout time.Duration `env:"ACCRUAL_LEASE_TIMEOUT"`
+	AccrualBackoffBase  time.Duration `env:"ACCRUAL_BACKOFF_BASE"`
+	AccrualBackoffMax   time.Duration `env:"ACCRUAL_BACKOFF_MAX"`
+	AccrualMaxAttempts  int           `env:"ACCRUAL_MAX_ATTEMPTS"`
+	AccrualIdleInterval time.Duration `env:"ACCRUAL_IDLE_INTERVAL"`
+	AccrualWorkers      int           `env:"ACCRUAL_WORKERS"`
+	AccrualRateLimit    int           `env:"ACCRUAL_RATE_LIMIT"`
+
+	AccrualBreakerFailures int           `env:"ACCRUAL_BREAKER_FAILURES"`
+	AccrualBreakerCoolDown time.Duration `env:"ACCRUAL_BREAKER_COOL_DOWN"`
+
+	AccrualCallbackSecret    string        `env:"ACCRUAL_CALLBACK_SECRET"`
+	AccrualCallbackTolerance time.Duration `env:"ACCRUAL_CALLBACK_TOLERANCE"`
+
+	OrderStreamHeartbeat time.Duration `env:"ORDER_STREAM_HEARTBEAT"`
+	OrderEventsRetention time.Duration `env:"ORDER_EVENTS_RETENTION"`
 }
 
 func InitConfig() (config Config) {
 	flag.StringVar(&config.NetAddr, "a", "localhost:8080", "net address host:port")
-	flag.StringVar(&config.DBConnect, "d", "", "database credentials in format: host=host port=port user=myuser password=xxxx dbname=mydb sslmode=disable")
+	flag.StringVar(&config.DBConnect, "d", "", "database credentials in format: host=host port=port user=myuser password=xxxx dbname=mydb sslmode=disable or memory:// for in-memory storage")
 	flag.StringVar(&config.AccrualAddr, "r", "", "charge calculation system address")
 	flag.StringVar(&config.LogLevel, "l", "info", "log level")
+	flag.StringVar(&config.JWTSecret, "jwt-secret", "", "secret for signing auth tokens")
+	flag.StringVar(&config.JWTKeysFile, "jwt-keys-file", "", "file with kid=secret or kid=file:/path/to/key.pem lines for auth tokens, the first key signs new tokens, overrides -jwt-private-key-file and -jwt-secret")
+	flag.StringVar(&config.JWTPrivateKeyFile, "jwt-private-key-file", "", "RSA or Ed25519 private key PEM file for signing auth tokens with RS256 or EdDSA, overrides -jwt-secret")
+	flag.BoolVar(&config.JWTRandomSecret, "jwt-random-secret", false, "sign auth tokens with a random secret when no jwt key is configured, tokens don't survive restart and aren't accepted by other instances, for development only")
+	flag.DurationVar(&config.JWTTokenTTL, "jwt-token-ttl", 3*time.Hour, "lifetime of auth tokens")
+	flag.DurationVar(&config.JWTRefreshTokenTTL, "jwt-refresh-token-ttl", 30*24*time.Hour, "lifetime of refresh tokens, every refresh token can be used once")
+	flag.IntVar(&config.LoginMinLength, "login-min-length", 1, "min login length in characters")
+	flag.IntVar(&config.LoginMaxLength, "login-max-length", 50, "max login length in characters, can't exceed 50")
+	flag.StringVar(&config.LoginCharset, "login-charset", `^[\p{L}\p{N}._@+-]+$`, "regular expression every login must match")
+	flag.IntVar(&config.PasswordMinLength, "password-min-length", 8, "min password length in characters")
+	flag.IntVar(&config.PasswordMaxLength, "password-max-length", 72, "max password length in bytes, can't exceed 72")
+	flag.IntVar(&config.PasswordClasses, "password-classes", 1, "number of character classes (lowercase, uppercase, digits, other) a password must contain")
+	flag.StringVar(&config.PasswordBlocklistFile, "password-blocklist-file", "", "file with breached passwords, one per line, that can't be used")
+	flag.StringVar(&config.PasswordHashAlgorithm, "password-hash-algorithm", "argon2id", "algorithm for hashing new passwords: argon2id or bcrypt, old hashes are rehashed on login")
+	flag.IntVar(&config.BcryptCost, "bcrypt-cost", 14, "bcrypt cost for hashing passwords")
+	flag.UintVar(&config.Argon2Time, "argon2-time", 2, "number of argon2id passes over memory")
+	flag.UintVar(&config.Argon2Memory, "argon2-memory", 19*1024, "argon2id memory in KiB")
+	flag.UintVar(&config.Argon2Threads, "argon2-threads", 1, "argon2id parallelism")
+	flag.DurationVar(&config.LoginFailureWindow, "login-failure-window", 15*time.Minute, "time after which failed login attempts are forgotten")
+	flag.IntVar(&config.LoginFreeAttempts, "login-free-attempts", 3, "failed login attempts allowed without a delay")
+	flag.DurationVar(&config.LoginDelayBase, "login-delay-base", time.Second, "delay after the first throttled login attempt, doubled with every failure")
+	flag.DurationVar(&config.LoginDelayMax, "login-delay-max", 30*time.Second, "max delay between failed login attempts")
+	flag.IntVar(&config.LoginMaxFailures, "login-max-failures", 10, "failed login attempts per login or IP after which logins are locked out")
+	flag.DurationVar(&config.LoginLockout, "login-lockout", 15*time.Minute, "lockout time after too many failed login attempts")
+	flag.StringVar(&config.TrustedProxies, "trusted-proxies", "", "comma separated addresses or CIDRs of reverse proxies, X-Forwarded-For and X-Real-IP are read only from them, otherwise all clients behind a proxy share one IP for login throttling")
+	flag.StringVar(&config.AdminLogins, "admin-logins", "", "comma separated logins which are granted the ADMIN role at startup and on registration, removing a login doesn't revoke the role")
+	flag.StringVar(&config.AccrualAPIPath, "accrual-api-path", "/api/orders/", "path of the accrual system order endpoint")
+	flag.DurationVar(&config.AccrualTimeout, "accrual-timeout", 3*time.Second, "timeout of a request to the accrual system")
+	flag.StringVar(&config.AccrualProxy, "accrual-proxy", "", "proxy URL for requests to the accrual system")
+	flag.StringVar(&config.AccrualTLSCert, "accrual-tls-cert", "", "client certificate PEM file for mTLS with the accrual system")
+	flag.StringVar(&config.AccrualTLSKey, "accrual-tls-key", "", "client key PEM file for mTLS with the accrual system")
+	flag.StringVar(&config.AccrualTLSCA, "accrual-tls-ca", "", "CA certificate PEM file to verify the accrual system")
+	flag.DurationVar(&config.AccrualLeaseTimeout, "accrual-lease-timeout", 2*time.Minute, "time an order stays leased by one instance while its accrual is polled")
+	flag.DurationVar(&config.AccrualBackoffBase, "accrual-backoff-base", time.Second, "delay before the second accrual check of an order, doubled with every attempt")
+	flag.DurationVar(&config.AccrualBackoffMax, "accrual-backoff-max", 10*time.Minute, "max delay between accrual checks of an order")
+	flag.IntVar(&config.AccrualMaxAttempts, "accrual-max-attempts", 100, "accrual checks after which an order is parked for manual review, 0 disables parking")
+	flag.DurationVar(&config.AccrualIdleInterval, "accrual-idle-interval", time.Second, "sleep time when no orders are due for an accrual check")
+	flag.IntVar(&config.AccrualWorkers, "accrual-workers", 4, "number of concurrent requests to the accrual system")
+	flag.IntVar(&config.AccrualRateLimit, "accrual-rate-limit", 0, "max requests per second to the accrual system shared by all workers, 0 disables the limit")
+	flag.IntVar(&config.AccrualBreakerFailures, "accrual-breaker-failures", 5, "consecutive failed accrual requests that open the circuit breaker")
+	flag.DurationVar(&config.AccrualBreakerCoolDown, "accrual-breaker-cool-down", 30*time.Second, "time the circuit breaker stays open before a probe request")
+	flag.StringVar(&config.AccrualCallbackSecret, "accrual-callback-secret", "", "HMAC secret of accrual callbacks, the callback endpoint is disabled when empty")
+	flag.DurationVar(&config.AccrualCallbackTolerance, "accrual-callback-tolerance", 5*time.Minute, "max clock difference between a signed accrual callback and the server")
+	flag.DurationVar(&config.OrderStreamHeartbeat, "order-stream-heartbeat", 15*time.Second, "interval of heartbeat comments in the order events stream keeping idle connections open")
+	flag.DurationVar(&config.OrderEventsRetention, "order-events-retention", 24*time.Hour, "time the order events are kept for resuming the order events stream")
 	flag.Parse()
 
 	if err := env.Parse(&config); err != nil {
 		panic(fmt.Errorf("error while parsing config: %w", err))
 	}
 
+	prefixes, err := ParseTrustedProxies(config.TrustedProxies)
+	if err != nil {
+		panic(fmt.Errorf("error while parsing config: %w", err))
+	}
+	config.TrustedProxyPrefixes = prefixes
+	config.AdminLoginList = ParseAdminLogins(config.AdminLogins)
+
 	return
 }
+
+// ParseAdminLogins parses comma separated logins.
+func ParseAdminLogins(value string) []string {
+	var logins []string
+	for _, login := range strings.Split(value, ",") {
+		login = strings.TrimSpace(login)
+		if len(login) == 0 {
+			continue
+		}
+
+		logins = append(logins, login)
+	}
+
+	return logins
+}
+
+// ParseTrustedProxies parses comma separated addresses and CIDRs.
+func ParseTrustedProxies(value string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, proxy := range strings.Split(value, ",") {
+		proxy = strings.TrimSpace(proxy)
+		if len(proxy) == 0 {
+			continue
+		}
+
+		if !strings.Contains(proxy, "/") {
+			addr, err := netip.ParseAddr(proxy)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy address %q: %w", proxy, err)
+			}
+
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy CIDR %q: %w", proxy, err)
+		}
+
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	return prefixes, nil
+}
@@ -0,0 +1,305 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/avGenie/go-loyalty-system/internal/app/converter"
+	"github.com/avGenie/go-loyalty-system/internal/app/entity"
+	"github.com/avGenie/go-loyalty-system/internal/app/model"
+	"github.com/avGenie/go-loyalty-system/internal/app/money"
+	"github.com/avGenie/go-loyalty-system/internal/app/usecase/admin"
+	"github.com/avGenie/go-loyalty-system/internal/app/usecase/problem"
+	"github.com/avGenie/go-loyalty-system/internal/app/usecase/validator"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	ErrInvalidAuth  = "auth credentials are invalid"
+	ErrTokenRevoked = "token has been revoked"
+	ErrNotAdmin     = "admin role is required"
+	ErrEmptyLogin   = "login query parameter is empty"
+	ErrInvalidUser  = "user id is invalid"
+	ErrInvalidOrder = "order number is invalid"
+
+	ErrInvalidAdjustment = "wrong adjustment request format"
+	ErrZeroAdjustment    = "adjustment amount must not be zero"
+	ErrInvalidReason     = "adjustment reason must be one of GOODWILL, FRAUD_REVERSAL, CORRECTION"
+	ErrInvalidComment    = "adjustment comment must not be empty or longer than 1000 characters"
+)
+
+const (
+	commentMaxLength = 1000
+)
+
+const (
+	UserIDParam      = "userID"
+	OrderNumberParam = "number"
+	LoginQuery       = "login"
+)
+
+type Admin struct {
+	storage admin.AdminProcessor
+}
+
+func New(storage admin.AdminProcessor) Admin {
+	return Admin{
+		storage: storage,
+	}
+}
+
+// AdminMiddleware lets through only the requests authorized by a token
+// with the admin role.
+func (a *Admin) AdminMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userIDCtx, ok := r.Context().Value(entity.UserIDCtxKey{}).(entity.UserIDCtx)
+		if !ok {
+			zap.L().Error("user id couldn't obtain from context while checking admin role")
+			problem.WriteCode(w, problem.CodeInternal, "")
+			return
+		}
+
+		if userIDCtx.StatusCode == http.StatusInternalServerError {
+			problem.WriteCode(w, problem.CodeInternal, "")
+			return
+		}
+
+		if userIDCtx.Revoked {
+			problem.WriteCode(w, problem.CodeTokenRevoked, ErrTokenRevoked)
+			return
+		}
+
+		if userIDCtx.StatusCode != http.StatusOK || !userIDCtx.UserID.Valid() {
+			problem.WriteCode(w, problem.CodeUnauthorized, ErrInvalidAuth)
+			return
+		}
+
+		if !userIDCtx.Role.IsAdmin() {
+			zap.L().Info("admin api request without admin role", zap.String("user_id", userIDCtx.UserID.String()), zap.String("path", r.URL.Path))
+			problem.WriteCode(w, problem.CodeForbidden, ErrNotAdmin)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (a *Admin) GetUser() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		login := r.URL.Query().Get(LoginQuery)
+		if len(login) == 0 {
+			problem.WriteCode(w, problem.CodeInvalidQuery, ErrEmptyLogin)
+			return
+		}
+
+		err := a.audit(entity.AdminActionGetUser, login, w, r)
+		if err != nil {
+			return
+		}
+
+		user, err := admin.FindUser(login, a.storage, w)
+		if err != nil {
+			return
+		}
+
+		a.sendJSON(converter.ConvertUserToAdminResponse(user), w)
+	}
+}
+
+func (a *Admin) GetUserOrders() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := a.parseUserID(w, r)
+		if err != nil {
+			zap.L().Error("error while parsing user id while getting user orders for admin", zap.Error(err))
+			return
+		}
+
+		err = a.audit(entity.AdminActionGetUserOrders, userID.String(), w, r)
+		if err != nil {
+			return
+		}
+
+		orders, err := admin.GetUserOrders(userID, a.storage, w)
+		if err != nil {
+			return
+		}
+
+		outOrders, err := converter.ConvertStorageOrdersToOutputUploadedOrders(orders)
+		if err != nil {
+			zap.L().Error("error while converting user orders to output model for admin", zap.Error(err))
+			problem.Write(w, err)
+			return
+		}
+
+		a.sendJSON(outOrders, w)
+	}
+}
+
+func (a *Admin) GetUserWithdrawals() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := a.parseUserID(w, r)
+		if err != nil {
+			zap.L().Error("error while parsing user id while getting user withdrawals for admin", zap.Error(err))
+			return
+		}
+
+		err = a.audit(entity.AdminActionGetUserWithdrawals, userID.String(), w, r)
+		if err != nil {
+			return
+		}
+
+		withdrawals, err := admin.GetUserWithdrawals(userID, a.storage, w)
+		if err != nil {
+			return
+		}
+
+		a.sendJSON(converter.ConvertWithdrawToWithdrawResponse(withdrawals), w)
+	}
+}
+
+func (a *Admin) GetUserBalance() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := a.parseUserID(w, r)
+		if err != nil {
+			zap.L().Error("error while parsing user id while getting user balance for admin", zap.Error(err))
+			return
+		}
+
+		err = a.audit(entity.AdminActionGetUserBalance, userID.String(), w, r)
+		if err != nil {
+			return
+		}
+
+		balance, err := admin.GetUserBalance(userID, a.storage, w)
+		if err != nil {
+			return
+		}
+
+		a.sendJSON(converter.ConvertStorageBalanceToOutput(balance), w)
+	}
+}
+
+func (a *Admin) AdjustUserBalance() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := a.parseUserID(w, r)
+		if err != nil {
+			zap.L().Error("error while parsing user id while adjusting user balance", zap.Error(err))
+			return
+		}
+
+		adjustment, err := a.parseAdjustment(w, r)
+		if err != nil {
+			zap.L().Error("error while parsing balance adjustment", zap.Error(err))
+			return
+		}
+
+		err = a.audit(entity.AdminActionAdjustBalance, userID.String(), w, r)
+		if err != nil {
+			return
+		}
+
+		admin.AdjustUserBalance(userID, adjustment, a.storage, w)
+	}
+}
+
+func (a *Admin) RecheckOrder() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		number := entity.OrderNumber(chi.URLParam(r, OrderNumberParam))
+		if !validator.OrderNumberValidation(number) {
+			problem.WriteCode(w, problem.CodeInvalidOrderNumber, ErrInvalidOrder)
+			return
+		}
+
+		err := a.audit(entity.AdminActionRecheckOrder, string(number), w, r)
+		if err != nil {
+			return
+		}
+
+		admin.RecheckOrder(number, a.storage, w)
+	}
+}
+
+func (a *Admin) audit(action entity.AdminAction, target string, w http.ResponseWriter, r *http.Request) error {
+	adminID, err := a.parseAdminID(w, r)
+	if err != nil {
+		return err
+	}
+
+	return admin.Audit(entity.CreateAuditRecord(adminID, action, target), a.storage, w)
+}
+
+func (a *Admin) parseAdminID(w http.ResponseWriter, r *http.Request) (entity.UserID, error) {
+	userIDCtx, ok := r.Context().Value(entity.UserIDCtxKey{}).(entity.UserIDCtx)
+	if !ok {
+		problem.WriteCode(w, problem.CodeInternal, "")
+		return entity.UserID(""), fmt.Errorf("admin id couldn't obtain from context")
+	}
+
+	return userIDCtx.UserID, nil
+}
+
+func (a *Admin) parseAdjustment(w http.ResponseWriter, r *http.Request) (entity.Adjustment, error) {
+	adminID, err := a.parseAdminID(w, r)
+	if err != nil {
+		return entity.Adjustment{}, err
+	}
+
+	var request model.AdjustmentRequest
+	err = json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		if errors.Is(err, money.ErrInvalidPrecision) {
+			problem.WriteCode(w, problem.CodeInvalidAdjustment, ErrInvalidAdjustment)
+		} else {
+			problem.WriteCode(w, problem.CodeBadRequest, ErrInvalidAdjustment)
+		}
+		return entity.Adjustment{}, fmt.Errorf("error while decoding adjustment request: %w", err)
+	}
+	defer r.Body.Close()
+
+	adjustment := converter.ConvertAdjustmentRequestToEntity(adminID, request)
+	if adjustment.Amount == 0 {
+		problem.WriteCode(w, problem.CodeInvalidAdjustment, ErrZeroAdjustment)
+		return entity.Adjustment{}, fmt.Errorf(ErrZeroAdjustment)
+	}
+
+	if !adjustment.Reason.IsValid() {
+		problem.WriteCode(w, problem.CodeInvalidAdjustment, ErrInvalidReason)
+		return entity.Adjustment{}, fmt.Errorf("adjustment reason = %s is invalid", adjustment.Reason)
+	}
+
+	if len(strings.TrimSpace(adjustment.Comment)) == 0 || utf8.RuneCountInString(adjustment.Comment) > commentMaxLength {
+		problem.WriteCode(w, problem.CodeInvalidAdjustment, ErrInvalidComment)
+		return entity.Adjustment{}, fmt.Errorf(ErrInvalidComment)
+	}
+
+	return adjustment, nil
+}
+
+func (a *Admin) parseUserID(w http.ResponseWriter, r *http.Request) (entity.UserID, error) {
+	userID := chi.URLParam(r, UserIDParam)
+	if _, err := uuid.Parse(userID); err != nil {
+		problem.WriteCode(w, problem.CodeBadRequest, ErrInvalidUser)
+		return entity.UserID(""), fmt.Errorf("user id = %s is invalid: %w", userID, err)
+	}
+
+	return entity.UserID(userID), nil
+}
+
+func (a *Admin) sendJSON(response any, w http.ResponseWriter) {
+	out, err := json.Marshal(response)
+	if err != nil {
+		zap.L().Error("error while marshalling admin response", zap.Error(err))
+		problem.Write(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(out)
+}
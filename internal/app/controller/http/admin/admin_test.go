@@ -0,0 +1,325 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/avGenie/go-loyalty-system/internal/app/controller/http/admin/mock"
+	"github.com/avGenie/go-loyalty-system/internal/app/entity"
+	"github.com/avGenie/go-loyalty-system/internal/app/money"
+	err_storage "github.com/avGenie/go-loyalty-system/internal/app/storage/api/errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	adminID = entity.UserID("00308dff-b6b1-4f1b-8515-d09d3db49951")
+)
+
+func TestAdminMiddleware(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	s := mock.NewMockAdminProcessor(ctrl)
+
+	tests := []struct {
+		name       string
+		isContext  bool
+		userIDCtx  entity.UserIDCtx
+		statusCode int
+	}{
+		{
+			name:       "admin role",
+			isContext:  true,
+			userIDCtx:  entity.CreateTokenUserIDCtx(adminID, entity.UserRoleAdmin, "jti", time.Time{}),
+			statusCode: http.StatusOK,
+		},
+		{
+			name:       "user role",
+			isContext:  true,
+			userIDCtx:  entity.CreateTokenUserIDCtx(adminID, entity.UserRoleUser, "jti", time.Time{}),
+			statusCode: http.StatusForbidden,
+		},
+		{
+			name:       "token without role",
+			isContext:  true,
+			userIDCtx:  entity.CreateTokenUserIDCtx(adminID, "", "jti", time.Time{}),
+			statusCode: http.StatusForbidden,
+		},
+		{
+			name:       "expired token",
+			isContext:  true,
+			userIDCtx:  entity.CreateUserIDCtx("", http.StatusUnauthorized),
+			statusCode: http.StatusUnauthorized,
+		},
+		{
+			name:       "revocation check error",
+			isContext:  true,
+			userIDCtx:  entity.CreateUserIDCtx("", http.StatusInternalServerError),
+			statusCode: http.StatusInternalServerError,
+		},
+		{
+			name:       "without context",
+			isContext:  false,
+			statusCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/api/admin/users", nil)
+			writer := httptest.NewRecorder()
+
+			if test.isContext {
+				request = request.WithContext(context.WithValue(request.Context(), entity.UserIDCtxKey{}, test.userIDCtx))
+			}
+
+			admin := New(s)
+			handler := admin.AdminMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+			handler.ServeHTTP(writer, request)
+
+			res := writer.Result()
+
+			assert.Equal(t, test.statusCode, res.StatusCode)
+
+			err := res.Body.Close()
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestRecheckOrder(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	s := mock.NewMockAdminProcessor(ctrl)
+
+	tests := []struct {
+		name       string
+		number     string
+		auditErr   error
+		isAudit    bool
+		recheckErr error
+		isRecheck  bool
+		statusCode int
+	}{
+		{
+			name:       "parked order",
+			number:     "735584316112",
+			isAudit:    true,
+			isRecheck:  true,
+			statusCode: http.StatusAccepted,
+		},
+		{
+			name:       "unknown order",
+			number:     "735584316112",
+			isAudit:    true,
+			recheckErr: err_storage.ErrOrderNumberNotFound,
+			isRecheck:  true,
+			statusCode: http.StatusNotFound,
+		},
+		{
+			name:       "processed order",
+			number:     "735584316112",
+			isAudit:    true,
+			recheckErr: err_storage.ErrOrderProcessed,
+			isRecheck:  true,
+			statusCode: http.StatusConflict,
+		},
+		{
+			name:       "storage error",
+			number:     "735584316112",
+			isAudit:    true,
+			recheckErr: errors.New(""),
+			isRecheck:  true,
+			statusCode: http.StatusInternalServerError,
+		},
+		{
+			name:       "audit error",
+			number:     "735584316112",
+			auditErr:   errors.New(""),
+			isAudit:    true,
+			statusCode: http.StatusInternalServerError,
+		},
+		{
+			name:       "invalid order number",
+			number:     "735584316113",
+			statusCode: http.StatusUnprocessableEntity,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPost, "/api/admin/orders/"+test.number+"/recheck", nil)
+			request = request.WithContext(context.WithValue(request.Context(), entity.UserIDCtxKey{}, entity.CreateTokenUserIDCtx(adminID, entity.UserRoleAdmin, "jti", time.Time{})))
+			writer := httptest.NewRecorder()
+
+			if test.isAudit {
+				record := entity.CreateAuditRecord(adminID, entity.AdminActionRecheckOrder, test.number)
+				s.EXPECT().CreateAuditRecord(gomock.Any(), record).Return(test.auditErr)
+			} else {
+				s.EXPECT().CreateAuditRecord(gomock.Any(), gomock.Any()).Times(0)
+			}
+
+			if test.isRecheck {
+				s.EXPECT().RecheckOrder(gomock.Any(), entity.OrderNumber(test.number)).Return(test.recheckErr)
+			} else {
+				s.EXPECT().RecheckOrder(gomock.Any(), gomock.Any()).Times(0)
+			}
+
+			admin := New(s)
+			router := chi.NewRouter()
+			router.Post("/api/admin/orders/{number}/recheck", admin.RecheckOrder())
+			router.ServeHTTP(writer, request)
+
+			res := writer.Result()
+
+			assert.Equal(t, test.statusCode, res.StatusCode)
+
+			err := res.Body.Close()
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestAdjustUserBalance(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	s := mock.NewMockAdminProcessor(ctrl)
+
+	userID := entity.UserID("0b98bf79-833c-44e0-b979-2dae19dda46c")
+
+	tests := []struct {
+		name       string
+		userID     string
+		body       string
+		adjustment entity.Adjustment
+		isAdjust   bool
+		adjustErr  error
+		statusCode int
+	}{
+		{
+			name:   "goodwill credit",
+			userID: userID.String(),
+			body:   `{"amount": 100.5, "reason": "GOODWILL", "comment": "delayed delivery"}`,
+			adjustment: entity.Adjustment{
+				AdminID: adminID,
+				Amount:  money.Points(10050),
+				Reason:  entity.AdjustmentReasonGoodwill,
+				Comment: "delayed delivery",
+			},
+			isAdjust:   true,
+			statusCode: http.StatusOK,
+		},
+		{
+			name:   "fraud reversal",
+			userID: userID.String(),
+			body:   `{"amount": -50, "reason": "FRAUD_REVERSAL", "comment": "order 735584316112"}`,
+			adjustment: entity.Adjustment{
+				AdminID: adminID,
+				Amount:  money.Points(-5000),
+				Reason:  entity.AdjustmentReasonFraudReversal,
+				Comment: "order 735584316112",
+			},
+			isAdjust:   true,
+			statusCode: http.StatusOK,
+		},
+		{
+			name:   "negative balance",
+			userID: userID.String(),
+			body:   `{"amount": -50, "reason": "FRAUD_REVERSAL", "comment": "order 735584316112"}`,
+			adjustment: entity.Adjustment{
+				AdminID: adminID,
+				Amount:  money.Points(-5000),
+				Reason:  entity.AdjustmentReasonFraudReversal,
+				Comment: "order 735584316112",
+			},
+			isAdjust:   true,
+			adjustErr:  err_storage.ErrNotEnoughSum,
+			statusCode: http.StatusConflict,
+		},
+		{
+			name:   "unknown user",
+			userID: userID.String(),
+			body:   `{"amount": 1, "reason": "CORRECTION", "comment": "typo"}`,
+			adjustment: entity.Adjustment{
+				AdminID: adminID,
+				Amount:  money.Points(100),
+				Reason:  entity.AdjustmentReasonCorrection,
+				Comment: "typo",
+			},
+			isAdjust:   true,
+			adjustErr:  err_storage.ErrUserNotFoundTable,
+			statusCode: http.StatusNotFound,
+		},
+		{
+			name:       "zero amount",
+			userID:     userID.String(),
+			body:       `{"amount": 0, "reason": "CORRECTION", "comment": "typo"}`,
+			statusCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "opening balance reason",
+			userID:     userID.String(),
+			body:       `{"amount": 1, "reason": "OPENING_BALANCE", "comment": "typo"}`,
+			statusCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "empty comment",
+			userID:     userID.String(),
+			body:       `{"amount": 1, "reason": "CORRECTION", "comment": " "}`,
+			statusCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "invalid amount precision",
+			userID:     userID.String(),
+			body:       `{"amount": 1.005, "reason": "CORRECTION", "comment": "typo"}`,
+			statusCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "invalid user id",
+			userID:     "user",
+			body:       `{"amount": 1, "reason": "CORRECTION", "comment": "typo"}`,
+			statusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPost, "/api/admin/users/"+test.userID+"/balance/adjustments", strings.NewReader(test.body))
+			request = request.WithContext(context.WithValue(request.Context(), entity.UserIDCtxKey{}, entity.CreateTokenUserIDCtx(adminID, entity.UserRoleAdmin, "jti", time.Time{})))
+			writer := httptest.NewRecorder()
+
+			if test.isAdjust {
+				record := entity.CreateAuditRecord(adminID, entity.AdminActionAdjustBalance, test.userID)
+				s.EXPECT().CreateAuditRecord(gomock.Any(), record).Return(nil)
+				s.EXPECT().AdjustUserBalance(gomock.Any(), userID, test.adjustment).Return(test.adjustErr)
+			} else {
+				s.EXPECT().CreateAuditRecord(gomock.Any(), gomock.Any()).Times(0)
+				s.EXPECT().AdjustUserBalance(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			}
+
+			admin := New(s)
+			router := chi.NewRouter()
+			router.Post("/api/admin/users/{userID}/balance/adjustments", admin.AdjustUserBalance())
+			router.ServeHTTP(writer, request)
+
+			res := writer.Result()
+
+			assert.Equal(t, test.statusCode, res.StatusCode)
+
+			err := res.Body.Close()
+			require.NoError(t, err)
+		})
+	}
+}
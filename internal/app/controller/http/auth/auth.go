@@ -3,29 +3,78 @@ package auth
 import (
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
 
+	"github.com/avGenie/go-loyalty-system/internal/app/config"
 	"github.com/avGenie/go-loyalty-system/internal/app/entity"
 	"github.com/avGenie/go-loyalty-system/internal/app/model"
 	"github.com/avGenie/go-loyalty-system/internal/app/usecase/auth"
 	usecase "github.com/avGenie/go-loyalty-system/internal/app/usecase/converter"
 	"github.com/avGenie/go-loyalty-system/internal/app/usecase/crypto"
+	"github.com/avGenie/go-loyalty-system/internal/app/usecase/problem"
 	"github.com/avGenie/go-loyalty-system/internal/app/validator"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
 )
 
 const (
-	ErrEmptyUserRequest = "wrong user credentials format: empty login or password"
+	ErrEmptyUserRequest     = "wrong user credentials format: empty login or password"
+	ErrEmptyRefreshRequest  = "wrong refresh request format: empty refresh token"
+	ErrInvalidAuth          = "auth credentials are invalid"
+	ErrTokenRevoked         = "token has been revoked"
+	ErrEmptyPasswordRequest = "wrong change password request format: empty old or new password"
+	ErrInvalidJSON          = "request body isn't a valid JSON object"
+	ErrCredentialsPolicy    = "credentials violate the credentials policy"
+
+	defaultRefreshTokenTTL = 30 * 24 * time.Hour
+
+	defaultLoginFailureWindow = 15 * time.Minute
+	defaultLoginFreeAttempts  = 3
+	defaultLoginDelayBase     = time.Second
+	defaultLoginDelayMax      = 30 * time.Second
+	defaultLoginMaxFailures   = 10
+	defaultLoginLockout       = 15 * time.Minute
+)
+
+const (
+	forwardedForHeader = "X-Forwarded-For"
+	realIPHeader       = "X-Real-IP"
 )
 
 type AuthUser struct {
-	storage auth.UserAuthenticator
+	storage        auth.UserAuthenticator
+	policy         validator.CredentialsPolicy
+	refreshTTL     time.Duration
+	throttle       entity.LoginThrottle
+	trustedProxies []netip.Prefix
+	adminLogins    []string
 }
 
-func New(storage auth.UserAuthenticator) AuthUser {
+func New(storage auth.UserAuthenticator, config config.Config, policy validator.CredentialsPolicy) AuthUser {
+	refreshTTL := config.JWTRefreshTokenTTL
+	if refreshTTL <= 0 {
+		refreshTTL = defaultRefreshTokenTTL
+	}
+
 	return AuthUser{
-		storage: storage,
+		storage:        storage,
+		policy:         policy,
+		refreshTTL:     refreshTTL,
+		trustedProxies: config.TrustedProxyPrefixes,
+		adminLogins:    config.AdminLoginList,
+		throttle: entity.LoginThrottle{
+			Window:       durationOrDefault(config.LoginFailureWindow, defaultLoginFailureWindow),
+			FreeAttempts: intOrDefault(config.LoginFreeAttempts, defaultLoginFreeAttempts),
+			DelayBase:    durationOrDefault(config.LoginDelayBase, defaultLoginDelayBase),
+			DelayMax:     durationOrDefault(config.LoginDelayMax, defaultLoginDelayMax),
+			MaxFailures:  intOrDefault(config.LoginMaxFailures, defaultLoginMaxFailures),
+			Lockout:      durationOrDefault(config.LoginLockout, defaultLoginLockout),
+		},
 	}
 }
 
@@ -37,12 +86,16 @@ func (a *AuthUser) CreateUser() http.HandlerFunc {
 			return
 		}
 
+		if slices.Contains(a.adminLogins, user.Login) {
+			user.Role = entity.UserRoleAdmin
+		}
+
 		err = auth.CreateUser(user, a.storage, w)
 		if err != nil {
 			return
 		}
 
-		a.setUserIDToHeader(user.ID, w)
+		a.issueTokens(user, w)
 	}
 }
 
@@ -54,24 +107,127 @@ func (a *AuthUser) AuthenticateUser() http.HandlerFunc {
 			return
 		}
 
-		storageUser, err := auth.AuthUser(inputUser, a.storage, w)
+		storageUser, err := auth.AuthUser(inputUser, a.clientIP(r), a.throttle, a.storage, w)
+		if err != nil {
+			return
+		}
+
+		a.issueTokens(storageUser, w)
+	}
+}
+
+func (a *AuthUser) RefreshToken() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var request model.RefreshTokenRequest
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			zap.L().Error("error while decoding refresh token request", zap.Error(err))
+			problem.WriteCode(w, problem.CodeBadRequest, ErrInvalidJSON)
+			return
+		}
+		defer r.Body.Close()
+
+		if len(request.RefreshToken) == 0 {
+			problem.WriteCode(w, problem.CodeBadRequest, ErrEmptyRefreshRequest)
+			return
+		}
+
+		user, refreshToken, err := auth.RotateRefreshToken(request.RefreshToken, a.refreshTTL, a.storage, w)
+		if err != nil {
+			return
+		}
+
+		a.writeTokens(user, refreshToken, w)
+	}
+}
+
+func (a *AuthUser) Logout() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userCtx, err := a.parseUserCtx(w, r)
+		if err != nil {
+			zap.L().Error("error while parsing user id while logout", zap.Error(err))
+			return
+		}
+
+		auth.Logout(userCtx, a.storage, w)
+	}
+}
+
+func (a *AuthUser) ChangePassword() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userCtx, err := a.parseUserCtx(w, r)
+		if err != nil {
+			zap.L().Error("error while parsing user id while changing password", zap.Error(err))
+			return
+		}
+
+		var request model.ChangePasswordRequest
+		err = json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			zap.L().Error("error while decoding change password request", zap.Error(err))
+			problem.WriteCode(w, problem.CodeBadRequest, ErrInvalidJSON)
+			return
+		}
+		defer r.Body.Close()
+
+		if len(request.OldPassword) == 0 || len(request.NewPassword) == 0 {
+			problem.WriteCode(w, problem.CodeBadRequest, ErrEmptyPasswordRequest)
+			return
+		}
+
+		if errs := a.policy.ValidatePassword(request.NewPassword); len(errs) != 0 {
+			zap.L().Info("new password violates password policy", zap.String("user_id", userCtx.UserID.String()))
+			writeValidationErrors(errs, w)
+			return
+		}
+
+		auth.ChangePassword(userCtx.UserID, request.OldPassword, request.NewPassword, a.throttle, a.storage, w)
+	}
+}
+
+func (a *AuthUser) DeleteUser() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userCtx, err := a.parseUserCtx(w, r)
+		if err != nil {
+			zap.L().Error("error while parsing user id while deleting user", zap.Error(err))
+			return
+		}
+
+		auth.DeleteUser(userCtx.UserID, a.storage, w)
+	}
+}
+
+func (a *AuthUser) GetJWKS() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		out, err := json.Marshal(usecase.ConvertKeysToJWKS(crypto.PublicKeys()))
 		if err != nil {
+			zap.L().Error("error while marshalling jwks response", zap.Error(err))
+			problem.Write(w, err)
 			return
 		}
 
-		a.setUserIDToHeader(storageUser.ID, w)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(out)
 	}
 }
 
 func (a *AuthUser) createUserFromRequestPassHashed(userID entity.UserID, w http.ResponseWriter, r *http.Request) (entity.User, error) {
-	user, err := a.createUserFromRequest(userID, w, r)
+	userCreds, err := a.decodeUserCredentials(w, r)
 	if err != nil {
-		return user, err
+		return entity.User{}, err
 	}
 
+	if errs := a.policy.ValidateCredentials(userCreds); len(errs) != 0 {
+		writeValidationErrors(errs, w)
+		return entity.User{}, fmt.Errorf("user credentials violate credentials policy")
+	}
+
+	user := entity.CreateUserFromCreateRequest(userID, userCreds)
+
 	hashedPassword, err := crypto.HashPassword(user.Password)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		problem.Write(w, err)
 		return entity.User{}, fmt.Errorf("error while hashing password: %w", err)
 	}
 	user.Password = hashedPassword
@@ -80,16 +236,13 @@ func (a *AuthUser) createUserFromRequestPassHashed(userID entity.UserID, w http.
 }
 
 func (a *AuthUser) createUserFromRequest(userID entity.UserID, w http.ResponseWriter, r *http.Request) (entity.User, error) {
-	var userCreds model.UserCredentialsRequest
-	err := json.NewDecoder(r.Body).Decode(&userCreds)
+	userCreds, err := a.decodeUserCredentials(w, r)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		return entity.User{}, fmt.Errorf("error while decoding user credentials request: %w", err)
+		return entity.User{}, err
 	}
-	defer r.Body.Close()
 
 	if !validator.ValidateCreateUserRequest(userCreds) {
-		http.Error(w, ErrEmptyUserRequest, http.StatusBadRequest)
+		problem.WriteCode(w, problem.CodeBadRequest, ErrEmptyUserRequest)
 		return entity.User{}, fmt.Errorf(ErrEmptyUserRequest)
 	}
 
@@ -98,16 +251,73 @@ func (a *AuthUser) createUserFromRequest(userID entity.UserID, w http.ResponseWr
 	return user, nil
 }
 
-func (a *AuthUser) setUserIDToHeader(userID entity.UserID, w http.ResponseWriter) {
-	token, err := usecase.SetUserIDToAuthHeaderFormat(userID)
+func (a *AuthUser) decodeUserCredentials(w http.ResponseWriter, r *http.Request) (model.UserCredentialsRequest, error) {
+	var userCreds model.UserCredentialsRequest
+	err := json.NewDecoder(r.Body).Decode(&userCreds)
+	if err != nil {
+		problem.WriteCode(w, problem.CodeBadRequest, ErrInvalidJSON)
+		return model.UserCredentialsRequest{}, fmt.Errorf("error while decoding user credentials request: %w", err)
+	}
+	defer r.Body.Close()
+
+	return userCreds, nil
+}
+
+func (a *AuthUser) issueTokens(user entity.User, w http.ResponseWriter) {
+	refreshToken, err := auth.CreateRefreshToken(user.ID, a.refreshTTL, a.storage, w)
+	if err != nil {
+		return
+	}
+
+	a.writeTokens(user, refreshToken, w)
+}
+
+func (a *AuthUser) writeTokens(user entity.User, refreshToken string, w http.ResponseWriter) {
+	token, err := usecase.SetUserIDToAuthHeaderFormat(user.ID, user.Role)
 	if err != nil {
 		zap.L().Error("error while preparing auth header", zap.Error(err))
-		w.WriteHeader(http.StatusInternalServerError)
+		problem.Write(w, err)
+		return
+	}
+
+	out, err := json.Marshal(model.TokenResponse{
+		RefreshToken: refreshToken,
+	})
+	if err != nil {
+		zap.L().Error("error while marshalling token response", zap.Error(err))
+		problem.Write(w, err)
 		return
 	}
 
 	w.Header().Add(usecase.AuthHeader, token)
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
+	w.Write(out)
+}
+
+func (a *AuthUser) parseUserCtx(w http.ResponseWriter, r *http.Request) (entity.UserIDCtx, error) {
+	userIDCtx, ok := r.Context().Value(entity.UserIDCtxKey{}).(entity.UserIDCtx)
+	if !ok {
+		problem.WriteCode(w, problem.CodeInternal, "")
+		return entity.UserIDCtx{}, fmt.Errorf("user id couldn't obtain from context")
+	}
+
+	if userIDCtx.StatusCode == http.StatusInternalServerError {
+		problem.WriteCode(w, problem.CodeInternal, "")
+		return entity.UserIDCtx{}, fmt.Errorf("failed to check auth token")
+	}
+
+	if userIDCtx.Revoked {
+		problem.WriteCode(w, problem.CodeTokenRevoked, ErrTokenRevoked)
+		return entity.UserIDCtx{}, fmt.Errorf(ErrTokenRevoked)
+	}
+
+	if userIDCtx.StatusCode != http.StatusOK || !userIDCtx.UserID.Valid() {
+		problem.WriteCode(w, problem.CodeUnauthorized, ErrInvalidAuth)
+		return entity.UserIDCtx{}, fmt.Errorf("failed auth credentials")
+	}
+
+	return userIDCtx, nil
 }
 
 func (a *AuthUser) createUserID() entity.UserID {
@@ -116,3 +326,69 @@ func (a *AuthUser) createUserID() entity.UserID {
 
 	return userID
 }
+
+func writeValidationErrors(errs []model.ValidationError, w http.ResponseWriter) {
+	problem.Write(w, problem.New(problem.CodeValidationFailed, ErrCredentialsPolicy).WithErrors(errs))
+}
+
+// clientIP returns the client address for login throttling. The forwarding
+// headers are read only from the trusted proxies, otherwise any client could
+// choose its own key. X-Forwarded-For is read from the right, since only the
+// addresses appended by the trusted proxies can be relied on.
+func (a *AuthUser) clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	remote, err := netip.ParseAddr(host)
+	if err != nil || !a.isTrustedProxy(remote) {
+		return host
+	}
+
+	forwarded := strings.Split(strings.Join(r.Header.Values(forwardedForHeader), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(strings.TrimSpace(forwarded[i]))
+		if err != nil {
+			break
+		}
+
+		if !a.isTrustedProxy(addr) {
+			return addr.Unmap().String()
+		}
+	}
+
+	realIP, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get(realIPHeader)))
+	if err == nil {
+		return realIP.Unmap().String()
+	}
+
+	return host
+}
+
+func (a *AuthUser) isTrustedProxy(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range a.trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func durationOrDefault(value, defaultValue time.Duration) time.Duration {
+	if value <= 0 {
+		return defaultValue
+	}
+
+	return value
+}
+
+func intOrDefault(value, defaultValue int) int {
+	if value <= 0 {
+		return defaultValue
+	}
+
+	return value
+}
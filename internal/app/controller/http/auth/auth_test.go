@@ -1,15 +1,22 @@
 package auth
 
 import (
+	"context"
 	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/avGenie/go-loyalty-system/internal/app/config"
 	"github.com/avGenie/go-loyalty-system/internal/app/controller/http/auth/mock"
+	"github.com/avGenie/go-loyalty-system/internal/app/controller/http/openapi/openapitest"
 	"github.com/avGenie/go-loyalty-system/internal/app/entity"
 	err_storage "github.com/avGenie/go-loyalty-system/internal/app/storage/api/errors"
+	usecase "github.com/avGenie/go-loyalty-system/internal/app/usecase/converter"
+	"github.com/avGenie/go-loyalty-system/internal/app/validator"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -41,6 +48,8 @@ var (
 	}`)
 
 	inputInvalid = `<invalid json>`
+
+	testPolicy, _ = validator.NewCredentialsPolicy(config.Config{})
 )
 
 func TestCreateUser(t *testing.T) {
@@ -105,6 +114,17 @@ func TestCreateUser(t *testing.T) {
 				statusCode: http.StatusBadRequest,
 			},
 		},
+		{
+			name:            "password violates policy",
+			body:            `{"login": "login", "password": "short"}`,
+			createUserErr:   nil,
+			isCreateUser:    false,
+			authHeaderEmpty: true,
+
+			want: want{
+				statusCode: http.StatusBadRequest,
+			},
+		},
 		{
 			name:            "empty login in user credentials",
 			body:            inputEmptyLogin,
@@ -143,7 +163,6 @@ func TestCreateUser(t *testing.T) {
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			request := httptest.NewRequest(http.MethodPost, "/api/user/register", strings.NewReader(test.body))
-			writer := httptest.NewRecorder()
 
 			if test.isCreateUser {
 				s.EXPECT().CreateUser(gomock.Any(), gomock.Any()).Return(test.createUserErr)
@@ -151,11 +170,13 @@ func TestCreateUser(t *testing.T) {
 				s.EXPECT().CreateUser(gomock.Any(), gomock.Any()).Times(0)
 			}
 
-			authenticator := New(s)
-			handler := authenticator.CreateUser()
-			handler(writer, request)
+			if test.want.statusCode == http.StatusOK {
+				s.EXPECT().CreateRefreshToken(gomock.Any(), gomock.Any()).Return(nil)
+			}
 
-			res := writer.Result()
+			authenticator := New(s, config.Config{}, testPolicy)
+			handler := authenticator.CreateUser()
+			res := openapitest.Serve(t, handler, request)
 
 			assert.Equal(t, test.want.statusCode, res.StatusCode)
 
@@ -177,6 +198,12 @@ func TestAuthenticateUser(t *testing.T) {
 		Password: "$2a$14$x/4h3rb3YiVrlyR4w.Rme.cJmpvTwxwdS.kBJTzcsacKGIkBp0ITq",
 	}
 
+	currentHashOutputUser := entity.User{
+		ID:       "0b98bf79-833c-44e0-b979-2dae19dda46c",
+		Login:    "login",
+		Password: "$argon2id$v=19$m=19456,t=2,p=1$lwpG3bNGO/Nj9EXPjQctAg$gaODazIhMxLHkA2Bs5Us4AfcF9Tg0ML6FftS7xhiCtU",
+	}
+
 	invalidPasswordOutputUser := entity.User{
 		ID:       "0b98bf79-833c-44e0-b979-2dae19dda46c",
 		Login:    "login",
@@ -197,6 +224,9 @@ func TestAuthenticateUser(t *testing.T) {
 		getUser    entity.User
 		getUserErr error
 		isGetUser  bool
+		blocked    time.Duration
+		isBlocked  bool
+		isRehash   bool
 
 		want want
 	}{
@@ -206,6 +236,18 @@ func TestAuthenticateUser(t *testing.T) {
 			getUser:    validOutputUser,
 			getUserErr: nil,
 			isGetUser:  true,
+			isRehash:   true,
+
+			want: want{
+				statusCode: http.StatusOK,
+			},
+		},
+		{
+			name:       "current password hash",
+			body:       inputCorrect,
+			getUser:    currentHashOutputUser,
+			getUserErr: nil,
+			isGetUser:  true,
 
 			want: want{
 				statusCode: http.StatusOK,
@@ -244,6 +286,17 @@ func TestAuthenticateUser(t *testing.T) {
 				statusCode: http.StatusInternalServerError,
 			},
 		},
+		{
+			name:      "blocked login",
+			body:      inputCorrect,
+			blocked:   1500 * time.Millisecond,
+			isBlocked: true,
+			isGetUser: false,
+
+			want: want{
+				statusCode: http.StatusTooManyRequests,
+			},
+		},
 		{
 			name:       "invalid user credentials",
 			body:       inputInvalid,
@@ -289,7 +342,10 @@ func TestAuthenticateUser(t *testing.T) {
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			request := httptest.NewRequest(http.MethodPost, "/api/user/login", strings.NewReader(test.body))
-			writer := httptest.NewRecorder()
+
+			if test.isGetUser || test.isBlocked {
+				s.EXPECT().GetLoginBlock(gomock.Any(), []string{"login:login", "ip:192.0.2.1"}).Return(test.blocked, nil)
+			}
 
 			if test.isGetUser {
 				s.EXPECT().GetUser(gomock.Any(), gomock.Any()).Return(test.getUser, test.getUserErr)
@@ -297,11 +353,226 @@ func TestAuthenticateUser(t *testing.T) {
 				s.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(0)
 			}
 
-			authenticator := New(s)
+			if test.want.statusCode == http.StatusUnauthorized {
+				s.EXPECT().RegisterLoginFailure(gomock.Any(), "login:login", gomock.Any()).Return(entity.LoginAttempt{Key: "login:login", Failures: 1}, nil)
+				s.EXPECT().RegisterLoginFailure(gomock.Any(), "ip:192.0.2.1", gomock.Any()).Return(entity.LoginAttempt{Key: "ip:192.0.2.1", Failures: 1}, nil)
+			}
+
+			if test.want.statusCode == http.StatusOK {
+				s.EXPECT().ResetLoginFailures(gomock.Any(), "login:login").Return(nil)
+				s.EXPECT().CreateRefreshToken(gomock.Any(), gomock.Any()).Return(nil)
+			}
+
+			if test.isRehash {
+				s.EXPECT().UpdateUserPassword(gomock.Any(), test.getUser.ID, gomock.Any()).
+					DoAndReturn(func(_ context.Context, _ entity.UserID, password string) error {
+						assert.True(t, strings.HasPrefix(password, "$argon2id$"))
+						return nil
+					})
+			}
+
+			authenticator := New(s, config.Config{}, testPolicy)
 			handler := authenticator.AuthenticateUser()
-			handler(writer, request)
+			res := openapitest.Serve(t, handler, request)
+
+			assert.Equal(t, test.want.statusCode, res.StatusCode)
+
+			err := res.Body.Close()
+			require.NoError(t, err)
+
+			if test.isBlocked {
+				assert.Equal(t, "2", res.Header.Get("Retry-After"))
+			}
+		})
+	}
+}
+
+func TestRefreshToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	s := mock.NewMockUserAuthenticator(ctrl)
+
+	type want struct {
+		statusCode int
+	}
+	tests := []struct {
+		name        string
+		body        string
+		rotateErr   error
+		isRotate    bool
+		rotatedUser entity.UserID
+		ownerErr    error
+		isOwner     bool
+
+		want want
+	}{
+		{
+			name:        "correct refresh token",
+			body:        `{"refresh_token": "token"}`,
+			isRotate:    true,
+			rotatedUser: entity.UserID("0b98bf79-833c-44e0-b979-2dae19dda46c"),
+			isOwner:     true,
 
-			res := writer.Result()
+			want: want{
+				statusCode: http.StatusOK,
+			},
+		},
+		{
+			name:        "deleted token owner",
+			body:        `{"refresh_token": "token"}`,
+			isRotate:    true,
+			rotatedUser: entity.UserID("0b98bf79-833c-44e0-b979-2dae19dda46c"),
+			ownerErr:    err_storage.ErrUserNotFoundTable,
+			isOwner:     true,
+
+			want: want{
+				statusCode: http.StatusUnauthorized,
+			},
+		},
+		{
+			name:      "unknown refresh token",
+			body:      `{"refresh_token": "token"}`,
+			rotateErr: err_storage.ErrRefreshTokenNotFound,
+			isRotate:  true,
+
+			want: want{
+				statusCode: http.StatusUnauthorized,
+			},
+		},
+		{
+			name:      "storage error",
+			body:      `{"refresh_token": "token"}`,
+			rotateErr: errors.New(""),
+			isRotate:  true,
+
+			want: want{
+				statusCode: http.StatusInternalServerError,
+			},
+		},
+		{
+			name:     "empty refresh token",
+			body:     `{"refresh_token": ""}`,
+			isRotate: false,
+
+			want: want{
+				statusCode: http.StatusBadRequest,
+			},
+		},
+		{
+			name:     "invalid request",
+			body:     inputInvalid,
+			isRotate: false,
+
+			want: want{
+				statusCode: http.StatusBadRequest,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPost, "/api/user/token/refresh", strings.NewReader(test.body))
+
+			if test.isRotate {
+				s.EXPECT().RotateRefreshToken(gomock.Any(), gomock.Any(), gomock.Any()).Return(test.rotatedUser, test.rotateErr)
+			} else {
+				s.EXPECT().RotateRefreshToken(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			}
+
+			if test.isOwner {
+				s.EXPECT().GetUserByID(gomock.Any(), test.rotatedUser).Return(entity.User{
+					ID:   test.rotatedUser,
+					Role: entity.UserRoleAdmin,
+				}, test.ownerErr)
+			}
+
+			authenticator := New(s, config.Config{}, testPolicy)
+			handler := authenticator.RefreshToken()
+			res := openapitest.Serve(t, handler, request)
+
+			assert.Equal(t, test.want.statusCode, res.StatusCode)
+
+			body, err := io.ReadAll(res.Body)
+			require.NoError(t, err)
+
+			err = res.Body.Close()
+			require.NoError(t, err)
+
+			if test.want.statusCode == http.StatusOK {
+				assert.Contains(t, string(body), `"refresh_token"`)
+
+				claims, err := usecase.GetClaimsFromAuthHeader(res.Header.Get("Authorization"))
+				require.NoError(t, err)
+				assert.Equal(t, entity.UserRoleAdmin, claims.Role)
+			}
+		})
+	}
+}
+
+func TestLogout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	s := mock.NewMockUserAuthenticator(ctrl)
+
+	userID := entity.UserID("0b98bf79-833c-44e0-b979-2dae19dda46c")
+
+	type want struct {
+		statusCode int
+	}
+	tests := []struct {
+		name      string
+		userCtx   entity.UserIDCtx
+		revokeErr error
+		isRevoke  bool
+
+		want want
+	}{
+		{
+			name:     "correct user",
+			userCtx:  entity.CreateTokenUserIDCtx(userID, entity.UserRoleUser, "jti", time.Now().Add(time.Hour)),
+			isRevoke: true,
+
+			want: want{
+				statusCode: http.StatusOK,
+			},
+		},
+		{
+			name:      "storage error",
+			userCtx:   entity.CreateTokenUserIDCtx(userID, entity.UserRoleUser, "jti", time.Now().Add(time.Hour)),
+			revokeErr: errors.New(""),
+			isRevoke:  true,
+
+			want: want{
+				statusCode: http.StatusInternalServerError,
+			},
+		},
+		{
+			name:     "expired token",
+			userCtx:  entity.CreateUserIDCtx("", http.StatusUnauthorized),
+			isRevoke: false,
+
+			want: want{
+				statusCode: http.StatusUnauthorized,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPost, "/api/user/logout", nil)
+			request = request.WithContext(context.WithValue(request.Context(), entity.UserIDCtxKey{}, test.userCtx))
+
+			if test.isRevoke {
+				s.EXPECT().RevokeUserTokens(gomock.Any(), userID, test.userCtx.Token).Return(test.revokeErr)
+			} else {
+				s.EXPECT().RevokeUserTokens(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			}
+
+			authenticator := New(s, config.Config{}, testPolicy)
+			handler := authenticator.Logout()
+			res := openapitest.Serve(t, handler, request)
 
 			assert.Equal(t, test.want.statusCode, res.StatusCode)
 
@@ -310,3 +581,301 @@ func TestAuthenticateUser(t *testing.T) {
 		})
 	}
 }
+
+func TestChangePassword(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	s := mock.NewMockUserAuthenticator(ctrl)
+
+	userID := entity.UserID("0b98bf79-833c-44e0-b979-2dae19dda46c")
+	storageUser := entity.User{
+		ID:       userID,
+		Login:    "login",
+		Password: "$2a$14$x/4h3rb3YiVrlyR4w.Rme.cJmpvTwxwdS.kBJTzcsacKGIkBp0ITq",
+		Status:   entity.UserStatusActive,
+	}
+
+	type want struct {
+		statusCode int
+	}
+	tests := []struct {
+		name       string
+		body       string
+		getUserErr error
+		isGetUser  bool
+		isBlock    bool
+		blocked    time.Duration
+		isFailure  bool
+		isUpdate   bool
+		revokeErr  error
+
+		want want
+	}{
+		{
+			name:      "correct old password",
+			body:      `{"old_password": "password", "new_password": "new password"}`,
+			isGetUser: true,
+			isBlock:   true,
+			isUpdate:  true,
+
+			want: want{
+				statusCode: http.StatusOK,
+			},
+		},
+		{
+			name:      "wrong old password",
+			body:      `{"old_password": "wrong", "new_password": "new password"}`,
+			isGetUser: true,
+			isBlock:   true,
+			isFailure: true,
+
+			want: want{
+				statusCode: http.StatusForbidden,
+			},
+		},
+		{
+			name:      "blocked password change",
+			body:      `{"old_password": "password", "new_password": "new password"}`,
+			isGetUser: true,
+			isBlock:   true,
+			blocked:   time.Minute,
+
+			want: want{
+				statusCode: http.StatusTooManyRequests,
+			},
+		},
+		{
+			name:      "revocation error",
+			body:      `{"old_password": "password", "new_password": "new password"}`,
+			isGetUser: true,
+			isBlock:   true,
+			isUpdate:  true,
+			revokeErr: errors.New(""),
+
+			want: want{
+				statusCode: http.StatusInternalServerError,
+			},
+		},
+		{
+			name:       "deleted user",
+			body:       `{"old_password": "password", "new_password": "new password"}`,
+			getUserErr: err_storage.ErrUserNotFoundTable,
+			isGetUser:  true,
+
+			want: want{
+				statusCode: http.StatusUnauthorized,
+			},
+		},
+		{
+			name: "empty new password",
+			body: `{"old_password": "password", "new_password": ""}`,
+
+			want: want{
+				statusCode: http.StatusBadRequest,
+			},
+		},
+		{
+			name: "invalid request",
+			body: inputInvalid,
+
+			want: want{
+				statusCode: http.StatusBadRequest,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPut, "/api/user/password", strings.NewReader(test.body))
+			userCtx := entity.CreateTokenUserIDCtx(userID, entity.UserRoleUser, "jti", time.Now().Add(time.Hour))
+			request = request.WithContext(context.WithValue(request.Context(), entity.UserIDCtxKey{}, userCtx))
+
+			if test.isGetUser {
+				s.EXPECT().GetUserByID(gomock.Any(), userID).Return(storageUser, test.getUserErr)
+			} else {
+				s.EXPECT().GetUserByID(gomock.Any(), gomock.Any()).Times(0)
+			}
+
+			if test.isBlock {
+				s.EXPECT().GetLoginBlock(gomock.Any(), []string{entity.LoginKey(storageUser.Login)}).Return(test.blocked, nil)
+			}
+
+			if test.isFailure {
+				s.EXPECT().RegisterLoginFailure(gomock.Any(), entity.LoginKey(storageUser.Login), gomock.Any()).Return(entity.LoginAttempt{Failures: 1}, nil)
+			} else {
+				s.EXPECT().RegisterLoginFailure(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			}
+
+			if test.isUpdate {
+				s.EXPECT().UpdateUserPassword(gomock.Any(), userID, gomock.Not(storageUser.Password)).Return(nil)
+				s.EXPECT().ResetLoginFailures(gomock.Any(), entity.LoginKey(storageUser.Login)).Return(nil)
+				s.EXPECT().RevokeUserTokens(gomock.Any(), userID, entity.AccessToken{}).Return(test.revokeErr)
+			} else {
+				s.EXPECT().UpdateUserPassword(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+				s.EXPECT().RevokeUserTokens(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			}
+
+			authenticator := New(s, config.Config{}, testPolicy)
+			handler := authenticator.ChangePassword()
+			res := openapitest.Serve(t, handler, request)
+
+			assert.Equal(t, test.want.statusCode, res.StatusCode)
+
+			err := res.Body.Close()
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestDeleteUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	s := mock.NewMockUserAuthenticator(ctrl)
+
+	userID := entity.UserID("0b98bf79-833c-44e0-b979-2dae19dda46c")
+
+	type want struct {
+		statusCode int
+	}
+	tests := []struct {
+		name      string
+		userCtx   entity.UserIDCtx
+		deleteErr error
+		isDelete  bool
+
+		want want
+	}{
+		{
+			name:     "correct user",
+			userCtx:  entity.CreateTokenUserIDCtx(userID, entity.UserRoleUser, "jti", time.Now().Add(time.Hour)),
+			isDelete: true,
+
+			want: want{
+				statusCode: http.StatusOK,
+			},
+		},
+		{
+			name:      "already deleted user",
+			userCtx:   entity.CreateTokenUserIDCtx(userID, entity.UserRoleUser, "jti", time.Now().Add(time.Hour)),
+			deleteErr: err_storage.ErrUserNotFoundTable,
+			isDelete:  true,
+
+			want: want{
+				statusCode: http.StatusUnauthorized,
+			},
+		},
+		{
+			name:      "storage error",
+			userCtx:   entity.CreateTokenUserIDCtx(userID, entity.UserRoleUser, "jti", time.Now().Add(time.Hour)),
+			deleteErr: errors.New(""),
+			isDelete:  true,
+
+			want: want{
+				statusCode: http.StatusInternalServerError,
+			},
+		},
+		{
+			name:    "invalid token",
+			userCtx: entity.CreateUserIDCtx("", http.StatusBadRequest),
+
+			want: want{
+				statusCode: http.StatusUnauthorized,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodDelete, "/api/user", nil)
+			request = request.WithContext(context.WithValue(request.Context(), entity.UserIDCtxKey{}, test.userCtx))
+
+			if test.isDelete {
+				s.EXPECT().DeleteUser(gomock.Any(), userID).Return(test.deleteErr)
+			} else {
+				s.EXPECT().DeleteUser(gomock.Any(), gomock.Any()).Times(0)
+			}
+
+			authenticator := New(s, config.Config{}, testPolicy)
+			handler := authenticator.DeleteUser()
+			res := openapitest.Serve(t, handler, request)
+
+			assert.Equal(t, test.want.statusCode, res.StatusCode)
+
+			err := res.Body.Close()
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	trustedProxies, err := config.ParseTrustedProxies("10.0.0.0/8, 192.168.1.1")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor []string
+		realIP       string
+
+		want string
+	}{
+		{
+			name:       "direct client",
+			remoteAddr: "203.0.113.5:1234",
+
+			want: "203.0.113.5",
+		},
+		{
+			name:         "forwarded header from untrusted client",
+			remoteAddr:   "203.0.113.5:1234",
+			forwardedFor: []string{"198.51.100.7"},
+
+			want: "203.0.113.5",
+		},
+		{
+			name:         "forwarded header from trusted proxy",
+			remoteAddr:   "10.0.0.2:1234",
+			forwardedFor: []string{"198.51.100.7"},
+
+			want: "198.51.100.7",
+		},
+		{
+			name:         "spoofed forwarded address behind trusted proxies",
+			remoteAddr:   "10.0.0.2:1234",
+			forwardedFor: []string{"1.1.1.1, 198.51.100.7", "192.168.1.1"},
+
+			want: "198.51.100.7",
+		},
+		{
+			name:       "real ip header from trusted proxy",
+			remoteAddr: "192.168.1.1:1234",
+			realIP:     "198.51.100.7",
+
+			want: "198.51.100.7",
+		},
+		{
+			name:       "trusted proxy without headers",
+			remoteAddr: "10.0.0.2:1234",
+
+			want: "10.0.0.2",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPost, "/api/user/login", nil)
+			request.RemoteAddr = test.remoteAddr
+			for _, value := range test.forwardedFor {
+				request.Header.Add(forwardedForHeader, value)
+			}
+			if len(test.realIP) != 0 {
+				request.Header.Set(realIPHeader, test.realIP)
+			}
+
+			authenticator := New(nil, config.Config{TrustedProxyPrefixes: trustedProxies}, testPolicy)
+			assert.Equal(t, test.want, authenticator.clientIP(request))
+		})
+	}
+}
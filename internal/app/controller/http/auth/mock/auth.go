@@ -7,6 +7,7 @@ package mock
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	entity "github.com/avGenie/go-loyalty-system/internal/app/entity"
 	gomock "github.com/golang/mock/gomock"
@@ -35,6 +36,20 @@ func (m *MockUserAuthenticator) EXPECT() *MockUserAuthenticatorMockRecorder {
 	return m.recorder
 }
 
+// CreateRefreshToken mocks base method.
+func (m *MockUserAuthenticator) CreateRefreshToken(ctx context.Context, token entity.RefreshToken) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateRefreshToken", ctx, token)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateRefreshToken indicates an expected call of CreateRefreshToken.
+func (mr *MockUserAuthenticatorMockRecorder) CreateRefreshToken(ctx, token interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateRefreshToken", reflect.TypeOf((*MockUserAuthenticator)(nil).CreateRefreshToken), ctx, token)
+}
+
 // CreateUser mocks base method.
 func (m *MockUserAuthenticator) CreateUser(ctx context.Context, user entity.User) error {
 	m.ctrl.T.Helper()
@@ -49,6 +64,35 @@ func (mr *MockUserAuthenticatorMockRecorder) CreateUser(ctx, user interface{}) *
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUser", reflect.TypeOf((*MockUserAuthenticator)(nil).CreateUser), ctx, user)
 }
 
+// DeleteUser mocks base method.
+func (m *MockUserAuthenticator) DeleteUser(ctx context.Context, userID entity.UserID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteUser", ctx, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteUser indicates an expected call of DeleteUser.
+func (mr *MockUserAuthenticatorMockRecorder) DeleteUser(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUser", reflect.TypeOf((*MockUserAuthenticator)(nil).DeleteUser), ctx, userID)
+}
+
+// GetLoginBlock mocks base method.
+func (m *MockUserAuthenticator) GetLoginBlock(ctx context.Context, keys []string) (time.Duration, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLoginBlock", ctx, keys)
+	ret0, _ := ret[0].(time.Duration)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLoginBlock indicates an expected call of GetLoginBlock.
+func (mr *MockUserAuthenticatorMockRecorder) GetLoginBlock(ctx, keys interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLoginBlock", reflect.TypeOf((*MockUserAuthenticator)(nil).GetLoginBlock), ctx, keys)
+}
+
 // GetUser mocks base method.
 func (m *MockUserAuthenticator) GetUser(ctx context.Context, user entity.User) (entity.User, error) {
 	m.ctrl.T.Helper()
@@ -63,3 +107,90 @@ func (mr *MockUserAuthenticatorMockRecorder) GetUser(ctx, user interface{}) *gom
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUser", reflect.TypeOf((*MockUserAuthenticator)(nil).GetUser), ctx, user)
 }
+
+// GetUserByID mocks base method.
+func (m *MockUserAuthenticator) GetUserByID(ctx context.Context, userID entity.UserID) (entity.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserByID", ctx, userID)
+	ret0, _ := ret[0].(entity.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserByID indicates an expected call of GetUserByID.
+func (mr *MockUserAuthenticatorMockRecorder) GetUserByID(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByID", reflect.TypeOf((*MockUserAuthenticator)(nil).GetUserByID), ctx, userID)
+}
+
+// RegisterLoginFailure mocks base method.
+func (m *MockUserAuthenticator) RegisterLoginFailure(ctx context.Context, key string, throttle entity.LoginThrottle) (entity.LoginAttempt, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RegisterLoginFailure", ctx, key, throttle)
+	ret0, _ := ret[0].(entity.LoginAttempt)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RegisterLoginFailure indicates an expected call of RegisterLoginFailure.
+func (mr *MockUserAuthenticatorMockRecorder) RegisterLoginFailure(ctx, key, throttle interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RegisterLoginFailure", reflect.TypeOf((*MockUserAuthenticator)(nil).RegisterLoginFailure), ctx, key, throttle)
+}
+
+// ResetLoginFailures mocks base method.
+func (m *MockUserAuthenticator) ResetLoginFailures(ctx context.Context, key string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResetLoginFailures", ctx, key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ResetLoginFailures indicates an expected call of ResetLoginFailures.
+func (mr *MockUserAuthenticatorMockRecorder) ResetLoginFailures(ctx, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResetLoginFailures", reflect.TypeOf((*MockUserAuthenticator)(nil).ResetLoginFailures), ctx, key)
+}
+
+// RevokeUserTokens mocks base method.
+func (m *MockUserAuthenticator) RevokeUserTokens(ctx context.Context, userID entity.UserID, accessToken entity.AccessToken) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeUserTokens", ctx, userID, accessToken)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeUserTokens indicates an expected call of RevokeUserTokens.
+func (mr *MockUserAuthenticatorMockRecorder) RevokeUserTokens(ctx, userID, accessToken interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeUserTokens", reflect.TypeOf((*MockUserAuthenticator)(nil).RevokeUserTokens), ctx, userID, accessToken)
+}
+
+// RotateRefreshToken mocks base method.
+func (m *MockUserAuthenticator) RotateRefreshToken(ctx context.Context, oldHash string, token entity.RefreshToken) (entity.UserID, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RotateRefreshToken", ctx, oldHash, token)
+	ret0, _ := ret[0].(entity.UserID)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RotateRefreshToken indicates an expected call of RotateRefreshToken.
+func (mr *MockUserAuthenticatorMockRecorder) RotateRefreshToken(ctx, oldHash, token interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RotateRefreshToken", reflect.TypeOf((*MockUserAuthenticator)(nil).RotateRefreshToken), ctx, oldHash, token)
+}
+
+// UpdateUserPassword mocks base method.
+func (m *MockUserAuthenticator) UpdateUserPassword(ctx context.Context, userID entity.UserID, password string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUserPassword", ctx, userID, password)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateUserPassword indicates an expected call of UpdateUserPassword.
+func (mr *MockUserAuthenticatorMockRecorder) UpdateUserPassword(ctx, userID, password interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUserPassword", reflect.TypeOf((*MockUserAuthenticator)(nil).UpdateUserPassword), ctx, userID, password)
+}
@@ -0,0 +1,151 @@
+package callback
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/avGenie/go-loyalty-system/internal/app/config"
+	"github.com/avGenie/go-loyalty-system/internal/app/converter"
+	"github.com/avGenie/go-loyalty-system/internal/app/entity"
+	"github.com/avGenie/go-loyalty-system/internal/app/model"
+	"github.com/avGenie/go-loyalty-system/internal/app/usecase/callback"
+	"github.com/avGenie/go-loyalty-system/internal/app/usecase/crypto"
+	"github.com/avGenie/go-loyalty-system/internal/app/usecase/problem"
+	"go.uber.org/zap"
+)
+
+const (
+	SignatureHeader = "X-Accrual-Signature"
+	TimestampHeader = "X-Accrual-Timestamp"
+)
+
+const (
+	ErrNotSigned         = "callback signature or timestamp header is missing"
+	ErrSignatureMismatch = "callback signature doesn't match the payload"
+	ErrInvalidTimestamp  = "callback timestamp must be unix time in seconds"
+	ErrExpiredTimestamp  = "callback timestamp is out of the allowed window"
+	ErrInvalidCallback   = "callback must contain order number, known status and valid accrual"
+)
+
+const (
+	defaultTolerance = 5 * time.Minute
+)
+
+type Callback struct {
+	storage   callback.AccrualCallbackProcessor
+	secret    []byte
+	tolerance time.Duration
+}
+
+func New(storage callback.AccrualCallbackProcessor, config config.Config) Callback {
+	tolerance := config.AccrualCallbackTolerance
+	if tolerance <= 0 {
+		tolerance = defaultTolerance
+	}
+
+	return Callback{
+		storage:   storage,
+		secret:    []byte(config.AccrualCallbackSecret),
+		tolerance: tolerance,
+	}
+}
+
+func (c *Callback) AccrualCallback() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if len(c.secret) == 0 {
+			problem.WriteCode(w, problem.CodeNotFound, "")
+			return
+		}
+
+		body, signature, err := c.verifyRequest(w, r)
+		if err != nil {
+			zap.L().Error("error while verifying accrual callback", zap.Error(err))
+			return
+		}
+
+		order, err := c.parseOrder(body, w)
+		if err != nil {
+			zap.L().Error("error while parsing accrual callback", zap.Error(err))
+			return
+		}
+
+		// подпись покрывает метку времени, поэтому хранить её дольше окна допуска не нужно
+		err = callback.ProcessAccrualCallback(order, signature, 2*c.tolerance, c.storage, w)
+		if err != nil {
+			zap.L().Error("error while processing accrual callback", zap.Error(err))
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func (c *Callback) verifyRequest(w http.ResponseWriter, r *http.Request) ([]byte, string, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		problem.Write(w, err)
+		return nil, "", fmt.Errorf("error while request body parsing: %w", err)
+	}
+	defer r.Body.Close()
+
+	timestamp := r.Header.Get(TimestampHeader)
+	signature := r.Header.Get(SignatureHeader)
+	if len(timestamp) == 0 || len(signature) == 0 {
+		problem.WriteCode(w, problem.CodeInvalidSignature, ErrNotSigned)
+		return nil, "", fmt.Errorf("accrual callback isn't signed")
+	}
+
+	if !crypto.VerifyPayloadSignature(c.secret, timestamp, body, signature) {
+		problem.WriteCode(w, problem.CodeInvalidSignature, ErrSignatureMismatch)
+		return nil, "", fmt.Errorf("accrual callback signature mismatch")
+	}
+
+	unixTime, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		problem.WriteCode(w, problem.CodeBadRequest, ErrInvalidTimestamp)
+		return nil, "", fmt.Errorf("invalid accrual callback timestamp: %w", err)
+	}
+
+	age := time.Since(time.Unix(unixTime, 0))
+	if age > c.tolerance || age < -c.tolerance {
+		problem.WriteCode(w, problem.CodeInvalidSignature, ErrExpiredTimestamp)
+		return nil, "", fmt.Errorf("accrual callback timestamp is out of tolerance: %s", age)
+	}
+
+	return body, signature, nil
+}
+
+func (c *Callback) parseOrder(body []byte, w http.ResponseWriter) (entity.Order, error) {
+	var response model.AccrualResponse
+	err := json.Unmarshal(body, &response)
+	if err != nil {
+		problem.WriteCode(w, problem.CodeBadRequest, ErrInvalidCallback)
+		return entity.Order{}, fmt.Errorf("error while decoding accrual callback: %w", err)
+	}
+
+	if len(response.Number) == 0 || !isAccrualStatus(model.AccrualOrderStatus(response.Status)) {
+		problem.WriteCode(w, problem.CodeBadRequest, ErrInvalidCallback)
+		return entity.Order{}, fmt.Errorf("accrual callback without order number or with unknown status %q", response.Status)
+	}
+
+	order, err := converter.ConvertAccrualResponseToOrder(response)
+	if err != nil {
+		problem.WriteCode(w, problem.CodeBadRequest, ErrInvalidCallback)
+		return entity.Order{}, fmt.Errorf("error while converting accrual callback: %w", err)
+	}
+
+	return order, nil
+}
+
+func isAccrualStatus(status model.AccrualOrderStatus) bool {
+	switch status {
+	case model.StatusRegisteredAccrual, model.StatusInvalidAccrual, model.StatusProcessingAccrual, model.StatusProcessedAccrual:
+		return true
+	default:
+		return false
+	}
+}
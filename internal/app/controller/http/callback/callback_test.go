@@ -0,0 +1,204 @@
+package callback
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/avGenie/go-loyalty-system/internal/app/config"
+	"github.com/avGenie/go-loyalty-system/internal/app/controller/http/callback/mock"
+	"github.com/avGenie/go-loyalty-system/internal/app/entity"
+	"github.com/avGenie/go-loyalty-system/internal/app/money"
+	err_storage "github.com/avGenie/go-loyalty-system/internal/app/storage/api/errors"
+	"github.com/avGenie/go-loyalty-system/internal/app/usecase/crypto"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testSecret = "secret"
+
+	inputProcessed = `{"order": "12345678903", "status": "PROCESSED", "accrual": 500}`
+)
+
+func TestAccrualCallback(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	processor := mock.NewMockAccrualCallbackProcessor(ctrl)
+
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	expired := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+
+	type want struct {
+		statusCode int
+	}
+	tests := []struct {
+		name      string
+		secret    string
+		body      string
+		timestamp string
+		signature string
+
+		isApply  bool
+		applyErr error
+
+		want want
+	}{
+		{
+			name:      "processed order",
+			secret:    testSecret,
+			body:      inputProcessed,
+			timestamp: now,
+			isApply:   true,
+
+			want: want{
+				statusCode: http.StatusOK,
+			},
+		},
+		{
+			name:      "callbacks disabled",
+			body:      inputProcessed,
+			timestamp: now,
+
+			want: want{
+				statusCode: http.StatusNotFound,
+			},
+		},
+		{
+			name:   "no signature",
+			secret: testSecret,
+			body:   inputProcessed,
+
+			want: want{
+				statusCode: http.StatusUnauthorized,
+			},
+		},
+		{
+			name:      "wrong signature",
+			secret:    testSecret,
+			body:      inputProcessed,
+			timestamp: now,
+			signature: "0000",
+
+			want: want{
+				statusCode: http.StatusUnauthorized,
+			},
+		},
+		{
+			name:      "expired timestamp",
+			secret:    testSecret,
+			body:      inputProcessed,
+			timestamp: expired,
+
+			want: want{
+				statusCode: http.StatusUnauthorized,
+			},
+		},
+		{
+			name:      "invalid body",
+			secret:    testSecret,
+			body:      `<invalid json>`,
+			timestamp: now,
+
+			want: want{
+				statusCode: http.StatusBadRequest,
+			},
+		},
+		{
+			name:      "unknown status",
+			secret:    testSecret,
+			body:      `{"order": "12345678903", "status": "UNKNOWN"}`,
+			timestamp: now,
+
+			want: want{
+				statusCode: http.StatusBadRequest,
+			},
+		},
+		{
+			name:      "replayed callback",
+			secret:    testSecret,
+			body:      inputProcessed,
+			timestamp: now,
+			isApply:   true,
+			applyErr:  err_storage.ErrAccrualCallbackExists,
+
+			want: want{
+				statusCode: http.StatusConflict,
+			},
+		},
+		{
+			name:      "unknown order",
+			secret:    testSecret,
+			body:      inputProcessed,
+			timestamp: now,
+			isApply:   true,
+			applyErr:  err_storage.ErrOrderNumberNotFound,
+
+			want: want{
+				statusCode: http.StatusNotFound,
+			},
+		},
+		{
+			name:      "order with final status",
+			secret:    testSecret,
+			body:      inputProcessed,
+			timestamp: now,
+			isApply:   true,
+			applyErr:  err_storage.ErrOrderProcessed,
+
+			want: want{
+				statusCode: http.StatusConflict,
+			},
+		},
+		{
+			name:      "update error",
+			secret:    testSecret,
+			body:      inputProcessed,
+			timestamp: now,
+			isApply:   true,
+			applyErr:  errors.New(""),
+
+			want: want{
+				statusCode: http.StatusInternalServerError,
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPost, "/internal/accrual/callback", strings.NewReader(test.body))
+			writer := httptest.NewRecorder()
+
+			if len(test.timestamp) != 0 {
+				signature := test.signature
+				if len(signature) == 0 {
+					signature = crypto.SignPayload([]byte(testSecret), test.timestamp, []byte(test.body))
+				}
+
+				request.Header.Set(TimestampHeader, test.timestamp)
+				request.Header.Set(SignatureHeader, signature)
+			}
+
+			if test.isApply {
+				processor.EXPECT().ApplyAccrualCallback(gomock.Any(), gomock.Any(), gomock.Any(), entity.Order{
+					Number:  "12345678903",
+					Status:  entity.StatusProcessedOrder,
+					Accrual: money.Points(50000),
+				}).Return(test.applyErr)
+			}
+
+			callback := New(processor, config.Config{AccrualCallbackSecret: test.secret})
+			handler := callback.AccrualCallback()
+			handler(writer, request)
+
+			res := writer.Result()
+			defer res.Body.Close()
+
+			require.Equal(t, test.want.statusCode, res.StatusCode)
+		})
+	}
+}
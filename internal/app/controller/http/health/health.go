@@ -0,0 +1,55 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/avGenie/go-loyalty-system/internal/app/model"
+	"github.com/avGenie/go-loyalty-system/internal/app/usecase/accrual"
+	"github.com/avGenie/go-loyalty-system/internal/app/usecase/problem"
+	"go.uber.org/zap"
+)
+
+const (
+	StatusOK       = "ok"
+	StatusDegraded = "degraded"
+)
+
+type AccrualState interface {
+	State() accrual.BreakerState
+}
+
+type Health struct {
+	accrual AccrualState
+}
+
+func New(accrual AccrualState) Health {
+	return Health{
+		accrual: accrual,
+	}
+}
+
+func (h *Health) Check() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		state := h.accrual.State()
+
+		response := model.HealthResponse{
+			Status:  StatusOK,
+			Accrual: state.String(),
+		}
+		if accrual.BreakerClosed != state {
+			response.Status = StatusDegraded
+		}
+
+		out, err := json.Marshal(response)
+		if err != nil {
+			zap.L().Error("error while marshalling health response", zap.Error(err))
+			problem.Write(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(out)
+	}
+}
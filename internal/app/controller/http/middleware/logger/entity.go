@@ -25,3 +25,8 @@ func (w *logResponseWriter) WriteHeader(statusCode int) {
 	w.ResponseWriter.WriteHeader(statusCode)
 	w.responseData.statusCode = statusCode
 }
+
+// Unwrap lets http.ResponseController flush the order events stream.
+func (w *logResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
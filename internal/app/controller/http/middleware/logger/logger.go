@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/avGenie/go-loyalty-system/internal/app/usecase/problem"
 	"go.uber.org/zap"
 )
 
@@ -30,6 +31,7 @@ func LoggerMiddleware(h http.Handler) http.Handler {
 			zap.Duration("duration", duration),
 			zap.Int("status", respData.statusCode),
 			zap.Int("size", respData.size),
+			zap.String("request_id", w.Header().Get(problem.RequestIDHeader)),
 		)
 	}
 
@@ -0,0 +1,43 @@
+package requestid
+
+import (
+	"net/http"
+
+	"github.com/avGenie/go-loyalty-system/internal/app/usecase/problem"
+	"github.com/google/uuid"
+)
+
+const (
+	maxRequestIDLen = 128
+)
+
+// RequestIDMiddleware sets the request ID response header before the handler
+// runs, so error responses and logs can refer to it. The client request ID is
+// reused if it is sane, otherwise a new one is generated.
+func RequestIDMiddleware(h http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(problem.RequestIDHeader)
+		if !isValidRequestID(requestID) {
+			requestID = uuid.NewString()
+		}
+
+		w.Header().Set(problem.RequestIDHeader, requestID)
+		h.ServeHTTP(w, r)
+	}
+
+	return http.HandlerFunc(fn)
+}
+
+func isValidRequestID(requestID string) bool {
+	if len(requestID) == 0 || len(requestID) > maxRequestIDLen {
+		return false
+	}
+
+	for _, symbol := range requestID {
+		if symbol <= ' ' || symbol > '~' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -3,23 +3,36 @@ package token
 import (
 	"context"
 	"errors"
-	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/avGenie/go-loyalty-system/internal/app/entity"
 	usecase "github.com/avGenie/go-loyalty-system/internal/app/usecase/converter"
 	err_usecase "github.com/avGenie/go-loyalty-system/internal/app/usecase/errors"
+	httputils "github.com/avGenie/go-loyalty-system/internal/app/usecase/utils"
 	"go.uber.org/zap"
 )
 
-func TokenParserMiddleware(next http.Handler) http.Handler {
+type TokenRevocationChecker interface {
+	IsTokenRevoked(ctx context.Context, userID entity.UserID, tokenID string) (bool, error)
+}
+
+type TokenParser struct {
+	storage TokenRevocationChecker
+}
+
+func New(storage TokenRevocationChecker) TokenParser {
+	return TokenParser{
+		storage: storage,
+	}
+}
+
+func (p *TokenParser) TokenParserMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		zap.L().Info("start token parsing")
 
 		authHeader := r.Header[usecase.AuthHeader]
-		userCtx := processAuthUserID(authHeader)
-
-		fmt.Println(userCtx)
+		userCtx := p.processAuthUserID(r.Context(), authHeader)
 
 		ctx := context.WithValue(r.Context(), entity.UserIDCtxKey{}, userCtx)
 		r = r.WithContext(ctx)
@@ -28,14 +41,14 @@ func TokenParserMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-func processAuthUserID(authHeader []string) entity.UserIDCtx {
+func (p *TokenParser) processAuthUserID(ctx context.Context, authHeader []string) entity.UserIDCtx {
 	if len(authHeader) == 0 {
 		zap.L().Info("authorization header is empty")
 
 		return entity.CreateUserIDCtx("", http.StatusBadRequest)
 	}
 
-	userID, err := usecase.GetUserIDFromAuthHeader(authHeader[0])
+	claims, err := usecase.GetClaimsFromAuthHeader(authHeader[0])
 	if err != nil {
 		zap.L().Error("error while parsing auth header", zap.Error(err), zap.String("header", authHeader[0]))
 		if errors.Is(err, err_usecase.ErrTokenExpired) {
@@ -45,11 +58,33 @@ func processAuthUserID(authHeader []string) entity.UserIDCtx {
 		return entity.CreateUserIDCtx("", http.StatusBadRequest)
 	}
 
-	if !userID.Valid() {
+	if !claims.UserID.Valid() {
 		zap.L().Error("empty user id in authorization header")
 
 		return entity.CreateUserIDCtx("", http.StatusBadRequest)
 	}
 
-	return entity.CreateUserIDCtx(userID, http.StatusOK)
+	ctx, cancel := context.WithTimeout(ctx, httputils.RequestTimeout)
+	defer cancel()
+
+	// токен отзывается при выходе из аккаунта и при его удалении
+	isRevoked, err := p.storage.IsTokenRevoked(ctx, claims.UserID, claims.ID)
+	if err != nil {
+		zap.L().Error("error while checking token revocation", zap.Error(err), zap.String("jti", claims.ID))
+
+		return entity.CreateUserIDCtx("", http.StatusInternalServerError)
+	}
+
+	if isRevoked {
+		zap.L().Info("token has been revoked", zap.String("jti", claims.ID), zap.String("user_id", claims.UserID.String()))
+
+		return entity.CreateRevokedUserIDCtx()
+	}
+
+	var expiresAt time.Time
+	if claims.ExpiresAt != nil {
+		expiresAt = claims.ExpiresAt.Time
+	}
+
+	return entity.CreateTokenUserIDCtx(claims.UserID, claims.Role, claims.ID, expiresAt)
 }
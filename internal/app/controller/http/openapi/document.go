@@ -0,0 +1,523 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	schemaRefPrefix      = "#/components/schemas/"
+	parameterRefPrefix   = "#/components/parameters/"
+	headerRefPrefix      = "#/components/headers/"
+	requestBodyRefPrefix = "#/components/requestBodies/"
+	responseRefPrefix    = "#/components/responses/"
+)
+
+// Document is the part of the OpenAPI document needed to validate requests
+// and responses. References are resolved while loading.
+type Document struct {
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+// PathItem maps lower case HTTP methods to the operations.
+type PathItem map[string]*Operation
+
+type Components struct {
+	Schemas       map[string]*Schema      `json:"schemas"`
+	Parameters    map[string]*Parameter   `json:"parameters"`
+	Headers       map[string]*Header      `json:"headers"`
+	RequestBodies map[string]*RequestBody `json:"requestBodies"`
+	Responses     map[string]*Response    `json:"responses"`
+}
+
+type Operation struct {
+	OperationID string               `json:"operationId"`
+	Parameters  []*Parameter         `json:"parameters"`
+	RequestBody *RequestBody         `json:"requestBody"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+type Parameter struct {
+	Ref      string  `json:"$ref"`
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+type Header struct {
+	Ref      string  `json:"$ref"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Ref      string                `json:"$ref"`
+	Required bool                  `json:"required"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+type Response struct {
+	Ref     string                `json:"$ref"`
+	Headers map[string]*Header    `json:"headers"`
+	Content map[string]*MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Load parses the embedded OpenAPI document.
+func Load() (*Document, error) {
+	return Parse(spec)
+}
+
+func Parse(data []byte) (*Document, error) {
+	var document Document
+	err := json.Unmarshal(data, &document)
+	if err != nil {
+		return nil, fmt.Errorf("error while parsing openapi document: %w", err)
+	}
+
+	err = document.resolve()
+	if err != nil {
+		return nil, fmt.Errorf("error while resolving openapi document references: %w", err)
+	}
+
+	return &document, nil
+}
+
+// Routes returns all documented routes as "METHOD /path" sorted strings.
+func (d *Document) Routes() []string {
+	var routes []string
+	for path, item := range d.Paths {
+		for method := range item {
+			routes = append(routes, strings.ToUpper(method)+" "+path)
+		}
+	}
+	sort.Strings(routes)
+
+	return routes
+}
+
+// FindOperation returns the operation matching the method and the request
+// path along with the path parameters values.
+func (d *Document) FindOperation(method, path string) (*Operation, map[string]string, error) {
+	var (
+		found      *Operation
+		foundCount int
+		params     map[string]string
+	)
+	for template, item := range d.Paths {
+		operation, ok := item[strings.ToLower(method)]
+		if !ok {
+			continue
+		}
+
+		values, ok := matchPath(template, path)
+		if !ok {
+			continue
+		}
+
+		// литеральные пути приоритетнее шаблонных
+		if found == nil || len(values) < foundCount {
+			found, foundCount, params = operation, len(values), values
+		}
+	}
+
+	if found == nil {
+		return nil, nil, fmt.Errorf("operation %s %s isn't documented", method, path)
+	}
+
+	return found, params, nil
+}
+
+// ValidateRequest checks the request parameters and the body against the
+// documented operation. The body is passed separately since the request
+// body can be read only once.
+func (d *Document) ValidateRequest(r *http.Request, body []byte) error {
+	operation, pathValues, err := d.FindOperation(r.Method, r.URL.Path)
+	if err != nil {
+		return err
+	}
+
+	err = validateParameters(operation, pathValues, r)
+	if err != nil {
+		return fmt.Errorf("%s: %w", operation.OperationID, err)
+	}
+
+	err = validateRequestBody(operation.RequestBody, r.Header.Get("Content-Type"), body)
+	if err != nil {
+		return fmt.Errorf("%s: request body: %w", operation.OperationID, err)
+	}
+
+	return nil
+}
+
+// ValidateResponse checks the status code, the headers and the body of the
+// response to the request against the documented operation.
+func (d *Document) ValidateResponse(r *http.Request, statusCode int, header http.Header, body []byte) error {
+	operation, _, err := d.FindOperation(r.Method, r.URL.Path)
+	if err != nil {
+		return err
+	}
+
+	response, ok := operation.Responses[strconv.Itoa(statusCode)]
+	if !ok {
+		response, ok = operation.Responses["default"]
+	}
+	if !ok {
+		return fmt.Errorf("%s: response status %d isn't documented", operation.OperationID, statusCode)
+	}
+
+	for name, documented := range response.Headers {
+		value := header.Get(name)
+		if len(value) == 0 {
+			if documented.Required {
+				return fmt.Errorf("%s: response header %s is required", operation.OperationID, name)
+			}
+			continue
+		}
+
+		err = documented.Schema.validateString(name, value)
+		if err != nil {
+			return fmt.Errorf("%s: response header: %w", operation.OperationID, err)
+		}
+	}
+
+	err = validateContent(response.Content, header.Get("Content-Type"), body, false)
+	if err != nil {
+		return fmt.Errorf("%s: response %d body: %w", operation.OperationID, statusCode, err)
+	}
+
+	return nil
+}
+
+func validateParameters(operation *Operation, pathValues map[string]string, r *http.Request) error {
+	query := r.URL.Query()
+	documentedQuery := make(map[string]bool)
+
+	for _, parameter := range operation.Parameters {
+		var (
+			value string
+			ok    bool
+		)
+		switch parameter.In {
+		case "path":
+			value, ok = pathValues[parameter.Name]
+		case "query":
+			documentedQuery[parameter.Name] = true
+			value, ok = query.Get(parameter.Name), query.Has(parameter.Name)
+		case "header":
+			value = r.Header.Get(parameter.Name)
+			ok = len(value) != 0
+		default:
+			return fmt.Errorf("parameter %s in %s isn't supported", parameter.Name, parameter.In)
+		}
+
+		if !ok {
+			if parameter.Required {
+				return fmt.Errorf("%s parameter %s is required", parameter.In, parameter.Name)
+			}
+			continue
+		}
+
+		err := parameter.Schema.validateString(parameter.Name, value)
+		if err != nil {
+			return fmt.Errorf("%s parameter: %w", parameter.In, err)
+		}
+	}
+
+	for name := range query {
+		if !documentedQuery[name] {
+			return fmt.Errorf("query parameter %s isn't documented", name)
+		}
+	}
+
+	return nil
+}
+
+func validateRequestBody(requestBody *RequestBody, contentType string, body []byte) error {
+	if requestBody == nil {
+		if len(body) != 0 {
+			return fmt.Errorf("body isn't documented")
+		}
+
+		return nil
+	}
+
+	if len(body) == 0 {
+		if requestBody.Required {
+			return fmt.Errorf("body is required")
+		}
+
+		return nil
+	}
+
+	return validateContent(requestBody.Content, contentType, body, true)
+}
+
+// validateContent checks the body against the schema of its media type.
+// Requests without Content-Type are allowed only when the single media type
+// is documented, clients of the original API don't send it.
+func validateContent(content map[string]*MediaType, contentType string, body []byte, lenient bool) error {
+	if len(body) == 0 {
+		if len(content) != 0 {
+			return fmt.Errorf("body is empty")
+		}
+
+		return nil
+	}
+
+	if len(content) == 0 {
+		return fmt.Errorf("body isn't documented")
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		if !lenient || len(contentType) != 0 || len(content) != 1 {
+			return fmt.Errorf("content type %q is invalid", contentType)
+		}
+
+		for documented := range content {
+			mediaType = documented
+		}
+	}
+
+	documented, ok := content[mediaType]
+	if !ok {
+		return fmt.Errorf("content type %s isn't documented", mediaType)
+	}
+
+	if !isJSONMediaType(mediaType) {
+		return documented.Schema.validateString("body", string(body))
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	var value any
+	err = decoder.Decode(&value)
+	if err != nil {
+		return fmt.Errorf("body isn't a valid JSON: %w", err)
+	}
+
+	return documented.Schema.Validate(value)
+}
+
+func matchPath(template, path string) (map[string]string, bool) {
+	templateSegments := strings.Split(strings.Trim(template, "/"), "/")
+	pathSegments := strings.Split(strings.Trim(path, "/"), "/")
+	if len(templateSegments) != len(pathSegments) {
+		return nil, false
+	}
+
+	values := make(map[string]string)
+	for i, segment := range templateSegments {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			if len(pathSegments[i]) == 0 {
+				return nil, false
+			}
+
+			values[strings.Trim(segment, "{}")] = pathSegments[i]
+			continue
+		}
+
+		if segment != pathSegments[i] {
+			return nil, false
+		}
+	}
+
+	return values, true
+}
+
+func isJSONMediaType(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+func (d *Document) resolve() error {
+	for _, schema := range d.Components.Schemas {
+		err := d.resolveSchemaFields(schema)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, parameter := range d.Components.Parameters {
+		err := d.resolveSchema(&parameter.Schema)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, header := range d.Components.Headers {
+		err := d.resolveSchema(&header.Schema)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, requestBody := range d.Components.RequestBodies {
+		err := d.resolveContent(requestBody.Content)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, response := range d.Components.Responses {
+		err := d.resolveResponseFields(response)
+		if err != nil {
+			return err
+		}
+	}
+
+	for path, item := range d.Paths {
+		for method, operation := range item {
+			err := d.resolveOperation(operation)
+			if err != nil {
+				return fmt.Errorf("%s %s: %w", method, path, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (d *Document) resolveOperation(operation *Operation) error {
+	for i, parameter := range operation.Parameters {
+		if len(parameter.Ref) != 0 {
+			resolved, err := lookup(d.Components.Parameters, parameter.Ref, parameterRefPrefix)
+			if err != nil {
+				return err
+			}
+
+			operation.Parameters[i] = resolved
+			continue
+		}
+
+		err := d.resolveSchema(&parameter.Schema)
+		if err != nil {
+			return err
+		}
+	}
+
+	if operation.RequestBody != nil {
+		if len(operation.RequestBody.Ref) != 0 {
+			resolved, err := lookup(d.Components.RequestBodies, operation.RequestBody.Ref, requestBodyRefPrefix)
+			if err != nil {
+				return err
+			}
+
+			operation.RequestBody = resolved
+		} else {
+			err := d.resolveContent(operation.RequestBody.Content)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for status, response := range operation.Responses {
+		if len(response.Ref) != 0 {
+			resolved, err := lookup(d.Components.Responses, response.Ref, responseRefPrefix)
+			if err != nil {
+				return err
+			}
+
+			operation.Responses[status] = resolved
+			continue
+		}
+
+		err := d.resolveResponseFields(response)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (d *Document) resolveResponseFields(response *Response) error {
+	for name, header := range response.Headers {
+		if len(header.Ref) != 0 {
+			resolved, err := lookup(d.Components.Headers, header.Ref, headerRefPrefix)
+			if err != nil {
+				return err
+			}
+
+			response.Headers[name] = resolved
+			continue
+		}
+
+		err := d.resolveSchema(&header.Schema)
+		if err != nil {
+			return err
+		}
+	}
+
+	return d.resolveContent(response.Content)
+}
+
+func (d *Document) resolveContent(content map[string]*MediaType) error {
+	for _, mediaType := range content {
+		err := d.resolveSchema(&mediaType.Schema)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// resolveSchema replaces the schema reference with the component schema.
+// Component schemas are resolved on their own, so recursion stops on them.
+func (d *Document) resolveSchema(schema **Schema) error {
+	if *schema == nil {
+		return nil
+	}
+
+	if len((*schema).Ref) == 0 {
+		return d.resolveSchemaFields(*schema)
+	}
+
+	resolved, err := lookup(d.Components.Schemas, (*schema).Ref, schemaRefPrefix)
+	if err != nil {
+		return err
+	}
+	*schema = resolved
+
+	return nil
+}
+
+func (d *Document) resolveSchemaFields(schema *Schema) error {
+	for name, property := range schema.Properties {
+		err := d.resolveSchema(&property)
+		if err != nil {
+			return err
+		}
+		schema.Properties[name] = property
+	}
+
+	return d.resolveSchema(&schema.Items)
+}
+
+func lookup[T any](components map[string]*T, ref, prefix string) (*T, error) {
+	name, ok := strings.CutPrefix(ref, prefix)
+	if !ok {
+		return nil, fmt.Errorf("reference %s must start with %s", ref, prefix)
+	}
+
+	component, ok := components[name]
+	if !ok {
+		return nil, fmt.Errorf("reference %s isn't found", ref)
+	}
+
+	return component, nil
+}
@@ -0,0 +1,265 @@
+package openapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateRequest(t *testing.T) {
+	document, err := Load()
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		method      string
+		target      string
+		contentType string
+		headers     map[string]string
+		body        string
+		isValid     bool
+	}{
+		{
+			name:    "credentials",
+			method:  http.MethodPost,
+			target:  "/api/user/register",
+			body:    `{"login": "user", "password": "password"}`,
+			isValid: true,
+		},
+		{
+			name:    "credentials without password",
+			method:  http.MethodPost,
+			target:  "/api/user/login",
+			body:    `{"login": "user"}`,
+			isValid: false,
+		},
+		{
+			name:    "order number",
+			method:  http.MethodPost,
+			target:  "/api/user/orders",
+			body:    "735584316112",
+			isValid: true,
+		},
+		{
+			name:    "empty order number",
+			method:  http.MethodPost,
+			target:  "/api/user/orders",
+			isValid: false,
+		},
+		{
+			name:        "json batch",
+			method:      http.MethodPost,
+			target:      "/api/user/orders/batch",
+			contentType: "application/json; charset=utf-8",
+			body:        `["735584316112", "527652728124"]`,
+			isValid:     true,
+		},
+		{
+			name:    "batch without content type",
+			method:  http.MethodPost,
+			target:  "/api/user/orders/batch",
+			body:    "735584316112\n527652728124",
+			isValid: false,
+		},
+		{
+			name:    "orders page",
+			method:  http.MethodGet,
+			target:  "/api/user/orders?limit=10&status=NEW,PROCESSING&from=2024-05-01T00:00:00Z&sort=asc",
+			isValid: true,
+		},
+		{
+			name:    "orders page limit out of range",
+			method:  http.MethodGet,
+			target:  "/api/user/orders?limit=1001",
+			isValid: false,
+		},
+		{
+			name:    "undocumented query parameter",
+			method:  http.MethodGet,
+			target:  "/api/user/withdrawals?status=NEW",
+			isValid: false,
+		},
+		{
+			name:    "too long idempotency key",
+			method:  http.MethodPost,
+			target:  "/api/user/balance/withdraw",
+			headers: map[string]string{"Idempotency-Key": strings.Repeat("k", 256)},
+			body:    `{"order": "2377225624", "sum": 751}`,
+			isValid: false,
+		},
+		{
+			name:    "admin user path",
+			method:  http.MethodGet,
+			target:  "/api/admin/users/00308dff-b6b1-4f1b-8515-d09d3db49951/balance",
+			isValid: true,
+		},
+		{
+			name:    "admin invalid user path",
+			method:  http.MethodGet,
+			target:  "/api/admin/users/user/balance",
+			isValid: false,
+		},
+		{
+			name:    "undocumented route",
+			method:  http.MethodGet,
+			target:  "/api/user/unknown",
+			isValid: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest(test.method, test.target, nil)
+			if len(test.contentType) != 0 {
+				request.Header.Set("Content-Type", test.contentType)
+			}
+			for name, value := range test.headers {
+				request.Header.Set(name, value)
+			}
+
+			err := document.ValidateRequest(request, []byte(test.body))
+			if test.isValid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateResponse(t *testing.T) {
+	document, err := Load()
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		method      string
+		target      string
+		statusCode  int
+		contentType string
+		headers     map[string]string
+		body        string
+		isValid     bool
+	}{
+		{
+			name:        "orders",
+			method:      http.MethodGet,
+			target:      "/api/user/orders",
+			statusCode:  http.StatusOK,
+			contentType: "application/json",
+			body:        `[{"number": "9278923470", "status": "PROCESSED", "accrual": 500, "uploaded_at": "2020-12-10T15:15:45+03:00"}]`,
+			isValid:     true,
+		},
+		{
+			name:        "orders with unknown status",
+			method:      http.MethodGet,
+			target:      "/api/user/orders",
+			statusCode:  http.StatusOK,
+			contentType: "application/json",
+			body:        `[{"number": "9278923470", "status": "REGISTERED", "accrual": 0, "uploaded_at": "2020-12-10T15:15:45+03:00"}]`,
+			isValid:     false,
+		},
+		{
+			name:        "balance with undocumented property",
+			method:      http.MethodGet,
+			target:      "/api/user/balance",
+			statusCode:  http.StatusOK,
+			contentType: "application/json",
+			body:        `{"current": 500.5, "withdrawn": 42, "pending": 1}`,
+			isValid:     false,
+		},
+		{
+			name:       "no content",
+			method:     http.MethodGet,
+			target:     "/api/user/withdrawals",
+			statusCode: http.StatusNoContent,
+			isValid:    true,
+		},
+		{
+			name:        "tokens",
+			method:      http.MethodPost,
+			target:      "/api/user/login",
+			statusCode:  http.StatusOK,
+			contentType: "application/json",
+			headers:     map[string]string{"Authorization": "Bearer token"},
+			body:        `{"refresh_token": "refresh"}`,
+			isValid:     true,
+		},
+		{
+			name:        "tokens without authorization header",
+			method:      http.MethodPost,
+			target:      "/api/user/login",
+			statusCode:  http.StatusOK,
+			contentType: "application/json",
+			body:        `{"refresh_token": "refresh"}`,
+			isValid:     false,
+		},
+		{
+			name:        "problem",
+			method:      http.MethodPost,
+			target:      "/api/user/balance/withdraw",
+			statusCode:  http.StatusPaymentRequired,
+			contentType: "application/problem+json",
+			body:        `{"type": "urn:gophermart:problem:not_enough_points", "title": "Not enough points", "status": 402, "code": "not_enough_points"}`,
+			isValid:     true,
+		},
+		{
+			name:        "problem as plain text",
+			method:      http.MethodPost,
+			target:      "/api/user/balance/withdraw",
+			statusCode:  http.StatusPaymentRequired,
+			contentType: "text/plain; charset=utf-8",
+			body:        "not enough points",
+			isValid:     false,
+		},
+		{
+			name:       "undocumented status",
+			method:     http.MethodGet,
+			target:     "/api/user/balance",
+			statusCode: http.StatusNoContent,
+			isValid:    false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest(test.method, test.target, nil)
+
+			header := http.Header{}
+			if len(test.contentType) != 0 {
+				header.Set("Content-Type", test.contentType)
+			}
+			for name, value := range test.headers {
+				header.Set(name, value)
+			}
+
+			err := document.ValidateResponse(request, test.statusCode, header, []byte(test.body))
+			if test.isValid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestParseInvalidReference(t *testing.T) {
+	_, err := Parse([]byte(`{
+		"paths": {
+			"/api/health": {
+				"get": {
+					"responses": {
+						"200": {
+							"$ref": "#/components/responses/Unknown"
+						}
+					}
+				}
+			}
+		}
+	}`))
+	assert.Error(t, err)
+}
@@ -0,0 +1,71 @@
+package openapitest
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/avGenie/go-loyalty-system/internal/app/controller/http/openapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	document     *openapi.Document
+	documentErr  error
+	documentOnce sync.Once
+)
+
+// Serve serves the request by the handler and checks the exchange against
+// the OpenAPI document. The response is always checked, the request only
+// when the handler has accepted it, since tests send invalid requests on
+// purpose.
+func Serve(t testing.TB, handler http.HandlerFunc, request *http.Request) *http.Response {
+	t.Helper()
+
+	documentOnce.Do(func() {
+		document, documentErr = openapi.Load()
+	})
+	require.NoError(t, documentErr)
+
+	body, readErr := readBody(request)
+
+	writer := httptest.NewRecorder()
+	handler(writer, request)
+	res := writer.Result()
+
+	if readErr == nil && res.StatusCode < http.StatusMultipleChoices {
+		assert.NoError(t, document.ValidateRequest(request, body), "request doesn't match openapi document")
+	}
+	assert.NoError(t, document.ValidateResponse(request, res.StatusCode, res.Header, writer.Body.Bytes()), "response doesn't match openapi document")
+
+	return res
+}
+
+// readBody reads the request body and puts it back for the handler. If the
+// body fails, the handler gets the same error.
+func readBody(request *http.Request) ([]byte, error) {
+	if request.Body == nil {
+		return nil, nil
+	}
+
+	body, err := io.ReadAll(request.Body)
+	if err != nil {
+		request.Body = io.NopCloser(failingReader{err: err})
+		return nil, err
+	}
+	request.Body = io.NopCloser(bytes.NewReader(body))
+
+	return body, nil
+}
+
+type failingReader struct {
+	err error
+}
+
+func (r failingReader) Read(p []byte) (int, error) {
+	return 0, r.err
+}
@@ -0,0 +1,232 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// Schema is the subset of the OpenAPI schema object used by the document.
+type Schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Format               string             `json:"format"`
+	Pattern              string             `json:"pattern"`
+	Enum                 []any              `json:"enum"`
+	Required             []string           `json:"required"`
+	Properties           map[string]*Schema `json:"properties"`
+	AdditionalProperties *bool              `json:"additionalProperties"`
+	Items                *Schema            `json:"items"`
+	MinLength            *int               `json:"minLength"`
+	MaxLength            *int               `json:"maxLength"`
+	MinItems             *int               `json:"minItems"`
+	MaxItems             *int               `json:"maxItems"`
+	Minimum              *float64           `json:"minimum"`
+	Maximum              *float64           `json:"maximum"`
+}
+
+// Validate checks the value decoded from JSON with json.Number numbers.
+func (s *Schema) Validate(value any) error {
+	return s.validate("$", value)
+}
+
+// validateString checks the parameter or the header value converting it
+// to the schema type first.
+func (s *Schema) validateString(name, value string) error {
+	if s == nil {
+		return nil
+	}
+
+	switch s.Type {
+	case "integer", "number":
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return fmt.Errorf("%s: %q isn't a number", name, value)
+		}
+
+		return s.validate(name, json.Number(value))
+	case "boolean":
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%s: %q isn't a boolean", name, value)
+		}
+
+		return s.validate(name, parsed)
+	default:
+		return s.validate(name, value)
+	}
+}
+
+func (s *Schema) validate(path string, value any) error {
+	if s == nil {
+		return nil
+	}
+
+	var err error
+	switch s.Type {
+	case "object":
+		err = s.validateObject(path, value)
+	case "array":
+		err = s.validateArray(path, value)
+	case "string":
+		err = s.validateStringValue(path, value)
+	case "integer", "number":
+		err = s.validateNumber(path, value)
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			err = fmt.Errorf("%s: %v isn't a boolean", path, value)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	return s.validateEnum(path, value)
+}
+
+func (s *Schema) validateObject(path string, value any) error {
+	object, ok := value.(map[string]any)
+	if !ok {
+		return fmt.Errorf("%s: %v isn't an object", path, value)
+	}
+
+	for _, name := range s.Required {
+		if _, ok := object[name]; !ok {
+			return fmt.Errorf("%s: property %s is required", path, name)
+		}
+	}
+
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		property, ok := s.Properties[name]
+		if !ok {
+			if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+				return fmt.Errorf("%s: property %s isn't documented", path, name)
+			}
+			continue
+		}
+
+		err := property.validate(path+"."+name, object[name])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Schema) validateArray(path string, value any) error {
+	array, ok := value.([]any)
+	if !ok {
+		return fmt.Errorf("%s: %v isn't an array", path, value)
+	}
+
+	if s.MinItems != nil && len(array) < *s.MinItems {
+		return fmt.Errorf("%s: array must have at least %d items", path, *s.MinItems)
+	}
+
+	if s.MaxItems != nil && len(array) > *s.MaxItems {
+		return fmt.Errorf("%s: array must have at most %d items", path, *s.MaxItems)
+	}
+
+	for i, item := range array {
+		err := s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Schema) validateStringValue(path string, value any) error {
+	str, ok := value.(string)
+	if !ok {
+		return fmt.Errorf("%s: %v isn't a string", path, value)
+	}
+
+	length := utf8.RuneCountInString(str)
+	if s.MinLength != nil && length < *s.MinLength {
+		return fmt.Errorf("%s: string must be at least %d characters long", path, *s.MinLength)
+	}
+
+	if s.MaxLength != nil && length > *s.MaxLength {
+		return fmt.Errorf("%s: string must be at most %d characters long", path, *s.MaxLength)
+	}
+
+	if len(s.Pattern) != 0 {
+		matched, err := regexp.MatchString(s.Pattern, str)
+		if err != nil {
+			return fmt.Errorf("%s: pattern %s is invalid: %w", path, s.Pattern, err)
+		}
+
+		if !matched {
+			return fmt.Errorf("%s: %q doesn't match pattern %s", path, str, s.Pattern)
+		}
+	}
+
+	switch s.Format {
+	case "date-time":
+		if _, err := time.Parse(time.RFC3339, str); err != nil {
+			return fmt.Errorf("%s: %q isn't a date-time", path, str)
+		}
+	case "uuid":
+		if _, err := uuid.Parse(str); err != nil {
+			return fmt.Errorf("%s: %q isn't an uuid", path, str)
+		}
+	}
+
+	return nil
+}
+
+func (s *Schema) validateNumber(path string, value any) error {
+	number, ok := value.(json.Number)
+	if !ok {
+		return fmt.Errorf("%s: %v isn't a number", path, value)
+	}
+
+	if s.Type == "integer" {
+		if _, err := number.Int64(); err != nil {
+			return fmt.Errorf("%s: %s isn't an integer", path, number)
+		}
+	}
+
+	parsed, err := number.Float64()
+	if err != nil {
+		return fmt.Errorf("%s: %s isn't a number", path, number)
+	}
+
+	if s.Minimum != nil && parsed < *s.Minimum {
+		return fmt.Errorf("%s: %s must be at least %v", path, number, *s.Minimum)
+	}
+
+	if s.Maximum != nil && parsed > *s.Maximum {
+		return fmt.Errorf("%s: %s must be at most %v", path, number, *s.Maximum)
+	}
+
+	return nil
+}
+
+func (s *Schema) validateEnum(path string, value any) error {
+	if len(s.Enum) == 0 {
+		return nil
+	}
+
+	for _, allowed := range s.Enum {
+		if fmt.Sprint(allowed) == fmt.Sprint(value) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%s: %v isn't one of %v", path, value, s.Enum)
+}
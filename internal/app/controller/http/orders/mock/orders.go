@@ -7,6 +7,7 @@ package mock
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	entity "github.com/avGenie/go-loyalty-system/internal/app/entity"
 	gomock "github.com/golang/mock/gomock"
@@ -35,19 +36,63 @@ func (m *MockOrderProcessor) EXPECT() *MockOrderProcessorMockRecorder {
 	return m.recorder
 }
 
+// DeleteExpiredOrderEvents mocks base method.
+func (m *MockOrderProcessor) DeleteExpiredOrderEvents(ctx context.Context, retention time.Duration) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpiredOrderEvents", ctx, retention)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteExpiredOrderEvents indicates an expected call of DeleteExpiredOrderEvents.
+func (mr *MockOrderProcessorMockRecorder) DeleteExpiredOrderEvents(ctx, retention interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpiredOrderEvents", reflect.TypeOf((*MockOrderProcessor)(nil).DeleteExpiredOrderEvents), ctx, retention)
+}
+
+// GetIdempotencyKey mocks base method.
+func (m *MockOrderProcessor) GetIdempotencyKey(ctx context.Context, userID entity.UserID, key string) (entity.IdempotencyKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetIdempotencyKey", ctx, userID, key)
+	ret0, _ := ret[0].(entity.IdempotencyKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetIdempotencyKey indicates an expected call of GetIdempotencyKey.
+func (mr *MockOrderProcessorMockRecorder) GetIdempotencyKey(ctx, userID, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetIdempotencyKey", reflect.TypeOf((*MockOrderProcessor)(nil).GetIdempotencyKey), ctx, userID, key)
+}
+
+// GetOrderEvents mocks base method.
+func (m *MockOrderProcessor) GetOrderEvents(ctx context.Context, userID entity.UserID, afterID int64) (entity.OrderEvents, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOrderEvents", ctx, userID, afterID)
+	ret0, _ := ret[0].(entity.OrderEvents)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOrderEvents indicates an expected call of GetOrderEvents.
+func (mr *MockOrderProcessorMockRecorder) GetOrderEvents(ctx, userID, afterID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrderEvents", reflect.TypeOf((*MockOrderProcessor)(nil).GetOrderEvents), ctx, userID, afterID)
+}
+
 // GetOrdersForUpdate mocks base method.
-func (m *MockOrderProcessor) GetOrdersForUpdate(ctx context.Context, count, offset int) (entity.UpdateUserOrders, error) {
+func (m *MockOrderProcessor) GetOrdersForUpdate(ctx context.Context, count int, lease time.Duration) (entity.UpdateUserOrders, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetOrdersForUpdate", ctx, count, offset)
+	ret := m.ctrl.Call(m, "GetOrdersForUpdate", ctx, count, lease)
 	ret0, _ := ret[0].(entity.UpdateUserOrders)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetOrdersForUpdate indicates an expected call of GetOrdersForUpdate.
-func (mr *MockOrderProcessorMockRecorder) GetOrdersForUpdate(ctx, count, offset interface{}) *gomock.Call {
+func (mr *MockOrderProcessorMockRecorder) GetOrdersForUpdate(ctx, count, lease interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrdersForUpdate", reflect.TypeOf((*MockOrderProcessor)(nil).GetOrdersForUpdate), ctx, count, offset)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrdersForUpdate", reflect.TypeOf((*MockOrderProcessor)(nil).GetOrdersForUpdate), ctx, count, lease)
 }
 
 // GetUserBalance mocks base method.
@@ -65,34 +110,92 @@ func (mr *MockOrderProcessorMockRecorder) GetUserBalance(ctx, userID interface{}
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserBalance", reflect.TypeOf((*MockOrderProcessor)(nil).GetUserBalance), ctx, userID)
 }
 
+// GetUserLedger mocks base method.
+func (m *MockOrderProcessor) GetUserLedger(ctx context.Context, userID entity.UserID) (entity.LedgerEntries, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserLedger", ctx, userID)
+	ret0, _ := ret[0].(entity.LedgerEntries)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserLedger indicates an expected call of GetUserLedger.
+func (mr *MockOrderProcessorMockRecorder) GetUserLedger(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserLedger", reflect.TypeOf((*MockOrderProcessor)(nil).GetUserLedger), ctx, userID)
+}
+
 // GetUserOrders mocks base method.
-func (m *MockOrderProcessor) GetUserOrders(ctx context.Context, userID entity.UserID) (entity.Orders, error) {
+func (m *MockOrderProcessor) GetUserOrders(ctx context.Context, userID entity.UserID, query entity.ListQuery) (entity.Orders, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetUserOrders", ctx, userID)
+	ret := m.ctrl.Call(m, "GetUserOrders", ctx, userID, query)
 	ret0, _ := ret[0].(entity.Orders)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetUserOrders indicates an expected call of GetUserOrders.
-func (mr *MockOrderProcessorMockRecorder) GetUserOrders(ctx, userID interface{}) *gomock.Call {
+func (mr *MockOrderProcessorMockRecorder) GetUserOrders(ctx, userID, query interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserOrders", reflect.TypeOf((*MockOrderProcessor)(nil).GetUserOrders), ctx, userID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserOrders", reflect.TypeOf((*MockOrderProcessor)(nil).GetUserOrders), ctx, userID, query)
 }
 
 // GetUserWithdrawals mocks base method.
-func (m *MockOrderProcessor) GetUserWithdrawals(ctx context.Context, userID entity.UserID) (entity.Withdrawals, error) {
+func (m *MockOrderProcessor) GetUserWithdrawals(ctx context.Context, userID entity.UserID, query entity.ListQuery) (entity.Withdrawals, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetUserWithdrawals", ctx, userID)
+	ret := m.ctrl.Call(m, "GetUserWithdrawals", ctx, userID, query)
 	ret0, _ := ret[0].(entity.Withdrawals)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetUserWithdrawals indicates an expected call of GetUserWithdrawals.
-func (mr *MockOrderProcessorMockRecorder) GetUserWithdrawals(ctx, userID interface{}) *gomock.Call {
+func (mr *MockOrderProcessorMockRecorder) GetUserWithdrawals(ctx, userID, query interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserWithdrawals", reflect.TypeOf((*MockOrderProcessor)(nil).GetUserWithdrawals), ctx, userID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserWithdrawals", reflect.TypeOf((*MockOrderProcessor)(nil).GetUserWithdrawals), ctx, userID, query)
+}
+
+// ListenOrderEvents mocks base method.
+func (m *MockOrderProcessor) ListenOrderEvents(ctx context.Context) (<-chan entity.OrderEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListenOrderEvents", ctx)
+	ret0, _ := ret[0].(<-chan entity.OrderEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListenOrderEvents indicates an expected call of ListenOrderEvents.
+func (mr *MockOrderProcessorMockRecorder) ListenOrderEvents(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListenOrderEvents", reflect.TypeOf((*MockOrderProcessor)(nil).ListenOrderEvents), ctx)
+}
+
+// ScheduleOrderChecks mocks base method.
+func (m *MockOrderProcessor) ScheduleOrderChecks(ctx context.Context, checks entity.OrderChecks) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ScheduleOrderChecks", ctx, checks)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ScheduleOrderChecks indicates an expected call of ScheduleOrderChecks.
+func (mr *MockOrderProcessorMockRecorder) ScheduleOrderChecks(ctx, checks interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ScheduleOrderChecks", reflect.TypeOf((*MockOrderProcessor)(nil).ScheduleOrderChecks), ctx, checks)
+}
+
+// StoreIdempotencyKey mocks base method.
+func (m *MockOrderProcessor) StoreIdempotencyKey(ctx context.Context, userID entity.UserID, key entity.IdempotencyKey) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StoreIdempotencyKey", ctx, userID, key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// StoreIdempotencyKey indicates an expected call of StoreIdempotencyKey.
+func (mr *MockOrderProcessorMockRecorder) StoreIdempotencyKey(ctx, userID, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StoreIdempotencyKey", reflect.TypeOf((*MockOrderProcessor)(nil).StoreIdempotencyKey), ctx, userID, key)
 }
 
 // UpdateOrders mocks base method.
@@ -124,6 +227,21 @@ func (mr *MockOrderProcessorMockRecorder) UploadOrder(ctx, userID, orderNumber i
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UploadOrder", reflect.TypeOf((*MockOrderProcessor)(nil).UploadOrder), ctx, userID, orderNumber)
 }
 
+// UploadOrders mocks base method.
+func (m *MockOrderProcessor) UploadOrders(ctx context.Context, userID entity.UserID, numbers []entity.OrderNumber) (entity.OrderUploadResults, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UploadOrders", ctx, userID, numbers)
+	ret0, _ := ret[0].(entity.OrderUploadResults)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UploadOrders indicates an expected call of UploadOrders.
+func (mr *MockOrderProcessorMockRecorder) UploadOrders(ctx, userID, numbers interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UploadOrders", reflect.TypeOf((*MockOrderProcessor)(nil).UploadOrders), ctx, userID, numbers)
+}
+
 // WithdrawUser mocks base method.
 func (m *MockOrderProcessor) WithdrawUser(ctx context.Context, userID entity.UserID, withdraw entity.Withdraw) error {
 	m.ctrl.T.Helper()
@@ -138,64 +256,16 @@ func (mr *MockOrderProcessorMockRecorder) WithdrawUser(ctx, userID, withdraw int
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WithdrawUser", reflect.TypeOf((*MockOrderProcessor)(nil).WithdrawUser), ctx, userID, withdraw)
 }
 
-// MockAccrualOrderConnector is a mock of AccrualOrderConnector interface.
-type MockAccrualOrderConnector struct {
-	ctrl     *gomock.Controller
-	recorder *MockAccrualOrderConnectorMockRecorder
-}
-
-// MockAccrualOrderConnectorMockRecorder is the mock recorder for MockAccrualOrderConnector.
-type MockAccrualOrderConnectorMockRecorder struct {
-	mock *MockAccrualOrderConnector
-}
-
-// NewMockAccrualOrderConnector creates a new mock instance.
-func NewMockAccrualOrderConnector(ctrl *gomock.Controller) *MockAccrualOrderConnector {
-	mock := &MockAccrualOrderConnector{ctrl: ctrl}
-	mock.recorder = &MockAccrualOrderConnectorMockRecorder{mock}
-	return mock
-}
-
-// EXPECT returns an object that allows the caller to indicate expected use.
-func (m *MockAccrualOrderConnector) EXPECT() *MockAccrualOrderConnectorMockRecorder {
-	return m.recorder
-}
-
-// CloseInput mocks base method.
-func (m *MockAccrualOrderConnector) CloseInput() {
-	m.ctrl.T.Helper()
-	m.ctrl.Call(m, "CloseInput")
-}
-
-// CloseInput indicates an expected call of CloseInput.
-func (mr *MockAccrualOrderConnectorMockRecorder) CloseInput() *gomock.Call {
-	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CloseInput", reflect.TypeOf((*MockAccrualOrderConnector)(nil).CloseInput))
-}
-
-// GetOutput mocks base method.
-func (m *MockAccrualOrderConnector) GetOutput() (entity.AccrualOrder, bool) {
+// WithdrawUserIdempotent mocks base method.
+func (m *MockOrderProcessor) WithdrawUserIdempotent(ctx context.Context, userID entity.UserID, withdraw entity.Withdraw, key entity.IdempotencyKey) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetOutput")
-	ret0, _ := ret[0].(entity.AccrualOrder)
-	ret1, _ := ret[1].(bool)
-	return ret0, ret1
-}
-
-// GetOutput indicates an expected call of GetOutput.
-func (mr *MockAccrualOrderConnectorMockRecorder) GetOutput() *gomock.Call {
-	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOutput", reflect.TypeOf((*MockAccrualOrderConnector)(nil).GetOutput))
-}
-
-// SetInput mocks base method.
-func (m *MockAccrualOrderConnector) SetInput(number entity.AccrualOrderRequest) {
-	m.ctrl.T.Helper()
-	m.ctrl.Call(m, "SetInput", number)
+	ret := m.ctrl.Call(m, "WithdrawUserIdempotent", ctx, userID, withdraw, key)
+	ret0, _ := ret[0].(error)
+	return ret0
 }
 
-// SetInput indicates an expected call of SetInput.
-func (mr *MockAccrualOrderConnectorMockRecorder) SetInput(number interface{}) *gomock.Call {
+// WithdrawUserIdempotent indicates an expected call of WithdrawUserIdempotent.
+func (mr *MockOrderProcessorMockRecorder) WithdrawUserIdempotent(ctx, userID, withdraw, key interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetInput", reflect.TypeOf((*MockAccrualOrderConnector)(nil).SetInput), number)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WithdrawUserIdempotent", reflect.TypeOf((*MockOrderProcessor)(nil).WithdrawUserIdempotent), ctx, userID, withdraw, key)
 }
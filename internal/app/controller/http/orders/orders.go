@@ -1,11 +1,17 @@
 package orders
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -13,38 +19,79 @@ import (
 	"github.com/avGenie/go-loyalty-system/internal/app/converter"
 	"github.com/avGenie/go-loyalty-system/internal/app/entity"
 	"github.com/avGenie/go-loyalty-system/internal/app/model"
+	"github.com/avGenie/go-loyalty-system/internal/app/money"
 	err_storage "github.com/avGenie/go-loyalty-system/internal/app/storage/api/errors"
+	"github.com/avGenie/go-loyalty-system/internal/app/usecase/accrual"
 	"github.com/avGenie/go-loyalty-system/internal/app/usecase/order"
+	"github.com/avGenie/go-loyalty-system/internal/app/usecase/problem"
 	"github.com/avGenie/go-loyalty-system/internal/app/usecase/validator"
 	"go.uber.org/zap"
 )
 
 const (
 	ErrTokenExpired = "token has expired"
+	ErrTokenRevoked = "token has been revoked"
 	ErrInvalidAuth  = "auth credentials are invalid"
+
+	ErrInvalidOrderNumber    = "order number doesn't pass the Luhn check"
+	ErrInvalidPrecision      = "sum must have at most two decimal places"
+	ErrNotPositiveSum        = "sum must be positive"
+	ErrIdempotencyKeyTooLong = "idempotency key must be at most 255 characters long"
+	ErrInvalidBatch          = "batch must be a JSON array of strings"
+	ErrEmptyBatch            = "batch doesn't contain order numbers"
+	ErrInvalidLastEventID    = "Last-Event-ID must be a non-negative integer"
+)
+
+const (
+	IdempotencyKeyHeader = "Idempotency-Key"
+	LastEventIDHeader    = "Last-Event-ID"
 )
 
 const (
 	stopTimeout = 5 * time.Second
+
+	idempotencyKeyMaxLen = 255
+
+	maxBatchSize = 1000
+
+	defaultPageLimit = 100
+	maxPageLimit     = 1000
+
+	orderEventName         = "order"
+	streamRetry            = 3 * time.Second
+	defaultStreamHeartbeat = 15 * time.Second
 )
 
-type AccrualOrderConnector interface {
-	SetInput(number entity.AccrualOrderRequest)
-	CloseInput()
-	GetOutput() (entity.AccrualOrder, bool)
-}
+const (
+	limitParam  = "limit"
+	cursorParam = "cursor"
+	statusParam = "status"
+	fromParam   = "from"
+	toParam     = "to"
+	sortParam   = "sort"
+)
 
 type Order struct {
-	storage       order.OrderProcessor
-	statusUpdater *order.StatusUpdater
-	wg            *sync.WaitGroup
+	storage         order.OrderProcessor
+	statusUpdater   *order.StatusUpdater
+	events          *order.EventBroker
+	eventCleaner    *order.EventCleaner
+	streamHeartbeat time.Duration
+	wg              *sync.WaitGroup
 }
 
-func New(storage order.OrderProcessor, config config.Config) Order {
+func New(storage order.OrderProcessor, client accrual.AccrualClient, config config.Config) Order {
 	instance := Order{
-		storage:       storage,
-		statusUpdater: order.CreateStatusUpdater(storage, config),
-		wg:            &sync.WaitGroup{},
+		storage:         storage,
+		statusUpdater:   order.CreateStatusUpdater(storage, client, config),
+		events:          order.CreateEventBroker(storage),
+		eventCleaner:    order.CreateEventCleaner(storage, config),
+		streamHeartbeat: config.OrderStreamHeartbeat,
+		wg:              &sync.WaitGroup{},
+	}
+
+	if instance.streamHeartbeat <= 0 {
+		instance.streamHeartbeat = defaultStreamHeartbeat
 	}
 
 	instance.wg.Add(1)
@@ -53,11 +100,18 @@ func New(storage order.OrderProcessor, config config.Config) Order {
 		instance.statusUpdater.Start()
 	}()
 
+	instance.wg.Add(1)
+	go func() {
+		defer instance.wg.Done()
+		instance.eventCleaner.Start()
+	}()
+
 	return instance
 }
 
 func (p *Order) Stop() {
 	p.statusUpdater.Stop()
+	p.eventCleaner.Stop()
 
 	ready := make(chan bool)
 	go func() {
@@ -76,6 +130,12 @@ func (p *Order) Stop() {
 	}
 }
 
+// CloseStreams ends the order event streams, they don't let the server shut
+// down otherwise.
+func (p *Order) CloseStreams() {
+	p.events.Stop()
+}
+
 func (p *Order) UploadOrder() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		userID, err := p.parseUserID(w, r)
@@ -117,6 +177,45 @@ func (p *Order) UploadOrder() http.HandlerFunc {
 	}
 }
 
+func (p *Order) UploadOrdersBatch() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := p.parseUserID(w, r)
+		if err != nil {
+			zap.L().Error("error while parsing user id while uploading orders batch", zap.Error(err))
+			return
+		}
+
+		numbers, err := p.parseOrderNumbers(w, r)
+		if err != nil {
+			zap.L().Error("error while parsing order numbers while uploading orders batch", zap.Error(err))
+			return
+		}
+
+		results, err := order.UploadOrders(userID, numbers, p.storage, w)
+		if err != nil {
+			zap.L().Error("error while uploading orders batch to storage", zap.Error(err))
+			return
+		}
+
+		zap.L().Info(
+			"upload orders batch to storage",
+			zap.String("user_id", userID.String()),
+			zap.Int("count", len(numbers)),
+		)
+
+		out, err := json.Marshal(converter.ConvertOrderUploadResultsToResponse(results))
+		if err != nil {
+			zap.L().Error("error while marshalling orders batch results", zap.Error(err))
+			problem.Write(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(out)
+	}
+}
+
 func (p *Order) GetUserOrders() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		userID, err := p.parseUserID(w, r)
@@ -125,11 +224,18 @@ func (p *Order) GetUserOrders() http.HandlerFunc {
 			return
 		}
 
-		orders, err := order.GetUserOrders(userID, p.storage, w)
+		query, err := p.parseListQuery(true, w, r)
+		if err != nil {
+			zap.L().Error("error while parsing user orders query", zap.Error(err))
+			return
+		}
+
+		orders, cursor, err := order.GetUserOrders(userID, query, p.storage, w)
 		if err != nil {
 			return
 		}
 
+		p.setNextPageLink(cursor, w, r)
 		p.sendUserOrders(orders, w)
 	}
 }
@@ -166,7 +272,18 @@ func (p *Order) WithdrawBonuses() http.HandlerFunc {
 			return
 		}
 
-		order.WithdrawUserBonuses(userID, withdraw, p.storage, w)
+		if len(r.Header.Get(IdempotencyKeyHeader)) == 0 {
+			order.WithdrawUserBonuses(userID, withdraw, p.storage, w)
+			return
+		}
+
+		key, err := p.parseIdempotencyKey(withdraw, w, r)
+		if err != nil {
+			zap.L().Error("error while parsing idempotency key", zap.Error(err))
+			return
+		}
+
+		order.WithdrawUserBonusesIdempotent(userID, withdraw, key, p.storage, w)
 	}
 }
 
@@ -178,22 +295,170 @@ func (p *Order) GetUserWithdrawals() http.HandlerFunc {
 			return
 		}
 
-		withdrawals, err := order.GetUserWithdrawals(userID, p.storage, w)
+		query, err := p.parseListQuery(false, w, r)
 		if err != nil {
+			zap.L().Error("error while parsing user withdrawals query", zap.Error(err))
 			return
 		}
 
+		withdrawals, cursor, err := order.GetUserWithdrawals(userID, query, p.storage, w)
+		if err != nil {
+			return
+		}
+
+		p.setNextPageLink(cursor, w, r)
 		p.sendUserWithdrawals(withdrawals, w)
 	}
 }
 
+func (p *Order) GetUserBalanceHistory() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := p.parseUserID(w, r)
+		if err != nil {
+			zap.L().Error("error while parsing user id while getting user balance history", zap.Error(err))
+			return
+		}
+
+		entries, err := order.GetUserLedger(userID, p.storage, w)
+		if err != nil {
+			return
+		}
+
+		out, err := json.Marshal(converter.ConvertLedgerToLedgerResponse(entries))
+		if err != nil {
+			zap.L().Error("error while marshalling user balance history", zap.Error(err))
+			problem.Write(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(out)
+	}
+}
+
+// StreamOrderEvents sends the status changes of the user orders as
+// server-sent events. The stream resumed with the Last-Event-ID header
+// starts with the events missed since then.
+func (p *Order) StreamOrderEvents() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := p.parseUserID(w, r)
+		if err != nil {
+			zap.L().Error("error while parsing user id while streaming order events", zap.Error(err))
+			return
+		}
+
+		lastEventID, err := p.parseLastEventID(w, r)
+		if err != nil {
+			zap.L().Error("error while parsing last event id", zap.Error(err))
+			return
+		}
+
+		events, unsubscribe, err := p.events.Subscribe(userID)
+		if err != nil {
+			zap.L().Error("error while subscribing to order events", zap.Error(err))
+			problem.Write(w, err)
+			return
+		}
+		defer unsubscribe()
+
+		// подписываемся до чтения пропущенных событий, чтобы не потерять
+		// события между запросом в хранилище и подпиской
+		var missed entity.OrderEvents
+		if len(r.Header.Get(LastEventIDHeader)) != 0 {
+			missed, err = order.GetMissedOrderEvents(userID, lastEventID, p.storage, w)
+			if err != nil {
+				return
+			}
+		}
+
+		zap.L().Info(
+			"order events stream has started",
+			zap.String("user_id", userID.String()),
+			zap.Int64("last_event_id", lastEventID),
+		)
+
+		p.streamOrderEvents(lastEventID, missed, events, w, r)
+	}
+}
+
+// streamOrderEvents writes the missed events and then the live ones, an
+// event received both ways is sent once. The stream ends when the client
+// goes away or the subscription is closed, the client reconnects with the
+// last event ID then.
+func (p *Order) streamOrderEvents(lastEventID int64, missed entity.OrderEvents, events <-chan entity.OrderEvent, w http.ResponseWriter, r *http.Request) {
+	controller := http.NewResponseController(w)
+
+	send := func(event entity.OrderEvent) error {
+		if event.ID <= lastEventID {
+			return nil
+		}
+
+		err := writeOrderEvent(event, w)
+		if err != nil {
+			return err
+		}
+
+		lastEventID = event.ID
+		return nil
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	_, err := fmt.Fprintf(w, "retry: %d\n\n", streamRetry.Milliseconds())
+	for i := 0; err == nil && i < len(missed); i++ {
+		err = send(missed[i])
+	}
+	if err == nil {
+		err = controller.Flush()
+	}
+
+	heartbeat := time.NewTicker(p.streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for err == nil {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			_, err = io.WriteString(w, ": heartbeat\n\n")
+		case event, ok := <-events:
+			if !ok {
+				zap.L().Info("order events subscription has been closed", zap.Int64("last_event_id", lastEventID))
+				return
+			}
+			err = send(event)
+		}
+
+		if err == nil {
+			err = controller.Flush()
+		}
+	}
+
+	zap.L().Error("error while writing order events stream", zap.Error(err))
+}
+
+func writeOrderEvent(event entity.OrderEvent, w io.Writer) error {
+	data, err := json.Marshal(converter.ConvertOrderEventToResponse(event))
+	if err != nil {
+		return fmt.Errorf("error while marshalling order event: %w", err)
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, orderEventName, data)
+
+	return err
+}
+
 func (p *Order) sendUserWithdrawals(withdrawals entity.Withdrawals, w http.ResponseWriter) {
 	outWithdrawals := converter.ConvertWithdrawToWithdrawResponse(withdrawals)
 
 	out, err := json.Marshal(outWithdrawals)
 	if err != nil {
 		zap.L().Error("error while marshalling user withdrawals", zap.Error(err))
-		w.WriteHeader(http.StatusInternalServerError)
+		problem.Write(w, err)
 		return
 	}
 
@@ -205,7 +470,7 @@ func (p *Order) sendUserWithdrawals(withdrawals entity.Withdrawals, w http.Respo
 func (p *Order) parseUserWithdraw(w http.ResponseWriter, r *http.Request) (entity.Withdraw, error) {
 	bodyResult, err := io.ReadAll(r.Body)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		problem.Write(w, err)
 		return entity.Withdraw{}, fmt.Errorf("error while reading request body :%w", err)
 	}
 	defer r.Body.Close()
@@ -213,27 +478,63 @@ func (p *Order) parseUserWithdraw(w http.ResponseWriter, r *http.Request) (entit
 	var withdraw model.WithdrawRequest
 	err = json.Unmarshal(bodyResult, &withdraw)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		if errors.Is(err, money.ErrInvalidPrecision) {
+			problem.WriteCode(w, problem.CodeInvalidAmount, ErrInvalidPrecision)
+		} else {
+			problem.Write(w, err)
+		}
 		return entity.Withdraw{}, fmt.Errorf("error while unmarshal request body :%w", err)
 	}
 
+	if !withdraw.Sum.IsPositive() {
+		problem.WriteCode(w, problem.CodeInvalidAmount, ErrNotPositiveSum)
+		return entity.Withdraw{}, fmt.Errorf("withdraw sum = %s is not positive while parse user withdraw", withdraw.Sum)
+	}
+
 	orderNumber := entity.OrderNumber(withdraw.Order)
 	isValid := validator.OrderNumberValidation(orderNumber)
 	if !isValid {
-		w.WriteHeader(http.StatusUnprocessableEntity)
+		problem.WriteCode(w, problem.CodeInvalidOrderNumber, ErrInvalidOrderNumber)
 		return entity.Withdraw{}, fmt.Errorf("order number = %s is invalid while parse user withdraw", orderNumber)
 	}
 
 	return converter.ConvertRequestWithdrawToEntity(withdraw), nil
 }
 
+func (p *Order) parseIdempotencyKey(withdraw entity.Withdraw, w http.ResponseWriter, r *http.Request) (entity.IdempotencyKey, error) {
+	key := r.Header.Get(IdempotencyKeyHeader)
+	if len(key) > idempotencyKeyMaxLen {
+		problem.WriteCode(w, problem.CodeInvalidIdempotencyKey, ErrIdempotencyKeyTooLong)
+		return entity.IdempotencyKey{}, fmt.Errorf("idempotency key length = %d exceeds %d", len(key), idempotencyKeyMaxLen)
+	}
+
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%s:%s", withdraw.OrderNumber, withdraw.Sum)))
+
+	return entity.CreateIdempotencyKey(key, hex.EncodeToString(hash[:])), nil
+}
+
+func (p *Order) parseLastEventID(w http.ResponseWriter, r *http.Request) (int64, error) {
+	value := r.Header.Get(LastEventIDHeader)
+	if len(value) == 0 {
+		return 0, nil
+	}
+
+	lastEventID, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || lastEventID < 0 {
+		problem.WriteCode(w, problem.CodeBadRequest, ErrInvalidLastEventID)
+		return 0, fmt.Errorf("last event id = %s is invalid", value)
+	}
+
+	return lastEventID, nil
+}
+
 func (p *Order) sendUserBalance(balance entity.UserBalance, w http.ResponseWriter) {
 	outBalance := converter.ConvertStorageBalanceToOutput(balance)
 
 	out, err := json.Marshal(outBalance)
 	if err != nil {
 		zap.L().Error("error while marshalling user balance", zap.Error(err))
-		w.WriteHeader(http.StatusInternalServerError)
+		problem.Write(w, err)
 		return
 	}
 
@@ -246,14 +547,14 @@ func (p *Order) sendUserOrders(orders entity.Orders, w http.ResponseWriter) {
 	outOrders, err := converter.ConvertStorageOrdersToOutputUploadedOrders(orders)
 	if err != nil {
 		zap.L().Error("error while converting user orders to output model", zap.Error(err))
-		w.WriteHeader(http.StatusInternalServerError)
+		problem.Write(w, err)
 		return
 	}
 
 	out, err := json.Marshal(outOrders)
 	if err != nil {
 		zap.L().Error("error while marshalling user orders", zap.Error(err))
-		w.WriteHeader(http.StatusInternalServerError)
+		problem.Write(w, err)
 		return
 	}
 
@@ -262,10 +563,117 @@ func (p *Order) sendUserOrders(orders entity.Orders, w http.ResponseWriter) {
 	w.Write(out)
 }
 
+// parseListQuery parses pagination, filter and sort parameters of the user
+// orders and withdrawals lists. Status filter is accepted only for orders.
+func (p *Order) parseListQuery(withStatus bool, w http.ResponseWriter, r *http.Request) (entity.ListQuery, error) {
+	query, err := buildListQuery(withStatus, r.URL.Query())
+	if err != nil {
+		problem.WriteCode(w, problem.CodeInvalidQuery, err.Error())
+		return entity.ListQuery{}, err
+	}
+
+	return query, nil
+}
+
+func buildListQuery(withStatus bool, values url.Values) (entity.ListQuery, error) {
+	query := entity.ListQuery{
+		Limit: defaultPageLimit,
+		Sort:  entity.SortDesc,
+	}
+
+	if limit := values.Get(limitParam); len(limit) != 0 {
+		value, err := strconv.Atoi(limit)
+		if err != nil || value <= 0 || value > maxPageLimit {
+			return entity.ListQuery{}, fmt.Errorf("limit = %s must be in range [1, %d]", limit, maxPageLimit)
+		}
+
+		query.Limit = value
+	}
+
+	if token := values.Get(cursorParam); len(token) != 0 {
+		cursor, err := converter.ConvertTokenToCursor(token)
+		if err != nil {
+			return entity.ListQuery{}, fmt.Errorf("cursor is invalid")
+		}
+
+		query.Cursor = &cursor
+	}
+
+	if statuses := values.Get(statusParam); len(statuses) != 0 {
+		if !withStatus {
+			return entity.ListQuery{}, fmt.Errorf("status filter is not supported")
+		}
+
+		for _, value := range strings.Split(statuses, ",") {
+			status := entity.OrderStatus(strings.ToUpper(strings.TrimSpace(value)))
+			if !status.IsValid() {
+				return entity.ListQuery{}, fmt.Errorf("order status = %s is invalid", value)
+			}
+
+			query.Statuses = append(query.Statuses, status)
+		}
+	}
+
+	var err error
+	query.From, err = parseQueryTime(fromParam, values.Get(fromParam))
+	if err != nil {
+		return entity.ListQuery{}, err
+	}
+
+	query.To, err = parseQueryTime(toParam, values.Get(toParam))
+	if err != nil {
+		return entity.ListQuery{}, err
+	}
+
+	if !query.From.IsZero() && !query.To.IsZero() && !query.From.Before(query.To) {
+		return entity.ListQuery{}, fmt.Errorf("from = %s must be before to = %s", query.From, query.To)
+	}
+
+	if sort := values.Get(sortParam); len(sort) != 0 {
+		query.Sort = entity.SortOrder(strings.ToLower(sort))
+		if !query.Sort.IsValid() {
+			return entity.ListQuery{}, fmt.Errorf("sort = %s is invalid", sort)
+		}
+	}
+
+	return query, nil
+}
+
+// setNextPageLink sets the Link header with the next page URL, which keeps
+// all parameters of the current request except the cursor.
+func (p *Order) setNextPageLink(cursor *entity.Cursor, w http.ResponseWriter, r *http.Request) {
+	if cursor == nil {
+		return
+	}
+
+	values := r.URL.Query()
+	values.Set(cursorParam, converter.ConvertCursorToToken(*cursor))
+
+	next := url.URL{
+		Path:     r.URL.Path,
+		RawQuery: values.Encode(),
+	}
+
+	w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next.String()))
+}
+
+func parseQueryTime(name, value string) (time.Time, error) {
+	if len(value) == 0 {
+		return time.Time{}, nil
+	}
+
+	result, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s = %s must be in RFC3339 format", name, value)
+	}
+
+	return result, nil
+}
+
 func (p *Order) parseOrderNumber(w http.ResponseWriter, r *http.Request) (entity.OrderNumber, error) {
 	data, err := io.ReadAll(r.Body)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		problem.Write(w, err)
 		return entity.OrderNumber(""), fmt.Errorf("error while request body parsing: %w", err)
 	}
 	defer r.Body.Close()
@@ -273,33 +681,88 @@ func (p *Order) parseOrderNumber(w http.ResponseWriter, r *http.Request) (entity
 	orderNumber := entity.OrderNumber(string(data))
 	isValid := validator.OrderNumberValidation(orderNumber)
 	if !isValid {
-		w.WriteHeader(http.StatusUnprocessableEntity)
+		problem.WriteCode(w, problem.CodeInvalidOrderNumber, ErrInvalidOrderNumber)
 		return entity.OrderNumber(""), fmt.Errorf("order number = %s is invalid", orderNumber)
 	}
 
 	return orderNumber, nil
 }
 
+// parseOrderNumbers reads the batch of order numbers, which is a JSON array of
+// strings for the application/json body and one number per line otherwise.
+func (p *Order) parseOrderNumbers(w http.ResponseWriter, r *http.Request) ([]entity.OrderNumber, error) {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		problem.Write(w, err)
+		return nil, fmt.Errorf("error while request body parsing: %w", err)
+	}
+	defer r.Body.Close()
+
+	var lines []string
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "application/json" {
+		err = json.Unmarshal(data, &lines)
+		if err != nil {
+			problem.WriteCode(w, problem.CodeBadRequest, ErrInvalidBatch)
+			return nil, fmt.Errorf("error while unmarshal request body: %w", err)
+		}
+	} else {
+		lines = strings.Split(string(data), "\n")
+	}
+
+	numbers := make([]entity.OrderNumber, 0, len(lines))
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		numbers = append(numbers, entity.OrderNumber(line))
+	}
+
+	if len(numbers) == 0 {
+		problem.WriteCode(w, problem.CodeBadRequest, ErrEmptyBatch)
+		return nil, fmt.Errorf("orders batch is empty")
+	}
+
+	if len(numbers) > maxBatchSize {
+		problem.WriteCode(w, problem.CodeBatchTooLarge, fmt.Sprintf("batch must contain at most %d order numbers", maxBatchSize))
+		return nil, fmt.Errorf("orders batch size = %d exceeds %d", len(numbers), maxBatchSize)
+	}
+
+	return numbers, nil
+}
+
 func (p *Order) parseUserID(w http.ResponseWriter, r *http.Request) (entity.UserID, error) {
 	userIDCtx, ok := r.Context().Value(entity.UserIDCtxKey{}).(entity.UserIDCtx)
 
 	if !ok {
-		w.WriteHeader(http.StatusInternalServerError)
+		problem.WriteCode(w, problem.CodeInternal, "")
 		return entity.UserID(""), fmt.Errorf("user id couldn't obtain from context")
 	}
 
+	if userIDCtx.StatusCode == http.StatusInternalServerError {
+		problem.WriteCode(w, problem.CodeInternal, "")
+		return entity.UserID(""), fmt.Errorf("failed to check auth token")
+	}
+
 	if userIDCtx.StatusCode == http.StatusBadRequest {
-		http.Error(w, ErrInvalidAuth, http.StatusUnauthorized)
+		problem.WriteCode(w, problem.CodeUnauthorized, ErrInvalidAuth)
 		return entity.UserID(""), fmt.Errorf("failed auth credentials")
 	}
 
+	if userIDCtx.Revoked {
+		problem.WriteCode(w, problem.CodeTokenRevoked, ErrTokenRevoked)
+		return entity.UserID(""), fmt.Errorf(ErrTokenRevoked)
+	}
+
 	if userIDCtx.StatusCode == http.StatusUnauthorized {
-		http.Error(w, ErrTokenExpired, http.StatusUnauthorized)
+		problem.WriteCode(w, problem.CodeTokenExpired, ErrTokenExpired)
 		return entity.UserID(""), fmt.Errorf(ErrTokenExpired)
 	}
 
 	if userIDCtx.StatusCode == http.StatusOK && !userIDCtx.UserID.Valid() {
-		http.Error(w, ErrInvalidAuth, http.StatusUnauthorized)
+		problem.WriteCode(w, problem.CodeUnauthorized, ErrInvalidAuth)
 		return entity.UserID(""), fmt.Errorf("invalid user id with status ok")
 	}
 
@@ -2,6 +2,9 @@ package orders
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -9,11 +12,16 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/avGenie/go-loyalty-system/internal/app/config"
+	"github.com/avGenie/go-loyalty-system/internal/app/controller/http/openapi/openapitest"
 	"github.com/avGenie/go-loyalty-system/internal/app/controller/http/orders/mock"
 	"github.com/avGenie/go-loyalty-system/internal/app/entity"
+	"github.com/avGenie/go-loyalty-system/internal/app/money"
 	err_storage "github.com/avGenie/go-loyalty-system/internal/app/storage/api/errors"
+	accrual_mock "github.com/avGenie/go-loyalty-system/internal/app/usecase/accrual/mock"
+	"github.com/avGenie/go-loyalty-system/internal/app/usecase/problem"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -21,6 +29,35 @@ import (
 
 const (
 	inputInvalid = `<invalid json>`
+
+	outputInvalidAuth = `{
+		"type": "urn:gophermart:problem:unauthorized",
+		"title": "Authentication required",
+		"status": 401,
+		"detail": "auth credentials are invalid",
+		"code": "unauthorized"
+	}`
+	outputNotEnoughPoints = `{
+		"type": "urn:gophermart:problem:not_enough_points",
+		"title": "Not enough points",
+		"status": 402,
+		"detail": "not enough points on the balance for withdrawal",
+		"code": "not_enough_points"
+	}`
+	outputTokenExpired = `{
+		"type": "urn:gophermart:problem:token_expired",
+		"title": "Token expired",
+		"status": 401,
+		"detail": "token has expired",
+		"code": "token_expired"
+	}`
+	outputTokenRevoked = `{
+		"type": "urn:gophermart:problem:token_revoked",
+		"title": "Token revoked",
+		"status": 401,
+		"detail": "token has been revoked",
+		"code": "token_revoked"
+	}`
 )
 
 type Reader interface {
@@ -44,6 +81,7 @@ func TestUploadOrder(t *testing.T) {
 	defer ctrl.Finish()
 
 	orderProcessor := mock.NewMockOrderProcessor(ctrl)
+	accrualClient := accrual_mock.NewMockAccrualClient(ctrl)
 
 	type want struct {
 		statusCode int
@@ -109,6 +147,22 @@ func TestUploadOrder(t *testing.T) {
 				statusCode: http.StatusConflict,
 			},
 		},
+		{
+			name:          "deleted user",
+			body:          strings.NewReader("735584316112"),
+			uploadErr:     err_storage.ErrUserDeleted,
+			isUploadUser:  true,
+			isContext:     true,
+			storageUserID: "",
+			userIDCtx: entity.UserIDCtx{
+				UserID:     "ac2a4811-4f10-487f-bde3-e39a14af7cd8",
+				StatusCode: http.StatusOK,
+			},
+
+			want: want{
+				statusCode: http.StatusForbidden,
+			},
+		},
 		{
 			name:          "user id context undefined",
 			body:          strings.NewReader("735584316112"),
@@ -135,7 +189,7 @@ func TestUploadOrder(t *testing.T) {
 
 			want: want{
 				statusCode: http.StatusUnauthorized,
-				outputBody: ErrInvalidAuth,
+				outputBody: outputInvalidAuth,
 			},
 		},
 		{
@@ -152,7 +206,21 @@ func TestUploadOrder(t *testing.T) {
 
 			want: want{
 				statusCode: http.StatusUnauthorized,
-				outputBody: ErrTokenExpired,
+				outputBody: outputTokenExpired,
+			},
+		},
+		{
+			name:          "token revoked",
+			body:          strings.NewReader("735584316112"),
+			uploadErr:     nil,
+			isUploadUser:  false,
+			isContext:     true,
+			storageUserID: "6f28a678-7eba-4a4e-966c-7fedc6420df7",
+			userIDCtx:     entity.CreateRevokedUserIDCtx(),
+
+			want: want{
+				statusCode: http.StatusUnauthorized,
+				outputBody: outputTokenRevoked,
 			},
 		},
 		{
@@ -169,7 +237,7 @@ func TestUploadOrder(t *testing.T) {
 
 			want: want{
 				statusCode: http.StatusUnauthorized,
-				outputBody: ErrInvalidAuth,
+				outputBody: outputInvalidAuth,
 			},
 		},
 		{
@@ -206,8 +274,7 @@ func TestUploadOrder(t *testing.T) {
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			request := httptest.NewRequest(http.MethodPost, "/api/user/register", test.body)
-			writer := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodPost, "/api/user/orders", test.body)
 
 			if test.isContext {
 				request = request.WithContext(context.WithValue(request.Context(), entity.UserIDCtxKey{}, test.userIDCtx))
@@ -221,18 +288,176 @@ func TestUploadOrder(t *testing.T) {
 
 			orderProcessor.EXPECT().GetOrdersForUpdate(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes()
 
-			orders := New(orderProcessor, Config())
+			orders := New(orderProcessor, accrualClient, Config())
 			handler := orders.UploadOrder()
-			handler(writer, request)
+			res := openapitest.Serve(t, handler, request)
+
+			assert.Equal(t, test.want.statusCode, res.StatusCode)
+
+			if len(test.want.outputBody) != 0 {
+				bodyResult, err := io.ReadAll(res.Body)
+				require.NoError(t, err)
+				assert.JSONEq(t, test.want.outputBody, string(bodyResult))
+			}
+
+			err := res.Body.Close()
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestUploadOrdersBatch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	orderProcessor := mock.NewMockOrderProcessor(ctrl)
+	accrualClient := accrual_mock.NewMockAccrualClient(ctrl)
+
+	userIDCtx := entity.UserIDCtx{
+		UserID:     "ac2a4811-4f10-487f-bde3-e39a14af7cd8",
+		StatusCode: http.StatusOK,
+	}
+
+	type want struct {
+		statusCode int
+		outputBody string
+	}
+	tests := []struct {
+		name           string
+		body           string
+		contentType    string
+		isUpload       bool
+		uploadNumbers  []entity.OrderNumber
+		storageResults entity.OrderUploadResults
+		uploadErr      error
+
+		want want
+	}{
+		{
+			name:          "json batch",
+			body:          `["735584316112", "527652728124", "1234", "735584316112", "044606165247"]`,
+			contentType:   "application/json",
+			isUpload:      true,
+			uploadNumbers: []entity.OrderNumber{"735584316112", "527652728124", "044606165247"},
+			storageResults: entity.OrderUploadResults{
+				{Number: "735584316112", Status: entity.UploadAcceptedOrder},
+				{Number: "527652728124", Status: entity.UploadExistsOrder},
+				{Number: "044606165247", Status: entity.UploadConflictOrder},
+			},
+
+			want: want{
+				statusCode: http.StatusOK,
+				outputBody: `[
+					{"number": "735584316112", "status": "ACCEPTED"},
+					{"number": "527652728124", "status": "ALREADY_UPLOADED"},
+					{"number": "1234", "status": "INVALID"},
+					{"number": "735584316112", "status": "ALREADY_UPLOADED"},
+					{"number": "044606165247", "status": "CONFLICT"}
+				]`,
+			},
+		},
+		{
+			name:          "newline delimited batch",
+			body:          "735584316112\r\n\n 0000000000000000000 \nabc0\n",
+			contentType:   "text/plain",
+			isUpload:      true,
+			uploadNumbers: []entity.OrderNumber{"735584316112"},
+			storageResults: entity.OrderUploadResults{
+				{Number: "735584316112", Status: entity.UploadAcceptedOrder},
+			},
+
+			want: want{
+				statusCode: http.StatusOK,
+				outputBody: `[
+					{"number": "735584316112", "status": "ACCEPTED"},
+					{"number": "0000000000000000000", "status": "INVALID"},
+					{"number": "abc0", "status": "INVALID"}
+				]`,
+			},
+		},
+		{
+			name:        "only invalid numbers",
+			body:        "1234",
+			contentType: "text/plain",
+
+			want: want{
+				statusCode: http.StatusOK,
+				outputBody: `[{"number": "1234", "status": "INVALID"}]`,
+			},
+		},
+		{
+			name:          "deleted user",
+			body:          "735584316112",
+			isUpload:      true,
+			uploadNumbers: []entity.OrderNumber{"735584316112"},
+			uploadErr:     err_storage.ErrUserDeleted,
+
+			want: want{
+				statusCode: http.StatusForbidden,
+			},
+		},
+		{
+			name:          "storage error",
+			body:          "735584316112",
+			isUpload:      true,
+			uploadNumbers: []entity.OrderNumber{"735584316112"},
+			uploadErr:     fmt.Errorf("storage error"),
+
+			want: want{
+				statusCode: http.StatusInternalServerError,
+			},
+		},
+		{
+			name:        "invalid json",
+			body:        `["735584316112", 735584316112]`,
+			contentType: "application/json; charset=utf-8",
+
+			want: want{
+				statusCode: http.StatusBadRequest,
+			},
+		},
+		{
+			name: "empty batch",
+			body: "\n\n",
+
+			want: want{
+				statusCode: http.StatusBadRequest,
+			},
+		},
+		{
+			name: "batch too large",
+			body: strings.Repeat("735584316112\n", 1001),
+
+			want: want{
+				statusCode: http.StatusRequestEntityTooLarge,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPost, "/api/user/orders/batch", strings.NewReader(test.body))
+			request.Header.Set("Content-Type", test.contentType)
+			request = request.WithContext(context.WithValue(request.Context(), entity.UserIDCtxKey{}, userIDCtx))
+
+			if test.isUpload {
+				orderProcessor.EXPECT().UploadOrders(gomock.Any(), userIDCtx.UserID, test.uploadNumbers).Return(test.storageResults, test.uploadErr)
+			} else {
+				orderProcessor.EXPECT().UploadOrders(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			}
+
+			orderProcessor.EXPECT().GetOrdersForUpdate(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes()
 
-			res := writer.Result()
+			orders := New(orderProcessor, accrualClient, Config())
+			handler := orders.UploadOrdersBatch()
+			res := openapitest.Serve(t, handler, request)
 
 			assert.Equal(t, test.want.statusCode, res.StatusCode)
 
 			if len(test.want.outputBody) != 0 {
 				bodyResult, err := io.ReadAll(res.Body)
 				require.NoError(t, err)
-				assert.Equal(t, test.want.outputBody, strings.TrimSuffix(string(bodyResult), "\n"))
+				assert.JSONEq(t, test.want.outputBody, string(bodyResult))
 			}
 
 			err := res.Body.Close()
@@ -246,6 +471,7 @@ func TestGetUserOrders(t *testing.T) {
 	defer ctrl.Finish()
 
 	orderProcessor := mock.NewMockOrderProcessor(ctrl)
+	accrualClient := accrual_mock.NewMockAccrualClient(ctrl)
 
 	outputCorrect := strings.TrimSpace(`
 	[
@@ -300,7 +526,6 @@ func TestGetUserOrders(t *testing.T) {
 		isGetOrders     bool
 		isUpdateAccrual bool
 		isContext       bool
-		isJSONBody      bool
 		accrualCount    int
 		dbOutput        entity.Orders
 		userIDCtx       entity.UserIDCtx
@@ -313,7 +538,6 @@ func TestGetUserOrders(t *testing.T) {
 			isGetOrders:     true,
 			isUpdateAccrual: true,
 			isContext:       true,
-			isJSONBody:      true,
 			accrualCount:    3,
 			dbOutput:        correctDBOutput,
 			userIDCtx: entity.UserIDCtx{
@@ -376,7 +600,7 @@ func TestGetUserOrders(t *testing.T) {
 
 			want: want{
 				statusCode: http.StatusUnauthorized,
-				outputBody: ErrInvalidAuth,
+				outputBody: outputInvalidAuth,
 			},
 		},
 		{
@@ -391,7 +615,7 @@ func TestGetUserOrders(t *testing.T) {
 
 			want: want{
 				statusCode: http.StatusUnauthorized,
-				outputBody: ErrTokenExpired,
+				outputBody: outputTokenExpired,
 			},
 		},
 		{
@@ -406,7 +630,7 @@ func TestGetUserOrders(t *testing.T) {
 
 			want: want{
 				statusCode: http.StatusUnauthorized,
-				outputBody: ErrInvalidAuth,
+				outputBody: outputInvalidAuth,
 			},
 		},
 	}
@@ -414,36 +638,217 @@ func TestGetUserOrders(t *testing.T) {
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			request := httptest.NewRequest(http.MethodGet, "/api/user/orders", nil)
-			writer := httptest.NewRecorder()
 
 			if test.isContext {
 				request = request.WithContext(context.WithValue(request.Context(), entity.UserIDCtxKey{}, test.userIDCtx))
 			}
 
 			if test.isGetOrders {
-				orderProcessor.EXPECT().GetUserOrders(gomock.Any(), gomock.Any()).Return(test.dbOutput, test.storageErr)
+				orderProcessor.EXPECT().GetUserOrders(gomock.Any(), gomock.Any(), gomock.Any()).Return(test.dbOutput, test.storageErr)
 			} else {
-				orderProcessor.EXPECT().GetUserOrders(gomock.Any(), gomock.Any()).Times(0)
+				orderProcessor.EXPECT().GetUserOrders(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
 			}
 
 			orderProcessor.EXPECT().GetOrdersForUpdate(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes()
 
-			orders := New(orderProcessor, Config())
+			orders := New(orderProcessor, accrualClient, Config())
 			handler := orders.GetUserOrders()
-			handler(writer, request)
-
-			res := writer.Result()
+			res := openapitest.Serve(t, handler, request)
 
 			assert.Equal(t, test.want.statusCode, res.StatusCode)
 
 			if len(test.want.outputBody) != 0 {
 				bodyResult, err := io.ReadAll(res.Body)
 				require.NoError(t, err)
-				if test.isJSONBody {
-					assert.JSONEq(t, test.want.outputBody, strings.TrimSuffix(string(bodyResult), "\n"))
-				} else {
-					assert.Equal(t, test.want.outputBody, strings.TrimSuffix(string(bodyResult), "\n"))
-				}
+				assert.JSONEq(t, test.want.outputBody, string(bodyResult))
+			}
+
+			err := res.Body.Close()
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestGetUserOrdersPage(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	orderProcessor := mock.NewMockOrderProcessor(ctrl)
+	accrualClient := accrual_mock.NewMockAccrualClient(ctrl)
+
+	dbOutput := entity.Orders{
+		{
+			Number:      entity.OrderNumber("735584316112"),
+			Status:      "NEW",
+			DateCreated: "2024-04-16T09:40:29.841538Z",
+		},
+		{
+			Number:      entity.OrderNumber("527652728124"),
+			Status:      "PROCESSED",
+			Accrual:     50000,
+			DateCreated: "2024-04-19T08:56:43.208729Z",
+		},
+		{
+			Number:      entity.OrderNumber("044606165247"),
+			Status:      "NEW",
+			DateCreated: "2024-04-19T08:58:34.616336Z",
+		},
+	}
+
+	cursorToken := "MjAyNC0wNC0xOVQwODo1Njo0My4yMDg3MjlafDUyNzY1MjcyODEyNA"
+	cursor := entity.Cursor{
+		Date: time.Date(2024, 4, 19, 8, 56, 43, 208729000, time.UTC),
+		Key:  "527652728124",
+	}
+
+	type want struct {
+		statusCode int
+		count      int
+		link       string
+	}
+	tests := []struct {
+		name        string
+		target      string
+		isGetOrders bool
+		query       entity.ListQuery
+
+		want want
+	}{
+		{
+			name:        "default query",
+			target:      "/api/user/orders",
+			isGetOrders: true,
+			query: entity.ListQuery{
+				Limit: 101,
+				Sort:  entity.SortDesc,
+			},
+
+			want: want{
+				statusCode: http.StatusOK,
+				count:      3,
+			},
+		},
+		{
+			name:        "page with next link",
+			target:      "/api/user/orders?limit=2&status=new,processed&from=2024-04-16T00:00:00Z&to=2024-04-20T00:00:00Z&sort=asc",
+			isGetOrders: true,
+			query: entity.ListQuery{
+				Limit:    3,
+				Statuses: []entity.OrderStatus{entity.StatusNewOrder, entity.StatusProcessedOrder},
+				From:     time.Date(2024, 4, 16, 0, 0, 0, 0, time.UTC),
+				To:       time.Date(2024, 4, 20, 0, 0, 0, 0, time.UTC),
+				Sort:     entity.SortAsc,
+			},
+
+			want: want{
+				statusCode: http.StatusOK,
+				count:      2,
+				link:       `</api/user/orders?cursor=` + cursorToken + `&from=2024-04-16T00%3A00%3A00Z&limit=2&sort=asc&status=new%2Cprocessed&to=2024-04-20T00%3A00%3A00Z>; rel="next"`,
+			},
+		},
+		{
+			name:        "page by cursor",
+			target:      "/api/user/orders?limit=3&cursor=" + cursorToken,
+			isGetOrders: true,
+			query: entity.ListQuery{
+				Limit:  4,
+				Cursor: &cursor,
+				Sort:   entity.SortDesc,
+			},
+
+			want: want{
+				statusCode: http.StatusOK,
+				count:      3,
+			},
+		},
+		{
+			name:   "zero limit",
+			target: "/api/user/orders?limit=0",
+
+			want: want{
+				statusCode: http.StatusBadRequest,
+			},
+		},
+		{
+			name:   "limit exceeds maximum",
+			target: "/api/user/orders?limit=1001",
+
+			want: want{
+				statusCode: http.StatusBadRequest,
+			},
+		},
+		{
+			name:   "invalid cursor",
+			target: "/api/user/orders?cursor=invalid",
+
+			want: want{
+				statusCode: http.StatusBadRequest,
+			},
+		},
+		{
+			name:   "invalid status",
+			target: "/api/user/orders?status=NEW,REGISTERED",
+
+			want: want{
+				statusCode: http.StatusBadRequest,
+			},
+		},
+		{
+			name:   "invalid date",
+			target: "/api/user/orders?from=2024-04-16",
+
+			want: want{
+				statusCode: http.StatusBadRequest,
+			},
+		},
+		{
+			name:   "empty date range",
+			target: "/api/user/orders?from=2024-04-20T00:00:00Z&to=2024-04-16T00:00:00Z",
+
+			want: want{
+				statusCode: http.StatusBadRequest,
+			},
+		},
+		{
+			name:   "invalid sort",
+			target: "/api/user/orders?sort=random",
+
+			want: want{
+				statusCode: http.StatusBadRequest,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, test.target, nil)
+
+			userIDCtx := entity.UserIDCtx{
+				UserID:     "ac2a4811-4f10-487f-bde3-e39a14af7cd8",
+				StatusCode: http.StatusOK,
+			}
+			request = request.WithContext(context.WithValue(request.Context(), entity.UserIDCtxKey{}, userIDCtx))
+
+			if test.isGetOrders {
+				orderProcessor.EXPECT().GetUserOrders(gomock.Any(), gomock.Any(), test.query).Return(dbOutput, nil)
+			} else {
+				orderProcessor.EXPECT().GetUserOrders(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			}
+
+			orderProcessor.EXPECT().GetOrdersForUpdate(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes()
+
+			orders := New(orderProcessor, accrualClient, Config())
+			handler := orders.GetUserOrders()
+			res := openapitest.Serve(t, handler, request)
+
+			assert.Equal(t, test.want.statusCode, res.StatusCode)
+			assert.Equal(t, test.want.link, res.Header.Get("Link"))
+
+			if test.want.count != 0 {
+				var outOrders []map[string]any
+				err := json.NewDecoder(res.Body).Decode(&outOrders)
+				require.NoError(t, err)
+				assert.Len(t, outOrders, test.want.count)
 			}
 
 			err := res.Body.Close()
@@ -457,6 +862,7 @@ func TestGetUserBalance(t *testing.T) {
 	defer ctrl.Finish()
 
 	orderProcessor := mock.NewMockOrderProcessor(ctrl)
+	accrualClient := accrual_mock.NewMockAccrualClient(ctrl)
 
 	outputCorrect := strings.TrimSpace(`
 	{
@@ -466,8 +872,8 @@ func TestGetUserBalance(t *testing.T) {
 
 	correctDBOutput := entity.UserBalance{
 		UserID:      "ac2a4811-4f10-487f-bde3-e39a14af7cd8",
-		Balance:     600.20,
-		Withdrawans: 350.80,
+		Balance:     money.Points(60020),
+		Withdrawans: money.Points(35080),
 	}
 
 	type want struct {
@@ -480,7 +886,6 @@ func TestGetUserBalance(t *testing.T) {
 		storageErr   error
 		isGetBalance bool
 		isContext    bool
-		isJSONBody   bool
 		dbOutput     entity.UserBalance
 		userIDCtx    entity.UserIDCtx
 
@@ -492,7 +897,6 @@ func TestGetUserBalance(t *testing.T) {
 			storageErr:   nil,
 			isGetBalance: true,
 			isContext:    true,
-			isJSONBody:   true,
 			dbOutput:     correctDBOutput,
 			userIDCtx: entity.UserIDCtx{
 				UserID:     "ac2a4811-4f10-487f-bde3-e39a14af7cd8",
@@ -540,7 +944,7 @@ func TestGetUserBalance(t *testing.T) {
 
 			want: want{
 				statusCode: http.StatusUnauthorized,
-				outputBody: ErrInvalidAuth,
+				outputBody: outputInvalidAuth,
 			},
 		},
 		{
@@ -555,7 +959,7 @@ func TestGetUserBalance(t *testing.T) {
 
 			want: want{
 				statusCode: http.StatusUnauthorized,
-				outputBody: ErrTokenExpired,
+				outputBody: outputTokenExpired,
 			},
 		},
 		{
@@ -570,7 +974,7 @@ func TestGetUserBalance(t *testing.T) {
 
 			want: want{
 				statusCode: http.StatusUnauthorized,
-				outputBody: ErrInvalidAuth,
+				outputBody: outputInvalidAuth,
 			},
 		},
 	}
@@ -578,7 +982,6 @@ func TestGetUserBalance(t *testing.T) {
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			request := httptest.NewRequest(http.MethodGet, "/api/user/balance", nil)
-			writer := httptest.NewRecorder()
 
 			if test.isContext {
 				request = request.WithContext(context.WithValue(request.Context(), entity.UserIDCtxKey{}, test.userIDCtx))
@@ -592,22 +995,16 @@ func TestGetUserBalance(t *testing.T) {
 
 			orderProcessor.EXPECT().GetOrdersForUpdate(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes()
 
-			orders := New(orderProcessor, Config())
+			orders := New(orderProcessor, accrualClient, Config())
 			handler := orders.GetUserBalance()
-			handler(writer, request)
-
-			res := writer.Result()
+			res := openapitest.Serve(t, handler, request)
 
 			assert.Equal(t, test.want.statusCode, res.StatusCode)
 
 			if len(test.want.outputBody) != 0 {
 				bodyResult, err := io.ReadAll(res.Body)
 				require.NoError(t, err)
-				if test.isJSONBody {
-					assert.JSONEq(t, test.want.outputBody, strings.TrimSuffix(string(bodyResult), "\n"))
-				} else {
-					assert.Equal(t, test.want.outputBody, strings.TrimSuffix(string(bodyResult), "\n"))
-				}
+				assert.JSONEq(t, test.want.outputBody, string(bodyResult))
 			}
 
 			err := res.Body.Close()
@@ -616,25 +1013,171 @@ func TestGetUserBalance(t *testing.T) {
 	}
 }
 
-func TestWithdrawBonuses(t *testing.T) {
+func TestGetUserBalanceHistory(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
 	orderProcessor := mock.NewMockOrderProcessor(ctrl)
+	accrualClient := accrual_mock.NewMockAccrualClient(ctrl)
 
-	inputCorrect := strings.TrimSpace(`
-	{
-		"order": "221488416308",
-		"sum": 751
-	}`)
+	outputCorrect := strings.TrimSpace(`
+	[
+		{
+			"type": "ACCRUAL",
+			"order": "735584316112",
+			"amount": 500,
+			"processed_at": "2024-04-16T12:40:29+03:00"
+		},
+		{
+			"type": "ADJUSTMENT",
+			"amount": -100.5,
+			"reason": "FRAUD_REVERSAL",
+			"comment": "chargeback",
+			"processed_at": "2024-04-19T11:56:43+03:00"
+		}
+	]`)
 
-	inputIncorrect := strings.TrimSpace(`
-	{
-		"order": "1",
-		"sum": 751
-	}`)
+	correctDBOutput := entity.LedgerEntries{
+		{
+			Kind:        entity.LedgerEntryAccrual,
+			OrderNumber: entity.OrderNumber("735584316112"),
+			Amount:      money.Points(50000),
+			DateCreated: "2024-04-16T09:40:29.841538Z",
+		},
+		{
+			Kind:        entity.LedgerEntryAdjustment,
+			Amount:      money.Points(-10050),
+			Reason:      entity.AdjustmentReasonFraudReversal,
+			Comment:     "chargeback",
+			DateCreated: "2024-04-19T08:56:43.841538Z",
+		},
+	}
 
-	type want struct {
+	validUserIDCtx := entity.UserIDCtx{
+		UserID:     "ac2a4811-4f10-487f-bde3-e39a14af7cd8",
+		StatusCode: http.StatusOK,
+	}
+
+	type want struct {
+		statusCode int
+		outputBody string
+	}
+	tests := []struct {
+		name         string
+		storageErr   error
+		isGetHistory bool
+		dbOutput     entity.LedgerEntries
+		userIDCtx    entity.UserIDCtx
+
+		want want
+	}{
+		{
+			name:         "get correct history",
+			isGetHistory: true,
+			dbOutput:     correctDBOutput,
+			userIDCtx:    validUserIDCtx,
+
+			want: want{
+				statusCode: http.StatusOK,
+				outputBody: outputCorrect,
+			},
+		},
+		{
+			name:         "empty history",
+			storageErr:   err_storage.ErrLedgerForUserNotFound,
+			isGetHistory: true,
+			userIDCtx:    validUserIDCtx,
+
+			want: want{
+				statusCode: http.StatusNoContent,
+			},
+		},
+		{
+			name:         "storage error",
+			storageErr:   fmt.Errorf("storage error"),
+			isGetHistory: true,
+			userIDCtx:    validUserIDCtx,
+
+			want: want{
+				statusCode: http.StatusInternalServerError,
+			},
+		},
+		{
+			name:         "token has expired",
+			isGetHistory: false,
+			userIDCtx: entity.UserIDCtx{
+				StatusCode: http.StatusUnauthorized,
+			},
+
+			want: want{
+				statusCode: http.StatusUnauthorized,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/api/user/balance/history", nil)
+			request = request.WithContext(context.WithValue(request.Context(), entity.UserIDCtxKey{}, test.userIDCtx))
+
+			if test.isGetHistory {
+				orderProcessor.EXPECT().GetUserLedger(gomock.Any(), test.userIDCtx.UserID).Return(test.dbOutput, test.storageErr)
+			} else {
+				orderProcessor.EXPECT().GetUserLedger(gomock.Any(), gomock.Any()).Times(0)
+			}
+
+			orderProcessor.EXPECT().GetOrdersForUpdate(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes()
+
+			orders := New(orderProcessor, accrualClient, Config())
+			handler := orders.GetUserBalanceHistory()
+			res := openapitest.Serve(t, handler, request)
+
+			assert.Equal(t, test.want.statusCode, res.StatusCode)
+
+			if len(test.want.outputBody) != 0 {
+				bodyResult, err := io.ReadAll(res.Body)
+				require.NoError(t, err)
+				assert.JSONEq(t, test.want.outputBody, string(bodyResult))
+			}
+
+			err := res.Body.Close()
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestWithdrawBonuses(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	orderProcessor := mock.NewMockOrderProcessor(ctrl)
+	accrualClient := accrual_mock.NewMockAccrualClient(ctrl)
+
+	inputCorrect := strings.TrimSpace(`
+	{
+		"order": "221488416308",
+		"sum": 751
+	}`)
+
+	inputIncorrect := strings.TrimSpace(`
+	{
+		"order": "1",
+		"sum": 751
+	}`)
+
+	inputInvalidPrecision := strings.TrimSpace(`
+	{
+		"order": "221488416308",
+		"sum": 751.123
+	}`)
+
+	inputNegativeSum := strings.TrimSpace(`
+	{
+		"order": "221488416308",
+		"sum": -751
+	}`)
+
+	type want struct {
 		statusCode int
 		outputBody string
 	}
@@ -678,6 +1221,36 @@ func TestWithdrawBonuses(t *testing.T) {
 				statusCode: http.StatusUnprocessableEntity,
 			},
 		},
+		{
+			name:              "invalid sum precision",
+			storageErr:        nil,
+			isWithdrawBonuses: false,
+			isContext:         true,
+			body:              strings.NewReader(inputInvalidPrecision),
+			userIDCtx: entity.UserIDCtx{
+				UserID:     "ac2a4811-4f10-487f-bde3-e39a14af7cd8",
+				StatusCode: http.StatusOK,
+			},
+
+			want: want{
+				statusCode: http.StatusUnprocessableEntity,
+			},
+		},
+		{
+			name:              "negative sum",
+			storageErr:        nil,
+			isWithdrawBonuses: false,
+			isContext:         true,
+			body:              strings.NewReader(inputNegativeSum),
+			userIDCtx: entity.UserIDCtx{
+				UserID:     "ac2a4811-4f10-487f-bde3-e39a14af7cd8",
+				StatusCode: http.StatusOK,
+			},
+
+			want: want{
+				statusCode: http.StatusUnprocessableEntity,
+			},
+		},
 		{
 			name:              "invalid JSON",
 			storageErr:        nil,
@@ -723,6 +1296,21 @@ func TestWithdrawBonuses(t *testing.T) {
 				statusCode: http.StatusPaymentRequired,
 			},
 		},
+		{
+			name:              "deleted user",
+			storageErr:        err_storage.ErrUserDeleted,
+			isWithdrawBonuses: true,
+			isContext:         true,
+			body:              strings.NewReader(inputCorrect),
+			userIDCtx: entity.UserIDCtx{
+				UserID:     "ac2a4811-4f10-487f-bde3-e39a14af7cd8",
+				StatusCode: http.StatusOK,
+			},
+
+			want: want{
+				statusCode: http.StatusForbidden,
+			},
+		},
 		{
 			name:              "database error",
 			storageErr:        errors.New(""),
@@ -762,7 +1350,7 @@ func TestWithdrawBonuses(t *testing.T) {
 
 			want: want{
 				statusCode: http.StatusUnauthorized,
-				outputBody: ErrInvalidAuth,
+				outputBody: outputInvalidAuth,
 			},
 		},
 		{
@@ -778,7 +1366,7 @@ func TestWithdrawBonuses(t *testing.T) {
 
 			want: want{
 				statusCode: http.StatusUnauthorized,
-				outputBody: ErrTokenExpired,
+				outputBody: outputTokenExpired,
 			},
 		},
 		{
@@ -794,7 +1382,7 @@ func TestWithdrawBonuses(t *testing.T) {
 
 			want: want{
 				statusCode: http.StatusUnauthorized,
-				outputBody: ErrInvalidAuth,
+				outputBody: outputInvalidAuth,
 			},
 		},
 	}
@@ -802,7 +1390,6 @@ func TestWithdrawBonuses(t *testing.T) {
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			request := httptest.NewRequest(http.MethodPost, "/api/user/balance/withdraw", test.body)
-			writer := httptest.NewRecorder()
 
 			if test.isContext {
 				request = request.WithContext(context.WithValue(request.Context(), entity.UserIDCtxKey{}, test.userIDCtx))
@@ -816,18 +1403,250 @@ func TestWithdrawBonuses(t *testing.T) {
 
 			orderProcessor.EXPECT().GetOrdersForUpdate(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes()
 
-			orders := New(orderProcessor, Config())
+			orders := New(orderProcessor, accrualClient, Config())
 			handler := orders.WithdrawBonuses()
-			handler(writer, request)
+			res := openapitest.Serve(t, handler, request)
+
+			assert.Equal(t, test.want.statusCode, res.StatusCode)
+
+			if len(test.want.outputBody) != 0 {
+				bodyResult, err := io.ReadAll(res.Body)
+				require.NoError(t, err)
+				assert.JSONEq(t, test.want.outputBody, string(bodyResult))
+			}
+
+			err := res.Body.Close()
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestWithdrawBonusesIdempotent(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	orderProcessor := mock.NewMockOrderProcessor(ctrl)
+	accrualClient := accrual_mock.NewMockAccrualClient(ctrl)
+
+	inputCorrect := strings.TrimSpace(`
+	{
+		"order": "221488416308",
+		"sum": 751
+	}`)
+
+	requestHash := sha256.Sum256([]byte("221488416308:751"))
+	storedKey := entity.IdempotencyKey{
+		Key:         "a6d5a1b4-5ab6-4d3c-9a54-0f4c2e1f7a10",
+		RequestHash: hex.EncodeToString(requestHash[:]),
+		StatusCode:  http.StatusOK,
+	}
+
+	rejectedKey := entity.IdempotencyKey{
+		Key:         storedKey.Key,
+		RequestHash: storedKey.RequestHash,
+		StatusCode:  http.StatusPaymentRequired,
+		Body:        []byte(outputNotEnoughPoints),
+	}
+
+	type want struct {
+		statusCode int
+		outputBody string
+	}
+	tests := []struct {
+		name        string
+		key         string
+		getKeys     []entity.IdempotencyKey
+		getKeyErrs  []error
+		isWithdraw  bool
+		withdrawErr error
+		isStore     bool
+		storeErr    error
+
+		want want
+	}{
+		{
+			name:       "new idempotency key",
+			key:        storedKey.Key,
+			getKeys:    []entity.IdempotencyKey{{}},
+			getKeyErrs: []error{err_storage.ErrIdempotencyKeyNotFound},
+			isWithdraw: true,
+
+			want: want{
+				statusCode: http.StatusOK,
+			},
+		},
+		{
+			name:       "replay stored response",
+			key:        storedKey.Key,
+			getKeys:    []entity.IdempotencyKey{storedKey},
+			getKeyErrs: []error{nil},
+			isWithdraw: false,
+
+			want: want{
+				statusCode: http.StatusOK,
+			},
+		},
+		{
+			name: "key reused with different payload",
+			key:  storedKey.Key,
+			getKeys: []entity.IdempotencyKey{{
+				Key:         storedKey.Key,
+				RequestHash: "different",
+				StatusCode:  http.StatusOK,
+			}},
+			getKeyErrs: []error{nil},
+			isWithdraw: false,
+
+			want: want{
+				statusCode: http.StatusUnprocessableEntity,
+			},
+		},
+		{
+			name:        "key stored by concurrent request",
+			key:         storedKey.Key,
+			getKeys:     []entity.IdempotencyKey{{}, storedKey},
+			getKeyErrs:  []error{err_storage.ErrIdempotencyKeyNotFound, nil},
+			isWithdraw:  true,
+			withdrawErr: err_storage.ErrIdempotencyKeyExists,
+
+			want: want{
+				statusCode: http.StatusOK,
+			},
+		},
+		{
+			name:        "not enough money",
+			key:         storedKey.Key,
+			getKeys:     []entity.IdempotencyKey{{}},
+			getKeyErrs:  []error{err_storage.ErrIdempotencyKeyNotFound},
+			isWithdraw:  true,
+			withdrawErr: err_storage.ErrNotEnoughSum,
+			isStore:     true,
+
+			want: want{
+				statusCode: http.StatusPaymentRequired,
+				outputBody: outputNotEnoughPoints,
+			},
+		},
+		{
+			name:       "replay stored rejection",
+			key:        storedKey.Key,
+			getKeys:    []entity.IdempotencyKey{rejectedKey},
+			getKeyErrs: []error{nil},
+			isWithdraw: false,
+
+			want: want{
+				statusCode: http.StatusPaymentRequired,
+				outputBody: outputNotEnoughPoints,
+			},
+		},
+		{
+			name:        "rejection stored by concurrent request",
+			key:         storedKey.Key,
+			getKeys:     []entity.IdempotencyKey{{}, storedKey},
+			getKeyErrs:  []error{err_storage.ErrIdempotencyKeyNotFound, nil},
+			isWithdraw:  true,
+			withdrawErr: err_storage.ErrNotEnoughSum,
+			isStore:     true,
+			storeErr:    err_storage.ErrIdempotencyKeyExists,
+
+			want: want{
+				statusCode: http.StatusOK,
+			},
+		},
+		{
+			name:        "storage error while storing rejection",
+			key:         storedKey.Key,
+			getKeys:     []entity.IdempotencyKey{{}},
+			getKeyErrs:  []error{err_storage.ErrIdempotencyKeyNotFound},
+			isWithdraw:  true,
+			withdrawErr: err_storage.ErrNotEnoughSum,
+			isStore:     true,
+			storeErr:    errors.New(""),
+
+			want: want{
+				statusCode: http.StatusInternalServerError,
+			},
+		},
+		{
+			name:        "storage error while withdrawing",
+			key:         storedKey.Key,
+			getKeys:     []entity.IdempotencyKey{{}},
+			getKeyErrs:  []error{err_storage.ErrIdempotencyKeyNotFound},
+			isWithdraw:  true,
+			withdrawErr: errors.New(""),
+
+			want: want{
+				statusCode: http.StatusInternalServerError,
+			},
+		},
+		{
+			name:       "storage error while getting key",
+			key:        storedKey.Key,
+			getKeys:    []entity.IdempotencyKey{{}},
+			getKeyErrs: []error{errors.New("")},
+			isWithdraw: false,
+
+			want: want{
+				statusCode: http.StatusInternalServerError,
+			},
+		},
+		{
+			name:       "too long idempotency key",
+			key:        strings.Repeat("k", 256),
+			isWithdraw: false,
 
-			res := writer.Result()
+			want: want{
+				statusCode: http.StatusBadRequest,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPost, "/api/user/balance/withdraw", strings.NewReader(inputCorrect))
+			request.Header.Set(IdempotencyKeyHeader, test.key)
+
+			request = request.WithContext(context.WithValue(request.Context(), entity.UserIDCtxKey{}, entity.UserIDCtx{
+				UserID:     "ac2a4811-4f10-487f-bde3-e39a14af7cd8",
+				StatusCode: http.StatusOK,
+			}))
+
+			for i := range test.getKeys {
+				orderProcessor.EXPECT().GetIdempotencyKey(gomock.Any(), gomock.Any(), test.key).Return(test.getKeys[i], test.getKeyErrs[i])
+			}
+
+			if test.isWithdraw {
+				orderProcessor.EXPECT().WithdrawUserIdempotent(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(test.withdrawErr)
+			} else {
+				orderProcessor.EXPECT().WithdrawUserIdempotent(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			}
+
+			if test.isStore {
+				orderProcessor.EXPECT().StoreIdempotencyKey(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+					func(ctx context.Context, userID entity.UserID, key entity.IdempotencyKey) error {
+						assert.Equal(t, rejectedKey.StatusCode, key.StatusCode)
+						assert.JSONEq(t, string(rejectedKey.Body), string(key.Body))
+						return test.storeErr
+					})
+			} else {
+				orderProcessor.EXPECT().StoreIdempotencyKey(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			}
+
+			orderProcessor.EXPECT().WithdrawUser(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			orderProcessor.EXPECT().GetOrdersForUpdate(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes()
+
+			orders := New(orderProcessor, accrualClient, Config())
+			handler := orders.WithdrawBonuses()
+			res := openapitest.Serve(t, handler, request)
 
 			assert.Equal(t, test.want.statusCode, res.StatusCode)
 
 			if len(test.want.outputBody) != 0 {
+				assert.Equal(t, problem.ContentType, res.Header.Get("Content-Type"))
+
 				bodyResult, err := io.ReadAll(res.Body)
 				require.NoError(t, err)
-				assert.Equal(t, test.want.outputBody, strings.TrimSuffix(string(bodyResult), "\n"))
+				assert.JSONEq(t, test.want.outputBody, string(bodyResult))
 			}
 
 			err := res.Body.Close()
@@ -841,6 +1660,7 @@ func TestGetUserWithdrawals(t *testing.T) {
 	defer ctrl.Finish()
 
 	orderProcessor := mock.NewMockOrderProcessor(ctrl)
+	accrualClient := accrual_mock.NewMockAccrualClient(ctrl)
 
 	outputCorrect := strings.TrimSpace(`
 	[
@@ -864,17 +1684,17 @@ func TestGetUserWithdrawals(t *testing.T) {
 	dbOutputCorrect := entity.Withdrawals{
 		{
 			OrderNumber: "374311367329",
-			Sum:         100,
+			Sum:         money.Points(10000),
 			DateCreated: "2024-04-30T18:32:05.187329Z",
 		},
 		{
 			OrderNumber: "475622844086",
-			Sum:         50,
+			Sum:         money.Points(5000),
 			DateCreated: "2024-04-30T18:32:18.574718Z",
 		},
 		{
 			OrderNumber: "221488416308",
-			Sum:         50,
+			Sum:         money.Points(5000),
 			DateCreated: "2024-04-30T18:32:23.250438Z",
 		},
 	}
@@ -888,7 +1708,6 @@ func TestGetUserWithdrawals(t *testing.T) {
 		storageErr           error
 		isGetUserWithdrawals bool
 		isContext            bool
-		isJSONBody           bool
 		dbOutput             entity.Withdrawals
 		userIDCtx            entity.UserIDCtx
 
@@ -899,7 +1718,6 @@ func TestGetUserWithdrawals(t *testing.T) {
 			storageErr:           nil,
 			isGetUserWithdrawals: true,
 			isContext:            true,
-			isJSONBody:           true,
 			dbOutput:             dbOutputCorrect,
 			userIDCtx: entity.UserIDCtx{
 				UserID:     "ac2a4811-4f10-487f-bde3-e39a14af7cd8",
@@ -963,7 +1781,7 @@ func TestGetUserWithdrawals(t *testing.T) {
 
 			want: want{
 				statusCode: http.StatusUnauthorized,
-				outputBody: ErrInvalidAuth,
+				outputBody: outputInvalidAuth,
 			},
 		},
 		{
@@ -978,7 +1796,7 @@ func TestGetUserWithdrawals(t *testing.T) {
 
 			want: want{
 				statusCode: http.StatusUnauthorized,
-				outputBody: ErrTokenExpired,
+				outputBody: outputTokenExpired,
 			},
 		},
 		{
@@ -993,7 +1811,7 @@ func TestGetUserWithdrawals(t *testing.T) {
 
 			want: want{
 				statusCode: http.StatusUnauthorized,
-				outputBody: ErrInvalidAuth,
+				outputBody: outputInvalidAuth,
 			},
 		},
 	}
@@ -1001,39 +1819,218 @@ func TestGetUserWithdrawals(t *testing.T) {
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			request := httptest.NewRequest(http.MethodGet, "/api/user/withdrawals", nil)
-			writer := httptest.NewRecorder()
 
 			if test.isContext {
 				request = request.WithContext(context.WithValue(request.Context(), entity.UserIDCtxKey{}, test.userIDCtx))
 			}
 
 			if test.isGetUserWithdrawals {
-				orderProcessor.EXPECT().GetUserWithdrawals(gomock.Any(), gomock.Any()).Return(test.dbOutput, test.storageErr)
+				orderProcessor.EXPECT().GetUserWithdrawals(gomock.Any(), gomock.Any(), gomock.Any()).Return(test.dbOutput, test.storageErr)
 			} else {
-				orderProcessor.EXPECT().GetUserWithdrawals(gomock.Any(), gomock.Any()).Times(0)
+				orderProcessor.EXPECT().GetUserWithdrawals(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
 			}
 
 			orderProcessor.EXPECT().GetOrdersForUpdate(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes()
 
-			orders := New(orderProcessor, Config())
+			orders := New(orderProcessor, accrualClient, Config())
 			handler := orders.GetUserWithdrawals()
-			handler(writer, request)
-
-			res := writer.Result()
+			res := openapitest.Serve(t, handler, request)
 
 			assert.Equal(t, test.want.statusCode, res.StatusCode)
 
 			if len(test.want.outputBody) != 0 {
 				bodyResult, err := io.ReadAll(res.Body)
 				require.NoError(t, err)
-				if test.isJSONBody {
-					assert.JSONEq(t, test.want.outputBody, strings.TrimSuffix(string(bodyResult), "\n"))
+				assert.JSONEq(t, test.want.outputBody, string(bodyResult))
+			}
+
+			err := res.Body.Close()
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestStreamOrderEvents(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	orderProcessor := mock.NewMockOrderProcessor(ctrl)
+	accrualClient := accrual_mock.NewMockAccrualClient(ctrl)
+
+	outputMissed := "retry: 3000\n\n" +
+		"id: 2\nevent: order\ndata: {\"number\":\"735584316112\",\"status\":\"PROCESSING\",\"accrual\":0,\"updated_at\":\"2024-04-16T12:40:29+03:00\"}\n\n" +
+		"id: 3\nevent: order\ndata: {\"number\":\"735584316112\",\"status\":\"PROCESSED\",\"accrual\":500,\"updated_at\":\"2024-04-16T12:41:29+03:00\"}\n\n"
+
+	missedDBOutput := entity.OrderEvents{
+		{
+			ID:          2,
+			Number:      entity.OrderNumber("735584316112"),
+			Status:      entity.StatusProcessingOrder,
+			DateCreated: "2024-04-16T09:40:29.841538Z",
+		},
+		{
+			ID:          3,
+			Number:      entity.OrderNumber("735584316112"),
+			Status:      entity.StatusProcessedOrder,
+			Accrual:     money.Points(50000),
+			DateCreated: "2024-04-16T09:41:29.841538Z",
+		},
+	}
+
+	validUserIDCtx := entity.UserIDCtx{
+		UserID:     "ac2a4811-4f10-487f-bde3-e39a14af7cd8",
+		StatusCode: http.StatusOK,
+	}
+
+	listen := func(ctx context.Context) (<-chan entity.OrderEvent, error) {
+		events := make(chan entity.OrderEvent)
+		go func() {
+			<-ctx.Done()
+			close(events)
+		}()
+
+		return events, nil
+	}
+
+	type want struct {
+		statusCode  int
+		contentType string
+		outputBody  string
+	}
+	tests := []struct {
+		name        string
+		lastEventID string
+		listenErr   error
+		storageErr  error
+		isListen    bool
+		isGetMissed bool
+		dbOutput    entity.OrderEvents
+		userIDCtx   entity.UserIDCtx
+
+		want want
+	}{
+		{
+			name:      "new stream",
+			isListen:  true,
+			userIDCtx: validUserIDCtx,
+
+			want: want{
+				statusCode:  http.StatusOK,
+				contentType: "text/event-stream",
+				outputBody:  "retry: 3000\n\n",
+			},
+		},
+		{
+			name:        "resumed stream",
+			lastEventID: "1",
+			isListen:    true,
+			isGetMissed: true,
+			dbOutput:    missedDBOutput,
+			userIDCtx:   validUserIDCtx,
+
+			want: want{
+				statusCode:  http.StatusOK,
+				contentType: "text/event-stream",
+				outputBody:  outputMissed,
+			},
+		},
+		{
+			name:        "invalid last event id",
+			lastEventID: "-1",
+			userIDCtx:   validUserIDCtx,
+
+			want: want{
+				statusCode:  http.StatusBadRequest,
+				contentType: "application/problem+json",
+			},
+		},
+		{
+			name:      "listen error",
+			listenErr: fmt.Errorf("storage error"),
+			isListen:  true,
+			userIDCtx: validUserIDCtx,
+
+			want: want{
+				statusCode:  http.StatusInternalServerError,
+				contentType: "application/problem+json",
+			},
+		},
+		{
+			name:        "missed events storage error",
+			lastEventID: "1",
+			storageErr:  fmt.Errorf("storage error"),
+			isListen:    true,
+			isGetMissed: true,
+			userIDCtx:   validUserIDCtx,
+
+			want: want{
+				statusCode:  http.StatusInternalServerError,
+				contentType: "application/problem+json",
+			},
+		},
+		{
+			name: "token has expired",
+			userIDCtx: entity.UserIDCtx{
+				StatusCode: http.StatusUnauthorized,
+			},
+
+			want: want{
+				statusCode:  http.StatusUnauthorized,
+				contentType: "application/problem+json",
+				outputBody:  outputTokenExpired,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// клиент уже отключился, поэтому поток завершается после
+			// отправки пропущенных событий
+			ctx, cancel := context.WithCancel(context.WithValue(context.Background(), entity.UserIDCtxKey{}, test.userIDCtx))
+			cancel()
+
+			request := httptest.NewRequest(http.MethodGet, "/api/user/orders/stream", nil).WithContext(ctx)
+			if len(test.lastEventID) != 0 {
+				request.Header.Set(LastEventIDHeader, test.lastEventID)
+			}
+
+			if test.isListen {
+				if test.listenErr != nil {
+					orderProcessor.EXPECT().ListenOrderEvents(gomock.Any()).Return(nil, test.listenErr)
 				} else {
-					assert.Equal(t, test.want.outputBody, strings.TrimSuffix(string(bodyResult), "\n"))
+					orderProcessor.EXPECT().ListenOrderEvents(gomock.Any()).DoAndReturn(listen)
 				}
+			} else {
+				orderProcessor.EXPECT().ListenOrderEvents(gomock.Any()).Times(0)
 			}
 
-			err := res.Body.Close()
+			if test.isGetMissed {
+				orderProcessor.EXPECT().GetOrderEvents(gomock.Any(), test.userIDCtx.UserID, int64(1)).Return(test.dbOutput, test.storageErr)
+			} else {
+				orderProcessor.EXPECT().GetOrderEvents(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			}
+
+			orderProcessor.EXPECT().GetOrdersForUpdate(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes()
+
+			orders := New(orderProcessor, accrualClient, Config())
+			defer orders.CloseStreams()
+
+			handler := orders.StreamOrderEvents()
+			res := openapitest.Serve(t, handler, request)
+
+			assert.Equal(t, test.want.statusCode, res.StatusCode)
+			assert.Equal(t, test.want.contentType, res.Header.Get("Content-Type"))
+
+			bodyResult, err := io.ReadAll(res.Body)
+			require.NoError(t, err)
+
+			if test.want.contentType == "text/event-stream" {
+				assert.Equal(t, test.want.outputBody, string(bodyResult))
+			} else if len(test.want.outputBody) != 0 {
+				assert.JSONEq(t, test.want.outputBody, string(bodyResult))
+			}
+
+			err = res.Body.Close()
 			require.NoError(t, err)
 		})
 	}
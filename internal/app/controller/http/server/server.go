@@ -8,11 +8,19 @@ import (
 	"syscall"
 
 	"github.com/avGenie/go-loyalty-system/internal/app/config"
+	"github.com/avGenie/go-loyalty-system/internal/app/controller/http/admin"
 	"github.com/avGenie/go-loyalty-system/internal/app/controller/http/auth"
+	"github.com/avGenie/go-loyalty-system/internal/app/controller/http/callback"
+	"github.com/avGenie/go-loyalty-system/internal/app/controller/http/health"
 	"github.com/avGenie/go-loyalty-system/internal/app/controller/http/middleware/logger"
+	"github.com/avGenie/go-loyalty-system/internal/app/controller/http/middleware/requestid"
 	"github.com/avGenie/go-loyalty-system/internal/app/controller/http/middleware/token"
+	"github.com/avGenie/go-loyalty-system/internal/app/controller/http/openapi"
 	"github.com/avGenie/go-loyalty-system/internal/app/controller/http/orders"
 	storage "github.com/avGenie/go-loyalty-system/internal/app/storage/api/model"
+	"github.com/avGenie/go-loyalty-system/internal/app/usecase/accrual"
+	"github.com/avGenie/go-loyalty-system/internal/app/usecase/problem"
+	"github.com/avGenie/go-loyalty-system/internal/app/validator"
 	"github.com/go-chi/chi/v5"
 	"go.uber.org/zap"
 )
@@ -25,18 +33,28 @@ type HTTPServer struct {
 
 	authenticator auth.AuthUser
 	orders        orders.Order
+	health        health.Health
+	callback      callback.Callback
+	admin         admin.Admin
 }
 
-func New(config config.Config, storage storage.Storage) *HTTPServer {
-	authenticator := auth.New(storage)
-	order := orders.New(storage, config)
+func New(config config.Config, storage storage.Storage, client accrual.AccrualClient, policy validator.CredentialsPolicy) *HTTPServer {
+	breaker := accrual.NewBreaker(client, config)
 
-	mux := createMux(authenticator, order)
+	authenticator := auth.New(storage, config, policy)
+	tokenParser := token.New(storage)
+	order := orders.New(storage, breaker, config)
+	health := health.New(breaker)
+	callback := callback.New(storage, config)
+	admin := admin.New(storage)
+
+	mux := createMux(tokenParser, authenticator, order, health, callback, admin)
 
 	server := &http.Server{
 		Addr:    config.NetAddr,
 		Handler: mux,
 	}
+	server.RegisterOnShutdown(order.CloseStreams)
 
 	instance := &HTTPServer{
 		server:        server,
@@ -44,6 +62,9 @@ func New(config config.Config, storage storage.Storage) *HTTPServer {
 		storage:       storage,
 		authenticator: authenticator,
 		orders:        order,
+		health:        health,
+		callback:      callback,
+		admin:         admin,
 	}
 
 	return instance
@@ -76,20 +97,51 @@ func (s *HTTPServer) stop() {
 	zap.L().Info("server has been stopped")
 }
 
-func createMux(authenticator auth.AuthUser, orders orders.Order) *chi.Mux {
+func createMux(tokenParser token.TokenParser, authenticator auth.AuthUser, orders orders.Order, health health.Health, callback callback.Callback, admin admin.Admin) *chi.Mux {
 	r := chi.NewRouter()
 
+	r.Use(requestid.RequestIDMiddleware)
 	r.Use(logger.LoggerMiddleware)
-	r.Use(token.TokenParserMiddleware)
+	r.Use(tokenParser.TokenParserMiddleware)
+
+	r.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		problem.WriteCode(w, problem.CodeNotFound, "")
+	})
+	r.MethodNotAllowed(func(w http.ResponseWriter, r *http.Request) {
+		problem.WriteCode(w, problem.CodeMethodNotAllowed, "")
+	})
 
 	r.Post("/api/user/register", authenticator.CreateUser())
 	r.Post("/api/user/login", authenticator.AuthenticateUser())
+	r.Post("/api/user/token/refresh", authenticator.RefreshToken())
+	r.Post("/api/user/logout", authenticator.Logout())
+	r.Put("/api/user/password", authenticator.ChangePassword())
+	r.Delete("/api/user", authenticator.DeleteUser())
 	r.Post("/api/user/orders", orders.UploadOrder())
+	r.Post("/api/user/orders/batch", orders.UploadOrdersBatch())
 	r.Post("/api/user/balance/withdraw", orders.WithdrawBonuses())
 
 	r.Get("/api/user/orders", orders.GetUserOrders())
+	r.Get("/api/user/orders/stream", orders.StreamOrderEvents())
 	r.Get("/api/user/withdrawals", orders.GetUserWithdrawals())
 	r.Get("/api/user/balance", orders.GetUserBalance())
+	r.Get("/api/user/balance/history", orders.GetUserBalanceHistory())
+	r.Get("/api/health", health.Check())
+	r.Get("/api/openapi.json", openapi.Handler())
+	r.Get("/.well-known/jwks.json", authenticator.GetJWKS())
+
+	r.Post("/internal/accrual/callback", callback.AccrualCallback())
+
+	r.Route("/api/admin", func(r chi.Router) {
+		r.Use(admin.AdminMiddleware)
+
+		r.Get("/users", admin.GetUser())
+		r.Get("/users/{userID}/orders", admin.GetUserOrders())
+		r.Get("/users/{userID}/withdrawals", admin.GetUserWithdrawals())
+		r.Get("/users/{userID}/balance", admin.GetUserBalance())
+		r.Post("/users/{userID}/balance/adjustments", admin.AdjustUserBalance())
+		r.Post("/orders/{number}/recheck", admin.RecheckOrder())
+	})
 
 	return r
 }
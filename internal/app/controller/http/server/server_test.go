@@ -0,0 +1,907 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/avGenie/go-loyalty-system/internal/app/config"
+	"github.com/avGenie/go-loyalty-system/internal/app/controller/http/admin"
+	"github.com/avGenie/go-loyalty-system/internal/app/controller/http/auth"
+	"github.com/avGenie/go-loyalty-system/internal/app/controller/http/callback"
+	"github.com/avGenie/go-loyalty-system/internal/app/controller/http/health"
+	"github.com/avGenie/go-loyalty-system/internal/app/controller/http/middleware/token"
+	"github.com/avGenie/go-loyalty-system/internal/app/controller/http/openapi"
+	"github.com/avGenie/go-loyalty-system/internal/app/controller/http/orders"
+	"github.com/avGenie/go-loyalty-system/internal/app/entity"
+	"github.com/avGenie/go-loyalty-system/internal/app/model"
+	"github.com/avGenie/go-loyalty-system/internal/app/money"
+	err_api "github.com/avGenie/go-loyalty-system/internal/app/storage/api/errors"
+	storage "github.com/avGenie/go-loyalty-system/internal/app/storage/memory"
+	"github.com/avGenie/go-loyalty-system/internal/app/usecase/accrual"
+	usecase "github.com/avGenie/go-loyalty-system/internal/app/usecase/converter"
+	"github.com/avGenie/go-loyalty-system/internal/app/usecase/crypto"
+	"github.com/avGenie/go-loyalty-system/internal/app/usecase/problem"
+	"github.com/avGenie/go-loyalty-system/internal/app/validator"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testRequest(t *testing.T, server *httptest.Server, method, path, token, body string) (*http.Response, string) {
+	request, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+	require.NoError(t, err)
+
+	if len(token) != 0 {
+		request.Header.Set(usecase.AuthHeader, token)
+	}
+
+	response, err := server.Client().Do(request)
+	require.NoError(t, err)
+	defer response.Body.Close()
+
+	responseBody, err := io.ReadAll(response.Body)
+	require.NoError(t, err)
+
+	return response, string(responseBody)
+}
+
+func testPolicy(t *testing.T, config config.Config) validator.CredentialsPolicy {
+	policy, err := validator.NewCredentialsPolicy(config)
+	require.NoError(t, err)
+
+	return policy
+}
+
+func TestMemoryStorageRouter(t *testing.T) {
+	memoryStorage := storage.NewMemoryStorage()
+
+	authenticator := auth.New(memoryStorage, config.Config{}, testPolicy(t, config.Config{}))
+	accrualClient, err := accrual.New(config.Config{})
+	require.NoError(t, err)
+	breaker := accrual.NewBreaker(accrualClient, config.Config{})
+
+	order := orders.New(memoryStorage, breaker, config.Config{})
+	defer order.Stop()
+
+	server := httptest.NewServer(createMux(token.New(memoryStorage), authenticator, order, health.New(breaker), callback.New(memoryStorage, config.Config{}), admin.New(memoryStorage)))
+	defer server.Close()
+
+	response, body := testRequest(t, server, http.MethodGet, "/api/health", "", "")
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.JSONEq(t, `{"status": "ok", "accrual": "closed"}`, body)
+
+	response, body = testRequest(t, server, http.MethodGet, "/.well-known/jwks.json", "", "")
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.JSONEq(t, `{"keys": []}`, body)
+
+	response, _ = testRequest(t, server, http.MethodPost, "/api/user/register", "", `{"login": "first", "password": "password"}`)
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	firstToken := response.Header.Get(usecase.AuthHeader)
+	require.NotEmpty(t, firstToken)
+
+	response, _ = testRequest(t, server, http.MethodPost, "/api/user/register", "", `{"login": "first", "password": "password"}`)
+	assert.Equal(t, http.StatusConflict, response.StatusCode)
+
+	response, body = testRequest(t, server, http.MethodPost, "/api/user/register", "", `{"login": "`+strings.Repeat("a", 51)+`", "password": "password"}`)
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+	assert.JSONEq(t, `{
+		"type": "urn:gophermart:problem:validation_failed",
+		"title": "Validation failed",
+		"status": 400,
+		"detail": "credentials violate the credentials policy",
+		"code": "validation_failed",
+		"request_id": "`+response.Header.Get(problem.RequestIDHeader)+`",
+		"errors": [{"field": "login", "rule": "login_length", "message": "login must be from 1 to 50 characters long"}]
+	}`, body)
+
+	response, _ = testRequest(t, server, http.MethodPost, "/api/user/login", "", `{"login": "first", "password": "wrong"}`)
+	assert.Equal(t, http.StatusUnauthorized, response.StatusCode)
+
+	response, _ = testRequest(t, server, http.MethodPost, "/api/user/register", "", `{"login": "second", "password": "password"}`)
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	secondToken := response.Header.Get(usecase.AuthHeader)
+
+	response, _ = testRequest(t, server, http.MethodPost, "/api/user/orders", firstToken, "735584316112")
+	assert.Equal(t, http.StatusAccepted, response.StatusCode)
+
+	response, _ = testRequest(t, server, http.MethodPost, "/api/user/orders", firstToken, "735584316112")
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+
+	response, _ = testRequest(t, server, http.MethodPost, "/api/user/orders", secondToken, "735584316112")
+	assert.Equal(t, http.StatusConflict, response.StatusCode)
+
+	response, _ = testRequest(t, server, http.MethodPost, "/api/user/orders", firstToken, "1234")
+	assert.Equal(t, http.StatusUnprocessableEntity, response.StatusCode)
+
+	response, body = testRequest(t, server, http.MethodGet, "/api/user/orders", firstToken, "")
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Contains(t, body, `"number":"735584316112"`)
+
+	response, _ = testRequest(t, server, http.MethodGet, "/api/user/orders", secondToken, "")
+	assert.Equal(t, http.StatusNoContent, response.StatusCode)
+
+	response, body = testRequest(t, server, http.MethodGet, "/api/user/balance", firstToken, "")
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.JSONEq(t, `{"current": 0, "withdrawn": 0}`, body)
+
+	response, _ = testRequest(t, server, http.MethodPost, "/api/user/balance/withdraw", firstToken, `{"order": "2377225624", "sum": 751}`)
+	assert.Equal(t, http.StatusPaymentRequired, response.StatusCode)
+
+	response, _ = testRequest(t, server, http.MethodGet, "/api/user/withdrawals", firstToken, "")
+	assert.Equal(t, http.StatusNoContent, response.StatusCode)
+
+	response, _ = testRequest(t, server, http.MethodGet, "/api/user/balance", "", "")
+	assert.Equal(t, http.StatusUnauthorized, response.StatusCode)
+}
+
+func TestIdempotentWithdrawRouter(t *testing.T) {
+	memoryStorage := storage.NewMemoryStorage()
+
+	accrualClient, err := accrual.New(config.Config{})
+	require.NoError(t, err)
+	breaker := accrual.NewBreaker(accrualClient, config.Config{})
+
+	order := orders.New(memoryStorage, breaker, config.Config{})
+	defer order.Stop()
+
+	server := httptest.NewServer(createMux(token.New(memoryStorage), auth.New(memoryStorage, config.Config{}, testPolicy(t, config.Config{})), order, health.New(breaker), callback.New(memoryStorage, config.Config{}), admin.New(memoryStorage)))
+	defer server.Close()
+
+	response, _ := testRequest(t, server, http.MethodPost, "/api/user/register", "", `{"login": "user", "password": "password"}`)
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	accessToken := response.Header.Get(usecase.AuthHeader)
+
+	withdraw := func() (*http.Response, string) {
+		request, err := http.NewRequest(http.MethodPost, server.URL+"/api/user/balance/withdraw", strings.NewReader(`{"order": "2377225624", "sum": 751}`))
+		require.NoError(t, err)
+		request.Header.Set(usecase.AuthHeader, accessToken)
+		request.Header.Set(orders.IdempotencyKeyHeader, "a6d5a1b4-5ab6-4d3c-9a54-0f4c2e1f7a10")
+
+		response, err := server.Client().Do(request)
+		require.NoError(t, err)
+		defer response.Body.Close()
+
+		body, err := io.ReadAll(response.Body)
+		require.NoError(t, err)
+
+		return response, string(body)
+	}
+
+	response, rejectedBody := withdraw()
+	require.Equal(t, http.StatusPaymentRequired, response.StatusCode)
+
+	response, _ = testRequest(t, server, http.MethodPost, "/api/user/orders", accessToken, "735584316112")
+	require.Equal(t, http.StatusAccepted, response.StatusCode)
+
+	userID, err := memoryStorage.GetOrderOwner(context.Background(), entity.OrderNumber("735584316112"))
+	require.NoError(t, err)
+
+	err = memoryStorage.UpdateOrders(context.Background(), entity.UpdateUserOrders{
+		{
+			UserID: userID,
+			Order: entity.Order{
+				Number:  entity.OrderNumber("735584316112"),
+				Status:  entity.StatusProcessedOrder,
+				Accrual: money.Points(100000),
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	// повтор с тем же ключом получает первый ответ, хотя баллов уже хватает
+	response, body := withdraw()
+	assert.Equal(t, http.StatusPaymentRequired, response.StatusCode)
+	assert.Equal(t, problem.ContentType, response.Header.Get("Content-Type"))
+	assert.JSONEq(t, rejectedBody, body)
+
+	response, body = testRequest(t, server, http.MethodGet, "/api/user/balance", accessToken, "")
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.JSONEq(t, `{"current": 1000, "withdrawn": 0}`, body)
+}
+
+func TestAccrualCallbackRouter(t *testing.T) {
+	memoryStorage := storage.NewMemoryStorage()
+	config := config.Config{
+		AccrualCallbackSecret: "secret",
+	}
+
+	accrualClient, err := accrual.New(config)
+	require.NoError(t, err)
+	breaker := accrual.NewBreaker(accrualClient, config)
+
+	order := orders.New(memoryStorage, breaker, config)
+	defer order.Stop()
+
+	server := httptest.NewServer(createMux(token.New(memoryStorage), auth.New(memoryStorage, config, testPolicy(t, config)), order, health.New(breaker), callback.New(memoryStorage, config), admin.New(memoryStorage)))
+	defer server.Close()
+
+	response, _ := testRequest(t, server, http.MethodPost, "/api/user/register", "", `{"login": "user", "password": "password"}`)
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	token := response.Header.Get(usecase.AuthHeader)
+
+	body := `{"order": "735584316112", "status": "PROCESSED", "accrual": 500}`
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	signature := crypto.SignPayload([]byte(config.AccrualCallbackSecret), timestamp, []byte(body))
+
+	sendSignedCallback := func(body, signature string) *http.Response {
+		request, err := http.NewRequest(http.MethodPost, server.URL+"/internal/accrual/callback", strings.NewReader(body))
+		require.NoError(t, err)
+		request.Header.Set(callback.TimestampHeader, timestamp)
+		request.Header.Set(callback.SignatureHeader, signature)
+
+		response, err := server.Client().Do(request)
+		require.NoError(t, err)
+		response.Body.Close()
+
+		return response
+	}
+	sendCallback := func() *http.Response {
+		return sendSignedCallback(body, signature)
+	}
+
+	// the failed callback must not use up the signature
+	assert.Equal(t, http.StatusNotFound, sendCallback().StatusCode)
+
+	response, _ = testRequest(t, server, http.MethodPost, "/api/user/orders", token, "735584316112")
+	require.Equal(t, http.StatusAccepted, response.StatusCode)
+
+	assert.Equal(t, http.StatusOK, sendCallback().StatusCode)
+	assert.Equal(t, http.StatusConflict, sendCallback().StatusCode)
+
+	// the processed order is already credited, so its status is final
+	invalidBody := `{"order": "735584316112", "status": "INVALID"}`
+	invalidSignature := crypto.SignPayload([]byte(config.AccrualCallbackSecret), timestamp, []byte(invalidBody))
+	assert.Equal(t, http.StatusConflict, sendSignedCallback(invalidBody, invalidSignature).StatusCode)
+
+	response, balance := testRequest(t, server, http.MethodGet, "/api/user/balance", token, "")
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.JSONEq(t, `{"current": 500, "withdrawn": 0}`, balance)
+
+	response, orders := testRequest(t, server, http.MethodGet, "/api/user/orders", token, "")
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Contains(t, orders, `"status":"PROCESSED"`)
+}
+
+func TestChangePasswordRouter(t *testing.T) {
+	memoryStorage := storage.NewMemoryStorage()
+
+	accrualClient, err := accrual.New(config.Config{})
+	require.NoError(t, err)
+	breaker := accrual.NewBreaker(accrualClient, config.Config{})
+
+	order := orders.New(memoryStorage, breaker, config.Config{})
+	defer order.Stop()
+
+	server := httptest.NewServer(createMux(token.New(memoryStorage), auth.New(memoryStorage, config.Config{}, testPolicy(t, config.Config{})), order, health.New(breaker), callback.New(memoryStorage, config.Config{}), admin.New(memoryStorage)))
+	defer server.Close()
+
+	response, body := testRequest(t, server, http.MethodPost, "/api/user/register", "", `{"login": "user", "password": "password"}`)
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	accessToken := response.Header.Get(usecase.AuthHeader)
+
+	var tokens model.TokenResponse
+	require.NoError(t, json.Unmarshal([]byte(body), &tokens))
+	refreshRequest := `{"refresh_token": "` + tokens.RefreshToken + `"}`
+
+	response, _ = testRequest(t, server, http.MethodPut, "/api/user/password", accessToken, `{"old_password": "password", "new_password": "new password"}`)
+	require.Equal(t, http.StatusOK, response.StatusCode)
+
+	// сессии со старым паролем нельзя продлить
+	response, _ = testRequest(t, server, http.MethodPost, "/api/user/token/refresh", "", refreshRequest)
+	assert.Equal(t, http.StatusUnauthorized, response.StatusCode)
+
+	wrongRequest := `{"old_password": "wrong", "new_password": "another password"}`
+	for i := 0; i < 4; i++ {
+		response, _ = testRequest(t, server, http.MethodPut, "/api/user/password", accessToken, wrongRequest)
+		require.Equal(t, http.StatusForbidden, response.StatusCode)
+	}
+
+	response, _ = testRequest(t, server, http.MethodPut, "/api/user/password", accessToken, wrongRequest)
+	assert.Equal(t, http.StatusTooManyRequests, response.StatusCode)
+
+	response, _ = testRequest(t, server, http.MethodPost, "/api/user/login", "", `{"login": "user", "password": "new password"}`)
+	assert.Equal(t, http.StatusTooManyRequests, response.StatusCode)
+}
+
+func TestRefreshTokenRouter(t *testing.T) {
+	memoryStorage := storage.NewMemoryStorage()
+
+	accrualClient, err := accrual.New(config.Config{})
+	require.NoError(t, err)
+	breaker := accrual.NewBreaker(accrualClient, config.Config{})
+
+	order := orders.New(memoryStorage, breaker, config.Config{})
+	defer order.Stop()
+
+	server := httptest.NewServer(createMux(token.New(memoryStorage), auth.New(memoryStorage, config.Config{}, testPolicy(t, config.Config{})), order, health.New(breaker), callback.New(memoryStorage, config.Config{}), admin.New(memoryStorage)))
+	defer server.Close()
+
+	response, body := testRequest(t, server, http.MethodPost, "/api/user/register", "", `{"login": "user", "password": "password"}`)
+	require.Equal(t, http.StatusOK, response.StatusCode)
+
+	var tokens model.TokenResponse
+	require.NoError(t, json.Unmarshal([]byte(body), &tokens))
+	require.NotEmpty(t, tokens.RefreshToken)
+
+	refreshRequest := `{"refresh_token": "` + tokens.RefreshToken + `"}`
+	response, body = testRequest(t, server, http.MethodPost, "/api/user/token/refresh", "", refreshRequest)
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	accessToken := response.Header.Get(usecase.AuthHeader)
+	require.NotEmpty(t, accessToken)
+
+	response, _ = testRequest(t, server, http.MethodPost, "/api/user/token/refresh", "", refreshRequest)
+	assert.Equal(t, http.StatusUnauthorized, response.StatusCode)
+
+	require.NoError(t, json.Unmarshal([]byte(body), &tokens))
+
+	response, _ = testRequest(t, server, http.MethodGet, "/api/user/balance", accessToken, "")
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+
+	response, _ = testRequest(t, server, http.MethodPost, "/api/user/logout", accessToken, "")
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+
+	response, _ = testRequest(t, server, http.MethodGet, "/api/user/balance", accessToken, "")
+	assert.Equal(t, http.StatusUnauthorized, response.StatusCode)
+
+	response, _ = testRequest(t, server, http.MethodPost, "/api/user/token/refresh", "", `{"refresh_token": "`+tokens.RefreshToken+`"}`)
+	assert.Equal(t, http.StatusUnauthorized, response.StatusCode)
+}
+
+func TestLoginLockoutRouter(t *testing.T) {
+	memoryStorage := storage.NewMemoryStorage()
+	config := config.Config{
+		LoginFreeAttempts: 5,
+		LoginMaxFailures:  3,
+		LoginLockout:      time.Minute,
+	}
+
+	accrualClient, err := accrual.New(config)
+	require.NoError(t, err)
+	breaker := accrual.NewBreaker(accrualClient, config)
+
+	order := orders.New(memoryStorage, breaker, config)
+	defer order.Stop()
+
+	server := httptest.NewServer(createMux(token.New(memoryStorage), auth.New(memoryStorage, config, testPolicy(t, config)), order, health.New(breaker), callback.New(memoryStorage, config), admin.New(memoryStorage)))
+	defer server.Close()
+
+	response, _ := testRequest(t, server, http.MethodPost, "/api/user/register", "", `{"login": "user", "password": "password"}`)
+	require.Equal(t, http.StatusOK, response.StatusCode)
+
+	for i := 0; i < config.LoginMaxFailures; i++ {
+		response, _ = testRequest(t, server, http.MethodPost, "/api/user/login", "", `{"login": "user", "password": "wrong"}`)
+		require.Equal(t, http.StatusUnauthorized, response.StatusCode)
+	}
+
+	response, _ = testRequest(t, server, http.MethodPost, "/api/user/login", "", `{"login": "user", "password": "password"}`)
+	assert.Equal(t, http.StatusTooManyRequests, response.StatusCode)
+	assert.Equal(t, "60", response.Header.Get("Retry-After"))
+}
+
+func TestDeleteUserRouter(t *testing.T) {
+	memoryStorage := storage.NewMemoryStorage()
+
+	accrualClient, err := accrual.New(config.Config{})
+	require.NoError(t, err)
+	breaker := accrual.NewBreaker(accrualClient, config.Config{})
+
+	order := orders.New(memoryStorage, breaker, config.Config{})
+	defer order.Stop()
+
+	server := httptest.NewServer(createMux(token.New(memoryStorage), auth.New(memoryStorage, config.Config{}, testPolicy(t, config.Config{})), order, health.New(breaker), callback.New(memoryStorage, config.Config{}), admin.New(memoryStorage)))
+	defer server.Close()
+
+	response, body := testRequest(t, server, http.MethodPost, "/api/user/register", "", `{"login": "user", "password": "password"}`)
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	accessToken := response.Header.Get(usecase.AuthHeader)
+
+	var tokens model.TokenResponse
+	require.NoError(t, json.Unmarshal([]byte(body), &tokens))
+
+	response, _ = testRequest(t, server, http.MethodPut, "/api/user/password", accessToken, `{"old_password": "wrong", "new_password": "new password"}`)
+	assert.Equal(t, http.StatusForbidden, response.StatusCode)
+
+	response, _ = testRequest(t, server, http.MethodPut, "/api/user/password", accessToken, `{"old_password": "password", "new_password": "new password"}`)
+	require.Equal(t, http.StatusOK, response.StatusCode)
+
+	response, _ = testRequest(t, server, http.MethodPost, "/api/user/login", "", `{"login": "user", "password": "password"}`)
+	assert.Equal(t, http.StatusUnauthorized, response.StatusCode)
+
+	response, _ = testRequest(t, server, http.MethodPost, "/api/user/login", "", `{"login": "user", "password": "new password"}`)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+
+	response, _ = testRequest(t, server, http.MethodDelete, "/api/user", accessToken, "")
+	require.Equal(t, http.StatusOK, response.StatusCode)
+
+	response, _ = testRequest(t, server, http.MethodPost, "/api/user/orders", accessToken, "735584316112")
+	assert.Equal(t, http.StatusUnauthorized, response.StatusCode)
+
+	response, _ = testRequest(t, server, http.MethodPost, "/api/user/token/refresh", "", `{"refresh_token": "`+tokens.RefreshToken+`"}`)
+	assert.Equal(t, http.StatusUnauthorized, response.StatusCode)
+
+	response, _ = testRequest(t, server, http.MethodPost, "/api/user/login", "", `{"login": "user", "password": "new password"}`)
+	assert.Equal(t, http.StatusUnauthorized, response.StatusCode)
+
+	_, err = memoryStorage.UploadOrder(context.Background(), entity.UserID("unknown"), entity.OrderNumber("735584316112"))
+	assert.ErrorIs(t, err, err_api.ErrUserNotFoundTable)
+}
+
+func TestOrdersPageRouter(t *testing.T) {
+	memoryStorage := storage.NewMemoryStorage()
+
+	accrualClient, err := accrual.New(config.Config{})
+	require.NoError(t, err)
+	breaker := accrual.NewBreaker(accrualClient, config.Config{})
+
+	order := orders.New(memoryStorage, breaker, config.Config{})
+	defer order.Stop()
+
+	server := httptest.NewServer(createMux(token.New(memoryStorage), auth.New(memoryStorage, config.Config{}, testPolicy(t, config.Config{})), order, health.New(breaker), callback.New(memoryStorage, config.Config{}), admin.New(memoryStorage)))
+	defer server.Close()
+
+	response, _ := testRequest(t, server, http.MethodPost, "/api/user/register", "", `{"login": "user", "password": "password"}`)
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	accessToken := response.Header.Get(usecase.AuthHeader)
+
+	numbers := []string{"735584316112", "527652728124", "044606165247"}
+	for _, number := range numbers {
+		response, _ = testRequest(t, server, http.MethodPost, "/api/user/orders", accessToken, number)
+		require.Equal(t, http.StatusAccepted, response.StatusCode)
+	}
+
+	readPages := func(path string) []string {
+		var result []string
+		for len(path) != 0 {
+			response, body := testRequest(t, server, http.MethodGet, path, accessToken, "")
+			require.Equal(t, http.StatusOK, response.StatusCode)
+
+			var page model.UploadedOrders
+			require.NoError(t, json.Unmarshal([]byte(body), &page))
+			require.LessOrEqual(t, len(page), 2)
+			for _, order := range page {
+				result = append(result, order.Number)
+			}
+
+			path = strings.TrimSuffix(strings.TrimPrefix(response.Header.Get("Link"), "<"), `>; rel="next"`)
+		}
+
+		return result
+	}
+
+	assert.Equal(t, []string{"044606165247", "527652728124", "735584316112"}, readPages("/api/user/orders?limit=2"))
+	assert.Equal(t, numbers, readPages("/api/user/orders?limit=2&sort=asc"))
+
+	response, _ = testRequest(t, server, http.MethodGet, "/api/user/orders?status=PROCESSED", accessToken, "")
+	assert.Equal(t, http.StatusNoContent, response.StatusCode)
+
+	response, _ = testRequest(t, server, http.MethodGet, "/api/user/orders?to=2000-01-01T00:00:00Z", accessToken, "")
+	assert.Equal(t, http.StatusNoContent, response.StatusCode)
+
+	response, _ = testRequest(t, server, http.MethodGet, "/api/user/withdrawals?status=NEW", accessToken, "")
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+}
+
+func TestUploadOrdersBatchRouter(t *testing.T) {
+	memoryStorage := storage.NewMemoryStorage()
+
+	accrualClient, err := accrual.New(config.Config{})
+	require.NoError(t, err)
+	breaker := accrual.NewBreaker(accrualClient, config.Config{})
+
+	order := orders.New(memoryStorage, breaker, config.Config{})
+	defer order.Stop()
+
+	server := httptest.NewServer(createMux(token.New(memoryStorage), auth.New(memoryStorage, config.Config{}, testPolicy(t, config.Config{})), order, health.New(breaker), callback.New(memoryStorage, config.Config{}), admin.New(memoryStorage)))
+	defer server.Close()
+
+	response, _ := testRequest(t, server, http.MethodPost, "/api/user/register", "", `{"login": "first", "password": "password"}`)
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	firstToken := response.Header.Get(usecase.AuthHeader)
+
+	response, _ = testRequest(t, server, http.MethodPost, "/api/user/register", "", `{"login": "second", "password": "password"}`)
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	secondToken := response.Header.Get(usecase.AuthHeader)
+
+	response, _ = testRequest(t, server, http.MethodPost, "/api/user/orders", secondToken, "044606165247")
+	require.Equal(t, http.StatusAccepted, response.StatusCode)
+
+	response, body := testRequest(t, server, http.MethodPost, "/api/user/orders/batch", firstToken, "735584316112\n527652728124\n044606165247\n1234")
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	assert.JSONEq(t, `[
+		{"number": "735584316112", "status": "ACCEPTED"},
+		{"number": "527652728124", "status": "ACCEPTED"},
+		{"number": "044606165247", "status": "CONFLICT"},
+		{"number": "1234", "status": "INVALID"}
+	]`, body)
+
+	response, body = testRequest(t, server, http.MethodPost, "/api/user/orders/batch", firstToken, "735584316112")
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	assert.JSONEq(t, `[{"number": "735584316112", "status": "ALREADY_UPLOADED"}]`, body)
+
+	response, body = testRequest(t, server, http.MethodGet, "/api/user/orders?sort=asc", firstToken, "")
+	require.Equal(t, http.StatusOK, response.StatusCode)
+
+	var uploaded model.UploadedOrders
+	require.NoError(t, json.Unmarshal([]byte(body), &uploaded))
+	require.Len(t, uploaded, 2)
+	assert.Equal(t, "735584316112", uploaded[0].Number)
+	assert.Equal(t, "527652728124", uploaded[1].Number)
+}
+
+func TestAdminRouter(t *testing.T) {
+	memoryStorage := storage.NewMemoryStorage()
+
+	accrualClient, err := accrual.New(config.Config{})
+	require.NoError(t, err)
+	breaker := accrual.NewBreaker(accrualClient, config.Config{})
+
+	order := orders.New(memoryStorage, breaker, config.Config{})
+	defer order.Stop()
+
+	server := httptest.NewServer(createMux(token.New(memoryStorage), auth.New(memoryStorage, config.Config{}, testPolicy(t, config.Config{})), order, health.New(breaker), callback.New(memoryStorage, config.Config{}), admin.New(memoryStorage)))
+	defer server.Close()
+
+	password, err := crypto.HashPassword("password")
+	require.NoError(t, err)
+	err = memoryStorage.CreateUser(context.Background(), entity.User{
+		ID:       entity.UserID("00308dff-b6b1-4f1b-8515-d09d3db49951"),
+		Login:    "admin",
+		Password: password,
+		Role:     entity.UserRoleAdmin,
+	})
+	require.NoError(t, err)
+
+	response, _ := testRequest(t, server, http.MethodPost, "/api/user/register", "", `{"login": "user", "password": "password"}`)
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	userToken := response.Header.Get(usecase.AuthHeader)
+
+	response, _ = testRequest(t, server, http.MethodPost, "/api/user/orders", userToken, "735584316112")
+	require.Equal(t, http.StatusAccepted, response.StatusCode)
+
+	response, _ = testRequest(t, server, http.MethodGet, "/api/admin/users?login=user", userToken, "")
+	assert.Equal(t, http.StatusForbidden, response.StatusCode)
+
+	response, _ = testRequest(t, server, http.MethodGet, "/api/admin/users?login=user", "", "")
+	assert.Equal(t, http.StatusUnauthorized, response.StatusCode)
+
+	response, _ = testRequest(t, server, http.MethodPost, "/api/user/login", "", `{"login": "admin", "password": "password"}`)
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	adminToken := response.Header.Get(usecase.AuthHeader)
+
+	response, body := testRequest(t, server, http.MethodGet, "/api/admin/users?login=user", adminToken, "")
+	require.Equal(t, http.StatusOK, response.StatusCode)
+
+	var user model.AdminUserResponse
+	require.NoError(t, json.Unmarshal([]byte(body), &user))
+	assert.Equal(t, "user", user.Login)
+	assert.Equal(t, string(entity.UserRoleUser), user.Role)
+
+	response, body = testRequest(t, server, http.MethodGet, "/api/admin/users/"+user.ID+"/orders", adminToken, "")
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Contains(t, body, "735584316112")
+
+	response, _ = testRequest(t, server, http.MethodGet, "/api/admin/users/"+user.ID+"/withdrawals", adminToken, "")
+	assert.Equal(t, http.StatusNoContent, response.StatusCode)
+
+	response, body = testRequest(t, server, http.MethodGet, "/api/admin/users/"+user.ID+"/balance", adminToken, "")
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	assert.JSONEq(t, `{"current": 0, "withdrawn": 0}`, body)
+
+	response, _ = testRequest(t, server, http.MethodPost, "/api/admin/users/"+user.ID+"/balance/adjustments", adminToken, `{"amount": -1, "reason": "FRAUD_REVERSAL", "comment": "chargeback"}`)
+	assert.Equal(t, http.StatusConflict, response.StatusCode)
+
+	response, _ = testRequest(t, server, http.MethodPost, "/api/admin/users/"+user.ID+"/balance/adjustments", adminToken, `{"amount": 25.5, "reason": "GOODWILL", "comment": "late accrual"}`)
+	require.Equal(t, http.StatusOK, response.StatusCode)
+
+	response, _ = testRequest(t, server, http.MethodPost, "/api/admin/users/"+user.ID+"/balance/adjustments", adminToken, `{"amount": -5, "reason": "CORRECTION", "comment": "double credit"}`)
+	require.Equal(t, http.StatusOK, response.StatusCode)
+
+	response, body = testRequest(t, server, http.MethodGet, "/api/user/balance", userToken, "")
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	assert.JSONEq(t, `{"current": 20.5, "withdrawn": 0}`, body)
+
+	response, body = testRequest(t, server, http.MethodGet, "/api/user/balance/history", userToken, "")
+	require.Equal(t, http.StatusOK, response.StatusCode)
+
+	var history model.LedgerEntriesResponse
+	require.NoError(t, json.Unmarshal([]byte(body), &history))
+	require.Len(t, history, 2)
+	assert.Equal(t, string(entity.LedgerEntryAdjustment), history[0].Type)
+	assert.Equal(t, string(entity.AdjustmentReasonGoodwill), history[0].Reason)
+	assert.Equal(t, "late accrual", history[0].Comment)
+	assert.Equal(t, money.Points(-500), history[1].Amount)
+
+	response, _ = testRequest(t, server, http.MethodPost, "/api/admin/orders/735584316112/recheck", adminToken, "")
+	assert.Equal(t, http.StatusAccepted, response.StatusCode)
+
+	response, _ = testRequest(t, server, http.MethodPost, "/api/admin/orders/12345678903/recheck", adminToken, "")
+	assert.Equal(t, http.StatusNotFound, response.StatusCode)
+
+	response, _ = testRequest(t, server, http.MethodGet, "/api/admin/users?login=unknown", adminToken, "")
+	assert.Equal(t, http.StatusNotFound, response.StatusCode)
+
+	response, body = testRequest(t, server, http.MethodGet, "/api/admin/users/6a1c4c8e-5f0e-4b59-9d2a-3f1e3c7a2b10/orders", adminToken, "")
+	assert.Equal(t, http.StatusNotFound, response.StatusCode)
+	assert.Contains(t, body, string(problem.CodeUserNotFound))
+
+	response, _ = testRequest(t, server, http.MethodGet, "/api/admin/users/6a1c4c8e-5f0e-4b59-9d2a-3f1e3c7a2b10/withdrawals", adminToken, "")
+	assert.Equal(t, http.StatusNotFound, response.StatusCode)
+}
+
+func TestAdminLoginsRouter(t *testing.T) {
+	memoryStorage := storage.NewMemoryStorage()
+
+	accrualClient, err := accrual.New(config.Config{})
+	require.NoError(t, err)
+	breaker := accrual.NewBreaker(accrualClient, config.Config{})
+
+	order := orders.New(memoryStorage, breaker, config.Config{})
+	defer order.Stop()
+
+	authConfig := config.Config{AdminLoginList: config.ParseAdminLogins("boss, user")}
+	server := httptest.NewServer(createMux(token.New(memoryStorage), auth.New(memoryStorage, authConfig, testPolicy(t, authConfig)), order, health.New(breaker), callback.New(memoryStorage, config.Config{}), admin.New(memoryStorage)))
+	defer server.Close()
+
+	response, _ := testRequest(t, server, http.MethodPost, "/api/user/register", "", `{"login": "boss", "password": "password"}`)
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	bossToken := response.Header.Get(usecase.AuthHeader)
+
+	response, _ = testRequest(t, server, http.MethodGet, "/api/admin/users?login=boss", bossToken, "")
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+
+	err = memoryStorage.CreateUser(context.Background(), entity.User{
+		ID:       entity.UserID("00308dff-b6b1-4f1b-8515-d09d3db49951"),
+		Login:    "user",
+		Password: "password",
+	})
+	require.NoError(t, err)
+
+	user, err := memoryStorage.FindUserByLogin(context.Background(), "user")
+	require.NoError(t, err)
+	assert.Equal(t, entity.UserRoleUser, user.Role)
+
+	// пользователи, зарегистрированные до запуска, получают роль при старте
+	err = memoryStorage.GrantUserRole(context.Background(), authConfig.AdminLoginList, entity.UserRoleAdmin)
+	require.NoError(t, err)
+
+	user, err = memoryStorage.FindUserByLogin(context.Background(), "user")
+	require.NoError(t, err)
+	assert.Equal(t, entity.UserRoleAdmin, user.Role)
+
+	response, _ = testRequest(t, server, http.MethodPost, "/api/user/register", "", `{"login": "other", "password": "password"}`)
+	require.Equal(t, http.StatusOK, response.StatusCode)
+
+	response, _ = testRequest(t, server, http.MethodGet, "/api/admin/users?login=boss", response.Header.Get(usecase.AuthHeader), "")
+	assert.Equal(t, http.StatusForbidden, response.StatusCode)
+}
+
+func TestProblemRouter(t *testing.T) {
+	memoryStorage := storage.NewMemoryStorage()
+
+	accrualClient, err := accrual.New(config.Config{})
+	require.NoError(t, err)
+	breaker := accrual.NewBreaker(accrualClient, config.Config{})
+
+	order := orders.New(memoryStorage, breaker, config.Config{})
+	defer order.Stop()
+
+	server := httptest.NewServer(createMux(token.New(memoryStorage), auth.New(memoryStorage, config.Config{}, testPolicy(t, config.Config{})), order, health.New(breaker), callback.New(memoryStorage, config.Config{}), admin.New(memoryStorage)))
+	defer server.Close()
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		requestID  string
+		reused     bool
+		statusCode int
+		code       problem.Code
+	}{
+		{
+			name:       "unauthorized with client request id",
+			method:     http.MethodGet,
+			path:       "/api/user/orders",
+			requestID:  "client-request-1",
+			reused:     true,
+			statusCode: http.StatusUnauthorized,
+			code:       problem.CodeUnauthorized,
+		},
+		{
+			name:       "unknown route",
+			method:     http.MethodGet,
+			path:       "/api/unknown",
+			statusCode: http.StatusNotFound,
+			code:       problem.CodeNotFound,
+		},
+		{
+			name:       "method not allowed",
+			method:     http.MethodDelete,
+			path:       "/api/health",
+			requestID:  "invalid request id",
+			statusCode: http.StatusMethodNotAllowed,
+			code:       problem.CodeMethodNotAllowed,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request, err := http.NewRequest(test.method, server.URL+test.path, nil)
+			require.NoError(t, err)
+			if len(test.requestID) != 0 {
+				request.Header.Set(problem.RequestIDHeader, test.requestID)
+			}
+
+			response, err := server.Client().Do(request)
+			require.NoError(t, err)
+			defer response.Body.Close()
+
+			assert.Equal(t, test.statusCode, response.StatusCode)
+			assert.Equal(t, problem.ContentType, response.Header.Get("Content-Type"))
+
+			requestID := response.Header.Get(problem.RequestIDHeader)
+			require.NotEmpty(t, requestID)
+			if test.reused {
+				assert.Equal(t, test.requestID, requestID)
+			} else {
+				assert.NotEqual(t, test.requestID, requestID)
+			}
+
+			var body problem.Problem
+			require.NoError(t, json.NewDecoder(response.Body).Decode(&body))
+			assert.Equal(t, test.code, body.Code)
+			assert.Equal(t, test.statusCode, body.Status)
+			assert.Equal(t, "urn:gophermart:problem:"+string(test.code), body.Type)
+			assert.Equal(t, requestID, body.RequestID)
+		})
+	}
+}
+
+func TestOpenAPIRouter(t *testing.T) {
+	memoryStorage := storage.NewMemoryStorage()
+
+	accrualClient, err := accrual.New(config.Config{})
+	require.NoError(t, err)
+	breaker := accrual.NewBreaker(accrualClient, config.Config{})
+
+	order := orders.New(memoryStorage, breaker, config.Config{})
+	defer order.Stop()
+
+	mux := createMux(token.New(memoryStorage), auth.New(memoryStorage, config.Config{}, testPolicy(t, config.Config{})), order, health.New(breaker), callback.New(memoryStorage, config.Config{}), admin.New(memoryStorage))
+
+	var routes []string
+	err = chi.Walk(mux, func(method string, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		routes = append(routes, method+" "+route)
+		return nil
+	})
+	require.NoError(t, err)
+
+	document, err := openapi.Load()
+	require.NoError(t, err)
+	assert.ElementsMatch(t, document.Routes(), routes)
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	response, body := testRequest(t, server, http.MethodGet, "/api/openapi.json", "", "")
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "application/json", response.Header.Get("Content-Type"))
+	assert.JSONEq(t, string(openapi.Spec()), body)
+}
+
+func TestOrdersStreamRouter(t *testing.T) {
+	memoryStorage := storage.NewMemoryStorage()
+	config := config.Config{
+		OrderStreamHeartbeat: 50 * time.Millisecond,
+	}
+
+	accrualClient, err := accrual.New(config)
+	require.NoError(t, err)
+	breaker := accrual.NewBreaker(accrualClient, config)
+
+	order := orders.New(memoryStorage, breaker, config)
+	defer order.Stop()
+	defer order.CloseStreams()
+
+	server := httptest.NewServer(createMux(token.New(memoryStorage), auth.New(memoryStorage, config, testPolicy(t, config)), order, health.New(breaker), callback.New(memoryStorage, config), admin.New(memoryStorage)))
+	defer server.Close()
+
+	response, _ := testRequest(t, server, http.MethodPost, "/api/user/register", "", `{"login": "user", "password": "password"}`)
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	accessToken := response.Header.Get(usecase.AuthHeader)
+
+	response, _ = testRequest(t, server, http.MethodPost, "/api/user/orders", accessToken, "735584316112")
+	require.Equal(t, http.StatusAccepted, response.StatusCode)
+
+	userID, err := memoryStorage.GetOrderOwner(context.Background(), entity.OrderNumber("735584316112"))
+	require.NoError(t, err)
+
+	openStream := func(lastEventID string) (*bufio.Reader, func()) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+
+		request, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/api/user/orders/stream", nil)
+		require.NoError(t, err)
+		request.Header.Set(usecase.AuthHeader, accessToken)
+		if len(lastEventID) != 0 {
+			request.Header.Set("Last-Event-ID", lastEventID)
+		}
+
+		response, err := server.Client().Do(request)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		require.Equal(t, "text/event-stream", response.Header.Get("Content-Type"))
+
+		return bufio.NewReader(response.Body), func() {
+			cancel()
+			response.Body.Close()
+		}
+	}
+
+	readMessage := func(reader *bufio.Reader) []string {
+		var lines []string
+		for {
+			line, err := reader.ReadString('\n')
+			require.NoError(t, err)
+
+			line = strings.TrimSuffix(line, "\n")
+			if len(line) == 0 {
+				return lines
+			}
+			lines = append(lines, line)
+		}
+	}
+
+	checkEvent := func(lines []string, id string, status entity.OrderStatus, accrual money.Points) {
+		require.Len(t, lines, 3)
+		assert.Equal(t, "id: "+id, lines[0])
+		assert.Equal(t, "event: order", lines[1])
+
+		var event model.OrderEventResponse
+		require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(lines[2], "data: ")), &event))
+		assert.Equal(t, "735584316112", event.Number)
+		assert.Equal(t, string(status), event.Status)
+		assert.Equal(t, accrual, event.Accrual)
+	}
+
+	stream, closeStream := openStream("")
+	assert.Equal(t, []string{"retry: 3000"}, readMessage(stream))
+
+	err = memoryStorage.UpdateOrders(context.Background(), entity.UpdateUserOrders{
+		{
+			UserID: userID,
+			Order: entity.Order{
+				Number: entity.OrderNumber("735584316112"),
+				Status: entity.StatusProcessingOrder,
+			},
+		},
+	})
+	require.NoError(t, err)
+	checkEvent(readMessage(stream), "1", entity.StatusProcessingOrder, 0)
+	closeStream()
+
+	err = memoryStorage.UpdateOrders(context.Background(), entity.UpdateUserOrders{
+		{
+			UserID: userID,
+			Order: entity.Order{
+				Number:  entity.OrderNumber("735584316112"),
+				Status:  entity.StatusProcessedOrder,
+				Accrual: money.Points(50000),
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	stream, closeStream = openStream("1")
+	defer closeStream()
+
+	assert.Equal(t, []string{"retry: 3000"}, readMessage(stream))
+	checkEvent(readMessage(stream), "2", entity.StatusProcessedOrder, money.Points(50000))
+	assert.Equal(t, []string{": heartbeat"}, readMessage(stream))
+}
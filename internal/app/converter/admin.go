@@ -0,0 +1,15 @@
+package converter
+
+import (
+	"github.com/avGenie/go-loyalty-system/internal/app/entity"
+	"github.com/avGenie/go-loyalty-system/internal/app/model"
+)
+
+func ConvertUserToAdminResponse(user entity.User) model.AdminUserResponse {
+	return model.AdminUserResponse{
+		ID:     user.ID.String(),
+		Login:  user.Login,
+		Status: string(user.Status),
+		Role:   string(user.Role),
+	}
+}
@@ -0,0 +1,34 @@
+package converter
+
+import (
+	"github.com/avGenie/go-loyalty-system/internal/app/entity"
+	"github.com/avGenie/go-loyalty-system/internal/app/model"
+	"github.com/golang-module/carbon/v2"
+)
+
+func ConvertLedgerToLedgerResponse(entries entity.LedgerEntries) model.LedgerEntriesResponse {
+	responses := make(model.LedgerEntriesResponse, 0, len(entries))
+	for _, entry := range entries {
+		response := model.LedgerEntryResponse{
+			Type:        string(entry.Kind),
+			Order:       string(entry.OrderNumber),
+			Amount:      entry.Amount,
+			Reason:      string(entry.Reason),
+			Comment:     entry.Comment,
+			DateCreated: carbon.Parse(entry.DateCreated).ToRfc3339String(),
+		}
+
+		responses = append(responses, response)
+	}
+
+	return responses
+}
+
+func ConvertAdjustmentRequestToEntity(adminID entity.UserID, request model.AdjustmentRequest) entity.Adjustment {
+	return entity.Adjustment{
+		AdminID: adminID,
+		Amount:  request.Amount,
+		Reason:  entity.AdjustmentReason(request.Reason),
+		Comment: request.Comment,
+	}
+}
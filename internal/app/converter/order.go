@@ -8,6 +8,7 @@ import (
 
 	"github.com/avGenie/go-loyalty-system/internal/app/entity"
 	"github.com/avGenie/go-loyalty-system/internal/app/model"
+	"github.com/avGenie/go-loyalty-system/internal/app/money"
 )
 
 func ConvertStorageOrdersToOutputUploadedOrders(orders entity.Orders) (model.UploadedOrders, error) {
@@ -31,12 +32,42 @@ func ConvertStorageOrdersToOutputUploadedOrders(orders entity.Orders) (model.Upl
 	return uploadedOrders, nil
 }
 
-func ConvertAccrualResponseToOrder(response model.AccrualResponse) entity.Order {
-	return entity.Order{
-		Number: entity.OrderNumber(response.Number),
-		Status: ConvertAccrualStatusToAPI(model.AccrualOrderStatus(response.Status)),
-		Accrual: response.Accrual,
+func ConvertOrderUploadResultsToResponse(results entity.OrderUploadResults) model.OrderUploadResponses {
+	responses := make(model.OrderUploadResponses, 0, len(results))
+	for _, result := range results {
+		responses = append(responses, model.OrderUploadResponse{
+			Number: string(result.Number),
+			Status: string(result.Status),
+		})
+	}
+
+	return responses
+}
+
+func ConvertOrderEventToResponse(event entity.OrderEvent) model.OrderEventResponse {
+	return model.OrderEventResponse{
+		Number:     string(event.Number),
+		Status:     string(event.Status),
+		Accrual:    event.Accrual,
+		UpdateTime: carbon.Parse(event.DateCreated).ToRfc3339String(),
+	}
+}
+
+func ConvertAccrualResponseToOrder(response model.AccrualResponse) (entity.Order, error) {
+	var accrual money.Points
+	if len(response.Accrual) != 0 {
+		var err error
+		accrual, err = money.ParseRoundedPoints(response.Accrual.String())
+		if err != nil {
+			return entity.Order{}, fmt.Errorf("error while parsing accrual value: %w", err)
+		}
 	}
+
+	return entity.Order{
+		Number:  entity.OrderNumber(response.Number),
+		Status:  ConvertAccrualStatusToAPI(model.AccrualOrderStatus(response.Status)),
+		Accrual: accrual,
+	}, nil
 }
 
 func ConvertAccrualStatusToAPI(accrualStatus model.AccrualOrderStatus) entity.OrderStatus {
@@ -0,0 +1,41 @@
+package converter
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/avGenie/go-loyalty-system/internal/app/entity"
+)
+
+const cursorSeparator = "|"
+
+// ConvertCursorToToken encodes the cursor into an opaque URL safe token.
+func ConvertCursorToToken(cursor entity.Cursor) string {
+	value := cursor.Date.UTC().Format(time.RFC3339Nano) + cursorSeparator + cursor.Key
+
+	return base64.RawURLEncoding.EncodeToString([]byte(value))
+}
+
+func ConvertTokenToCursor(token string) (entity.Cursor, error) {
+	value, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return entity.Cursor{}, fmt.Errorf("error while decoding cursor: %w", err)
+	}
+
+	date, key, found := strings.Cut(string(value), cursorSeparator)
+	if !found || len(key) == 0 {
+		return entity.Cursor{}, fmt.Errorf("cursor %q has invalid format", value)
+	}
+
+	cursorDate, err := time.Parse(time.RFC3339Nano, date)
+	if err != nil {
+		return entity.Cursor{}, fmt.Errorf("error while parsing cursor date: %w", err)
+	}
+
+	return entity.Cursor{
+		Date: cursorDate,
+		Key:  key,
+	}, nil
+}
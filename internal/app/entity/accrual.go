@@ -12,6 +12,7 @@ const (
 	StatusOrderNotRegistered
 	StatusOK
 	StatusError
+	StatusUnavailable
 )
 
 type AccrualProcessingResponse struct {
@@ -0,0 +1,28 @@
+package entity
+
+type AdminAction string
+
+const (
+	AdminActionGetUser            AdminAction = `GET_USER`
+	AdminActionGetUserOrders      AdminAction = `GET_USER_ORDERS`
+	AdminActionGetUserWithdrawals AdminAction = `GET_USER_WITHDRAWALS`
+	AdminActionGetUserBalance     AdminAction = `GET_USER_BALANCE`
+	AdminActionRecheckOrder       AdminAction = `RECHECK_ORDER`
+	AdminActionAdjustBalance      AdminAction = `ADJUST_BALANCE`
+)
+
+// AuditRecord describes an admin action. Target is the login, the user id
+// or the order number the action is applied to.
+type AuditRecord struct {
+	AdminID UserID
+	Action  AdminAction
+	Target  string
+}
+
+func CreateAuditRecord(adminID UserID, action AdminAction, target string) AuditRecord {
+	return AuditRecord{
+		AdminID: adminID,
+		Action:  action,
+		Target:  target,
+	}
+}
@@ -1,14 +1,16 @@
 package entity
 
+import "github.com/avGenie/go-loyalty-system/internal/app/money"
+
 type UpdateUserBalances []UserBalance
 
 type UpdateUserBalance struct {
-	UserID      UserID
-	Balance     float64
+	UserID  UserID
+	Balance money.Points
 }
 
 type UserBalance struct {
 	UserID      UserID
-	Balance     float64
-	Withdrawans float64
+	Balance     money.Points
+	Withdrawans money.Points
 }
@@ -0,0 +1,26 @@
+package entity
+
+import "github.com/avGenie/go-loyalty-system/internal/app/money"
+
+type OrderEvents []OrderEvent
+
+// OrderEvent records the order status change made by the accrual system.
+// IDs of the user events grow in the commit order, so a user stream can be
+// resumed from the last received ID. Accrual is credited to the balance with the PROCESSED status.
+type OrderEvent struct {
+	ID          int64
+	UserID      UserID
+	Number      OrderNumber
+	Status      OrderStatus
+	Accrual     money.Points
+	DateCreated string
+}
+
+func CreateOrderEvent(userID UserID, order Order) OrderEvent {
+	return OrderEvent{
+		UserID:  userID,
+		Number:  order.Number,
+		Status:  order.Status,
+		Accrual: order.Accrual,
+	}
+}
@@ -0,0 +1,15 @@
+package entity
+
+type IdempotencyKey struct {
+	Key         string
+	RequestHash string
+	StatusCode  int
+	Body        []byte
+}
+
+func CreateIdempotencyKey(key, requestHash string) IdempotencyKey {
+	return IdempotencyKey{
+		Key:         key,
+		RequestHash: requestHash,
+	}
+}
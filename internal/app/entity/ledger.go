@@ -0,0 +1,104 @@
+package entity
+
+import "github.com/avGenie/go-loyalty-system/internal/app/money"
+
+type LedgerEntryKind string
+
+const (
+	LedgerEntryAccrual    LedgerEntryKind = `ACCRUAL`
+	LedgerEntryWithdrawal LedgerEntryKind = `WITHDRAWAL`
+	LedgerEntryAdjustment LedgerEntryKind = `ADJUSTMENT`
+)
+
+type LedgerAccount string
+
+const (
+	LedgerAccountUser        LedgerAccount = `USER`
+	LedgerAccountAccruals    LedgerAccount = `ACCRUALS`
+	LedgerAccountWithdrawals LedgerAccount = `WITHDRAWALS`
+	LedgerAccountAdjustments LedgerAccount = `ADJUSTMENTS`
+)
+
+type AdjustmentReason string
+
+const (
+	AdjustmentReasonGoodwill      AdjustmentReason = `GOODWILL`
+	AdjustmentReasonFraudReversal AdjustmentReason = `FRAUD_REVERSAL`
+	AdjustmentReasonCorrection    AdjustmentReason = `CORRECTION`
+	// AdjustmentReasonOpeningBalance marks the entries created while
+	// migrating balances to the ledger, admins can't use it.
+	AdjustmentReasonOpeningBalance AdjustmentReason = `OPENING_BALANCE`
+)
+
+type LedgerEntries []LedgerEntry
+
+// LedgerEntry is a single balanced movement of points: the user account
+// receives Amount and the counter account of the entry kind receives -Amount.
+// Adjustments also carry the reason, the comment and the admin who made them.
+type LedgerEntry struct {
+	UserID      UserID
+	Kind        LedgerEntryKind
+	OrderNumber OrderNumber
+	Amount      money.Points
+	Reason      AdjustmentReason
+	Comment     string
+	AdminID     UserID
+	DateCreated string
+}
+
+// Adjustment credits or debits the user balance by Amount.
+type Adjustment struct {
+	AdminID UserID
+	Amount  money.Points
+	Reason  AdjustmentReason
+	Comment string
+}
+
+func CreateAccrualLedgerEntry(userID UserID, order Order) LedgerEntry {
+	return LedgerEntry{
+		UserID:      userID,
+		Kind:        LedgerEntryAccrual,
+		OrderNumber: order.Number,
+		Amount:      order.Accrual,
+	}
+}
+
+func CreateWithdrawalLedgerEntry(userID UserID, withdraw Withdraw) LedgerEntry {
+	return LedgerEntry{
+		UserID:      userID,
+		Kind:        LedgerEntryWithdrawal,
+		OrderNumber: withdraw.OrderNumber,
+		Amount:      -withdraw.Sum,
+	}
+}
+
+func CreateAdjustmentLedgerEntry(userID UserID, adjustment Adjustment) LedgerEntry {
+	return LedgerEntry{
+		UserID:  userID,
+		Kind:    LedgerEntryAdjustment,
+		Amount:  adjustment.Amount,
+		Reason:  adjustment.Reason,
+		Comment: adjustment.Comment,
+		AdminID: adjustment.AdminID,
+	}
+}
+
+func (r AdjustmentReason) IsValid() bool {
+	switch r {
+	case AdjustmentReasonGoodwill, AdjustmentReasonFraudReversal, AdjustmentReasonCorrection:
+		return true
+	default:
+		return false
+	}
+}
+
+func (k LedgerEntryKind) CounterAccount() LedgerAccount {
+	switch k {
+	case LedgerEntryAccrual:
+		return LedgerAccountAccruals
+	case LedgerEntryWithdrawal:
+		return LedgerAccountWithdrawals
+	default:
+		return LedgerAccountAdjustments
+	}
+}
@@ -0,0 +1,100 @@
+package entity
+
+import (
+	"fmt"
+	"time"
+)
+
+type SortOrder string
+
+const (
+	SortAsc  SortOrder = `asc`
+	SortDesc SortOrder = `desc`
+)
+
+// Cursor points to the last item of the previous page. Items are ordered
+// by date and then by the order number, which is unique.
+type Cursor struct {
+	Date time.Time
+	Key  string
+}
+
+// ListQuery selects a page of the user orders or withdrawals.
+// Zero Limit means no limit, zero From and To mean an unbounded date range.
+type ListQuery struct {
+	Limit    int
+	Cursor   *Cursor
+	Statuses []OrderStatus
+	From     time.Time
+	To       time.Time
+	Sort     SortOrder
+}
+
+func (s SortOrder) IsValid() bool {
+	return s == SortAsc || s == SortDesc
+}
+
+// IsAfter reports whether the item with date and key follows the cursor
+// in the sort order.
+func (c Cursor) IsAfter(date time.Time, key string, sort SortOrder) bool {
+	if !date.Equal(c.Date) {
+		return date.After(c.Date) == (sort == SortAsc)
+	}
+
+	if key == c.Key {
+		return false
+	}
+
+	return (key > c.Key) == (sort == SortAsc)
+}
+
+// InRange reports whether the date is within [From, To).
+func (q ListQuery) InRange(date time.Time) bool {
+	if !q.From.IsZero() && date.Before(q.From) {
+		return false
+	}
+
+	if !q.To.IsZero() && !date.Before(q.To) {
+		return false
+	}
+
+	return true
+}
+
+func (q ListQuery) HasStatus(status OrderStatus) bool {
+	if len(q.Statuses) == 0 {
+		return true
+	}
+
+	for _, s := range q.Statuses {
+		if s == status {
+			return true
+		}
+	}
+
+	return false
+}
+
+func CreateOrderCursor(order Order) (Cursor, error) {
+	date, err := time.Parse(time.RFC3339Nano, order.DateCreated)
+	if err != nil {
+		return Cursor{}, fmt.Errorf("error while parsing order date for cursor: %w", err)
+	}
+
+	return Cursor{
+		Date: date,
+		Key:  string(order.Number),
+	}, nil
+}
+
+func CreateWithdrawCursor(withdraw Withdraw) (Cursor, error) {
+	date, err := time.Parse(time.RFC3339Nano, withdraw.DateCreated)
+	if err != nil {
+		return Cursor{}, fmt.Errorf("error while parsing withdrawal date for cursor: %w", err)
+	}
+
+	return Cursor{
+		Date: date,
+		Key:  string(withdraw.OrderNumber),
+	}, nil
+}
@@ -0,0 +1,60 @@
+package entity
+
+import "time"
+
+const (
+	loginAttemptPrefix = "login:"
+	ipAttemptPrefix    = "ip:"
+)
+
+// LoginThrottle describes how failed logins slow down further attempts.
+// After FreeAttempts failures every next attempt is delayed twice as long,
+// and MaxFailures failures lock the key out for Lockout.
+type LoginThrottle struct {
+	Window       time.Duration
+	FreeAttempts int
+	DelayBase    time.Duration
+	DelayMax     time.Duration
+	MaxFailures  int
+	Lockout      time.Duration
+}
+
+type LoginAttempt struct {
+	Key      string
+	Failures int
+	Blocked  time.Duration
+}
+
+func LoginAttemptKeys(login, ip string) []string {
+	keys := []string{loginAttemptPrefix + login}
+	if len(ip) != 0 {
+		keys = append(keys, ipAttemptPrefix+ip)
+	}
+
+	return keys
+}
+
+func LoginKey(login string) string {
+	return loginAttemptPrefix + login
+}
+
+func (t LoginThrottle) BlockDuration(failures int) time.Duration {
+	if t.IsLockout(failures) {
+		return t.Lockout
+	}
+
+	if failures <= t.FreeAttempts {
+		return 0
+	}
+
+	delay := t.DelayBase
+	for i := t.FreeAttempts + 1; i < failures && delay < t.DelayMax; i++ {
+		delay *= 2
+	}
+
+	return min(delay, t.DelayMax)
+}
+
+func (t LoginThrottle) IsLockout(failures int) bool {
+	return t.MaxFailures > 0 && failures >= t.MaxFailures
+}
@@ -1,5 +1,11 @@
 package entity
 
+import (
+	"time"
+
+	"github.com/avGenie/go-loyalty-system/internal/app/money"
+)
+
 type OrderStatus string
 
 const (
@@ -9,11 +15,32 @@ const (
 	StatusProcessedOrder  OrderStatus = `PROCESSED`
 )
 
+func (s OrderStatus) IsValid() bool {
+	switch s {
+	case StatusNewOrder, StatusProcessingOrder, StatusInvalidOrder, StatusProcessedOrder:
+		return true
+	}
+
+	return false
+}
+
 type UpdateUserOrders []UpdateUserOrder
 
 type UpdateUserOrder struct {
-	UserID UserID
-	Order  Order
+	UserID   UserID
+	Order    Order
+	Attempts int
+}
+
+type OrderChecks []OrderCheck
+
+// OrderCheck schedules the next accrual request for an unfinished order.
+// Parked orders aren't polled until they are rescheduled manually.
+type OrderCheck struct {
+	Number   OrderNumber
+	Attempts int
+	Delay    time.Duration
+	Parked   bool
 }
 
 type Orders []Order
@@ -23,6 +50,30 @@ type OrderNumber string
 type Order struct {
 	Number      OrderNumber
 	Status      OrderStatus
-	Accrual     float64
+	Accrual     money.Points
 	DateCreated string
 }
+
+type OrderUploadStatus string
+
+const (
+	UploadAcceptedOrder OrderUploadStatus = `ACCEPTED`
+	UploadExistsOrder   OrderUploadStatus = `ALREADY_UPLOADED`
+	UploadConflictOrder OrderUploadStatus = `CONFLICT`
+	UploadInvalidOrder  OrderUploadStatus = `INVALID`
+)
+
+type OrderUploadResults []OrderUploadResult
+
+// OrderUploadResult reports the outcome of uploading one order of a batch.
+type OrderUploadResult struct {
+	Number OrderNumber
+	Status OrderUploadStatus
+}
+
+func CreateOrderUploadResult(number OrderNumber, status OrderUploadStatus) OrderUploadResult {
+	return OrderUploadResult{
+		Number: number,
+		Status: status,
+	}
+}
@@ -0,0 +1,22 @@
+package entity
+
+import "time"
+
+type RefreshToken struct {
+	Hash      string
+	UserID    UserID
+	ExpiresAt time.Time
+}
+
+type AccessToken struct {
+	ID        string
+	ExpiresAt time.Time
+}
+
+func CreateRefreshToken(hash string, userID UserID, ttl time.Duration) RefreshToken {
+	return RefreshToken{
+		Hash:      hash,
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+}
@@ -1,26 +1,55 @@
 package entity
 
-import "github.com/avGenie/go-loyalty-system/internal/app/model"
+import (
+	"net/http"
+	"time"
+
+	"github.com/avGenie/go-loyalty-system/internal/app/model"
+)
 
 type UserID string
 
+type UserStatus string
+
+const (
+	UserStatusActive  UserStatus = `ACTIVE`
+	UserStatusDeleted UserStatus = `DELETED`
+)
+
+type UserRole string
+
+const (
+	UserRoleUser  UserRole = `USER`
+	UserRoleAdmin UserRole = `ADMIN`
+)
+
 type User struct {
 	ID       UserID
 	Login    string
 	Password string
+	Status   UserStatus
+	Role     UserRole
 }
 
 type UserIDCtxKey struct{}
 
 type UserIDCtx struct {
 	UserID     UserID
+	Role       UserRole
 	StatusCode int
+	Revoked    bool
+
+	Token AccessToken
 }
 
 func (u UserID) String() string {
 	return string(u)
 }
 
+func (r UserRole) IsAdmin() bool {
+	return r == UserRoleAdmin
+}
+
 func (u *UserID) Valid() bool {
 	return len(u.String()) != 0
 }
@@ -32,10 +61,33 @@ func CreateUserIDCtx(userID UserID, code int) UserIDCtx {
 	}
 }
 
+// CreateRevokedUserIDCtx is used for the tokens revoked on logout or account
+// deletion, they are rejected like the expired ones but reported separately.
+func CreateRevokedUserIDCtx() UserIDCtx {
+	return UserIDCtx{
+		StatusCode: http.StatusUnauthorized,
+		Revoked:    true,
+	}
+}
+
+func CreateTokenUserIDCtx(userID UserID, role UserRole, tokenID string, expiresAt time.Time) UserIDCtx {
+	return UserIDCtx{
+		UserID:     userID,
+		Role:       role,
+		StatusCode: http.StatusOK,
+		Token: AccessToken{
+			ID:        tokenID,
+			ExpiresAt: expiresAt,
+		},
+	}
+}
+
 func CreateUserFromCreateRequest(userID UserID, request model.UserCredentialsRequest) User {
 	return User{
 		ID:       userID,
 		Login:    request.Login,
 		Password: request.Password,
+		Status:   UserStatusActive,
+		Role:     UserRoleUser,
 	}
 }
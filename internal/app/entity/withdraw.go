@@ -1,9 +1,11 @@
 package entity
 
+import "github.com/avGenie/go-loyalty-system/internal/app/money"
+
 type Withdrawals []Withdraw
 
 type Withdraw struct {
 	OrderNumber OrderNumber
-	Sum         float64
+	Sum         money.Points
 	DateCreated string
 }
@@ -1,7 +1,9 @@
 package model
 
+import "encoding/json"
+
 type AccrualResponse struct {
-	Number  string  `json:"order"`
-	Status  string  `json:"status"`
-	Accrual float64 `json:"accrual,omitempty"`
+	Number  string      `json:"order"`
+	Status  string      `json:"status"`
+	Accrual json.Number `json:"accrual,omitempty"`
 }
@@ -0,0 +1,8 @@
+package model
+
+type AdminUserResponse struct {
+	ID     string `json:"id"`
+	Login  string `json:"login"`
+	Status string `json:"status"`
+	Role   string `json:"role"`
+}
@@ -1,6 +1,8 @@
 package model
 
+import "github.com/avGenie/go-loyalty-system/internal/app/money"
+
 type UserBalanceResponse struct {
-	Current   float64 `json:"current"`
-	Withdrawn float64 `json:"withdrawn"`
+	Current   money.Points `json:"current"`
+	Withdrawn money.Points `json:"withdrawn"`
 }
@@ -0,0 +1,6 @@
+package model
+
+type HealthResponse struct {
+	Status  string `json:"status"`
+	Accrual string `json:"accrual"`
+}
@@ -0,0 +1,20 @@
+package model
+
+import "github.com/avGenie/go-loyalty-system/internal/app/money"
+
+type LedgerEntriesResponse []LedgerEntryResponse
+
+type LedgerEntryResponse struct {
+	Type        string       `json:"type"`
+	Order       string       `json:"order,omitempty"`
+	Amount      money.Points `json:"amount"`
+	Reason      string       `json:"reason,omitempty"`
+	Comment     string       `json:"comment,omitempty"`
+	DateCreated string       `json:"processed_at"`
+}
+
+type AdjustmentRequest struct {
+	Amount  money.Points `json:"amount"`
+	Reason  string       `json:"reason"`
+	Comment string       `json:"comment"`
+}
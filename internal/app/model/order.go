@@ -1,5 +1,7 @@
 package model
 
+import "github.com/avGenie/go-loyalty-system/internal/app/money"
+
 type AccrualOrderStatus string
 
 const (
@@ -12,8 +14,23 @@ const (
 type UploadedOrders []UploadedOrder
 
 type UploadedOrder struct {
-	Number     string  `json:"number"`
-	Status     string  `json:"status"`
-	Accrual    float64 `json:"accrual"`
-	UploadTime string  `json:"uploaded_at"`
+	Number     string       `json:"number"`
+	Status     string       `json:"status"`
+	Accrual    money.Points `json:"accrual"`
+	UploadTime string       `json:"uploaded_at"`
+}
+
+type OrderUploadResponses []OrderUploadResponse
+
+type OrderUploadResponse struct {
+	Number string `json:"number"`
+	Status string `json:"status"`
+}
+
+// OrderEventResponse is the data of the order event in the orders stream.
+type OrderEventResponse struct {
+	Number     string       `json:"number"`
+	Status     string       `json:"status"`
+	Accrual    money.Points `json:"accrual"`
+	UpdateTime string       `json:"updated_at"`
 }
@@ -0,0 +1,9 @@
+package model
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type TokenResponse struct {
+	RefreshToken string `json:"refresh_token"`
+}
@@ -4,3 +4,8 @@ type UserCredentialsRequest struct {
 	Login    string `json:"login"`
 	Password string `json:"password"`
 }
+
+type ChangePasswordRequest struct {
+	OldPassword string `json:"old_password"`
+	NewPassword string `json:"new_password"`
+}
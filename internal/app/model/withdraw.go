@@ -1,14 +1,16 @@
 package model
 
+import "github.com/avGenie/go-loyalty-system/internal/app/money"
+
 type WithdrawRequest struct {
-	Order string  `json:"order"`
-	Sum   float64 `json:"sum"`
+	Order string       `json:"order"`
+	Sum   money.Points `json:"sum"`
 }
 
 type WithdrawalsResponses []WithdrawalsResponse
 
 type WithdrawalsResponse struct {
-	OrderNumber string  `json:"order"`
-	Sum         float64 `json:"sum"`
-	DateCreated string  `json:"processed_at"`
+	OrderNumber string       `json:"order"`
+	Sum         money.Points `json:"sum"`
+	DateCreated string       `json:"processed_at"`
 }
@@ -0,0 +1,136 @@
+package money
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+const (
+	pointsScale = 100
+)
+
+var (
+	ErrInvalidPoints    = errors.New("invalid points value")
+	ErrInvalidPrecision = errors.New("points value has more than two decimal places")
+)
+
+// Points is an amount of loyalty points stored as integer hundredths,
+// so 729.98 points are kept as 72998.
+type Points int64
+
+// ParsePoints parses a decimal number and rejects values that can't be
+// represented in hundredths exactly.
+func ParsePoints(value string) (Points, error) {
+	return parsePoints(value, false)
+}
+
+// ParseRoundedPoints parses a decimal number rounding it half away from zero
+// to hundredths.
+func ParseRoundedPoints(value string) (Points, error) {
+	return parsePoints(value, true)
+}
+
+func (p Points) IsPositive() bool {
+	return p > 0
+}
+
+func (p Points) String() string {
+	sign := ""
+	value := uint64(p)
+	if p < 0 {
+		sign = "-"
+		value = uint64(-p)
+	}
+
+	integer := value / pointsScale
+	fraction := value % pointsScale
+	if fraction == 0 {
+		return fmt.Sprintf("%s%d", sign, integer)
+	}
+
+	return strings.TrimSuffix(fmt.Sprintf("%s%d.%02d", sign, integer, fraction), "0")
+}
+
+func (p Points) MarshalJSON() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+func (p *Points) UnmarshalJSON(data []byte) error {
+	value := string(data)
+	if value == "null" {
+		return nil
+	}
+
+	points, err := ParsePoints(value)
+	if err != nil {
+		return err
+	}
+
+	*p = points
+
+	return nil
+}
+
+func (p Points) Value() (driver.Value, error) {
+	return p.String(), nil
+}
+
+func (p *Points) Scan(src any) error {
+	var err error
+	switch value := src.(type) {
+	case nil:
+		*p = 0
+	case int64:
+		*p, err = ParsePoints(strconv.FormatInt(value, 10))
+	case float64:
+		*p, err = ParseRoundedPoints(strconv.FormatFloat(value, 'f', -1, 64))
+	case string:
+		*p, err = ParseRoundedPoints(value)
+	case []byte:
+		*p, err = ParseRoundedPoints(string(value))
+	default:
+		return fmt.Errorf("unsupported points source type %T", src)
+	}
+
+	return err
+}
+
+func parsePoints(value string, round bool) (Points, error) {
+	rat, ok := new(big.Rat).SetString(strings.TrimSpace(value))
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidPoints, value)
+	}
+
+	rat.Mul(rat, big.NewRat(pointsScale, 1))
+	if !rat.IsInt() {
+		if !round {
+			return 0, fmt.Errorf("%w: %q", ErrInvalidPrecision, value)
+		}
+
+		rat = roundRat(rat)
+	}
+
+	hundredths := rat.Num()
+	if !hundredths.IsInt64() || hundredths.Int64() == math.MinInt64 {
+		return 0, fmt.Errorf("%w: %q is out of range", ErrInvalidPoints, value)
+	}
+
+	return Points(hundredths.Int64()), nil
+}
+
+func roundRat(rat *big.Rat) *big.Rat {
+	half := big.NewRat(1, 2)
+	if rat.Sign() < 0 {
+		half.Neg(half)
+	}
+
+	shifted := new(big.Rat).Add(rat, half)
+	rounded := new(big.Int).Quo(shifted.Num(), shifted.Denom())
+
+	return new(big.Rat).SetInt(rounded)
+}
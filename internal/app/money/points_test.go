@@ -0,0 +1,85 @@
+package money
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePoints(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  Points
+		err   error
+	}{
+		{name: "integer", value: "500", want: 50000},
+		{name: "two decimal places", value: "729.98", want: 72998},
+		{name: "one decimal place", value: "0.5", want: 50},
+		{name: "trailing zeros", value: "100.500", want: 10050},
+		{name: "exponent", value: "1e2", want: 10000},
+		{name: "negative", value: "-12.34", want: -1234},
+		{name: "three decimal places", value: "0.001", err: ErrInvalidPrecision},
+		{name: "not a number", value: "abc", err: ErrInvalidPoints},
+		{name: "out of range", value: "1e30", err: ErrInvalidPoints},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			points, err := ParsePoints(test.value)
+			if test.err != nil {
+				assert.ErrorIs(t, err, test.err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.want, points)
+		})
+	}
+}
+
+func TestParseRoundedPoints(t *testing.T) {
+	tests := []struct {
+		value string
+		want  Points
+	}{
+		{value: "729.984", want: 72998},
+		{value: "729.985", want: 72999},
+		{value: "-0.005", want: -1},
+		{value: "0.1", want: 10},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			points, err := ParseRoundedPoints(test.value)
+			require.NoError(t, err)
+			assert.Equal(t, test.want, points)
+		})
+	}
+}
+
+func TestPointsJSON(t *testing.T) {
+	var value struct {
+		Sum Points `json:"sum"`
+	}
+
+	err := json.Unmarshal([]byte(`{"sum": 751.1}`), &value)
+	require.NoError(t, err)
+	assert.Equal(t, Points(75110), value.Sum)
+
+	out, err := json.Marshal(value)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"sum": 751.1}`, string(out))
+
+	err = json.Unmarshal([]byte(`{"sum": 751.123}`), &value)
+	assert.ErrorIs(t, err, ErrInvalidPrecision)
+
+	err = json.Unmarshal([]byte(`{"sum": "751"}`), &value)
+	assert.ErrorIs(t, err, ErrInvalidPoints)
+
+	for points, want := range map[Points]string{0: "0", 5: "0.05", 50: "0.5", -1234: "-12.34", 50000: "500"} {
+		assert.Equal(t, want, points.String())
+	}
+}
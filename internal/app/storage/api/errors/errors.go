@@ -9,13 +9,23 @@ var (
 	ErrOrderNumberExists    = errors.New("order with given number already exists in storage")
 	ErrOrderNumberNotFound  = errors.New("order with given number doesn't exist in storage")
 	ErrOrderForUserNotFound = errors.New("order with given number doesn't exist for given user in storage")
+	ErrOrderProcessed       = errors.New("order with given number has final status")
 
 	ErrUserExistsTable   = errors.New("given user exist in table")
 	ErrUserNotFoundTable = errors.New("given user doesn't exist in table")
+	ErrUserDeleted       = errors.New("given user has been deleted")
 
 	ErrNotEnoughSum = errors.New("not enough sum")
 
 	ErrWithdrawalsForUserNotFound = errors.New("withdrawals not found for given user")
+	ErrLedgerForUserNotFound      = errors.New("ledger entries not found for given user")
 
 	ErrOrdersForUpdateNotFound = errors.New("orders for update not found")
+
+	ErrIdempotencyKeyExists   = errors.New("idempotency key already exists for given user")
+	ErrIdempotencyKeyNotFound = errors.New("idempotency key doesn't exist for given user")
+
+	ErrAccrualCallbackExists = errors.New("accrual callback with given signature has already been processed")
+
+	ErrRefreshTokenNotFound = errors.New("refresh token doesn't exist or is expired")
 )
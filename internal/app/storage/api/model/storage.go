@@ -2,6 +2,7 @@ package model
 
 import (
 	"context"
+	"time"
 
 	"github.com/avGenie/go-loyalty-system/internal/app/entity"
 )
@@ -11,14 +12,43 @@ type Storage interface {
 
 	CreateUser(ctx context.Context, user entity.User) error
 	GetUser(ctx context.Context, user entity.User) (entity.User, error)
+	GetUserByID(ctx context.Context, userID entity.UserID) (entity.User, error)
+	FindUserByLogin(ctx context.Context, login string) (entity.User, error)
+	UpdateUserPassword(ctx context.Context, userID entity.UserID, password string) error
+	DeleteUser(ctx context.Context, userID entity.UserID) error
+	GrantUserRole(ctx context.Context, logins []string, role entity.UserRole) error
+
+	CreateRefreshToken(ctx context.Context, token entity.RefreshToken) error
+	RotateRefreshToken(ctx context.Context, oldHash string, token entity.RefreshToken) (entity.UserID, error)
+	RevokeUserTokens(ctx context.Context, userID entity.UserID, accessToken entity.AccessToken) error
+	IsTokenRevoked(ctx context.Context, userID entity.UserID, tokenID string) (bool, error)
+
+	GetLoginBlock(ctx context.Context, keys []string) (time.Duration, error)
+	RegisterLoginFailure(ctx context.Context, key string, throttle entity.LoginThrottle) (entity.LoginAttempt, error)
+	ResetLoginFailures(ctx context.Context, key string) error
 
 	UploadOrder(ctx context.Context, userID entity.UserID, orderNumber entity.OrderNumber) (entity.UserID, error)
-	GetOrdersForUpdate(ctx context.Context, count, offset int) (entity.UpdateUserOrders, error)
-	GetUserOrders(ctx context.Context, userID entity.UserID) (entity.Orders, error)
+	UploadOrders(ctx context.Context, userID entity.UserID, numbers []entity.OrderNumber) (entity.OrderUploadResults, error)
+	GetOrdersForUpdate(ctx context.Context, count int, lease time.Duration) (entity.UpdateUserOrders, error)
+	GetUserOrders(ctx context.Context, userID entity.UserID, query entity.ListQuery) (entity.Orders, error)
 	UpdateOrders(ctx context.Context, orders entity.UpdateUserOrders) error
+	ScheduleOrderChecks(ctx context.Context, checks entity.OrderChecks) error
+	GetOrderOwner(ctx context.Context, number entity.OrderNumber) (entity.UserID, error)
+	RecheckOrder(ctx context.Context, number entity.OrderNumber) error
+	ApplyAccrualCallback(ctx context.Context, signature string, ttl time.Duration, order entity.Order) error
+	GetOrderEvents(ctx context.Context, userID entity.UserID, afterID int64) (entity.OrderEvents, error)
+	ListenOrderEvents(ctx context.Context) (<-chan entity.OrderEvent, error)
+	DeleteExpiredOrderEvents(ctx context.Context, retention time.Duration) error
 
 	GetUserBalance(ctx context.Context, userID entity.UserID) (entity.UserBalance, error)
+	AdjustUserBalance(ctx context.Context, userID entity.UserID, adjustment entity.Adjustment) error
+	GetUserLedger(ctx context.Context, userID entity.UserID) (entity.LedgerEntries, error)
 
 	WithdrawUser(ctx context.Context, userID entity.UserID, withdraw entity.Withdraw) error
-	GetUserWithdrawals(ctx context.Context, userID entity.UserID) (entity.Withdrawals, error)
+	WithdrawUserIdempotent(ctx context.Context, userID entity.UserID, withdraw entity.Withdraw, key entity.IdempotencyKey) error
+	GetIdempotencyKey(ctx context.Context, userID entity.UserID, key string) (entity.IdempotencyKey, error)
+	StoreIdempotencyKey(ctx context.Context, userID entity.UserID, key entity.IdempotencyKey) error
+	GetUserWithdrawals(ctx context.Context, userID entity.UserID, query entity.ListQuery) (entity.Withdrawals, error)
+
+	CreateAuditRecord(ctx context.Context, record entity.AuditRecord) error
 }
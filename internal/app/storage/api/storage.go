@@ -1,11 +1,22 @@
 package storage
 
 import (
+	"context"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/avGenie/go-loyalty-system/internal/app/config"
+	"github.com/avGenie/go-loyalty-system/internal/app/entity"
 	"github.com/avGenie/go-loyalty-system/internal/app/storage/api/model"
-	storage "github.com/avGenie/go-loyalty-system/internal/app/storage/postgres"
+	memory "github.com/avGenie/go-loyalty-system/internal/app/storage/memory"
+	postgres "github.com/avGenie/go-loyalty-system/internal/app/storage/postgres"
+)
+
+const (
+	memoryStoragePrefix = "memory://"
+
+	grantRoleTimeout = 10 * time.Second
 )
 
 func InitStorage(config config.Config) (model.Storage, error) {
@@ -13,5 +24,40 @@ func InitStorage(config config.Config) (model.Storage, error) {
 		return nil, fmt.Errorf("empty database config")
 	}
 
-	return storage.NewPostgresStorage(config.DBConnect)
+	var storage model.Storage
+	if strings.HasPrefix(config.DBConnect, memoryStoragePrefix) {
+		storage = memory.NewMemoryStorage()
+	} else {
+		postgresStorage, err := postgres.NewPostgresStorage(config.DBConnect)
+		if err != nil {
+			return nil, err
+		}
+		storage = postgresStorage
+	}
+
+	err := grantAdminRole(storage, config.AdminLoginList)
+	if err != nil {
+		storage.Close()
+		return nil, err
+	}
+
+	return storage, nil
+}
+
+// grantAdminRole grants the ADMIN role to the already registered users from
+// the admin logins list, the users registered later get it on registration.
+func grantAdminRole(storage model.Storage, logins []string) error {
+	if len(logins) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), grantRoleTimeout)
+	defer cancel()
+
+	err := storage.GrantUserRole(ctx, logins, entity.UserRoleAdmin)
+	if err != nil {
+		return fmt.Errorf("failed to grant admin role: %w", err)
+	}
+
+	return nil
 }
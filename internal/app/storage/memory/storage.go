@@ -0,0 +1,931 @@
+package storage
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/avGenie/go-loyalty-system/internal/app/entity"
+	"github.com/avGenie/go-loyalty-system/internal/app/money"
+	err_api "github.com/avGenie/go-loyalty-system/internal/app/storage/api/errors"
+	"github.com/avGenie/go-loyalty-system/internal/app/storage/api/model"
+	usecase "github.com/avGenie/go-loyalty-system/internal/app/usecase/order/storage_utils"
+)
+
+const (
+	orderEventsBufLen = 100
+)
+
+type memoryOrder struct {
+	userID      entity.UserID
+	order       entity.Order
+	lockedUntil time.Time
+	attempts    int
+	nextCheckAt time.Time
+	parked      bool
+}
+
+type memoryOrderEvent struct {
+	event     entity.OrderEvent
+	createdAt time.Time
+}
+
+type memoryLoginAttempt struct {
+	failures     int
+	lastFailure  time.Time
+	blockedUntil time.Time
+}
+
+type Memory struct {
+	model.Storage
+
+	mu sync.RWMutex
+
+	users       map[string]entity.User
+	orders      map[entity.OrderNumber]*memoryOrder
+	ordersList  []entity.OrderNumber
+	ledger      map[entity.UserID]entity.LedgerEntries
+	withdrawals map[entity.UserID]entity.Withdrawals
+	withdrawn   map[entity.OrderNumber]struct{}
+
+	idempotencyKeys  map[entity.UserID]map[string]entity.IdempotencyKey
+	accrualCallbacks map[string]time.Time
+	refreshTokens    map[string]entity.RefreshToken
+	revokedTokens    map[string]time.Time
+	loginAttempts    map[string]*memoryLoginAttempt
+	auditLog         []entity.AuditRecord
+
+	orderEvents      []memoryOrderEvent
+	lastOrderEventID int64
+
+	// listenersMu защищает слушателей отдельно, чтобы закрывать их каналы
+	// без блокировки хранилища
+	listenersMu         sync.Mutex
+	orderEventListeners []chan entity.OrderEvent
+}
+
+func NewMemoryStorage() *Memory {
+	return &Memory{
+		users:       make(map[string]entity.User),
+		orders:      make(map[entity.OrderNumber]*memoryOrder),
+		ledger:      make(map[entity.UserID]entity.LedgerEntries),
+		withdrawals: make(map[entity.UserID]entity.Withdrawals),
+		withdrawn:   make(map[entity.OrderNumber]struct{}),
+
+		idempotencyKeys:  make(map[entity.UserID]map[string]entity.IdempotencyKey),
+		accrualCallbacks: make(map[string]time.Time),
+		refreshTokens:    make(map[string]entity.RefreshToken),
+		revokedTokens:    make(map[string]time.Time),
+		loginAttempts:    make(map[string]*memoryLoginAttempt),
+	}
+}
+
+func (s *Memory) Close() error {
+	return nil
+}
+
+func (s *Memory) CreateUser(ctx context.Context, user entity.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[user.Login]; ok {
+		return err_api.ErrLoginExists
+	}
+
+	if _, ok := s.ledger[user.ID]; ok {
+		return err_api.ErrUserExistsTable
+	}
+
+	user.Status = entity.UserStatusActive
+	if len(user.Role) == 0 {
+		user.Role = entity.UserRoleUser
+	}
+	s.users[user.Login] = user
+	s.ledger[user.ID] = entity.LedgerEntries{}
+
+	return nil
+}
+
+func (s *Memory) GetUser(ctx context.Context, user entity.User) (entity.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	storageUser, ok := s.users[user.Login]
+	if !ok || storageUser.Status != entity.UserStatusActive {
+		return user, err_api.ErrLoginNotFound
+	}
+
+	user.ID = storageUser.ID
+	user.Password = storageUser.Password
+	user.Status = storageUser.Status
+	user.Role = storageUser.Role
+
+	return user, nil
+}
+
+func (s *Memory) GetUserByID(ctx context.Context, userID entity.UserID) (entity.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, ok := s.activeUser(userID)
+	if !ok {
+		return entity.User{}, err_api.ErrUserNotFoundTable
+	}
+
+	return user, nil
+}
+
+func (s *Memory) FindUserByLogin(ctx context.Context, login string) (entity.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, ok := s.users[login]
+	if !ok {
+		return entity.User{}, err_api.ErrLoginNotFound
+	}
+	user.Password = ""
+
+	return user, nil
+}
+
+func (s *Memory) UpdateUserPassword(ctx context.Context, userID entity.UserID, password string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.activeUser(userID)
+	if !ok {
+		return err_api.ErrUserNotFoundTable
+	}
+
+	user.Password = password
+	s.users[user.Login] = user
+
+	return nil
+}
+
+func (s *Memory) GrantUserRole(ctx context.Context, logins []string, role entity.UserRole) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, login := range logins {
+		user, ok := s.users[login]
+		if !ok {
+			continue
+		}
+
+		user.Role = role
+		s.users[login] = user
+	}
+
+	return nil
+}
+
+func (s *Memory) DeleteUser(ctx context.Context, userID entity.UserID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.activeUser(userID)
+	if !ok {
+		return err_api.ErrUserNotFoundTable
+	}
+
+	user.Status = entity.UserStatusDeleted
+	s.users[user.Login] = user
+
+	for hash, token := range s.refreshTokens {
+		if token.UserID == userID {
+			delete(s.refreshTokens, hash)
+		}
+	}
+
+	return nil
+}
+
+func (s *Memory) CreateRefreshToken(ctx context.Context, token entity.RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.createRefreshToken(token)
+}
+
+func (s *Memory) RotateRefreshToken(ctx context.Context, oldHash string, token entity.RefreshToken) (entity.UserID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	oldToken, ok := s.refreshTokens[oldHash]
+	if !ok {
+		return entity.UserID(""), err_api.ErrRefreshTokenNotFound
+	}
+	delete(s.refreshTokens, oldHash)
+
+	if oldToken.ExpiresAt.Before(time.Now()) {
+		return entity.UserID(""), err_api.ErrRefreshTokenNotFound
+	}
+
+	token.UserID = oldToken.UserID
+	err := s.createRefreshToken(token)
+	if err != nil {
+		return entity.UserID(""), err
+	}
+
+	return token.UserID, nil
+}
+
+func (s *Memory) RevokeUserTokens(ctx context.Context, userID entity.UserID, accessToken entity.AccessToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for hash, token := range s.refreshTokens {
+		if token.UserID == userID {
+			delete(s.refreshTokens, hash)
+		}
+	}
+
+	now := time.Now()
+	for tokenID, expiresAt := range s.revokedTokens {
+		if expiresAt.Before(now) {
+			delete(s.revokedTokens, tokenID)
+		}
+	}
+
+	if len(accessToken.ID) != 0 {
+		s.revokedTokens[accessToken.ID] = accessToken.ExpiresAt
+	}
+
+	return nil
+}
+
+func (s *Memory) IsTokenRevoked(ctx context.Context, userID entity.UserID, tokenID string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.activeUser(userID); !ok {
+		return true, nil
+	}
+
+	_, ok := s.revokedTokens[tokenID]
+
+	return ok, nil
+}
+
+func (s *Memory) GetLoginBlock(ctx context.Context, keys []string) (time.Duration, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	var blocked time.Duration
+	for _, key := range keys {
+		attempt, ok := s.loginAttempts[key]
+		if !ok {
+			continue
+		}
+
+		blocked = max(blocked, attempt.blockedUntil.Sub(now))
+	}
+
+	return blocked, nil
+}
+
+func (s *Memory) RegisterLoginFailure(ctx context.Context, key string, throttle entity.LoginThrottle) (entity.LoginAttempt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	attempt, ok := s.loginAttempts[key]
+	if !ok || attempt.lastFailure.Before(now.Add(-throttle.Window)) {
+		attempt = &memoryLoginAttempt{}
+		s.loginAttempts[key] = attempt
+	}
+
+	attempt.failures++
+	attempt.lastFailure = now
+
+	blocked := throttle.BlockDuration(attempt.failures)
+	if blocked > 0 {
+		attempt.blockedUntil = now.Add(blocked)
+	}
+
+	return entity.LoginAttempt{
+		Key:      key,
+		Failures: attempt.failures,
+		Blocked:  blocked,
+	}, nil
+}
+
+func (s *Memory) ResetLoginFailures(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.loginAttempts, key)
+
+	return nil
+}
+
+func (s *Memory) createRefreshToken(token entity.RefreshToken) error {
+	if _, ok := s.ledger[token.UserID]; !ok {
+		return err_api.ErrUserNotFoundTable
+	}
+
+	s.refreshTokens[token.Hash] = token
+
+	return nil
+}
+
+func (s *Memory) UploadOrder(ctx context.Context, userID entity.UserID, orderNumber entity.OrderNumber) (entity.UserID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkUserActive(userID); err != nil {
+		return entity.UserID(""), err
+	}
+
+	if order, ok := s.orders[orderNumber]; ok {
+		return order.userID, err_api.ErrOrderNumberExists
+	}
+
+	s.orders[orderNumber] = &memoryOrder{
+		userID: userID,
+		order: entity.Order{
+			Number:      orderNumber,
+			Status:      entity.StatusNewOrder,
+			DateCreated: currentTime(),
+		},
+	}
+	s.ordersList = append(s.ordersList, orderNumber)
+
+	return entity.UserID(""), nil
+}
+
+// UploadOrders reports a number repeated in the batch as already uploaded,
+// as the postgres storage does.
+func (s *Memory) UploadOrders(ctx context.Context, userID entity.UserID, numbers []entity.OrderNumber) (entity.OrderUploadResults, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkUserActive(userID); err != nil {
+		return nil, err
+	}
+
+	results := make(entity.OrderUploadResults, 0, len(numbers))
+	for _, number := range numbers {
+		if order, ok := s.orders[number]; ok {
+			status := entity.UploadConflictOrder
+			if order.userID == userID {
+				status = entity.UploadExistsOrder
+			}
+
+			results = append(results, entity.CreateOrderUploadResult(number, status))
+			continue
+		}
+
+		s.orders[number] = &memoryOrder{
+			userID: userID,
+			order: entity.Order{
+				Number:      number,
+				Status:      entity.StatusNewOrder,
+				DateCreated: currentTime(),
+			},
+		}
+		s.ordersList = append(s.ordersList, number)
+
+		results = append(results, entity.CreateOrderUploadResult(number, entity.UploadAcceptedOrder))
+	}
+
+	return results, nil
+}
+
+func (s *Memory) GetUserOrders(ctx context.Context, userID entity.UserID, query entity.ListQuery) (entity.Orders, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var orders entity.Orders
+	var cursors []entity.Cursor
+	for _, number := range s.ordersList {
+		order := s.orders[number]
+		if order.userID != userID || !query.HasStatus(order.order.Status) {
+			continue
+		}
+
+		cursor, err := entity.CreateOrderCursor(order.order)
+		if err != nil {
+			return nil, err
+		}
+
+		orders = append(orders, order.order)
+		cursors = append(cursors, cursor)
+	}
+
+	orders = selectPage(orders, cursors, query)
+	if len(orders) == 0 {
+		return nil, err_api.ErrOrderForUserNotFound
+	}
+
+	return orders, nil
+}
+
+func (s *Memory) UpdateOrders(ctx context.Context, orders entity.UpdateUserOrders) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.updateOrders(orders)
+}
+
+func (s *Memory) updateOrders(orders entity.UpdateUserOrders) error {
+	for _, order := range orders {
+		if _, ok := s.orders[order.Order.Number]; !ok {
+			return err_api.ErrOrderNumberNotFound
+		}
+	}
+
+	for _, order := range orders {
+		storageOrder := s.orders[order.Order.Number]
+		accrual := storageOrder.order.Accrual
+
+		storageOrder.lockedUntil = time.Time{}
+
+		if !usecase.IsUpdatableAccrualStatus(order.Order.Status, storageOrder.order.Status) {
+			continue
+		}
+
+		storageOrder.order.Status = order.Order.Status
+		storageOrder.order.Accrual = order.Order.Accrual
+		s.appendOrderEvent(entity.CreateOrderEvent(order.UserID, order.Order))
+
+		if usecase.IsUpdateDBBalance(accrual, order.Order.Status) {
+			s.appendLedgerEntry(entity.CreateAccrualLedgerEntry(order.UserID, order.Order))
+		}
+	}
+
+	return nil
+}
+
+// GetOrderEvents returns the user order events with IDs greater than afterID.
+func (s *Memory) GetOrderEvents(ctx context.Context, userID entity.UserID, afterID int64) (entity.OrderEvents, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var events entity.OrderEvents
+	for _, stored := range s.orderEvents {
+		if stored.event.UserID == userID && stored.event.ID > afterID {
+			events = append(events, stored.event)
+		}
+	}
+
+	return events, nil
+}
+
+func (s *Memory) DeleteExpiredOrderEvents(ctx context.Context, retention time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	expired := 0
+	for expired < len(s.orderEvents) && now.Sub(s.orderEvents[expired].createdAt) > retention {
+		expired++
+	}
+	s.orderEvents = s.orderEvents[expired:]
+
+	return nil
+}
+
+// ListenOrderEvents delivers the order events until the context is done.
+// The channel is closed early if the listener falls behind, the missed
+// events can be read with GetOrderEvents then.
+func (s *Memory) ListenOrderEvents(ctx context.Context) (<-chan entity.OrderEvent, error) {
+	listener := make(chan entity.OrderEvent, orderEventsBufLen)
+
+	s.listenersMu.Lock()
+	s.orderEventListeners = append(s.orderEventListeners, listener)
+	s.listenersMu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		s.listenersMu.Lock()
+		defer s.listenersMu.Unlock()
+
+		s.removeOrderEventsListener(listener)
+	}()
+
+	return listener, nil
+}
+
+func (s *Memory) ScheduleOrderChecks(ctx context.Context, checks entity.OrderChecks) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for _, check := range checks {
+		order, ok := s.orders[check.Number]
+		if !ok {
+			return err_api.ErrOrderNumberNotFound
+		}
+
+		order.attempts = check.Attempts
+		order.nextCheckAt = now.Add(check.Delay)
+		order.parked = check.Parked
+		order.lockedUntil = time.Time{}
+	}
+
+	return nil
+}
+
+func (s *Memory) GetOrderOwner(ctx context.Context, number entity.OrderNumber) (entity.UserID, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	order, ok := s.orders[number]
+	if !ok {
+		return entity.UserID(""), err_api.ErrOrderNumberNotFound
+	}
+
+	return order.userID, nil
+}
+
+func (s *Memory) RecheckOrder(ctx context.Context, number entity.OrderNumber) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order, ok := s.orders[number]
+	if !ok {
+		return err_api.ErrOrderNumberNotFound
+	}
+
+	if order.order.Status == entity.StatusProcessedOrder || order.order.Status == entity.StatusInvalidOrder {
+		return err_api.ErrOrderProcessed
+	}
+
+	order.attempts = 0
+	order.nextCheckAt = time.Time{}
+	order.parked = false
+	order.lockedUntil = time.Time{}
+
+	return nil
+}
+
+func (s *Memory) ApplyAccrualCallback(ctx context.Context, signature string, ttl time.Duration, order entity.Order) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for storedSignature, expiresAt := range s.accrualCallbacks {
+		if expiresAt.Before(now) {
+			delete(s.accrualCallbacks, storedSignature)
+		}
+	}
+
+	if _, ok := s.accrualCallbacks[signature]; ok {
+		return err_api.ErrAccrualCallbackExists
+	}
+
+	storageOrder, ok := s.orders[order.Number]
+	if !ok {
+		return err_api.ErrOrderNumberNotFound
+	}
+
+	if usecase.IsFinalOrderStatus(storageOrder.order.Status) {
+		return err_api.ErrOrderProcessed
+	}
+
+	err := s.updateOrders(entity.UpdateUserOrders{
+		{
+			UserID: storageOrder.userID,
+			Order:  order,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	s.accrualCallbacks[signature] = now.Add(ttl)
+
+	return nil
+}
+
+func (s *Memory) GetUserBalance(ctx context.Context, userID entity.UserID) (entity.UserBalance, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entries, ok := s.ledger[userID]
+	if !ok {
+		return entity.UserBalance{}, err_api.ErrUserNotFoundTable
+	}
+
+	userBalance := entity.UserBalance{
+		UserID: userID,
+	}
+	for _, entry := range entries {
+		userBalance.Balance += entry.Amount
+		if entry.Kind == entity.LedgerEntryWithdrawal {
+			userBalance.Withdrawans -= entry.Amount
+		}
+	}
+
+	return userBalance, nil
+}
+
+func (s *Memory) WithdrawUser(ctx context.Context, userID entity.UserID, withdraw entity.Withdraw) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.withdrawUser(userID, withdraw)
+}
+
+func (s *Memory) WithdrawUserIdempotent(ctx context.Context, userID entity.UserID, withdraw entity.Withdraw, key entity.IdempotencyKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.idempotencyKeys[userID][key.Key]; ok {
+		return err_api.ErrIdempotencyKeyExists
+	}
+
+	err := s.withdrawUser(userID, withdraw)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := s.idempotencyKeys[userID]; !ok {
+		s.idempotencyKeys[userID] = make(map[string]entity.IdempotencyKey)
+	}
+	s.idempotencyKeys[userID][key.Key] = key
+
+	return nil
+}
+
+func (s *Memory) GetIdempotencyKey(ctx context.Context, userID entity.UserID, key string) (entity.IdempotencyKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	idempotencyKey, ok := s.idempotencyKeys[userID][key]
+	if !ok {
+		return entity.IdempotencyKey{}, err_api.ErrIdempotencyKeyNotFound
+	}
+
+	return idempotencyKey, nil
+}
+
+func (s *Memory) StoreIdempotencyKey(ctx context.Context, userID entity.UserID, key entity.IdempotencyKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.idempotencyKeys[userID][key.Key]; ok {
+		return err_api.ErrIdempotencyKeyExists
+	}
+
+	if _, ok := s.idempotencyKeys[userID]; !ok {
+		s.idempotencyKeys[userID] = make(map[string]entity.IdempotencyKey)
+	}
+	s.idempotencyKeys[userID][key.Key] = key
+
+	return nil
+}
+
+func (s *Memory) withdrawUser(userID entity.UserID, withdraw entity.Withdraw) error {
+	if err := s.checkUserActive(userID); err != nil {
+		return err
+	}
+
+	entries, ok := s.ledger[userID]
+	if !ok {
+		return err_api.ErrUserNotFoundTable
+	}
+
+	var sum money.Points
+	for _, entry := range entries {
+		sum += entry.Amount
+	}
+
+	diffSum := sum - withdraw.Sum
+	if diffSum < 0 {
+		return err_api.ErrNotEnoughSum
+	}
+
+	if _, ok := s.withdrawn[withdraw.OrderNumber]; ok {
+		return err_api.ErrOrderNumberExists
+	}
+
+	withdraw.DateCreated = currentTime()
+	s.withdrawn[withdraw.OrderNumber] = struct{}{}
+	s.withdrawals[userID] = append(s.withdrawals[userID], withdraw)
+	s.appendLedgerEntry(entity.CreateWithdrawalLedgerEntry(userID, withdraw))
+
+	return nil
+}
+
+func (s *Memory) AdjustUserBalance(ctx context.Context, userID entity.UserID, adjustment entity.Adjustment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkUserActive(userID); err != nil {
+		return err
+	}
+
+	var sum money.Points
+	for _, entry := range s.ledger[userID] {
+		sum += entry.Amount
+	}
+
+	if sum+adjustment.Amount < 0 {
+		return err_api.ErrNotEnoughSum
+	}
+
+	s.appendLedgerEntry(entity.CreateAdjustmentLedgerEntry(userID, adjustment))
+
+	return nil
+}
+
+func (s *Memory) GetUserLedger(ctx context.Context, userID entity.UserID) (entity.LedgerEntries, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	userEntries := s.ledger[userID]
+	if len(userEntries) == 0 {
+		return nil, err_api.ErrLedgerForUserNotFound
+	}
+
+	entries := make(entity.LedgerEntries, len(userEntries))
+	copy(entries, userEntries)
+
+	return entries, nil
+}
+
+func (s *Memory) GetUserWithdrawals(ctx context.Context, userID entity.UserID, query entity.ListQuery) (entity.Withdrawals, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	userWithdrawals := s.withdrawals[userID]
+	cursors := make([]entity.Cursor, 0, len(userWithdrawals))
+	for _, withdraw := range userWithdrawals {
+		cursor, err := entity.CreateWithdrawCursor(withdraw)
+		if err != nil {
+			return nil, err
+		}
+
+		cursors = append(cursors, cursor)
+	}
+
+	withdrawals := selectPage(userWithdrawals, cursors, query)
+	if len(withdrawals) == 0 {
+		return nil, err_api.ErrWithdrawalsForUserNotFound
+	}
+
+	return withdrawals, nil
+}
+
+func (s *Memory) GetOrdersForUpdate(ctx context.Context, count int, lease time.Duration) (entity.UpdateUserOrders, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	var userOrders entity.UpdateUserOrders
+	for _, number := range s.ordersList {
+		if len(userOrders) == count {
+			break
+		}
+
+		order := s.orders[number]
+		if order.order.Status == entity.StatusProcessedOrder || order.order.Status == entity.StatusInvalidOrder {
+			continue
+		}
+
+		if order.parked || now.Before(order.nextCheckAt) || now.Before(order.lockedUntil) {
+			continue
+		}
+
+		order.lockedUntil = now.Add(lease)
+		userOrders = append(userOrders, entity.UpdateUserOrder{
+			UserID:   order.userID,
+			Order:    order.order,
+			Attempts: order.attempts,
+		})
+	}
+
+	if len(userOrders) == 0 {
+		return nil, err_api.ErrOrdersForUpdateNotFound
+	}
+
+	return userOrders, nil
+}
+
+func (s *Memory) CreateAuditRecord(ctx context.Context, record entity.AuditRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.auditLog = append(s.auditLog, record)
+
+	return nil
+}
+
+func (s *Memory) activeUser(userID entity.UserID) (entity.User, bool) {
+	for _, user := range s.users {
+		if user.ID == userID {
+			return user, user.Status == entity.UserStatusActive
+		}
+	}
+
+	return entity.User{}, false
+}
+
+func (s *Memory) checkUserActive(userID entity.UserID) error {
+	for _, user := range s.users {
+		if user.ID != userID {
+			continue
+		}
+
+		if user.Status != entity.UserStatusActive {
+			return err_api.ErrUserDeleted
+		}
+
+		return nil
+	}
+
+	return err_api.ErrUserNotFoundTable
+}
+
+// appendOrderEvent stores the event and sends it to the listeners. It must
+// be called under the storage lock, so the listeners get the events in the
+// ID order.
+func (s *Memory) appendOrderEvent(event entity.OrderEvent) {
+	s.lastOrderEventID++
+	event.ID = s.lastOrderEventID
+	event.DateCreated = currentTime()
+	s.orderEvents = append(s.orderEvents, memoryOrderEvent{
+		event:     event,
+		createdAt: time.Now(),
+	})
+
+	s.listenersMu.Lock()
+	defer s.listenersMu.Unlock()
+
+	// отправка не блокируется, иначе медленный слушатель остановит хранилище
+	var lagging []chan entity.OrderEvent
+	for _, listener := range s.orderEventListeners {
+		select {
+		case listener <- event:
+		default:
+			lagging = append(lagging, listener)
+		}
+	}
+
+	for _, listener := range lagging {
+		s.removeOrderEventsListener(listener)
+	}
+}
+
+// removeOrderEventsListener must be called under listenersMu, channels are
+// closed only here, so a listener can't be closed twice.
+func (s *Memory) removeOrderEventsListener(listener chan entity.OrderEvent) {
+	for i, stored := range s.orderEventListeners {
+		if stored == listener {
+			s.orderEventListeners = append(s.orderEventListeners[:i], s.orderEventListeners[i+1:]...)
+			close(listener)
+			return
+		}
+	}
+}
+
+func (s *Memory) appendLedgerEntry(entry entity.LedgerEntry) {
+	if _, ok := s.ledger[entry.UserID]; !ok {
+		return
+	}
+
+	entry.DateCreated = currentTime()
+	s.ledger[entry.UserID] = append(s.ledger[entry.UserID], entry)
+}
+
+// selectPage filters items by the query date range and cursor, sorts them
+// and cuts the page. cursors[i] holds the sort key of items[i].
+func selectPage[T any](items []T, cursors []entity.Cursor, query entity.ListQuery) []T {
+	indexes := make([]int, 0, len(items))
+	for i, cursor := range cursors {
+		if !query.InRange(cursor.Date) {
+			continue
+		}
+
+		if query.Cursor != nil && !query.Cursor.IsAfter(cursor.Date, cursor.Key, query.Sort) {
+			continue
+		}
+
+		indexes = append(indexes, i)
+	}
+
+	sort.SliceStable(indexes, func(i, j int) bool {
+		first, second := cursors[indexes[i]], cursors[indexes[j]]
+		return first.IsAfter(second.Date, second.Key, query.Sort)
+	})
+
+	if query.Limit > 0 && len(indexes) > query.Limit {
+		indexes = indexes[:query.Limit]
+	}
+
+	page := make([]T, 0, len(indexes))
+	for _, index := range indexes {
+		page = append(page, items[index])
+	}
+
+	return page
+}
+
+func currentTime() string {
+	return time.Now().UTC().Format(time.RFC3339Nano)
+}
@@ -0,0 +1,95 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TYPE ledger_entry_kind AS ENUM('ACCRUAL', 'WITHDRAWAL', 'ADJUSTMENT');
+CREATE TYPE ledger_account AS ENUM('USER', 'ACCRUALS', 'WITHDRAWALS', 'ADJUSTMENTS');
+
+CREATE TABLE IF NOT EXISTS ledger_entries(
+	id BIGSERIAL PRIMARY KEY,
+	user_id uuid NOT NULL REFERENCES users(id),
+	kind ledger_entry_kind NOT NULL,
+	order_number VARCHAR(16) REFERENCES orders(number),
+	withdrawal_number VARCHAR(16) REFERENCES withdrawals(order_number),
+	date_created TIMESTAMP NOT NULL DEFAULT now(),
+	CONSTRAINT entry_source CHECK (
+		(kind = 'ACCRUAL' AND order_number IS NOT NULL) OR
+		(kind = 'WITHDRAWAL' AND withdrawal_number IS NOT NULL) OR
+		kind = 'ADJUSTMENT'
+	)
+);
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_user ON ledger_entries(user_id);
+
+CREATE TABLE IF NOT EXISTS ledger_postings(
+	entry_id BIGINT NOT NULL REFERENCES ledger_entries(id),
+	account ledger_account NOT NULL,
+	amount numeric NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_ledger_postings_entry ON ledger_postings(entry_id);
+
+INSERT INTO ledger_entries(user_id, kind, order_number, date_created)
+	SELECT uo.user_id, 'ACCRUAL', o.number, o.date_created FROM orders AS o
+		JOIN users_orders AS uo
+			ON o.number=uo.order_number
+	WHERE o.status='PROCESSED' AND o.accrual > 0;
+
+INSERT INTO ledger_entries(user_id, kind, withdrawal_number, date_created)
+	SELECT uw.user_id, 'WITHDRAWAL', w.order_number, w.process_date FROM withdrawals AS w
+		JOIN users_withdrawals AS uw
+			ON w.order_number=uw.order_number;
+
+INSERT INTO ledger_postings(entry_id, account, amount)
+	SELECT e.id, 'USER', o.accrual FROM ledger_entries AS e
+		JOIN orders AS o ON e.order_number=o.number
+	UNION ALL
+	SELECT e.id, 'ACCRUALS', -o.accrual FROM ledger_entries AS e
+		JOIN orders AS o ON e.order_number=o.number
+	UNION ALL
+	SELECT e.id, 'USER', -w.sum FROM ledger_entries AS e
+		JOIN withdrawals AS w ON e.withdrawal_number=w.order_number
+	UNION ALL
+	SELECT e.id, 'WITHDRAWALS', w.sum FROM ledger_entries AS e
+		JOIN withdrawals AS w ON e.withdrawal_number=w.order_number;
+
+INSERT INTO ledger_entries(user_id, kind)
+	SELECT b.user_id, 'ADJUSTMENT' FROM balance AS b
+	WHERE b.sum <> (
+		SELECT COALESCE(SUM(p.amount), 0) FROM ledger_entries AS e
+			JOIN ledger_postings AS p
+				ON p.entry_id=e.id AND p.account='USER'
+		WHERE e.user_id=b.user_id
+	);
+
+WITH opening AS (
+	SELECT e.id, b.sum - (
+		SELECT COALESCE(SUM(p.amount), 0) FROM ledger_entries AS ue
+			JOIN ledger_postings AS p
+				ON p.entry_id=ue.id AND p.account='USER'
+		WHERE ue.user_id=e.user_id
+	) AS diff FROM ledger_entries AS e
+		JOIN balance AS b ON e.user_id=b.user_id
+	WHERE e.kind='ADJUSTMENT'
+)
+INSERT INTO ledger_postings(entry_id, account, amount)
+	SELECT id, 'USER', diff FROM opening
+	UNION ALL
+	SELECT id, 'ADJUSTMENTS', -diff FROM opening;
+
+DROP TABLE withdrawn_balance;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS withdrawn_balance(
+	user_id uuid REFERENCES users(id),
+	withdrawn numeric DEFAULT 0
+);
+INSERT INTO withdrawn_balance(user_id, withdrawn)
+	SELECT b.user_id, COALESCE(SUM(w.sum), 0) FROM balance AS b
+		LEFT JOIN users_withdrawals AS uw ON b.user_id=uw.user_id
+		LEFT JOIN withdrawals AS w ON uw.order_number=w.order_number
+	GROUP BY b.user_id;
+
+DROP TABLE ledger_postings;
+DROP TABLE ledger_entries;
+DROP TYPE ledger_account;
+DROP TYPE ledger_entry_kind;
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS idempotency_keys(
+	user_id uuid NOT NULL REFERENCES users(id),
+	key VARCHAR(255) NOT NULL,
+	request_hash VARCHAR(64) NOT NULL,
+	status_code INTEGER NOT NULL,
+	body BYTEA,
+	date_created TIMESTAMP NOT NULL DEFAULT now(),
+	PRIMARY KEY(user_id, key)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE idempotency_keys;
+-- +goose StatementEnd
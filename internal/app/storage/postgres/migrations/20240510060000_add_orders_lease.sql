@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE orders
+	ADD COLUMN locked_until TIMESTAMP;
+CREATE INDEX IF NOT EXISTS idx_orders_for_update ON orders(date_created)
+	WHERE status IN ('NEW', 'PROCESSING');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX idx_orders_for_update;
+ALTER TABLE orders
+	DROP COLUMN locked_until;
+-- +goose StatementEnd
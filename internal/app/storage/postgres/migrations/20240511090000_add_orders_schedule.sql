@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE orders
+	ADD COLUMN check_attempts INT NOT NULL DEFAULT 0,
+	ADD COLUMN next_check_at TIMESTAMP NOT NULL DEFAULT now(),
+	ADD COLUMN parked_at TIMESTAMP;
+DROP INDEX IF EXISTS idx_orders_for_update;
+CREATE INDEX IF NOT EXISTS idx_orders_for_update ON orders(next_check_at)
+	WHERE status IN ('NEW', 'PROCESSING') AND parked_at IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_orders_for_update;
+CREATE INDEX IF NOT EXISTS idx_orders_for_update ON orders(date_created)
+	WHERE status IN ('NEW', 'PROCESSING');
+ALTER TABLE orders
+	DROP COLUMN check_attempts,
+	DROP COLUMN next_check_at,
+	DROP COLUMN parked_at;
+-- +goose StatementEnd
@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS refresh_tokens(
+	token_hash VARCHAR(64) PRIMARY KEY,
+	user_id uuid NOT NULL REFERENCES users(id),
+	expires_at TIMESTAMPTZ NOT NULL,
+	date_created TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user ON refresh_tokens(user_id);
+
+CREATE TABLE IF NOT EXISTS revoked_tokens(
+	token_id VARCHAR(36) PRIMARY KEY,
+	expires_at TIMESTAMPTZ NOT NULL
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE revoked_tokens;
+DROP TABLE refresh_tokens;
+-- +goose StatementEnd
@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS login_attempts(
+	key TEXT PRIMARY KEY,
+	failures INT NOT NULL DEFAULT 0,
+	last_failure TIMESTAMPTZ NOT NULL DEFAULT now(),
+	blocked_until TIMESTAMPTZ
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE login_attempts;
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TYPE user_status AS ENUM('ACTIVE', 'DELETED');
+
+ALTER TABLE users
+	ADD COLUMN status user_status NOT NULL DEFAULT 'ACTIVE',
+	ADD COLUMN deleted_at TIMESTAMPTZ;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users
+	DROP COLUMN deleted_at,
+	DROP COLUMN status;
+
+DROP TYPE user_status;
+-- +goose StatementEnd
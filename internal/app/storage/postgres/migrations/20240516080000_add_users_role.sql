@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TYPE user_role AS ENUM('USER', 'ADMIN');
+
+ALTER TABLE users
+	ADD COLUMN role user_role NOT NULL DEFAULT 'USER';
+
+CREATE TABLE IF NOT EXISTS admin_audit_log(
+	id BIGSERIAL PRIMARY KEY,
+	admin_id uuid NOT NULL REFERENCES users(id),
+	action TEXT NOT NULL,
+	target TEXT NOT NULL,
+	date_created TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS idx_admin_audit_log_admin ON admin_audit_log(admin_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE admin_audit_log;
+
+ALTER TABLE users
+	DROP COLUMN role;
+
+DROP TYPE user_role;
+-- +goose StatementEnd
@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TYPE adjustment_reason AS ENUM('GOODWILL', 'FRAUD_REVERSAL', 'CORRECTION', 'OPENING_BALANCE');
+
+ALTER TABLE ledger_entries
+	ADD COLUMN reason adjustment_reason,
+	ADD COLUMN comment TEXT,
+	ADD COLUMN admin_id uuid REFERENCES users(id);
+
+UPDATE ledger_entries SET reason='OPENING_BALANCE' WHERE kind='ADJUSTMENT';
+
+ALTER TABLE ledger_entries
+	ADD CONSTRAINT adjustment_reason CHECK ((kind = 'ADJUSTMENT') = (reason IS NOT NULL));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE ledger_entries
+	DROP CONSTRAINT adjustment_reason,
+	DROP COLUMN admin_id,
+	DROP COLUMN comment,
+	DROP COLUMN reason;
+
+DROP TYPE adjustment_reason;
+-- +goose StatementEnd
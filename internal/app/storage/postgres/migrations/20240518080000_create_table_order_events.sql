@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS order_events(
+	id BIGSERIAL PRIMARY KEY,
+	user_id uuid NOT NULL REFERENCES users(id),
+	number VARCHAR(16) NOT NULL REFERENCES orders(number),
+	status order_status NOT NULL,
+	accrual numeric NOT NULL DEFAULT 0,
+	date_created TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS order_events_user_id ON order_events(user_id, id);
+CREATE INDEX IF NOT EXISTS order_events_date_created ON order_events(date_created);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE order_events;
+-- +goose StatementEnd
@@ -4,17 +4,20 @@ import (
 	"context"
 	"database/sql"
 	"embed"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/avGenie/go-loyalty-system/internal/app/entity"
+	"github.com/avGenie/go-loyalty-system/internal/app/money"
 	err_api "github.com/avGenie/go-loyalty-system/internal/app/storage/api/errors"
 	"github.com/avGenie/go-loyalty-system/internal/app/storage/api/model"
 	usecase "github.com/avGenie/go-loyalty-system/internal/app/usecase/order/storage_utils"
 	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
-	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/jackc/pgx/v5/stdlib"
 	"github.com/pressly/goose/v3"
 	"go.uber.org/zap"
 )
@@ -24,6 +27,14 @@ const (
 	migrationFolder = "migrations"
 )
 
+const (
+	orderEventsChannel     = "order_events"
+	orderEventsBufLen      = 100
+	orderEventsDeleteBatch = 1000
+
+	listenCloseTimeout = time.Second
+)
+
 //go:embed migrations/*.sql
 var migrationFs embed.FS
 
@@ -67,30 +78,30 @@ func (s *Postgres) CreateUser(ctx context.Context, user entity.User) error {
 	}
 	defer tx.Rollback()
 
-	queryInsertUser := `INSERT INTO users VALUES(@userID, @login, @password)`
+	role := user.Role
+	if len(role) == 0 {
+		role = entity.UserRoleUser
+	}
+
+	queryInsertUser := `INSERT INTO users(id, login, password, role) VALUES(@userID, @login, @password, @role)`
 	args := pgx.NamedArgs{
 		"userID":   user.ID.String(),
 		"login":    user.Login,
 		"password": user.Password,
+		"role":     role,
 	}
 
-	err = s.execInsertContext(ctx, err_api.ErrLoginExists, queryInsertUser, args)
+	err = s.execInsertContext(ctx, tx, err_api.ErrLoginExists, queryInsertUser, args)
 	if err != nil {
 		return fmt.Errorf("error while inserting user: %w", err)
 	}
 
 	queryInsertUserBalance := `INSERT INTO balance(user_id) VALUES($1)`
-	err = s.execInsertContext(ctx, err_api.ErrUserExistsTable, queryInsertUserBalance, user.ID)
+	err = s.execInsertContext(ctx, tx, err_api.ErrUserExistsTable, queryInsertUserBalance, user.ID)
 	if err != nil {
 		return fmt.Errorf("error while inserting user balance: %w", err)
 	}
 
-	queryInsertUserWithdrawnBalance := `INSERT INTO withdrawn_balance(user_id) VALUES($1)`
-	err = s.execInsertContext(ctx, err_api.ErrUserExistsTable, queryInsertUserWithdrawnBalance, user.ID)
-	if err != nil {
-		return fmt.Errorf("error while inserting user balance withdrawans: %w", err)
-	}
-
 	err = tx.Commit()
 	if err != nil {
 		return fmt.Errorf("failed to commit transaction in postgres while creating user: %w", err)
@@ -100,7 +111,7 @@ func (s *Postgres) CreateUser(ctx context.Context, user entity.User) error {
 }
 
 func (s *Postgres) GetUser(ctx context.Context, user entity.User) (entity.User, error) {
-	query := `SELECT id, password FROM users WHERE login=@login`
+	query := `SELECT id, password, status, role FROM users WHERE login=@login AND status='ACTIVE'`
 	args := pgx.NamedArgs{
 		"login": user.Login,
 	}
@@ -114,7 +125,7 @@ func (s *Postgres) GetUser(ctx context.Context, user entity.User) (entity.User,
 		return user, fmt.Errorf("error while postgres request execution while getting user: %w", row.Err())
 	}
 
-	err := row.Scan(&user.ID, &user.Password)
+	err := row.Scan(&user.ID, &user.Password, &user.Status, &user.Role)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return user, err_api.ErrLoginNotFound
@@ -125,347 +136,1359 @@ func (s *Postgres) GetUser(ctx context.Context, user entity.User) (entity.User,
 	return user, nil
 }
 
-func (s *Postgres) UploadOrder(ctx context.Context, userID entity.UserID, orderNumber entity.OrderNumber) (entity.UserID, error) {
-	tx, err := s.db.BeginTx(ctx, nil)
-	if err != nil {
-		return entity.UserID(""), fmt.Errorf("failed to create transaction in postgres while uploading order: %w", err)
-	}
-	defer tx.Rollback()
+func (s *Postgres) GetUserByID(ctx context.Context, userID entity.UserID) (entity.User, error) {
+	query := `SELECT login, password, status, role FROM users WHERE id=$1 AND status='ACTIVE'`
 
-	queryInsertOrder := `INSERT INTO orders(number) VALUES(@number)`
-	args := pgx.NamedArgs{
-		"number": orderNumber,
+	row := s.db.QueryRowContext(ctx, query, userID)
+	if row.Err() != nil {
+		return entity.User{}, fmt.Errorf("error while postgres request execution while getting user by id: %w", row.Err())
 	}
 
-	_, err = s.db.ExecContext(ctx, queryInsertOrder, args)
+	user := entity.User{
+		ID: userID,
+	}
+	err := row.Scan(&user.Login, &user.Password, &user.Status, &user.Role)
 	if err != nil {
-		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) && pgerrcode.IsIntegrityConstraintViolation(pgErr.Code) {
-			userID, err := s.getUserIDByOrderNumber(ctx, orderNumber)
-			if err != nil {
-				return entity.UserID(""), fmt.Errorf("error in postgres while uploading order: %w", err)
-			}
-
-			return userID, err_api.ErrOrderNumberExists
+		if errors.Is(err, sql.ErrNoRows) {
+			return entity.User{}, err_api.ErrUserNotFoundTable
 		}
-
-		return entity.UserID(""), fmt.Errorf("unable to insert row to postgres while uploading order: %w", err)
+		return entity.User{}, fmt.Errorf("error while processing response row in postgres while getting user by id: %w", err)
 	}
 
-	queryInsertOrderUser := `INSERT INTO users_orders VALUES(@user_id, @order_number)`
-	args = pgx.NamedArgs{
-		"user_id":      userID,
-		"order_number": orderNumber,
-	}
+	return user, nil
+}
 
-	_, err = s.db.ExecContext(ctx, queryInsertOrderUser, args)
-	if err != nil {
-		return entity.UserID(""), fmt.Errorf("unable to insert user id and order number to users_orders table in postgres while uploading order: %w", err)
+// FindUserByLogin returns the user with any status, so deleted accounts
+// can be looked up by support staff.
+func (s *Postgres) FindUserByLogin(ctx context.Context, login string) (entity.User, error) {
+	query := `SELECT id, status, role FROM users WHERE login=$1`
+
+	row := s.db.QueryRowContext(ctx, query, login)
+	if row.Err() != nil {
+		return entity.User{}, fmt.Errorf("error while postgres request execution while finding user by login: %w", row.Err())
 	}
 
-	err = tx.Commit()
+	user := entity.User{
+		Login: login,
+	}
+	err := row.Scan(&user.ID, &user.Status, &user.Role)
 	if err != nil {
-		return entity.UserID(""), fmt.Errorf("failed to commit transaction in postgres while uploading order: %w", err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return entity.User{}, err_api.ErrLoginNotFound
+		}
+		return entity.User{}, fmt.Errorf("error while processing response row in postgres while finding user by login: %w", err)
 	}
 
-	return entity.UserID(""), nil
+	return user, nil
 }
 
-func (s *Postgres) GetUserOrders(ctx context.Context, userID entity.UserID) (entity.Orders, error) {
-	query := `SELECT o.number, o.status, o.accrual, o.date_created FROM orders AS o
-				JOIN users_orders AS uo
-					ON o.number=uo.order_number
-			  WHERE uo.user_id=$1`
+func (s *Postgres) UpdateUserPassword(ctx context.Context, userID entity.UserID, password string) error {
+	query := `UPDATE users SET password=$2 WHERE id=$1 AND status='ACTIVE'`
 
-	rows, err := s.db.QueryContext(ctx, query, userID)
+	result, err := s.db.ExecContext(ctx, query, userID, password)
 	if err != nil {
-		return nil, fmt.Errorf("error in postgres request execution while getting user orders: %w", err)
-	}
-
-	if rows.Err() != nil {
-		return nil, fmt.Errorf("error in postgres requested rows while getting user orders: %w", rows.Err())
+		return fmt.Errorf("failed to update user password in postgres: %w", err)
 	}
 
-	var orders entity.Orders
-	for rows.Next() {
-		var order entity.Order
-		err := rows.Scan(&order.Number, &order.Status, &order.Accrual, &order.DateCreated)
-		if err != nil {
-			return nil, fmt.Errorf("error while parsing row while getting users order from postgres: %w", err)
-		}
+	return checkAffectedUser(result)
+}
 
-		orders = append(orders, order)
+// GrantUserRole sets the role of the users with the given logins.
+// Unknown logins are skipped.
+func (s *Postgres) GrantUserRole(ctx context.Context, logins []string, role entity.UserRole) error {
+	query := `UPDATE users SET role=@role WHERE login=ANY(@logins::text[]) AND role<>@role`
+	args := pgx.NamedArgs{
+		"logins": logins,
+		"role":   role,
 	}
 
-	if len(orders) == 0 {
-		return nil, err_api.ErrOrderForUserNotFound
+	_, err := s.db.ExecContext(ctx, query, args)
+	if err != nil {
+		return fmt.Errorf("failed to grant user role in postgres: %w", err)
 	}
 
-	return orders, nil
+	return nil
 }
 
-func (s *Postgres) UpdateOrders(ctx context.Context, orders entity.UpdateUserOrders) error {
+// DeleteUser marks the user as deleted and drops its refresh tokens.
+// Orders, withdrawals and the ledger are kept for accounting.
+func (s *Postgres) DeleteUser(ctx context.Context, userID entity.UserID) error {
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction while updating orders in postgres: %w", err)
+		return fmt.Errorf("failed to begin transaction while deleting user in postgres: %w", err)
 	}
 	defer tx.Rollback()
 
-	selectQuery := `SELECT status, accrual FROM orders WHERE number=$1 FOR UPDATE`
-	stmtSelect, err := tx.PrepareContext(ctx, selectQuery)
+	queryUpdate := `UPDATE users SET status='DELETED', deleted_at=now() WHERE id=$1 AND status='ACTIVE'`
+	result, err := tx.ExecContext(ctx, queryUpdate, userID)
 	if err != nil {
-		return fmt.Errorf("failed to prepare select query while updating orders in postgres: %w", err)
+		return fmt.Errorf("failed to delete user in postgres: %w", err)
 	}
 
-	updateOrderQuery := `UPDATE orders SET status=$1::order_status, accrual=$2 WHERE number=$3`
-	stmtUpdateOrder, err := tx.PrepareContext(ctx, updateOrderQuery)
+	err = checkAffectedUser(result)
 	if err != nil {
-		return fmt.Errorf("failed to prepare update query while updating orders in postgres: %w", err)
+		return err
 	}
 
-	queryUpdateBalance := `UPDATE balance SET sum=sum+$1 WHERE user_id=$2`
-	stmtUpdateBalance, err := tx.PrepareContext(ctx, queryUpdateBalance)
+	queryDeleteRefresh := `DELETE FROM refresh_tokens WHERE user_id=$1`
+	_, err = tx.ExecContext(ctx, queryDeleteRefresh, userID)
 	if err != nil {
-		return fmt.Errorf("failed to prepare update query while updating user balances in postgres: %w", err)
+		return fmt.Errorf("failed to delete refresh tokens while deleting user in postgres: %w", err)
 	}
 
-	for _, order := range orders {
-		row := stmtSelect.QueryRowContext(ctx, order.Order.Number)
-		if row == nil {
-			return fmt.Errorf("error while postgres request preparation while selecting order for update")
-		}
-	
-		if row.Err() != nil {
-			return fmt.Errorf("error while postgres request execution while selecting order for update: %w", row.Err())
-		}
-	
-		var status string
-		var accrual float64
-		err := row.Scan(&status, &accrual)
-		if err != nil {
-			if errors.Is(err, sql.ErrNoRows) {
-				return err_api.ErrOrderNumberNotFound
-			}
-			return fmt.Errorf("error while processing response row in postgres while selecting order for update: %w", err)
-		}
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("unable to commit transaction while deleting user in postgres: %w", err)
+	}
 
-		if !usecase.IsUpdatableAccrualStatus(order.Order.Status, entity.OrderStatus(status)) {
-			continue
-		}
+	return nil
+}
 
-		_, err = stmtUpdateOrder.ExecContext(ctx, order.Order.Status, order.Order.Accrual, order.Order.Number)
-		if err != nil {
-			return fmt.Errorf("failed to update query while updating orders in postgres: %w", err)
-		}
+func (s *Postgres) CreateRefreshToken(ctx context.Context, token entity.RefreshToken) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction while creating refresh token in postgres: %w", err)
+	}
+	defer tx.Rollback()
 
-		if usecase.IsUpdateDBBalance(accrual, order.Order.Status) {
-			_, err = stmtUpdateBalance.ExecContext(ctx, order.Order.Accrual, order.UserID)
-			if err != nil {
-				return fmt.Errorf("failed to update query while updating orders in postgres: %w", err)
-			}
-		}
+	err = s.insertRefreshToken(ctx, tx, token)
+	if err != nil {
+		return err
 	}
 
 	err = tx.Commit()
 	if err != nil {
-		return fmt.Errorf("unable to commit transaction while updating orders in postgres: %w", err)
+		return fmt.Errorf("unable to commit transaction while creating refresh token in postgres: %w", err)
 	}
 
 	return nil
 }
 
-func (s *Postgres) GetUserBalance(ctx context.Context, userID entity.UserID) (entity.UserBalance, error) {
-	query := `SELECT b.sum, wb.withdrawn FROM balance AS b
-				INNER JOIN withdrawn_balance AS wb
-					ON b.user_id=wb.user_id 
-			  WHERE b.user_id=$1`
-
-	row := s.db.QueryRowContext(ctx, query, userID)
-	if row == nil {
-		return entity.UserBalance{}, fmt.Errorf("error while postgres request preparation while getting user balance")
+// RotateRefreshToken deletes the refresh token with oldHash and stores
+// the new one for the same user, so every refresh token is used once.
+func (s *Postgres) RotateRefreshToken(ctx context.Context, oldHash string, token entity.RefreshToken) (entity.UserID, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return entity.UserID(""), fmt.Errorf("failed to begin transaction while rotating refresh token in postgres: %w", err)
 	}
+	defer tx.Rollback()
 
+	queryDelete := `DELETE FROM refresh_tokens WHERE token_hash=$1 RETURNING user_id, expires_at > now()`
+	row := tx.QueryRowContext(ctx, queryDelete, oldHash)
 	if row.Err() != nil {
-		return entity.UserBalance{}, fmt.Errorf("error while postgres request execution while getting user balance: %w", row.Err())
+		return entity.UserID(""), fmt.Errorf("error while postgres request execution while rotating refresh token: %w", row.Err())
 	}
 
-	userBalance := entity.UserBalance{
-		UserID: userID,
-	}
-	err := row.Scan(&userBalance.Balance, &userBalance.Withdrawans)
+	var isActive bool
+	err = row.Scan(&token.UserID, &isActive)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return entity.UserBalance{}, err_api.ErrUserNotFoundTable
+			return entity.UserID(""), err_api.ErrRefreshTokenNotFound
 		}
-		return entity.UserBalance{}, fmt.Errorf("error while processing response row in postgres while getting user balance: %w", err)
+
+		return entity.UserID(""), fmt.Errorf("error while processing response row in postgres while rotating refresh token: %w", err)
 	}
 
-	return userBalance, nil
-}
+	if !isActive {
+		// просроченный токен удаляется, но новый не выдается
+		err = tx.Commit()
+		if err != nil {
+			return entity.UserID(""), fmt.Errorf("unable to commit transaction while rotating refresh token in postgres: %w", err)
+		}
 
-func (s *Postgres) WithdrawUser(ctx context.Context, userID entity.UserID, withdraw entity.Withdraw) error {
-	tx, err := s.db.BeginTx(ctx, nil)
-	if err != nil {
-		return fmt.Errorf("failed to begin transaction while withdrawing user bonuses in postgres: %w", err)
+		return entity.UserID(""), err_api.ErrRefreshTokenNotFound
 	}
-	defer tx.Rollback()
 
-	sum, err := s.selectUserBalanceOnUpdate(ctx, userID)
+	err = s.insertRefreshToken(ctx, tx, token)
 	if err != nil {
-		return fmt.Errorf("error while withdrawing user bonuses in postgres: %w", err)
+		return entity.UserID(""), err
 	}
 
-	diffSum := sum - withdraw.Sum
-	if diffSum < 0 {
-		return err_api.ErrNotEnoughSum
+	err = tx.Commit()
+	if err != nil {
+		return entity.UserID(""), fmt.Errorf("unable to commit transaction while rotating refresh token in postgres: %w", err)
 	}
 
-	queryInsertWithdrawal := `INSERT INTO withdrawals(order_number, sum) VALUES(@orderNumber, @sum)`
-	argsWithdrawal := pgx.NamedArgs{
-		"orderNumber": withdraw.OrderNumber,
-		"sum":         withdraw.Sum,
-	}
-	err = s.execInsertContext(ctx, err_api.ErrOrderNumberExists, queryInsertWithdrawal, argsWithdrawal)
+	return token.UserID, nil
+}
+
+// RevokeUserTokens deletes all refresh tokens of the user and remembers
+// the access token id until the token expires.
+func (s *Postgres) RevokeUserTokens(ctx context.Context, userID entity.UserID, accessToken entity.AccessToken) error {
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("error while inserting user withdrawal while withdrawing user bonuses in postgres: %w", err)
+		return fmt.Errorf("failed to begin transaction while revoking user tokens in postgres: %w", err)
 	}
+	defer tx.Rollback()
 
-	queryInsertUserWithdrawals := `INSERT INTO users_withdrawals VALUES($1, $2)`
-	err = s.execInsertContext(ctx, err_api.ErrOrderNumberExists, queryInsertUserWithdrawals, userID, withdraw.OrderNumber)
+	queryDeleteRefresh := `DELETE FROM refresh_tokens WHERE user_id=$1`
+	_, err = tx.ExecContext(ctx, queryDeleteRefresh, userID)
 	if err != nil {
-		return fmt.Errorf("error while inserting user withdrawals while withdrawing user bonuses in postgres: %w", err)
+		return fmt.Errorf("failed to delete refresh tokens in postgres: %w", err)
 	}
 
-	queryUpdateBalance := `UPDATE balance SET sum=$1 WHERE user_id=$2`
-	_, err = s.db.ExecContext(ctx, queryUpdateBalance, diffSum, userID)
+	queryDeleteRevoked := `DELETE FROM revoked_tokens WHERE expires_at < now()`
+	_, err = tx.ExecContext(ctx, queryDeleteRevoked)
 	if err != nil {
-		return fmt.Errorf("error while updating balance while withdrawing user bonuses in postgres: %w", err)
+		return fmt.Errorf("failed to delete expired revoked tokens in postgres: %w", err)
 	}
 
-	queryUpdateWithdrawBalance := `UPDATE withdrawn_balance SET withdrawn=withdrawn+$1 WHERE user_id=$2`
-	_, err = s.db.ExecContext(ctx, queryUpdateWithdrawBalance, withdraw.Sum, userID)
-	if err != nil {
-		return fmt.Errorf("error while updating withdrawn sum while withdrawing user bonuses in postgres: %w", err)
+	if len(accessToken.ID) != 0 {
+		queryInsert := `INSERT INTO revoked_tokens(token_id, expires_at) VALUES($1, $2)
+							ON CONFLICT (token_id) DO NOTHING`
+		_, err = tx.ExecContext(ctx, queryInsert, accessToken.ID, accessToken.ExpiresAt)
+		if err != nil {
+			return fmt.Errorf("unable to insert revoked token to postgres: %w", err)
+		}
 	}
 
 	err = tx.Commit()
 	if err != nil {
-		return fmt.Errorf("unable to commit transaction while withdrawing user bonuses in postgres: %w", err)
+		return fmt.Errorf("unable to commit transaction while revoking user tokens in postgres: %w", err)
 	}
 
 	return nil
 }
 
-func (s *Postgres) GetUserWithdrawals(ctx context.Context, userID entity.UserID) (entity.Withdrawals, error) {
-	query := `SELECT w.order_number, w.sum, w.process_date FROM users_withdrawals AS uw
-				JOIN withdrawals AS w
-					ON uw.order_number=w.order_number
-			  WHERE uw.user_id=$1
-			  ORDER BY w.process_date`
+// IsTokenRevoked reports whether the token has been revoked by logout
+// or its user has been deleted.
+func (s *Postgres) IsTokenRevoked(ctx context.Context, userID entity.UserID, tokenID string) (bool, error) {
+	query := `SELECT EXISTS(SELECT 1 FROM revoked_tokens WHERE token_id=$2)
+				OR NOT EXISTS(SELECT 1 FROM users WHERE id=$1 AND status='ACTIVE')`
 
-	rows, err := s.db.QueryContext(ctx, query, userID)
-	if err != nil {
-		return nil, fmt.Errorf("error in postgres request execution while getting user withdrawals: %w", err)
+	row := s.db.QueryRowContext(ctx, query, userID, tokenID)
+	if row.Err() != nil {
+		return false, fmt.Errorf("error while postgres request execution while checking revoked token: %w", row.Err())
 	}
 
-	if rows.Err() != nil {
-		return nil, fmt.Errorf("error in postgres requested rows while getting user withdrawals: %w", rows.Err())
+	var isRevoked bool
+	err := row.Scan(&isRevoked)
+	if err != nil {
+		return false, fmt.Errorf("error while processing response row in postgres while checking revoked token: %w", err)
 	}
 
-	var withdrawals entity.Withdrawals
-	for rows.Next() {
-		var withdraw entity.Withdraw
-		err := rows.Scan(&withdraw.OrderNumber, &withdraw.Sum, &withdraw.DateCreated)
-		if err != nil {
-			return nil, fmt.Errorf("error while parsing row while getting users withdrawals from postgres: %w", err)
-		}
+	return isRevoked, nil
+}
 
-		withdrawals = append(withdrawals, withdraw)
+// GetLoginBlock returns how long the login attempts with given keys
+// stay blocked.
+func (s *Postgres) GetLoginBlock(ctx context.Context, keys []string) (time.Duration, error) {
+	query := `SELECT COALESCE(EXTRACT(EPOCH FROM MAX(blocked_until) - now()), 0) FROM login_attempts
+			  WHERE key=ANY($1) AND blocked_until > now()`
+
+	row := s.db.QueryRowContext(ctx, query, keys)
+	if row.Err() != nil {
+		return 0, fmt.Errorf("error while postgres request execution while getting login block: %w", row.Err())
 	}
 
-	if len(withdrawals) == 0 {
-		return nil, err_api.ErrWithdrawalsForUserNotFound
+	var seconds float64
+	err := row.Scan(&seconds)
+	if err != nil {
+		return 0, fmt.Errorf("error while processing response row in postgres while getting login block: %w", err)
 	}
 
-	return withdrawals, nil
+	return time.Duration(seconds * float64(time.Second)), nil
 }
 
-func (s *Postgres) GetOrdersForUpdate(ctx context.Context, count, offset int) (entity.UpdateUserOrders, error) {
-	querySelect := `SELECT uo.user_id, o.number, o.status, o.accrual, o.date_created FROM orders AS o
-						JOIN users_orders AS uo
-							ON o.number=uo.order_number
-					WHERE o.status NOT IN ('PROCESSED', 'INVALID')
-					LIMIT $1 OFFSET $2`
-
-	rows, err := s.db.QueryContext(ctx, querySelect, count, offset)
+// RegisterLoginFailure counts the failed attempt, failures older than
+// the throttle window are forgotten, and blocks the key accordingly.
+func (s *Postgres) RegisterLoginFailure(ctx context.Context, key string, throttle entity.LoginThrottle) (entity.LoginAttempt, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return nil, fmt.Errorf("error in postgres request execution while getting orders for update: %w", err)
+		return entity.LoginAttempt{}, fmt.Errorf("failed to begin transaction while registering login failure in postgres: %w", err)
 	}
+	defer tx.Rollback()
 
-	if rows.Err() != nil {
-		return nil, fmt.Errorf("error in postgres requested rows while getting orders for update: %w", rows.Err())
+	queryUpsert := `INSERT INTO login_attempts(key, failures, last_failure) VALUES(@key, 1, now())
+					ON CONFLICT (key) DO UPDATE SET
+						failures=CASE
+							WHEN login_attempts.last_failure < now() - make_interval(secs => @window) THEN 1
+							ELSE login_attempts.failures + 1
+						END,
+						last_failure=now()
+					RETURNING failures`
+	args := pgx.NamedArgs{
+		"key":    key,
+		"window": throttle.Window.Seconds(),
 	}
 
-	var userOrders entity.UpdateUserOrders
-	for rows.Next() {
-		var userOrder entity.UpdateUserOrder
-		err := rows.Scan(&userOrder.UserID, &userOrder.Order.Number, &userOrder.Order.Status, &userOrder.Order.Accrual, &userOrder.Order.DateCreated)
+	row := tx.QueryRowContext(ctx, queryUpsert, args)
+	if row.Err() != nil {
+		return entity.LoginAttempt{}, fmt.Errorf("error while postgres request execution while registering login failure: %w", row.Err())
+	}
+
+	attempt := entity.LoginAttempt{
+		Key: key,
+	}
+	err = row.Scan(&attempt.Failures)
+	if err != nil {
+		return entity.LoginAttempt{}, fmt.Errorf("error while processing response row in postgres while registering login failure: %w", err)
+	}
+
+	attempt.Blocked = throttle.BlockDuration(attempt.Failures)
+	if attempt.Blocked > 0 {
+		queryBlock := `UPDATE login_attempts SET blocked_until=now() + make_interval(secs => $2) WHERE key=$1`
+		_, err = tx.ExecContext(ctx, queryBlock, key, attempt.Blocked.Seconds())
 		if err != nil {
-			return nil, fmt.Errorf("error while parsing row while getting orders for update from postgres: %w", err)
+			return entity.LoginAttempt{}, fmt.Errorf("failed to block login attempts in postgres: %w", err)
 		}
-
-		userOrders = append(userOrders, userOrder)
 	}
 
-	if len(userOrders) == 0 {
-		return nil, err_api.ErrOrdersForUpdateNotFound
+	err = tx.Commit()
+	if err != nil {
+		return entity.LoginAttempt{}, fmt.Errorf("unable to commit transaction while registering login failure in postgres: %w", err)
 	}
 
-	return userOrders, nil
+	return attempt, nil
 }
 
-func (s *Postgres) selectUserBalanceOnUpdate(ctx context.Context, userID entity.UserID) (float64, error) {
-	querySelect := `SELECT sum FROM balance WHERE user_id=$1 FOR UPDATE`
-	row := s.db.QueryRowContext(ctx, querySelect, userID)
-	if row == nil {
-		return 0, fmt.Errorf("error while postgres request preparation while selecting user balance")
-	}
-
-	if row.Err() != nil {
-		return 0, fmt.Errorf("error while postgres request execution while selecting user balance: %w", row.Err())
-	}
+func (s *Postgres) ResetLoginFailures(ctx context.Context, key string) error {
+	query := `DELETE FROM login_attempts WHERE key=$1`
 
-	var sum float64
-	err := row.Scan(&sum)
+	_, err := s.db.ExecContext(ctx, query, key)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return 0, err_api.ErrUserNotFoundTable
-		}
-
-		return 0, fmt.Errorf("error while processing response row in postgres: %w", err)
+		return fmt.Errorf("failed to reset login failures in postgres: %w", err)
 	}
 
-	return sum, nil
+	return nil
 }
 
-func (s *Postgres) getUserIDByOrderNumber(ctx context.Context, orderNumber entity.OrderNumber) (entity.UserID, error) {
-	query := `SELECT uo.user_id FROM orders AS o
-				JOIN users_orders AS uo
-					ON o.number=uo.order_number
-			  WHERE o.number=$1`
-	row := s.db.QueryRowContext(ctx, query, orderNumber)
-	if row == nil {
-		return entity.UserID(""), fmt.Errorf("error while postgres request preparation while getting user id by order number")
+func (s *Postgres) insertRefreshToken(ctx context.Context, tx *sql.Tx, token entity.RefreshToken) error {
+	queryDelete := `DELETE FROM refresh_tokens WHERE user_id=$1 AND expires_at < now()`
+	_, err := tx.ExecContext(ctx, queryDelete, token.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to delete expired refresh tokens in postgres: %w", err)
 	}
 
-	if row.Err() != nil {
-		return entity.UserID(""), fmt.Errorf("error while postgres request execution while getting user id by order number: %w", row.Err())
+	queryInsert := `INSERT INTO refresh_tokens(token_hash, user_id, expires_at) VALUES(@hash, @userID, @expiresAt)`
+	args := pgx.NamedArgs{
+		"hash":      token.Hash,
+		"userID":    token.UserID,
+		"expiresAt": token.ExpiresAt,
 	}
 
-	var userID entity.UserID
-	err := row.Scan(&userID)
+	err = s.execInsertContext(ctx, tx, err_api.ErrUserNotFoundTable, queryInsert, args)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("error while inserting refresh token: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Postgres) UploadOrder(ctx context.Context, userID entity.UserID, orderNumber entity.OrderNumber) (entity.UserID, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return entity.UserID(""), fmt.Errorf("failed to create transaction in postgres while uploading order: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = s.checkUserActive(ctx, tx, userID)
+	if err != nil {
+		return entity.UserID(""), fmt.Errorf("error in postgres while uploading order: %w", err)
+	}
+
+	queryInsertOrder := `INSERT INTO orders(number) VALUES(@number)`
+	args := pgx.NamedArgs{
+		"number": orderNumber,
+	}
+
+	_, err = tx.ExecContext(ctx, queryInsertOrder, args)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgerrcode.IsIntegrityConstraintViolation(pgErr.Code) {
+			userID, err := s.getUserIDByOrderNumber(ctx, orderNumber)
+			if err != nil {
+				return entity.UserID(""), fmt.Errorf("error in postgres while uploading order: %w", err)
+			}
+
+			return userID, err_api.ErrOrderNumberExists
+		}
+
+		return entity.UserID(""), fmt.Errorf("unable to insert row to postgres while uploading order: %w", err)
+	}
+
+	queryInsertOrderUser := `INSERT INTO users_orders VALUES(@user_id, @order_number)`
+	args = pgx.NamedArgs{
+		"user_id":      userID,
+		"order_number": orderNumber,
+	}
+
+	_, err = tx.ExecContext(ctx, queryInsertOrderUser, args)
+	if err != nil {
+		return entity.UserID(""), fmt.Errorf("unable to insert user id and order number to users_orders table in postgres while uploading order: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return entity.UserID(""), fmt.Errorf("failed to commit transaction in postgres while uploading order: %w", err)
+	}
+
+	return entity.UserID(""), nil
+}
+
+// UploadOrders inserts the unique order numbers in one transaction and
+// reports the upload result of each of them. A number repeated in the batch
+// is accepted once and reported as already uploaded afterwards.
+func (s *Postgres) UploadOrders(ctx context.Context, userID entity.UserID, numbers []entity.OrderNumber) (entity.OrderUploadResults, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create transaction in postgres while uploading orders: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = s.checkUserActive(ctx, tx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error in postgres while uploading orders: %w", err)
+	}
+
+	batch := make([]string, 0, len(numbers))
+	for _, number := range numbers {
+		batch = append(batch, string(number))
+	}
+
+	// сортируем номера, чтобы конкурентные загрузки брали блокировки в одном порядке,
+	// повторы в пакете отбрасываем, чтобы номер был принят один раз
+	queryInsertOrders := `INSERT INTO orders(number)
+				SELECT b.number FROM (SELECT DISTINCT number FROM unnest(@numbers::text[]) AS number) AS b
+				ORDER BY b.number COLLATE "C"
+			  ON CONFLICT (number) DO NOTHING
+			  RETURNING number`
+	args := pgx.NamedArgs{
+		"numbers": batch,
+	}
+
+	rows, err := tx.QueryContext(ctx, queryInsertOrders, args)
+	if err != nil {
+		return nil, fmt.Errorf("unable to insert rows to postgres while uploading orders: %w", err)
+	}
+
+	inserted := make(map[entity.OrderNumber]bool)
+	var insertedNumbers []string
+	for rows.Next() {
+		var number entity.OrderNumber
+		err := rows.Scan(&number)
+		if err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("error while parsing inserted order number in postgres while uploading orders: %w", err)
+		}
+
+		inserted[number] = true
+		insertedNumbers = append(insertedNumbers, string(number))
+	}
+	rows.Close()
+
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("error in postgres inserted rows while uploading orders: %w", rows.Err())
+	}
+
+	queryInsertOrdersUser := `INSERT INTO users_orders(user_id, order_number)
+				SELECT @user_id, unnest(@numbers::text[])`
+	args = pgx.NamedArgs{
+		"user_id": userID,
+		"numbers": insertedNumbers,
+	}
+
+	_, err = tx.ExecContext(ctx, queryInsertOrdersUser, args)
+	if err != nil {
+		return nil, fmt.Errorf("unable to insert user id and order numbers to users_orders table in postgres while uploading orders: %w", err)
+	}
+
+	owners, err := s.getOrdersOwners(ctx, tx, batch)
+	if err != nil {
+		return nil, fmt.Errorf("error in postgres while uploading orders: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, fmt.Errorf("failed to commit transaction in postgres while uploading orders: %w", err)
+	}
+
+	results := make(entity.OrderUploadResults, 0, len(numbers))
+	for _, number := range numbers {
+		status := entity.UploadConflictOrder
+		if inserted[number] {
+			status = entity.UploadAcceptedOrder
+			// повтор номера в пакете уже загружен, как и в памяти
+			delete(inserted, number)
+		} else if owners[number] == userID {
+			status = entity.UploadExistsOrder
+		}
+
+		results = append(results, entity.CreateOrderUploadResult(number, status))
+	}
+
+	return results, nil
+}
+
+// GetUserOrders returns a page of the user orders ordered by upload date.
+func (s *Postgres) GetUserOrders(ctx context.Context, userID entity.UserID, listQuery entity.ListQuery) (entity.Orders, error) {
+	direction, comparison := sortDirection(listQuery.Sort)
+	query := fmt.Sprintf(`SELECT o.number, o.status, o.accrual, o.date_created FROM orders AS o
+				JOIN users_orders AS uo
+					ON o.number=uo.order_number
+			  WHERE uo.user_id=@userID
+				AND (cardinality(@statuses::text[]) = 0 OR o.status::text = ANY(@statuses::text[]))
+				AND (@from::timestamp IS NULL OR o.date_created >= @from::timestamp)
+				AND (@to::timestamp IS NULL OR o.date_created < @to::timestamp)
+				AND (@cursorDate::timestamp IS NULL OR
+					(o.date_created, o.number COLLATE "C") %[2]s (@cursorDate::timestamp, @cursorKey::text COLLATE "C"))
+			  ORDER BY o.date_created %[1]s, o.number COLLATE "C" %[1]s
+			  LIMIT @limit`, direction, comparison)
+
+	statuses := make([]string, 0, len(listQuery.Statuses))
+	for _, status := range listQuery.Statuses {
+		statuses = append(statuses, string(status))
+	}
+
+	args := listQueryArgs(listQuery)
+	args["userID"] = userID
+	args["statuses"] = statuses
+
+	rows, err := s.db.QueryContext(ctx, query, args)
+	if err != nil {
+		return nil, fmt.Errorf("error in postgres request execution while getting user orders: %w", err)
+	}
+
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("error in postgres requested rows while getting user orders: %w", rows.Err())
+	}
+
+	var orders entity.Orders
+	for rows.Next() {
+		var order entity.Order
+		err := rows.Scan(&order.Number, &order.Status, &order.Accrual, &order.DateCreated)
+		if err != nil {
+			return nil, fmt.Errorf("error while parsing row while getting users order from postgres: %w", err)
+		}
+
+		orders = append(orders, order)
+	}
+
+	if len(orders) == 0 {
+		return nil, err_api.ErrOrderForUserNotFound
+	}
+
+	return orders, nil
+}
+
+func (s *Postgres) UpdateOrders(ctx context.Context, orders entity.UpdateUserOrders) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction while updating orders in postgres: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = s.updateOrders(ctx, tx, orders)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("unable to commit transaction while updating orders in postgres: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Postgres) updateOrders(ctx context.Context, tx *sql.Tx, orders entity.UpdateUserOrders) error {
+	// блокировки берутся до блокировок заказов, иначе транзакции могут
+	// ждать друг друга
+	err := s.lockUsersOrderEvents(ctx, tx, orders)
+	if err != nil {
+		return err
+	}
+
+	selectQuery := `SELECT status, accrual FROM orders WHERE number=$1 FOR UPDATE`
+	stmtSelect, err := tx.PrepareContext(ctx, selectQuery)
+	if err != nil {
+		return fmt.Errorf("failed to prepare select query while updating orders in postgres: %w", err)
+	}
+
+	updateOrderQuery := `UPDATE orders SET status=$1::order_status, accrual=$2, locked_until=NULL WHERE number=$3`
+	stmtUpdateOrder, err := tx.PrepareContext(ctx, updateOrderQuery)
+	if err != nil {
+		return fmt.Errorf("failed to prepare update query while updating orders in postgres: %w", err)
+	}
+
+	releaseOrderQuery := `UPDATE orders SET locked_until=NULL WHERE number=$1`
+	stmtReleaseOrder, err := tx.PrepareContext(ctx, releaseOrderQuery)
+	if err != nil {
+		return fmt.Errorf("failed to prepare release query while updating orders in postgres: %w", err)
+	}
+
+	queryUpdateBalance := `UPDATE balance SET sum=sum+$1 WHERE user_id=$2`
+	stmtUpdateBalance, err := tx.PrepareContext(ctx, queryUpdateBalance)
+	if err != nil {
+		return fmt.Errorf("failed to prepare update query while updating user balances in postgres: %w", err)
+	}
+
+	for _, order := range orders {
+		row := stmtSelect.QueryRowContext(ctx, order.Order.Number)
+		if row == nil {
+			return fmt.Errorf("error while postgres request preparation while selecting order for update")
+		}
+	
+		if row.Err() != nil {
+			return fmt.Errorf("error while postgres request execution while selecting order for update: %w", row.Err())
+		}
+	
+		var status string
+		var accrual money.Points
+		err := row.Scan(&status, &accrual)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return err_api.ErrOrderNumberNotFound
+			}
+			return fmt.Errorf("error while processing response row in postgres while selecting order for update: %w", err)
+		}
+
+		if !usecase.IsUpdatableAccrualStatus(order.Order.Status, entity.OrderStatus(status)) {
+			_, err = stmtReleaseOrder.ExecContext(ctx, order.Order.Number)
+			if err != nil {
+				return fmt.Errorf("failed to release order lease while updating orders in postgres: %w", err)
+			}
+
+			continue
+		}
+
+		_, err = stmtUpdateOrder.ExecContext(ctx, order.Order.Status, order.Order.Accrual, order.Order.Number)
+		if err != nil {
+			return fmt.Errorf("failed to update query while updating orders in postgres: %w", err)
+		}
+
+		err = s.insertOrderEvent(ctx, tx, entity.CreateOrderEvent(order.UserID, order.Order))
+		if err != nil {
+			return fmt.Errorf("failed to insert order event while updating orders in postgres: %w", err)
+		}
+
+		if usecase.IsUpdateDBBalance(accrual, order.Order.Status) {
+			err = s.insertLedgerEntry(ctx, tx, entity.CreateAccrualLedgerEntry(order.UserID, order.Order))
+			if err != nil {
+				return fmt.Errorf("failed to insert accrual ledger entry while updating orders in postgres: %w", err)
+			}
+
+			_, err = stmtUpdateBalance.ExecContext(ctx, order.Order.Accrual, order.UserID)
+			if err != nil {
+				return fmt.Errorf("failed to update query while updating orders in postgres: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// ScheduleOrderChecks postpones the next accrual check of the orders and
+// releases their leases.
+func (s *Postgres) ScheduleOrderChecks(ctx context.Context, checks entity.OrderChecks) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction while scheduling order checks in postgres: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE orders SET check_attempts=@attempts,
+				next_check_at=now() + make_interval(secs => @delay),
+				parked_at=CASE WHEN @parked THEN now() END,
+				locked_until=NULL
+			  WHERE number=@number`
+	for _, check := range checks {
+		args := pgx.NamedArgs{
+			"number":   check.Number,
+			"attempts": check.Attempts,
+			"delay":    check.Delay.Seconds(),
+			"parked":   check.Parked,
+		}
+
+		_, err = tx.ExecContext(ctx, query, args)
+		if err != nil {
+			return fmt.Errorf("failed to update order schedule while scheduling order checks in postgres: %w", err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("unable to commit transaction while scheduling order checks in postgres: %w", err)
+	}
+
+	return nil
+}
+
+// RecheckOrder unparks the unfinished order and makes it due for
+// an accrual check right away.
+func (s *Postgres) RecheckOrder(ctx context.Context, number entity.OrderNumber) error {
+	query := `UPDATE orders SET check_attempts=0, next_check_at=now(), parked_at=NULL, locked_until=NULL
+			  WHERE number=$1 AND status IN ('NEW', 'PROCESSING')`
+
+	result, err := s.db.ExecContext(ctx, query, number)
+	if err != nil {
+		return fmt.Errorf("failed to reschedule order in postgres while rechecking order: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("unable to get affected rows in postgres while rechecking order: %w", err)
+	}
+
+	if affected != 0 {
+		return nil
+	}
+
+	_, err = s.getUserIDByOrderNumber(ctx, number)
+	if err != nil {
+		return err
+	}
+
+	return err_api.ErrOrderProcessed
+}
+
+func (s *Postgres) CreateAuditRecord(ctx context.Context, record entity.AuditRecord) error {
+	query := `INSERT INTO admin_audit_log(admin_id, action, target) VALUES(@adminID, @action, @target)`
+	args := pgx.NamedArgs{
+		"adminID": record.AdminID,
+		"action":  record.Action,
+		"target":  record.Target,
+	}
+
+	_, err := s.db.ExecContext(ctx, query, args)
+	if err != nil {
+		return fmt.Errorf("unable to insert admin audit record to postgres: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Postgres) GetUserBalance(ctx context.Context, userID entity.UserID) (entity.UserBalance, error) {
+	query := `SELECT b.sum,
+				COALESCE(SUM(p.amount), 0),
+				COALESCE(-SUM(p.amount) FILTER (WHERE e.kind='WITHDRAWAL'), 0)
+			  FROM balance AS b
+				LEFT JOIN ledger_entries AS e
+					ON b.user_id=e.user_id
+				LEFT JOIN ledger_postings AS p
+					ON e.id=p.entry_id AND p.account='USER'
+			  WHERE b.user_id=$1
+			  GROUP BY b.sum`
+
+	row := s.db.QueryRowContext(ctx, query, userID)
+	if row == nil {
+		return entity.UserBalance{}, fmt.Errorf("error while postgres request preparation while getting user balance")
+	}
+
+	if row.Err() != nil {
+		return entity.UserBalance{}, fmt.Errorf("error while postgres request execution while getting user balance: %w", row.Err())
+	}
+
+	userBalance := entity.UserBalance{
+		UserID: userID,
+	}
+	var snapshot money.Points
+	err := row.Scan(&snapshot, &userBalance.Balance, &userBalance.Withdrawans)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return entity.UserBalance{}, err_api.ErrUserNotFoundTable
+		}
+		return entity.UserBalance{}, fmt.Errorf("error while processing response row in postgres while getting user balance: %w", err)
+	}
+
+	if snapshot != userBalance.Balance {
+		zap.L().Error(
+			"user balance snapshot differs from ledger",
+			zap.String("user_id", userID.String()),
+			zap.Stringer("snapshot", snapshot),
+			zap.Stringer("ledger", userBalance.Balance),
+		)
+	}
+
+	return userBalance, nil
+}
+
+func (s *Postgres) WithdrawUser(ctx context.Context, userID entity.UserID, withdraw entity.Withdraw) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction while withdrawing user bonuses in postgres: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = s.withdrawUser(ctx, tx, userID, withdraw)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("unable to commit transaction while withdrawing user bonuses in postgres: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Postgres) WithdrawUserIdempotent(ctx context.Context, userID entity.UserID, withdraw entity.Withdraw, key entity.IdempotencyKey) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction while idempotent withdrawing user bonuses in postgres: %w", err)
+	}
+	defer tx.Rollback()
+
+	queryInsertKey := `INSERT INTO idempotency_keys(user_id, key, request_hash, status_code, body)
+						VALUES(@userID, @key, @requestHash, @statusCode, @body)`
+	args := pgx.NamedArgs{
+		"userID":      userID,
+		"key":         key.Key,
+		"requestHash": key.RequestHash,
+		"statusCode":  key.StatusCode,
+		"body":        key.Body,
+	}
+	err = s.execInsertContext(ctx, tx, err_api.ErrIdempotencyKeyExists, queryInsertKey, args)
+	if err != nil {
+		return fmt.Errorf("error while inserting idempotency key while withdrawing user bonuses in postgres: %w", err)
+	}
+
+	err = s.withdrawUser(ctx, tx, userID, withdraw)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("unable to commit transaction while idempotent withdrawing user bonuses in postgres: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Postgres) GetIdempotencyKey(ctx context.Context, userID entity.UserID, key string) (entity.IdempotencyKey, error) {
+	query := `SELECT request_hash, status_code, body FROM idempotency_keys WHERE user_id=$1 AND key=$2`
+
+	row := s.db.QueryRowContext(ctx, query, userID, key)
+	if row == nil {
+		return entity.IdempotencyKey{}, fmt.Errorf("error while postgres request preparation while getting idempotency key")
+	}
+
+	if row.Err() != nil {
+		return entity.IdempotencyKey{}, fmt.Errorf("error while postgres request execution while getting idempotency key: %w", row.Err())
+	}
+
+	idempotencyKey := entity.IdempotencyKey{
+		Key: key,
+	}
+	err := row.Scan(&idempotencyKey.RequestHash, &idempotencyKey.StatusCode, &idempotencyKey.Body)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return entity.IdempotencyKey{}, err_api.ErrIdempotencyKeyNotFound
+		}
+		return entity.IdempotencyKey{}, fmt.Errorf("error while processing response row in postgres while getting idempotency key: %w", err)
+	}
+
+	return idempotencyKey, nil
+}
+
+// StoreIdempotencyKey stores the response of the rejected request under the
+// key without changing anything else.
+func (s *Postgres) StoreIdempotencyKey(ctx context.Context, userID entity.UserID, key entity.IdempotencyKey) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction while storing idempotency key in postgres: %w", err)
+	}
+	defer tx.Rollback()
+
+	queryInsertKey := `INSERT INTO idempotency_keys(user_id, key, request_hash, status_code, body)
+						VALUES(@userID, @key, @requestHash, @statusCode, @body)`
+	args := pgx.NamedArgs{
+		"userID":      userID,
+		"key":         key.Key,
+		"requestHash": key.RequestHash,
+		"statusCode":  key.StatusCode,
+		"body":        key.Body,
+	}
+	err = s.execInsertContext(ctx, tx, err_api.ErrIdempotencyKeyExists, queryInsertKey, args)
+	if err != nil {
+		return fmt.Errorf("error while inserting idempotency key in postgres: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("unable to commit transaction while storing idempotency key in postgres: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Postgres) withdrawUser(ctx context.Context, tx *sql.Tx, userID entity.UserID, withdraw entity.Withdraw) error {
+	err := s.checkUserActive(ctx, tx, userID)
+	if err != nil {
+		return fmt.Errorf("error while withdrawing user bonuses in postgres: %w", err)
+	}
+
+	sum, err := s.selectUserBalanceOnUpdate(ctx, tx, userID)
+	if err != nil {
+		return fmt.Errorf("error while withdrawing user bonuses in postgres: %w", err)
+	}
+
+	diffSum := sum - withdraw.Sum
+	if diffSum < 0 {
+		return err_api.ErrNotEnoughSum
+	}
+
+	queryInsertWithdrawal := `INSERT INTO withdrawals(order_number, sum) VALUES(@orderNumber, @sum)`
+	argsWithdrawal := pgx.NamedArgs{
+		"orderNumber": withdraw.OrderNumber,
+		"sum":         withdraw.Sum,
+	}
+	err = s.execInsertContext(ctx, tx, err_api.ErrOrderNumberExists, queryInsertWithdrawal, argsWithdrawal)
+	if err != nil {
+		return fmt.Errorf("error while inserting user withdrawal while withdrawing user bonuses in postgres: %w", err)
+	}
+
+	queryInsertUserWithdrawals := `INSERT INTO users_withdrawals VALUES($1, $2)`
+	err = s.execInsertContext(ctx, tx, err_api.ErrOrderNumberExists, queryInsertUserWithdrawals, userID, withdraw.OrderNumber)
+	if err != nil {
+		return fmt.Errorf("error while inserting user withdrawals while withdrawing user bonuses in postgres: %w", err)
+	}
+
+	err = s.insertLedgerEntry(ctx, tx, entity.CreateWithdrawalLedgerEntry(userID, withdraw))
+	if err != nil {
+		return fmt.Errorf("error while inserting ledger entry while withdrawing user bonuses in postgres: %w", err)
+	}
+
+	queryUpdateBalance := `UPDATE balance SET sum=$1 WHERE user_id=$2`
+	_, err = tx.ExecContext(ctx, queryUpdateBalance, diffSum, userID)
+	if err != nil {
+		return fmt.Errorf("error while updating balance while withdrawing user bonuses in postgres: %w", err)
+	}
+
+	return nil
+}
+
+// AdjustUserBalance credits or debits the user balance by the admin.
+// A debit can't make the balance negative.
+func (s *Postgres) AdjustUserBalance(ctx context.Context, userID entity.UserID, adjustment entity.Adjustment) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction while adjusting user balance in postgres: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = s.checkUserActive(ctx, tx, userID)
+	if err != nil {
+		return fmt.Errorf("error while adjusting user balance in postgres: %w", err)
+	}
+
+	sum, err := s.selectUserBalanceOnUpdate(ctx, tx, userID)
+	if err != nil {
+		return fmt.Errorf("error while adjusting user balance in postgres: %w", err)
+	}
+
+	if sum+adjustment.Amount < 0 {
+		return err_api.ErrNotEnoughSum
+	}
+
+	err = s.insertLedgerEntry(ctx, tx, entity.CreateAdjustmentLedgerEntry(userID, adjustment))
+	if err != nil {
+		return fmt.Errorf("error while inserting ledger entry while adjusting user balance in postgres: %w", err)
+	}
+
+	queryUpdateBalance := `UPDATE balance SET sum=$1 WHERE user_id=$2`
+	_, err = tx.ExecContext(ctx, queryUpdateBalance, sum+adjustment.Amount, userID)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.CheckViolation {
+			return err_api.ErrNotEnoughSum
+		}
+
+		return fmt.Errorf("error while updating balance while adjusting user balance in postgres: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("unable to commit transaction while adjusting user balance in postgres: %w", err)
+	}
+
+	return nil
+}
+
+// GetUserLedger returns the movements of the user balance: accruals,
+// withdrawals and adjustments.
+func (s *Postgres) GetUserLedger(ctx context.Context, userID entity.UserID) (entity.LedgerEntries, error) {
+	query := `SELECT e.kind, COALESCE(e.order_number, e.withdrawal_number, ''), p.amount,
+				COALESCE(e.reason::text, ''), COALESCE(e.comment, ''), e.date_created
+			  FROM ledger_entries AS e
+				JOIN ledger_postings AS p
+					ON e.id=p.entry_id AND p.account='USER'
+			  WHERE e.user_id=$1
+			  ORDER BY e.date_created, e.id`
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error in postgres request execution while getting user ledger: %w", err)
+	}
+
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("error in postgres requested rows while getting user ledger: %w", rows.Err())
+	}
+
+	var entries entity.LedgerEntries
+	for rows.Next() {
+		entry := entity.LedgerEntry{
+			UserID: userID,
+		}
+		err := rows.Scan(&entry.Kind, &entry.OrderNumber, &entry.Amount, &entry.Reason, &entry.Comment, &entry.DateCreated)
+		if err != nil {
+			return nil, fmt.Errorf("error while parsing row while getting user ledger from postgres: %w", err)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	if len(entries) == 0 {
+		return nil, err_api.ErrLedgerForUserNotFound
+	}
+
+	return entries, nil
+}
+
+// GetOrderEvents returns the user order events with IDs greater than
+// afterID in the commit order.
+func (s *Postgres) GetOrderEvents(ctx context.Context, userID entity.UserID, afterID int64) (entity.OrderEvents, error) {
+	query := `SELECT id, user_id, number, status, accrual, date_created FROM order_events
+			  WHERE user_id=$1 AND id > $2
+			  ORDER BY id`
+
+	events, err := s.selectOrderEvents(ctx, query, userID, afterID)
+	if err != nil {
+		return nil, fmt.Errorf("error while getting user order events: %w", err)
+	}
+
+	return events, nil
+}
+
+// ListenOrderEvents delivers the order events committed by all instances
+// until the context is done. The channel is closed when the connection
+// fails, the missed events can be read with GetOrderEvents then.
+func (s *Postgres) ListenOrderEvents(ctx context.Context) (<-chan entity.OrderEvent, error) {
+	listener, err := s.listenOrderEvents(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan entity.OrderEvent, orderEventsBufLen)
+	go receiveOrderEvents(ctx, listener, events)
+
+	return events, nil
+}
+
+// DeleteExpiredOrderEvents deletes the order events older than retention
+// in small batches, so the order updates don't wait for the cleanup.
+func (s *Postgres) DeleteExpiredOrderEvents(ctx context.Context, retention time.Duration) error {
+	query := `DELETE FROM order_events WHERE id IN (
+				SELECT id FROM order_events
+				WHERE date_created < now() - make_interval(secs => $1)
+				ORDER BY date_created
+				LIMIT $2)`
+
+	for {
+		result, err := s.db.ExecContext(ctx, query, retention.Seconds(), orderEventsDeleteBatch)
+		if err != nil {
+			return fmt.Errorf("failed to delete expired order events in postgres: %w", err)
+		}
+
+		deleted, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to get count of deleted order events in postgres: %w", err)
+		}
+
+		if deleted < orderEventsDeleteBatch {
+			return nil
+		}
+	}
+}
+
+// GetUserWithdrawals returns a page of the user withdrawals ordered by
+// process date.
+func (s *Postgres) GetUserWithdrawals(ctx context.Context, userID entity.UserID, listQuery entity.ListQuery) (entity.Withdrawals, error) {
+	direction, comparison := sortDirection(listQuery.Sort)
+	query := fmt.Sprintf(`SELECT w.order_number, w.sum, w.process_date FROM users_withdrawals AS uw
+				JOIN withdrawals AS w
+					ON uw.order_number=w.order_number
+			  WHERE uw.user_id=@userID
+				AND (@from::timestamp IS NULL OR w.process_date >= @from::timestamp)
+				AND (@to::timestamp IS NULL OR w.process_date < @to::timestamp)
+				AND (@cursorDate::timestamp IS NULL OR
+					(w.process_date, w.order_number COLLATE "C") %[2]s (@cursorDate::timestamp, @cursorKey::text COLLATE "C"))
+			  ORDER BY w.process_date %[1]s, w.order_number COLLATE "C" %[1]s
+			  LIMIT @limit`, direction, comparison)
+
+	args := listQueryArgs(listQuery)
+	args["userID"] = userID
+
+	rows, err := s.db.QueryContext(ctx, query, args)
+	if err != nil {
+		return nil, fmt.Errorf("error in postgres request execution while getting user withdrawals: %w", err)
+	}
+
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("error in postgres requested rows while getting user withdrawals: %w", rows.Err())
+	}
+
+	var withdrawals entity.Withdrawals
+	for rows.Next() {
+		var withdraw entity.Withdraw
+		err := rows.Scan(&withdraw.OrderNumber, &withdraw.Sum, &withdraw.DateCreated)
+		if err != nil {
+			return nil, fmt.Errorf("error while parsing row while getting users withdrawals from postgres: %w", err)
+		}
+
+		withdrawals = append(withdrawals, withdraw)
+	}
+
+	if len(withdrawals) == 0 {
+		return nil, err_api.ErrWithdrawalsForUserNotFound
+	}
+
+	return withdrawals, nil
+}
+
+// GetOrdersForUpdate leases up to count unfinished orders that are due for
+// an accrual check.
+// Orders leased by other instances are skipped until the lease expires or
+// UpdateOrders releases it.
+func (s *Postgres) GetOrdersForUpdate(ctx context.Context, count int, lease time.Duration) (entity.UpdateUserOrders, error) {
+	queryClaim := `WITH claimed AS (
+						SELECT number FROM orders
+						WHERE status IN ('NEW', 'PROCESSING')
+							AND parked_at IS NULL
+							AND next_check_at <= now()
+							AND (locked_until IS NULL OR locked_until < now())
+						ORDER BY next_check_at
+						LIMIT $1
+						FOR UPDATE SKIP LOCKED
+					)
+					UPDATE orders AS o SET locked_until=now() + make_interval(secs => $2)
+						FROM claimed AS c, users_orders AS uo
+					WHERE o.number=c.number AND o.number=uo.order_number
+					RETURNING uo.user_id, o.number, o.status, o.accrual, o.date_created, o.check_attempts`
+
+	rows, err := s.db.QueryContext(ctx, queryClaim, count, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("error in postgres request execution while getting orders for update: %w", err)
+	}
+
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("error in postgres requested rows while getting orders for update: %w", rows.Err())
+	}
+
+	var userOrders entity.UpdateUserOrders
+	for rows.Next() {
+		var userOrder entity.UpdateUserOrder
+		err := rows.Scan(&userOrder.UserID, &userOrder.Order.Number, &userOrder.Order.Status, &userOrder.Order.Accrual, &userOrder.Order.DateCreated, &userOrder.Attempts)
+		if err != nil {
+			return nil, fmt.Errorf("error while parsing row while getting orders for update from postgres: %w", err)
+		}
+
+		userOrders = append(userOrders, userOrder)
+	}
+
+	if len(userOrders) == 0 {
+		return nil, err_api.ErrOrdersForUpdateNotFound
+	}
+
+	return userOrders, nil
+}
+
+// selectUserBalanceOnUpdate locks the balance snapshot, so the writers of
+// the user balance are serialized, and returns the balance computed from
+// the ledger, which the funds are checked against.
+func (s *Postgres) selectUserBalanceOnUpdate(ctx context.Context, tx *sql.Tx, userID entity.UserID) (money.Points, error) {
+	querySelect := `SELECT sum FROM balance WHERE user_id=$1 FOR UPDATE`
+	row := tx.QueryRowContext(ctx, querySelect, userID)
+	if row == nil {
+		return 0, fmt.Errorf("error while postgres request preparation while selecting user balance")
+	}
+
+	if row.Err() != nil {
+		return 0, fmt.Errorf("error while postgres request execution while selecting user balance: %w", row.Err())
+	}
+
+	var snapshot money.Points
+	err := row.Scan(&snapshot)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, err_api.ErrUserNotFoundTable
+		}
+
+		return 0, fmt.Errorf("error while processing response row in postgres: %w", err)
+	}
+
+	queryLedger := `SELECT COALESCE(SUM(p.amount), 0) FROM ledger_entries AS e
+						JOIN ledger_postings AS p
+							ON e.id=p.entry_id AND p.account='USER'
+					WHERE e.user_id=$1`
+	var sum money.Points
+	err = tx.QueryRowContext(ctx, queryLedger, userID).Scan(&sum)
+	if err != nil {
+		return 0, fmt.Errorf("error while selecting user ledger balance in postgres: %w", err)
+	}
+
+	if snapshot != sum {
+		zap.L().Error(
+			"user balance snapshot differs from ledger",
+			zap.String("user_id", userID.String()),
+			zap.Stringer("snapshot", snapshot),
+			zap.Stringer("ledger", sum),
+		)
+	}
+
+	return sum, nil
+}
+
+// checkUserActive locks the user row, so the user can't be deleted
+// until the transaction ends.
+func (s *Postgres) checkUserActive(ctx context.Context, tx *sql.Tx, userID entity.UserID) error {
+	query := `SELECT status FROM users WHERE id=$1 FOR SHARE`
+	row := tx.QueryRowContext(ctx, query, userID)
+	if row.Err() != nil {
+		return fmt.Errorf("error while postgres request execution while checking user status: %w", row.Err())
+	}
+
+	var status entity.UserStatus
+	err := row.Scan(&status)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return err_api.ErrUserNotFoundTable
+		}
+
+		return fmt.Errorf("error while processing response row in postgres while checking user status: %w", err)
+	}
+
+	if status != entity.UserStatusActive {
+		return err_api.ErrUserDeleted
+	}
+
+	return nil
+}
+
+func (s *Postgres) GetOrderOwner(ctx context.Context, number entity.OrderNumber) (entity.UserID, error) {
+	return s.getUserIDByOrderNumber(ctx, number)
+}
+
+// ApplyAccrualCallback updates the order pushed by the accrual system and
+// remembers the callback signature for ttl in the same transaction, so a
+// failed update can be retried with the same signature. Returns
+// ErrAccrualCallbackExists if the signature has been seen before and
+// ErrOrderProcessed if the order already has a final status.
+func (s *Postgres) ApplyAccrualCallback(ctx context.Context, signature string, ttl time.Duration, order entity.Order) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction while applying accrual callback in postgres: %w", err)
+	}
+	defer tx.Rollback()
+
+	queryDelete := `DELETE FROM accrual_callbacks WHERE expires_at < now()`
+	_, err = tx.ExecContext(ctx, queryDelete)
+	if err != nil {
+		return fmt.Errorf("failed to delete expired accrual callbacks in postgres: %w", err)
+	}
+
+	queryInsert := `INSERT INTO accrual_callbacks(signature, expires_at)
+						VALUES(@signature, now() + make_interval(secs => @ttl))`
+	args := pgx.NamedArgs{
+		"signature": signature,
+		"ttl":       ttl.Seconds(),
+	}
+
+	err = s.execInsertContext(ctx, tx, err_api.ErrAccrualCallbackExists, queryInsert, args)
+	if err != nil {
+		return err
+	}
+
+	querySelect := `SELECT uo.user_id, o.status FROM orders AS o
+						JOIN users_orders AS uo
+							ON o.number=uo.order_number
+					WHERE o.number=$1`
+	var userID entity.UserID
+	var status string
+	err = tx.QueryRowContext(ctx, querySelect, order.Number).Scan(&userID, &status)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return err_api.ErrOrderNumberNotFound
+		}
+
+		return fmt.Errorf("failed to get order while applying accrual callback in postgres: %w", err)
+	}
+
+	// заказ с финальным статусом уже учтён в балансе
+	if usecase.IsFinalOrderStatus(entity.OrderStatus(status)) {
+		return err_api.ErrOrderProcessed
+	}
+
+	err = s.updateOrders(ctx, tx, entity.UpdateUserOrders{
+		{
+			UserID: userID,
+			Order:  order,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("unable to commit transaction while applying accrual callback in postgres: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Postgres) getOrdersOwners(ctx context.Context, tx *sql.Tx, numbers []string) (map[entity.OrderNumber]entity.UserID, error) {
+	query := `SELECT order_number, user_id FROM users_orders
+			  WHERE order_number = ANY(@numbers::text[])`
+	args := pgx.NamedArgs{
+		"numbers": numbers,
+	}
+
+	rows, err := tx.QueryContext(ctx, query, args)
+	if err != nil {
+		return nil, fmt.Errorf("error in postgres request execution while getting orders owners: %w", err)
+	}
+	defer rows.Close()
+
+	owners := make(map[entity.OrderNumber]entity.UserID, len(numbers))
+	for rows.Next() {
+		var number entity.OrderNumber
+		var userID entity.UserID
+		err := rows.Scan(&number, &userID)
+		if err != nil {
+			return nil, fmt.Errorf("error while parsing row while getting orders owners from postgres: %w", err)
+		}
+
+		owners[number] = userID
+	}
+
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("error in postgres requested rows while getting orders owners: %w", rows.Err())
+	}
+
+	return owners, nil
+}
+
+func (s *Postgres) getUserIDByOrderNumber(ctx context.Context, orderNumber entity.OrderNumber) (entity.UserID, error) {
+	query := `SELECT uo.user_id FROM orders AS o
+				JOIN users_orders AS uo
+					ON o.number=uo.order_number
+			  WHERE o.number=$1`
+	row := s.db.QueryRowContext(ctx, query, orderNumber)
+	if row == nil {
+		return entity.UserID(""), fmt.Errorf("error while postgres request preparation while getting user id by order number")
+	}
+
+	if row.Err() != nil {
+		return entity.UserID(""), fmt.Errorf("error while postgres request execution while getting user id by order number: %w", row.Err())
+	}
+
+	var userID entity.UserID
+	err := row.Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
 			return entity.UserID(""), err_api.ErrOrderNumberNotFound
 		}
 
@@ -475,8 +1498,192 @@ func (s *Postgres) getUserIDByOrderNumber(ctx context.Context, orderNumber entit
 	return userID, nil
 }
 
-func (s *Postgres) execInsertContext(ctx context.Context, constraintErr error, query string, args ...any) error {
-	_, err := s.db.ExecContext(ctx, query, args...)
+func (s *Postgres) insertLedgerEntry(ctx context.Context, tx *sql.Tx, entry entity.LedgerEntry) error {
+	var orderNumber, withdrawalNumber, reason, comment, adminID sql.NullString
+	switch entry.Kind {
+	case entity.LedgerEntryAccrual:
+		orderNumber = sql.NullString{String: string(entry.OrderNumber), Valid: true}
+	case entity.LedgerEntryWithdrawal:
+		withdrawalNumber = sql.NullString{String: string(entry.OrderNumber), Valid: true}
+	case entity.LedgerEntryAdjustment:
+		reason = sql.NullString{String: string(entry.Reason), Valid: true}
+		comment = sql.NullString{String: entry.Comment, Valid: true}
+		adminID = sql.NullString{String: entry.AdminID.String(), Valid: entry.AdminID.Valid()}
+	}
+
+	queryInsertEntry := `INSERT INTO ledger_entries(user_id, kind, order_number, withdrawal_number, reason, comment, admin_id)
+							VALUES($1, $2::ledger_entry_kind, $3, $4, $5::adjustment_reason, $6, $7)
+						 RETURNING id`
+	row := tx.QueryRowContext(ctx, queryInsertEntry, entry.UserID, entry.Kind, orderNumber, withdrawalNumber, reason, comment, adminID)
+	if row.Err() != nil {
+		return fmt.Errorf("error while postgres request execution while inserting ledger entry: %w", row.Err())
+	}
+
+	var entryID int64
+	err := row.Scan(&entryID)
+	if err != nil {
+		return fmt.Errorf("error while processing response row in postgres while inserting ledger entry: %w", err)
+	}
+
+	queryInsertPostings := `INSERT INTO ledger_postings(entry_id, account, amount)
+								VALUES($1, $2::ledger_account, $3), ($1, $4::ledger_account, $5)`
+	_, err = tx.ExecContext(ctx, queryInsertPostings,
+		entryID, entity.LedgerAccountUser, entry.Amount, entry.Kind.CounterAccount(), -entry.Amount)
+	if err != nil {
+		return fmt.Errorf("unable to insert ledger postings to postgres: %w", err)
+	}
+
+	return nil
+}
+
+// lockUsersOrderEvents serializes the transactions writing order events of
+// the same user, so the user event IDs are committed in ascending order and
+// a stream resumed from an ID doesn't skip events committed later. The locks
+// are taken in the key order, so two batches can't wait for each other.
+func (s *Postgres) lockUsersOrderEvents(ctx context.Context, tx *sql.Tx, orders entity.UpdateUserOrders) error {
+	userIDs := make([]string, 0, len(orders))
+	for _, order := range orders {
+		userIDs = append(userIDs, order.UserID.String())
+	}
+
+	query := `SELECT pg_advisory_xact_lock(key) FROM (
+				SELECT DISTINCT hashtext(user_id) AS key FROM unnest($1::text[]) AS user_id
+			  ) AS keys
+			  ORDER BY key`
+	_, err := tx.ExecContext(ctx, query, userIDs)
+	if err != nil {
+		return fmt.Errorf("failed to lock user order events in postgres: %w", err)
+	}
+
+	return nil
+}
+
+// insertOrderEvent stores the event and notifies the listeners of all
+// instances, the notification is delivered on commit.
+func (s *Postgres) insertOrderEvent(ctx context.Context, tx *sql.Tx, event entity.OrderEvent) error {
+	queryInsert := `INSERT INTO order_events(user_id, number, status, accrual)
+						VALUES($1, $2, $3::order_status, $4)
+					 RETURNING id, date_created`
+	row := tx.QueryRowContext(ctx, queryInsert, event.UserID, event.Number, event.Status, event.Accrual)
+	if row.Err() != nil {
+		return fmt.Errorf("error while postgres request execution while inserting order event: %w", row.Err())
+	}
+
+	err := row.Scan(&event.ID, &event.DateCreated)
+	if err != nil {
+		return fmt.Errorf("error while processing response row in postgres while inserting order event: %w", err)
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("error while marshalling order event notification: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `SELECT pg_notify($1, $2)`, orderEventsChannel, string(payload))
+	if err != nil {
+		return fmt.Errorf("failed to notify order event listeners in postgres: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Postgres) selectOrderEvents(ctx context.Context, query string, args ...any) (entity.OrderEvents, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error in postgres request execution while selecting order events: %w", err)
+	}
+	defer rows.Close()
+
+	var events entity.OrderEvents
+	for rows.Next() {
+		var event entity.OrderEvent
+		err := rows.Scan(&event.ID, &event.UserID, &event.Number, &event.Status, &event.Accrual, &event.DateCreated)
+		if err != nil {
+			return nil, fmt.Errorf("error while parsing row while selecting order events from postgres: %w", err)
+		}
+
+		events = append(events, event)
+	}
+
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("error in postgres requested rows while selecting order events: %w", rows.Err())
+	}
+
+	return events, nil
+}
+
+// listenOrderEvents takes a connection out of the pool for the whole time
+// of listening, since notifications are delivered to the session.
+func (s *Postgres) listenOrderEvents(ctx context.Context) (*sql.Conn, error) {
+	listener, err := s.db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connection for order events listener from postgres: %w", err)
+	}
+
+	_, err = listener.ExecContext(ctx, "LISTEN "+orderEventsChannel)
+	if err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to listen order events in postgres: %w", err)
+	}
+
+	return listener, nil
+}
+
+func receiveOrderEvents(ctx context.Context, listener *sql.Conn, events chan<- entity.OrderEvent) {
+	defer close(events)
+
+	send := func(event entity.OrderEvent) bool {
+		select {
+		case events <- event:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	err := waitOrderEvents(ctx, listener, send)
+	closeOrderEventsListener(listener)
+	if ctx.Err() == nil {
+		zap.L().Error("order events listener has failed in postgres", zap.Error(err))
+	}
+}
+
+func waitOrderEvents(ctx context.Context, listener *sql.Conn, send func(entity.OrderEvent) bool) error {
+	return listener.Raw(func(driverConn any) error {
+		conn := driverConn.(*stdlib.Conn).Conn()
+
+		for {
+			notification, err := conn.WaitForNotification(ctx)
+			if err != nil {
+				return fmt.Errorf("error while waiting for order event notification: %w", err)
+			}
+
+			var event entity.OrderEvent
+			err = json.Unmarshal([]byte(notification.Payload), &event)
+			if err != nil {
+				zap.L().Error("invalid order event notification in postgres", zap.String("payload", notification.Payload), zap.Error(err))
+				continue
+			}
+
+			if !send(event) {
+				return ctx.Err()
+			}
+		}
+	})
+}
+
+// closeOrderEventsListener returns the connection to the pool without the
+// subscription, a broken connection is discarded by the pool.
+func closeOrderEventsListener(listener *sql.Conn) {
+	ctx, cancel := context.WithTimeout(context.Background(), listenCloseTimeout)
+	defer cancel()
+
+	listener.ExecContext(ctx, "UNLISTEN *")
+	listener.Close()
+}
+
+func (s *Postgres) execInsertContext(ctx context.Context, tx *sql.Tx, constraintErr error, query string, args ...any) error {
+	_, err := tx.ExecContext(ctx, query, args...)
 	if err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) && pgerrcode.IsIntegrityConstraintViolation(pgErr.Code) {
@@ -489,6 +1696,59 @@ func (s *Postgres) execInsertContext(ctx context.Context, constraintErr error, q
 	return nil
 }
 
+// listQueryArgs returns the common arguments of the page queries.
+// Timestamps are stored without time zone in UTC, so the bounds are
+// passed in UTC too.
+func listQueryArgs(listQuery entity.ListQuery) pgx.NamedArgs {
+	args := pgx.NamedArgs{
+		"from":       nil,
+		"to":         nil,
+		"cursorDate": nil,
+		"cursorKey":  nil,
+		"limit":      nil,
+	}
+
+	if !listQuery.From.IsZero() {
+		args["from"] = listQuery.From.UTC()
+	}
+
+	if !listQuery.To.IsZero() {
+		args["to"] = listQuery.To.UTC()
+	}
+
+	if listQuery.Cursor != nil {
+		args["cursorDate"] = listQuery.Cursor.Date.UTC()
+		args["cursorKey"] = listQuery.Cursor.Key
+	}
+
+	if listQuery.Limit > 0 {
+		args["limit"] = listQuery.Limit
+	}
+
+	return args
+}
+
+func sortDirection(sort entity.SortOrder) (string, string) {
+	if sort == entity.SortAsc {
+		return "ASC", ">"
+	}
+
+	return "DESC", "<"
+}
+
+func checkAffectedUser(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("unable to get affected rows in postgres: %w", err)
+	}
+
+	if affected == 0 {
+		return err_api.ErrUserNotFoundTable
+	}
+
+	return nil
+}
+
 func migration(db *sql.DB) error {
 	goose.SetBaseFS(migrationFs)
 
@@ -1,11 +1,17 @@
 package accrual
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/avGenie/go-loyalty-system/internal/app/config"
@@ -18,6 +24,8 @@ import (
 const (
 	accrualGetOrder   = `/api/orders/`
 	retryAfterDefault = 60
+
+	defaultRequestTimeout = 3 * time.Second
 )
 
 var (
@@ -27,27 +35,66 @@ var (
 	ErrRequestsExceeded       = errors.New("number of requests to accrual has been exceeded")
 )
 
+type AccrualClient interface {
+	GetOrder(ctx context.Context, number entity.OrderNumber) (entity.AccrualOrder, error)
+}
+
+type Option func(*Accrual)
+
+// WithHTTPClient replaces the client built from the config.
+func WithHTTPClient(client *http.Client) Option {
+	return func(a *Accrual) {
+		a.client = client
+	}
+}
+
+// WithTransport keeps the configured timeout but sends requests through
+// the given transport.
+func WithTransport(transport http.RoundTripper) Option {
+	return func(a *Accrual) {
+		a.client.Transport = transport
+	}
+}
+
 type Accrual struct {
-	client http.Client
+	client *http.Client
 
 	requestAddress string
 }
 
-func New(config config.Config) *Accrual {
-	client := http.Client{
-		Timeout: 3 * time.Second,
+func New(config config.Config, options ...Option) (*Accrual, error) {
+	transport, err := createTransport(config)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create transport for accrual service: %w", err)
+	}
+
+	timeout := config.AccrualTimeout
+	if timeout <= 0 {
+		timeout = defaultRequestTimeout
+	}
+
+	apiPath := config.AccrualAPIPath
+	if len(apiPath) == 0 {
+		apiPath = accrualGetOrder
 	}
 
-	requestAddress := fmt.Sprintf("%s%s", config.AccrualAddr, accrualGetOrder)
+	instance := &Accrual{
+		client: &http.Client{
+			Timeout:   timeout,
+			Transport: transport,
+		},
+		requestAddress: fmt.Sprintf("%s/%s/", strings.TrimSuffix(config.AccrualAddr, "/"), strings.Trim(apiPath, "/")),
+	}
 
-	return &Accrual{
-		client:         client,
-		requestAddress: requestAddress,
+	for _, option := range options {
+		option(instance)
 	}
+
+	return instance, nil
 }
 
-func (a *Accrual) MakeRequest(userID entity.UserID, number entity.OrderNumber) (entity.AccrualOrder, error) {
-	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s%s", a.requestAddress, number), nil)
+func (a *Accrual) GetOrder(ctx context.Context, number entity.OrderNumber) (entity.AccrualOrder, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.requestAddress+url.PathEscape(string(number)), nil)
 	if err != nil {
 		return entity.AccrualOrder{}, fmt.Errorf("cannot create request for accrual service: %w", err)
 	}
@@ -55,14 +102,13 @@ func (a *Accrual) MakeRequest(userID entity.UserID, number entity.OrderNumber) (
 	if err != nil {
 		return entity.AccrualOrder{}, fmt.Errorf("cannot create request to accrual service: %w", err)
 	}
+	defer res.Body.Close()
 
 	order, err := a.processAccrualResponse(res)
 	if err != nil {
 		return entity.AccrualOrder{}, fmt.Errorf("cannot process accrual response: %w", err)
 	}
 
-	order.UserID = userID
-	
 	return order, nil
 }
 
@@ -81,7 +127,7 @@ func (a *Accrual) processAccrualResponse(res *http.Response) (entity.AccrualOrde
 
 		return entity.AccrualOrder{
 			RetryAfter: time.Duration(retryTime) * time.Second,
-			Status: entity.StatusPause,
+			Status:     entity.StatusPause,
 		}, nil
 	}
 
@@ -98,10 +144,61 @@ func (a *Accrual) processAccrualResponse(res *http.Response) (entity.AccrualOrde
 			Status: entity.StatusError,
 		}, fmt.Errorf("error while decoding accrual response: %w", err)
 	}
-	res.Body.Close()
+
+	order, err := converter.ConvertAccrualResponseToOrder(response)
+	if err != nil {
+		return entity.AccrualOrder{
+			Status: entity.StatusError,
+		}, fmt.Errorf("error while converting accrual response: %w", err)
+	}
 
 	return entity.AccrualOrder{
-		Order: converter.ConvertAccrualResponseToOrder(response),
+		Order:  order,
 		Status: entity.StatusOK,
 	}, nil
 }
+
+func createTransport(config config.Config) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if len(config.AccrualProxy) != 0 {
+		proxyURL, err := url.Parse(config.AccrualProxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy address: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	if len(config.AccrualTLSCert) == 0 && len(config.AccrualTLSCA) == 0 {
+		return transport, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if len(config.AccrualTLSCert) != 0 {
+		cert, err := tls.LoadX509KeyPair(config.AccrualTLSCert, config.AccrualTLSKey)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if len(config.AccrualTLSCA) != 0 {
+		pem, err := os.ReadFile(config.AccrualTLSCA)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read CA certificate: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", config.AccrualTLSCA)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	transport.TLSClientConfig = tlsConfig
+
+	return transport, nil
+}
@@ -0,0 +1,158 @@
+package accrual
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/avGenie/go-loyalty-system/internal/app/config"
+	"github.com/avGenie/go-loyalty-system/internal/app/entity"
+	"github.com/avGenie/go-loyalty-system/internal/app/money"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestGetOrder(t *testing.T) {
+	type want struct {
+		status     entity.AccrualStatus
+		order      entity.Order
+		retryAfter time.Duration
+		isError    bool
+	}
+	tests := []struct {
+		name        string
+		apiPath     string
+		statusCode  int
+		retryAfter  string
+		body        string
+		requestPath string
+		want        want
+	}{
+		{
+			name:        "processed order",
+			statusCode:  http.StatusOK,
+			body:        `{"order": "12345678903", "status": "PROCESSED", "accrual": 729.98}`,
+			requestPath: "/api/orders/12345678903",
+			want: want{
+				status: entity.StatusOK,
+				order: entity.Order{
+					Number:  "12345678903",
+					Status:  entity.StatusProcessedOrder,
+					Accrual: money.Points(72998),
+				},
+			},
+		},
+		{
+			name:        "custom api path",
+			apiPath:     "/accrual/v2/orders",
+			statusCode:  http.StatusOK,
+			body:        `{"order": "12345678903", "status": "REGISTERED"}`,
+			requestPath: "/accrual/v2/orders/12345678903",
+			want: want{
+				status: entity.StatusOK,
+				order: entity.Order{
+					Number: "12345678903",
+					Status: entity.StatusNewOrder,
+				},
+			},
+		},
+		{
+			name:        "order not registered",
+			statusCode:  http.StatusNoContent,
+			requestPath: "/api/orders/12345678903",
+			want: want{
+				status: entity.StatusOrderNotRegistered,
+			},
+		},
+		{
+			name:        "too many requests",
+			statusCode:  http.StatusTooManyRequests,
+			retryAfter:  "30",
+			requestPath: "/api/orders/12345678903",
+			want: want{
+				status:     entity.StatusPause,
+				retryAfter: 30 * time.Second,
+			},
+		},
+		{
+			name:        "internal error",
+			statusCode:  http.StatusInternalServerError,
+			requestPath: "/api/orders/12345678903",
+			want: want{
+				isError: true,
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, test.requestPath, r.URL.Path)
+
+				if len(test.retryAfter) != 0 {
+					w.Header().Set("Retry-After", test.retryAfter)
+				}
+				w.WriteHeader(test.statusCode)
+				w.Write([]byte(test.body))
+			}))
+			defer server.Close()
+
+			client, err := New(config.Config{
+				AccrualAddr:    server.URL,
+				AccrualAPIPath: test.apiPath,
+			})
+			require.NoError(t, err)
+
+			order, err := client.GetOrder(context.Background(), "12345678903")
+			if test.want.isError {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.want.status, order.Status)
+			assert.Equal(t, test.want.order, order.Order)
+			assert.Equal(t, test.want.retryAfter, order.RetryAfter)
+		})
+	}
+}
+
+func TestGetOrderWithTransport(t *testing.T) {
+	errTransport := errors.New("transport error")
+	transport := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		assert.Equal(t, "http://accrual/api/orders/12345678903", r.URL.String())
+		return nil, errTransport
+	})
+
+	client, err := New(config.Config{AccrualAddr: "http://accrual/"}, WithTransport(transport))
+	require.NoError(t, err)
+
+	_, err = client.GetOrder(context.Background(), "12345678903")
+	assert.ErrorIs(t, err, errTransport)
+}
+
+func TestGetOrderCancelled(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client, err := New(config.Config{AccrualAddr: server.URL})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	_, err = client.GetOrder(ctx, "12345678903")
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,180 @@
+package accrual
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/avGenie/go-loyalty-system/internal/app/config"
+	"github.com/avGenie/go-loyalty-system/internal/app/entity"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultFailureThreshold = 5
+	defaultCoolDown         = 30 * time.Second
+)
+
+var (
+	ErrCircuitOpen = errors.New("accrual circuit breaker is open")
+)
+
+type BreakerState int
+
+const (
+	BreakerClosed BreakerState = iota
+	BreakerOpen
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// Breaker stops requests to the accrual system after a series of failures.
+// When the cool-down has passed a single probe request is let through:
+// its success closes the breaker, its failure opens it again.
+type Breaker struct {
+	client AccrualClient
+
+	failureThreshold int
+	coolDown         time.Duration
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+func NewBreaker(client AccrualClient, config config.Config) *Breaker {
+	failureThreshold := config.AccrualBreakerFailures
+	if failureThreshold <= 0 {
+		failureThreshold = defaultFailureThreshold
+	}
+
+	coolDown := config.AccrualBreakerCoolDown
+	if coolDown <= 0 {
+		coolDown = defaultCoolDown
+	}
+
+	return &Breaker{
+		client:           client,
+		failureThreshold: failureThreshold,
+		coolDown:         coolDown,
+	}
+}
+
+func (b *Breaker) GetOrder(ctx context.Context, number entity.OrderNumber) (entity.AccrualOrder, error) {
+	if !b.allow() {
+		return entity.AccrualOrder{}, ErrCircuitOpen
+	}
+
+	order, err := b.client.GetOrder(ctx, number)
+	if err != nil && ctx.Err() != nil {
+		// запрос отменён при остановке сервиса, accrual тут ни при чём
+		b.release()
+		return order, err
+	}
+
+	b.record(err == nil)
+
+	return order, err
+}
+
+func (b *Breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.state
+}
+
+// IsAvailable reports whether requests to the accrual system would be let through.
+func (b *Breaker) IsAvailable() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		return time.Since(b.openedAt) >= b.coolDown
+	case BreakerHalfOpen:
+		return !b.probing
+	default:
+		return true
+	}
+}
+
+func (b *Breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.openedAt) < b.coolDown {
+			return false
+		}
+
+		b.setState(BreakerHalfOpen)
+		b.probing = true
+
+		return true
+	case BreakerHalfOpen:
+		if b.probing {
+			return false
+		}
+
+		b.probing = true
+
+		return true
+	default:
+		return true
+	}
+}
+
+func (b *Breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+}
+
+func (b *Breaker) record(success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+
+	if success {
+		b.failures = 0
+		if b.state != BreakerClosed {
+			b.setState(BreakerClosed)
+		}
+
+		return
+	}
+
+	b.failures++
+	if b.state == BreakerHalfOpen || b.failures >= b.failureThreshold {
+		b.openedAt = time.Now()
+		if b.state != BreakerOpen {
+			b.setState(BreakerOpen)
+		}
+	}
+}
+
+func (b *Breaker) setState(state BreakerState) {
+	zap.L().Warn("accrual circuit breaker state changed",
+		zap.Stringer("from", b.state),
+		zap.Stringer("to", state),
+		zap.Int("failures", b.failures))
+
+	b.state = state
+}
@@ -0,0 +1,104 @@
+package accrual
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/avGenie/go-loyalty-system/internal/app/config"
+	"github.com/avGenie/go-loyalty-system/internal/app/entity"
+	"github.com/avGenie/go-loyalty-system/internal/app/usecase/accrual/mock"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBreaker(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	errAccrual := errors.New("accrual is down")
+	client := mock.NewMockAccrualClient(ctrl)
+
+	breaker := NewBreaker(client, config.Config{
+		AccrualBreakerFailures: 2,
+		AccrualBreakerCoolDown: 50 * time.Millisecond,
+	})
+	ctx := context.Background()
+
+	client.EXPECT().GetOrder(gomock.Any(), gomock.Any()).Return(entity.AccrualOrder{}, errAccrual).Times(2)
+
+	_, err := breaker.GetOrder(ctx, "12345678903")
+	require.ErrorIs(t, err, errAccrual)
+	assert.Equal(t, BreakerClosed, breaker.State())
+
+	_, err = breaker.GetOrder(ctx, "12345678903")
+	require.ErrorIs(t, err, errAccrual)
+	assert.Equal(t, BreakerOpen, breaker.State())
+	assert.False(t, breaker.IsAvailable())
+
+	_, err = breaker.GetOrder(ctx, "12345678903")
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+
+	time.Sleep(60 * time.Millisecond)
+	assert.True(t, breaker.IsAvailable())
+
+	client.EXPECT().GetOrder(gomock.Any(), gomock.Any()).Return(entity.AccrualOrder{}, errAccrual)
+
+	_, err = breaker.GetOrder(ctx, "12345678903")
+	require.ErrorIs(t, err, errAccrual)
+	assert.Equal(t, BreakerOpen, breaker.State())
+
+	time.Sleep(60 * time.Millisecond)
+
+	client.EXPECT().GetOrder(gomock.Any(), gomock.Any()).Return(entity.AccrualOrder{Status: entity.StatusOK}, nil)
+
+	_, err = breaker.GetOrder(ctx, "12345678903")
+	require.NoError(t, err)
+	assert.Equal(t, BreakerClosed, breaker.State())
+	assert.True(t, breaker.IsAvailable())
+}
+
+func TestBreakerHalfOpenSingleProbe(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	client := mock.NewMockAccrualClient(ctrl)
+
+	breaker := NewBreaker(client, config.Config{
+		AccrualBreakerFailures: 1,
+		AccrualBreakerCoolDown: time.Millisecond,
+	})
+	ctx := context.Background()
+
+	client.EXPECT().GetOrder(gomock.Any(), gomock.Any()).Return(entity.AccrualOrder{}, errors.New(""))
+	_, err := breaker.GetOrder(ctx, "12345678903")
+	require.Error(t, err)
+
+	time.Sleep(5 * time.Millisecond)
+
+	probe := make(chan struct{})
+	client.EXPECT().GetOrder(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, number entity.OrderNumber) (entity.AccrualOrder, error) {
+		<-probe
+		return entity.AccrualOrder{Status: entity.StatusOK}, nil
+	})
+
+	done := make(chan error)
+	go func() {
+		_, err := breaker.GetOrder(ctx, "12345678903")
+		done <- err
+	}()
+
+	require.Eventually(t, func() bool {
+		return breaker.State() == BreakerHalfOpen
+	}, time.Second, time.Millisecond)
+
+	_, err = breaker.GetOrder(ctx, "2377225624")
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.False(t, breaker.IsAvailable())
+
+	close(probe)
+	assert.NoError(t, <-done)
+	assert.Equal(t, BreakerClosed, breaker.State())
+}
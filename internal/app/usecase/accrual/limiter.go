@@ -0,0 +1,64 @@
+package accrual
+
+import (
+	"sync"
+	"time"
+)
+
+// limiter spaces requests of all workers evenly and holds them back
+// while the accrual system asks to retry later.
+type limiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newLimiter(rps int) *limiter {
+	var interval time.Duration
+	if rps > 0 {
+		interval = time.Second / time.Duration(rps)
+	}
+
+	return &limiter{
+		interval: interval,
+	}
+}
+
+// Wait blocks until the next request is allowed. It returns false if done
+// is closed first.
+func (l *limiter) Wait(done <-chan struct{}) bool {
+	l.mu.Lock()
+	now := time.Now()
+	slot := l.next
+	if slot.Before(now) {
+		slot = now
+	}
+	l.next = slot.Add(l.interval)
+	l.mu.Unlock()
+
+	delay := time.Until(slot)
+	if delay <= 0 {
+		return true
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// Pause postpones all requests that haven't started yet by the given time.
+func (l *limiter) Pause(retryAfter time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	until := time.Now().Add(retryAfter)
+	if l.next.Before(until) {
+		l.next = until
+	}
+}
@@ -0,0 +1,124 @@
+package accrual
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/avGenie/go-loyalty-system/internal/app/config"
+	"github.com/avGenie/go-loyalty-system/internal/app/entity"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultWorkers = 4
+)
+
+// Pool requests accruals of orders concurrently. All workers share one rate
+// limiter, so Retry-After from any of them pauses the whole pool.
+type Pool struct {
+	client  AccrualClient
+	limiter *limiter
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	input  chan entity.AccrualOrderRequest
+	output chan entity.AccrualOrder
+	done   chan struct{}
+	once   sync.Once
+}
+
+func NewPool(client AccrualClient, config config.Config) *Pool {
+	workers := config.AccrualWorkers
+	if workers <= 0 {
+		workers = defaultWorkers
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	pool := &Pool{
+		client:  client,
+		limiter: newLimiter(config.AccrualRateLimit),
+		ctx:     ctx,
+		cancel:  cancel,
+		input:   make(chan entity.AccrualOrderRequest, workers),
+		output:  make(chan entity.AccrualOrder, workers),
+		done:    make(chan struct{}),
+	}
+
+	for i := 0; i < workers; i++ {
+		go pool.worker()
+	}
+
+	return pool
+}
+
+// SetInput queues the order for the accrual request. The order is dropped if
+// the pool has been closed.
+func (p *Pool) SetInput(request entity.AccrualOrderRequest) {
+	select {
+	case <-p.done:
+	case p.input <- request:
+	}
+}
+
+// CloseInput stops the workers and cancels requests in flight. Pending
+// requests and responses are dropped.
+func (p *Pool) CloseInput() {
+	p.once.Do(func() {
+		close(p.done)
+		p.cancel()
+	})
+}
+
+// GetOutput returns the next processed order or false if the pool has been closed.
+func (p *Pool) GetOutput() (entity.AccrualOrder, bool) {
+	select {
+	case <-p.done:
+		return entity.AccrualOrder{}, false
+	case order := <-p.output:
+		return order, true
+	}
+}
+
+func (p *Pool) worker() {
+	for {
+		select {
+		case <-p.done:
+			return
+		case request := <-p.input:
+			if !p.limiter.Wait(p.done) {
+				return
+			}
+
+			order := p.process(request)
+
+			select {
+			case <-p.done:
+				return
+			case p.output <- order:
+			}
+		}
+	}
+}
+
+func (p *Pool) process(request entity.AccrualOrderRequest) entity.AccrualOrder {
+	order, err := p.client.GetOrder(p.ctx, request.Number)
+	if errors.Is(err, ErrCircuitOpen) {
+		order.Status = entity.StatusUnavailable
+	} else if err != nil {
+		zap.L().Error("error while getting accrual update", zap.Error(err))
+		order.Status = entity.StatusError
+	}
+
+	if entity.StatusPause == order.Status {
+		zap.L().Info("accrual system asks to pause requests", zap.Duration("retry after", order.RetryAfter))
+		p.limiter.Pause(order.RetryAfter)
+	}
+
+	order.UserID = request.UserID
+	order.Order.Number = request.Number
+
+	return order
+}
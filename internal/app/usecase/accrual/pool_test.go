@@ -0,0 +1,94 @@
+package accrual
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/avGenie/go-loyalty-system/internal/app/config"
+	"github.com/avGenie/go-loyalty-system/internal/app/entity"
+	"github.com/avGenie/go-loyalty-system/internal/app/money"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPoolPausesAllWorkers(t *testing.T) {
+	var mu sync.Mutex
+	var requests []time.Time
+	paused := false
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, time.Now())
+		pause := !paused
+		paused = true
+		mu.Unlock()
+
+		if pause {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+
+		number := strings.TrimPrefix(r.URL.Path, accrualGetOrder)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"order": "%s", "status": "PROCESSED", "accrual": 500}`, number)
+	}))
+	defer server.Close()
+
+	config := config.Config{
+		AccrualAddr:    server.URL,
+		AccrualWorkers: 1,
+	}
+	client, err := New(config)
+	require.NoError(t, err)
+
+	pool := NewPool(client, config)
+	defer pool.CloseInput()
+
+	numbers := []entity.OrderNumber{"12345678903", "2377225624", "735584316112"}
+	go func() {
+		for _, number := range numbers {
+			pool.SetInput(entity.CreateAccrualRequest("user", number))
+		}
+	}()
+
+	statuses := make(map[entity.OrderNumber]entity.AccrualStatus, len(numbers))
+	for range numbers {
+		order, ok := pool.GetOutput()
+		require.True(t, ok)
+		assert.Equal(t, entity.UserID("user"), order.UserID)
+
+		statuses[order.Order.Number] = order.Status
+		if entity.StatusOK == order.Status {
+			assert.Equal(t, money.Points(50000), order.Order.Accrual)
+		}
+	}
+
+	assert.Equal(t, entity.StatusPause, statuses[numbers[0]])
+	assert.Equal(t, entity.StatusOK, statuses[numbers[1]])
+	assert.Equal(t, entity.StatusOK, statuses[numbers[2]])
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, requests, len(numbers))
+	assert.GreaterOrEqual(t, requests[1].Sub(requests[0]), 900*time.Millisecond)
+}
+
+func TestPoolCloseInput(t *testing.T) {
+	client, err := New(config.Config{})
+	require.NoError(t, err)
+
+	pool := NewPool(client, config.Config{})
+	pool.CloseInput()
+	pool.CloseInput()
+
+	pool.SetInput(entity.CreateAccrualRequest("user", "12345678903"))
+
+	_, ok := pool.GetOutput()
+	assert.False(t, ok)
+}
@@ -0,0 +1,211 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/avGenie/go-loyalty-system/internal/app/entity"
+	err_storage "github.com/avGenie/go-loyalty-system/internal/app/storage/api/errors"
+	"github.com/avGenie/go-loyalty-system/internal/app/usecase/problem"
+	httputils "github.com/avGenie/go-loyalty-system/internal/app/usecase/utils"
+	"go.uber.org/zap"
+)
+
+const (
+	ErrLoginNotExist  = "login doesn't exist"
+	ErrUserNotExist   = "user doesn't exist"
+	ErrOrderNotExist  = "order doesn't exist"
+	ErrOrderProcessed = "order has final status"
+	ErrNotEnoughSum   = "adjustment makes the balance negative"
+	ErrUserDeleted    = "user has been deleted"
+)
+
+type AdminProcessor interface {
+	FindUserByLogin(ctx context.Context, login string) (entity.User, error)
+	GetUserOrders(ctx context.Context, userID entity.UserID, query entity.ListQuery) (entity.Orders, error)
+	GetUserWithdrawals(ctx context.Context, userID entity.UserID, query entity.ListQuery) (entity.Withdrawals, error)
+	GetUserBalance(ctx context.Context, userID entity.UserID) (entity.UserBalance, error)
+	AdjustUserBalance(ctx context.Context, userID entity.UserID, adjustment entity.Adjustment) error
+	RecheckOrder(ctx context.Context, number entity.OrderNumber) error
+	CreateAuditRecord(ctx context.Context, record entity.AuditRecord) error
+}
+
+// Audit stores the admin action before it is performed, so an action
+// that can't be audited isn't performed at all.
+func Audit(record entity.AuditRecord, processor AdminProcessor, w http.ResponseWriter) error {
+	ctx, cancel := context.WithTimeout(context.Background(), httputils.RequestTimeout)
+	defer cancel()
+
+	err := processor.CreateAuditRecord(ctx, record)
+	if err != nil {
+		zap.L().Error("error while storing admin audit record", zap.Error(err), zap.String("admin_id", record.AdminID.String()))
+		problem.Write(w, err)
+		return fmt.Errorf("error while storing admin audit record: %w", err)
+	}
+
+	zap.L().Info("admin action",
+		zap.String("admin_id", record.AdminID.String()),
+		zap.String("action", string(record.Action)),
+		zap.String("target", record.Target),
+	)
+
+	return nil
+}
+
+func FindUser(login string, processor AdminProcessor, w http.ResponseWriter) (entity.User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), httputils.RequestTimeout)
+	defer cancel()
+
+	user, err := processor.FindUserByLogin(ctx, login)
+	if err != nil {
+		if errors.Is(err, err_storage.ErrLoginNotFound) {
+			problem.WriteCode(w, problem.CodeUserNotFound, ErrLoginNotExist)
+			return entity.User{}, err
+		}
+
+		zap.L().Error("error while finding user by login", zap.Error(err))
+		problem.Write(w, err)
+		return entity.User{}, err
+	}
+
+	return user, nil
+}
+
+func GetUserOrders(userID entity.UserID, processor AdminProcessor, w http.ResponseWriter) (entity.Orders, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), httputils.RequestTimeout)
+	defer cancel()
+
+	orders, err := processor.GetUserOrders(ctx, userID, entity.ListQuery{Sort: entity.SortDesc})
+	if err != nil {
+		if errors.Is(err, err_storage.ErrOrderForUserNotFound) {
+			writeEmptyUserList(ctx, userID, processor, w)
+		} else {
+			zap.L().Error("error while getting user orders for admin", zap.Error(err))
+			problem.Write(w, err)
+		}
+
+		return nil, err
+	}
+
+	return orders, nil
+}
+
+func GetUserWithdrawals(userID entity.UserID, processor AdminProcessor, w http.ResponseWriter) (entity.Withdrawals, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), httputils.RequestTimeout)
+	defer cancel()
+
+	withdrawals, err := processor.GetUserWithdrawals(ctx, userID, entity.ListQuery{Sort: entity.SortDesc})
+	if err != nil {
+		if errors.Is(err, err_storage.ErrWithdrawalsForUserNotFound) {
+			writeEmptyUserList(ctx, userID, processor, w)
+		} else {
+			zap.L().Error("error while getting user withdrawals for admin", zap.Error(err))
+			problem.Write(w, err)
+		}
+
+		return nil, err
+	}
+
+	return withdrawals, nil
+}
+
+// writeEmptyUserList tells the user without orders or withdrawals from
+// the unknown one. The balance is kept for deleted users, so their history
+// stays available.
+func writeEmptyUserList(ctx context.Context, userID entity.UserID, processor AdminProcessor, w http.ResponseWriter) {
+	_, err := processor.GetUserBalance(ctx, userID)
+	if err != nil {
+		if errors.Is(err, err_storage.ErrUserNotFoundTable) {
+			problem.WriteCode(w, problem.CodeUserNotFound, ErrUserNotExist)
+			return
+		}
+
+		zap.L().Error("error while checking user for admin", zap.Error(err), zap.String("user_id", userID.String()))
+		problem.Write(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func GetUserBalance(userID entity.UserID, processor AdminProcessor, w http.ResponseWriter) (entity.UserBalance, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), httputils.RequestTimeout)
+	defer cancel()
+
+	balance, err := processor.GetUserBalance(ctx, userID)
+	if err != nil {
+		if errors.Is(err, err_storage.ErrUserNotFoundTable) {
+			problem.WriteCode(w, problem.CodeUserNotFound, ErrUserNotExist)
+			return entity.UserBalance{}, err
+		}
+
+		zap.L().Error("error while getting user balance for admin", zap.Error(err))
+		problem.Write(w, err)
+		return entity.UserBalance{}, err
+	}
+
+	return balance, nil
+}
+
+func AdjustUserBalance(userID entity.UserID, adjustment entity.Adjustment, processor AdminProcessor, w http.ResponseWriter) error {
+	ctx, cancel := context.WithTimeout(context.Background(), httputils.RequestTimeout)
+	defer cancel()
+
+	err := processor.AdjustUserBalance(ctx, userID, adjustment)
+	if err != nil {
+		switch {
+		case errors.Is(err, err_storage.ErrUserNotFoundTable):
+			problem.WriteCode(w, problem.CodeUserNotFound, ErrUserNotExist)
+		case errors.Is(err, err_storage.ErrUserDeleted):
+			problem.WriteCode(w, problem.CodeTargetUserDeleted, ErrUserDeleted)
+		case errors.Is(err, err_storage.ErrNotEnoughSum):
+			problem.WriteCode(w, problem.CodeNegativeBalance, ErrNotEnoughSum)
+		default:
+			zap.L().Error("error while adjusting user balance", zap.Error(err), zap.String("user_id", userID.String()))
+			problem.Write(w, err)
+		}
+
+		return err
+	}
+
+	zap.L().Info("user balance has been adjusted",
+		zap.String("user_id", userID.String()),
+		zap.String("admin_id", adjustment.AdminID.String()),
+		zap.Stringer("amount", adjustment.Amount),
+		zap.String("reason", string(adjustment.Reason)),
+	)
+
+	w.WriteHeader(http.StatusOK)
+
+	return nil
+}
+
+// RecheckOrder unparks the order, so the status updater polls the accrual
+// system for it again.
+func RecheckOrder(number entity.OrderNumber, processor AdminProcessor, w http.ResponseWriter) error {
+	ctx, cancel := context.WithTimeout(context.Background(), httputils.RequestTimeout)
+	defer cancel()
+
+	err := processor.RecheckOrder(ctx, number)
+	if err != nil {
+		if errors.Is(err, err_storage.ErrOrderNumberNotFound) {
+			problem.WriteCode(w, problem.CodeOrderNotFound, ErrOrderNotExist)
+			return err
+		}
+
+		if errors.Is(err, err_storage.ErrOrderProcessed) {
+			problem.WriteCode(w, problem.CodeOrderProcessed, ErrOrderProcessed)
+			return err
+		}
+
+		zap.L().Error("error while rescheduling order check", zap.Error(err), zap.String("order_number", string(number)))
+		problem.Write(w, err)
+		return err
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+
+	return nil
+}
@@ -4,23 +4,46 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/avGenie/go-loyalty-system/internal/app/entity"
 	err_storage "github.com/avGenie/go-loyalty-system/internal/app/storage/api/errors"
 	"github.com/avGenie/go-loyalty-system/internal/app/usecase/crypto"
+	"github.com/avGenie/go-loyalty-system/internal/app/usecase/problem"
 	httputils "github.com/avGenie/go-loyalty-system/internal/app/usecase/utils"
 	"go.uber.org/zap"
 )
 
+var (
+	ErrLoginBlocked = errors.New("login attempts are temporarily blocked")
+)
+
 const (
 	ErrLoginNotExist    = "login doesn't exist"
+	ErrLoginExists      = "login is already taken"
 	ErrWrongPassword    = "wrong password"
+	ErrRefreshToken     = "refresh token is invalid or expired"
+	ErrTooManyAttempts  = "too many failed login attempts"
+	ErrUserNotExist     = "user doesn't exist"
 )
 
 type UserAuthenticator interface {
 	CreateUser(ctx context.Context, user entity.User) error
 	GetUser(ctx context.Context, user entity.User) (entity.User, error)
+	GetUserByID(ctx context.Context, userID entity.UserID) (entity.User, error)
+	UpdateUserPassword(ctx context.Context, userID entity.UserID, password string) error
+	DeleteUser(ctx context.Context, userID entity.UserID) error
+
+	CreateRefreshToken(ctx context.Context, token entity.RefreshToken) error
+	RotateRefreshToken(ctx context.Context, oldHash string, token entity.RefreshToken) (entity.UserID, error)
+	RevokeUserTokens(ctx context.Context, userID entity.UserID, accessToken entity.AccessToken) error
+
+	GetLoginBlock(ctx context.Context, keys []string) (time.Duration, error)
+	RegisterLoginFailure(ctx context.Context, key string, throttle entity.LoginThrottle) (entity.LoginAttempt, error)
+	ResetLoginFailures(ctx context.Context, key string) error
 }
 
 func CreateUser(user entity.User, authenticator UserAuthenticator, w http.ResponseWriter) error {
@@ -31,32 +54,50 @@ func CreateUser(user entity.User, authenticator UserAuthenticator, w http.Respon
 	if err != nil {
 		if errors.Is(err, err_storage.ErrLoginExists) {
 			zap.L().Error("error while creating user", zap.Error(err), zap.String("login", user.Login))
-			w.WriteHeader(http.StatusConflict)
+			problem.WriteCode(w, problem.CodeLoginExists, ErrLoginExists)
 			return fmt.Errorf("error while creating user: %w", err)
 		}
 
 		zap.L().Error("error while creating user", zap.Error(err))
-		w.WriteHeader(http.StatusInternalServerError)
+		problem.Write(w, err)
 		return fmt.Errorf("error while creating user: %w", err)
 	}
 
 	return nil
 }
 
-func AuthUser(inputUser entity.User, authenticator UserAuthenticator, w http.ResponseWriter) (entity.User, error) {
+// AuthUser checks user credentials. Failed attempts are counted per login
+// and per client IP, so repeated failures delay and then lock out the
+// following attempts.
+func AuthUser(inputUser entity.User, ip string, throttle entity.LoginThrottle, authenticator UserAuthenticator, w http.ResponseWriter) (entity.User, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), httputils.RequestTimeout)
 	defer cancel()
 
+	keys := entity.LoginAttemptKeys(inputUser.Login, ip)
+	blocked, err := authenticator.GetLoginBlock(ctx, keys)
+	if err != nil {
+		zap.L().Error("error while getting login block while authentication request", zap.Error(err))
+		problem.Write(w, err)
+		return entity.User{}, err
+	}
+
+	if blocked > 0 {
+		zap.L().Info("login attempt is blocked", zap.String("login", inputUser.Login), zap.String("ip", ip), zap.Duration("retry_after", blocked))
+		writeTooManyAttempts(blocked, w)
+		return entity.User{}, ErrLoginBlocked
+	}
+
 	storageUser, err := authenticator.GetUser(ctx, inputUser)
 	if err != nil {
 		zap.L().Error("error while getting user while authentication request", zap.Error(err))
 
 		if errors.Is(err, err_storage.ErrLoginNotFound) {
-			http.Error(w, ErrLoginNotExist, http.StatusUnauthorized)
+			registerLoginFailure(ctx, keys, ip, throttle, authenticator)
+			problem.WriteCode(w, problem.CodeInvalidCredentials, ErrLoginNotExist)
 			return entity.User{}, err
 		}
 
-		w.WriteHeader(http.StatusInternalServerError)
+		problem.Write(w, err)
 		return entity.User{}, err
 	}
 
@@ -64,13 +105,250 @@ func AuthUser(inputUser entity.User, authenticator UserAuthenticator, w http.Res
 	if err != nil {
 		zap.L().Error("error while checking user password while authentication request", zap.Error(err))
 		if errors.Is(err, crypto.ErrWrongPassword) {
-			http.Error(w, ErrLoginNotExist, http.StatusUnauthorized)
+			registerLoginFailure(ctx, keys, ip, throttle, authenticator)
+			problem.WriteCode(w, problem.CodeInvalidCredentials, ErrLoginNotExist)
 			return entity.User{}, err
 		}
 
-		w.WriteHeader(http.StatusInternalServerError)
+		problem.Write(w, err)
 		return entity.User{}, err
 	}
 
+	// счётчик IP не сбрасывается: иначе подбирающий пароли сбрасывал бы его
+	// входом в свой аккаунт между попытками, он истекает сам через окно
+	err = authenticator.ResetLoginFailures(ctx, entity.LoginKey(inputUser.Login))
+	if err != nil {
+		zap.L().Error("error while resetting login failures", zap.Error(err), zap.String("login", inputUser.Login))
+	}
+
+	if crypto.NeedsRehash(storageUser.Password) {
+		rehashPassword(ctx, storageUser.ID, inputUser.Password, authenticator)
+	}
+
 	return storageUser, nil
 }
+
+// rehashPassword upgrades the stored hash to the current algorithm and parameters,
+// the login succeeds even if it fails.
+func rehashPassword(ctx context.Context, userID entity.UserID, password string, authenticator UserAuthenticator) {
+	hashedPassword, err := crypto.HashPassword(password)
+	if err != nil {
+		zap.L().Error("error while rehashing user password", zap.Error(err))
+		return
+	}
+
+	err = authenticator.UpdateUserPassword(ctx, userID, hashedPassword)
+	if err != nil {
+		zap.L().Error("error while updating rehashed user password", zap.Error(err), zap.String("user_id", userID.String()))
+	}
+}
+
+func registerLoginFailure(ctx context.Context, keys []string, ip string, throttle entity.LoginThrottle, authenticator UserAuthenticator) {
+	for _, key := range keys {
+		attempt, err := authenticator.RegisterLoginFailure(ctx, key, throttle)
+		if err != nil {
+			zap.L().Error("error while registering login failure", zap.Error(err), zap.String("key", key))
+			continue
+		}
+
+		if throttle.IsLockout(attempt.Failures) {
+			zap.L().Warn("login attempts are locked out",
+				zap.String("key", attempt.Key),
+				zap.String("ip", ip),
+				zap.Int("failures", attempt.Failures),
+				zap.Duration("lockout", attempt.Blocked),
+			)
+		}
+	}
+}
+
+func writeTooManyAttempts(blocked time.Duration, w http.ResponseWriter) {
+	retryAfter := int64(math.Ceil(blocked.Seconds()))
+	w.Header().Set("Retry-After", strconv.FormatInt(retryAfter, 10))
+	problem.WriteCode(w, problem.CodeTooManyAttempts, ErrTooManyAttempts)
+}
+
+func CreateRefreshToken(userID entity.UserID, ttl time.Duration, authenticator UserAuthenticator, w http.ResponseWriter) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), httputils.RequestTimeout)
+	defer cancel()
+
+	refreshToken, err := crypto.GenerateRefreshToken()
+	if err != nil {
+		zap.L().Error("error while generating refresh token", zap.Error(err))
+		problem.Write(w, err)
+		return "", err
+	}
+
+	token := entity.CreateRefreshToken(crypto.HashRefreshToken(refreshToken), userID, ttl)
+	err = authenticator.CreateRefreshToken(ctx, token)
+	if err != nil {
+		zap.L().Error("error while creating refresh token", zap.Error(err), zap.String("user_id", userID.String()))
+		problem.Write(w, err)
+		return "", fmt.Errorf("error while creating refresh token: %w", err)
+	}
+
+	return refreshToken, nil
+}
+
+// RotateRefreshToken exchanges the refresh token for a new one and
+// returns the token owner.
+func RotateRefreshToken(refreshToken string, ttl time.Duration, authenticator UserAuthenticator, w http.ResponseWriter) (entity.User, string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), httputils.RequestTimeout)
+	defer cancel()
+
+	newRefreshToken, err := crypto.GenerateRefreshToken()
+	if err != nil {
+		zap.L().Error("error while generating refresh token", zap.Error(err))
+		problem.Write(w, err)
+		return entity.User{}, "", err
+	}
+
+	token := entity.CreateRefreshToken(crypto.HashRefreshToken(newRefreshToken), entity.UserID(""), ttl)
+	userID, err := authenticator.RotateRefreshToken(ctx, crypto.HashRefreshToken(refreshToken), token)
+	if err != nil {
+		zap.L().Error("error while rotating refresh token", zap.Error(err))
+
+		if errors.Is(err, err_storage.ErrRefreshTokenNotFound) {
+			problem.WriteCode(w, problem.CodeInvalidRefreshToken, ErrRefreshToken)
+			return entity.User{}, "", err
+		}
+
+		problem.Write(w, err)
+		return entity.User{}, "", fmt.Errorf("error while rotating refresh token: %w", err)
+	}
+
+	// роль читается заново, чтобы ее изменение попало в новый токен
+	user, err := authenticator.GetUserByID(ctx, userID)
+	if err != nil {
+		zap.L().Error("error while getting refresh token owner", zap.Error(err), zap.String("user_id", userID.String()))
+
+		if errors.Is(err, err_storage.ErrUserNotFoundTable) {
+			problem.WriteCode(w, problem.CodeInvalidRefreshToken, ErrRefreshToken)
+			return entity.User{}, "", err
+		}
+
+		problem.Write(w, err)
+		return entity.User{}, "", fmt.Errorf("error while getting refresh token owner: %w", err)
+	}
+
+	return user, newRefreshToken, nil
+}
+
+func Logout(userCtx entity.UserIDCtx, authenticator UserAuthenticator, w http.ResponseWriter) error {
+	ctx, cancel := context.WithTimeout(context.Background(), httputils.RequestTimeout)
+	defer cancel()
+
+	err := authenticator.RevokeUserTokens(ctx, userCtx.UserID, userCtx.Token)
+	if err != nil {
+		zap.L().Error("error while revoking user tokens", zap.Error(err), zap.String("user_id", userCtx.UserID.String()))
+		problem.Write(w, err)
+		return fmt.Errorf("error while revoking user tokens: %w", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	return nil
+}
+
+// ChangePassword checks the old password under the same throttle as the
+// login and revokes the refresh tokens of the user, so the sessions opened
+// with the old password can't be extended.
+func ChangePassword(userID entity.UserID, oldPassword, newPassword string, throttle entity.LoginThrottle, authenticator UserAuthenticator, w http.ResponseWriter) error {
+	ctx, cancel := context.WithTimeout(context.Background(), httputils.RequestTimeout)
+	defer cancel()
+
+	storageUser, err := authenticator.GetUserByID(ctx, userID)
+	if err != nil {
+		zap.L().Error("error while getting user while changing password", zap.Error(err), zap.String("user_id", userID.String()))
+
+		if errors.Is(err, err_storage.ErrUserNotFoundTable) {
+			problem.WriteCode(w, problem.CodeUnauthorized, ErrUserNotExist)
+			return err
+		}
+
+		problem.Write(w, err)
+		return err
+	}
+
+	keys := []string{entity.LoginKey(storageUser.Login)}
+	blocked, err := authenticator.GetLoginBlock(ctx, keys)
+	if err != nil {
+		zap.L().Error("error while getting login block while changing password", zap.Error(err))
+		problem.Write(w, err)
+		return err
+	}
+
+	if blocked > 0 {
+		zap.L().Info("password change is blocked", zap.String("user_id", userID.String()), zap.Duration("retry_after", blocked))
+		writeTooManyAttempts(blocked, w)
+		return ErrLoginBlocked
+	}
+
+	err = crypto.CheckPasswordHash(oldPassword, storageUser.Password)
+	if err != nil {
+		zap.L().Error("error while checking old password while changing password", zap.Error(err))
+		if errors.Is(err, crypto.ErrWrongPassword) {
+			registerLoginFailure(ctx, keys, "", throttle, authenticator)
+			problem.WriteCode(w, problem.CodeWrongPassword, ErrWrongPassword)
+			return err
+		}
+
+		problem.Write(w, err)
+		return err
+	}
+
+	hashedPassword, err := crypto.HashPassword(newPassword)
+	if err != nil {
+		zap.L().Error("error while hashing new password", zap.Error(err))
+		problem.Write(w, err)
+		return fmt.Errorf("error while hashing password: %w", err)
+	}
+
+	err = authenticator.UpdateUserPassword(ctx, userID, hashedPassword)
+	if err != nil {
+		zap.L().Error("error while updating user password", zap.Error(err), zap.String("user_id", userID.String()))
+		problem.Write(w, err)
+		return fmt.Errorf("error while updating user password: %w", err)
+	}
+
+	err = authenticator.ResetLoginFailures(ctx, keys[0])
+	if err != nil {
+		zap.L().Error("error while resetting login failures", zap.Error(err), zap.String("login", storageUser.Login))
+	}
+
+	err = authenticator.RevokeUserTokens(ctx, userID, entity.AccessToken{})
+	if err != nil {
+		zap.L().Error("error while revoking user tokens after password change", zap.Error(err), zap.String("user_id", userID.String()))
+		problem.Write(w, err)
+		return fmt.Errorf("error while revoking user tokens: %w", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	return nil
+}
+
+// DeleteUser closes the user account. Existing tokens of the user stop
+// working because the token parser checks the account status.
+func DeleteUser(userID entity.UserID, authenticator UserAuthenticator, w http.ResponseWriter) error {
+	ctx, cancel := context.WithTimeout(context.Background(), httputils.RequestTimeout)
+	defer cancel()
+
+	err := authenticator.DeleteUser(ctx, userID)
+	if err != nil {
+		zap.L().Error("error while deleting user", zap.Error(err), zap.String("user_id", userID.String()))
+
+		if errors.Is(err, err_storage.ErrUserNotFoundTable) {
+			problem.WriteCode(w, problem.CodeUnauthorized, ErrUserNotExist)
+			return err
+		}
+
+		problem.Write(w, err)
+		return fmt.Errorf("error while deleting user: %w", err)
+	}
+
+	zap.L().Info("user has been deleted", zap.String("user_id", userID.String()))
+	w.WriteHeader(http.StatusOK)
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package callback
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/avGenie/go-loyalty-system/internal/app/entity"
+	err_storage "github.com/avGenie/go-loyalty-system/internal/app/storage/api/errors"
+	"github.com/avGenie/go-loyalty-system/internal/app/usecase/problem"
+	httputils "github.com/avGenie/go-loyalty-system/internal/app/usecase/utils"
+	"go.uber.org/zap"
+)
+
+type AccrualCallbackProcessor interface {
+	ApplyAccrualCallback(ctx context.Context, signature string, ttl time.Duration, order entity.Order) error
+}
+
+// ProcessAccrualCallback applies the order status pushed by the accrual system.
+// The signature is remembered for ttl together with the update, so the same
+// callback can't be replayed, but a failed one can be retried.
+func ProcessAccrualCallback(order entity.Order, signature string, ttl time.Duration, processor AccrualCallbackProcessor, w http.ResponseWriter) error {
+	ctx, cancel := context.WithTimeout(context.Background(), httputils.RequestTimeout)
+	defer cancel()
+
+	err := processor.ApplyAccrualCallback(ctx, signature, ttl, order)
+	if err != nil {
+		if errors.Is(err, err_storage.ErrAccrualCallbackExists) {
+			problem.Write(w, err)
+			return fmt.Errorf("accrual callback replayed: %w", err)
+		}
+
+		if errors.Is(err, err_storage.ErrOrderNumberNotFound) {
+			problem.Write(w, err)
+			return fmt.Errorf("accrual callback for unknown order: %w", err)
+		}
+
+		if errors.Is(err, err_storage.ErrOrderProcessed) {
+			problem.Write(w, err)
+			return fmt.Errorf("accrual callback for order with final status: %w", err)
+		}
+
+		problem.Write(w, err)
+		return fmt.Errorf("error while updating order from accrual callback: %w", err)
+	}
+
+	zap.L().Info("order updated from accrual callback",
+		zap.String("order_number", string(order.Number)),
+		zap.String("status", string(order.Status)))
+
+	return nil
+}
@@ -15,25 +15,34 @@ const (
 )
 
 func GetUserIDFromAuthHeader(header string) (entity.UserID, error) {
+	claims, err := GetClaimsFromAuthHeader(header)
+	if err != nil {
+		return entity.UserID(""), err
+	}
+
+	return claims.UserID, nil
+}
+
+func GetClaimsFromAuthHeader(header string) (*crypto.Claims, error) {
 	headerParts := strings.Split(header, " ")
 	if len(headerParts) != 2 {
-		return entity.UserID(""), fmt.Errorf("auth header doesn't contain two parts")
+		return nil, fmt.Errorf("auth header doesn't contain two parts")
 	}
 
 	if headerParts[0] != bearerHeader {
-		return entity.UserID(""), fmt.Errorf("first auth header part is invalid")
+		return nil, fmt.Errorf("first auth header part is invalid")
 	}
 
-	userID, err := crypto.GetUserID(headerParts[1])
+	claims, err := crypto.ParseToken(headerParts[1])
 	if err != nil {
-		return entity.UserID(""), fmt.Errorf("error while getting user id from token: %w", err)
+		return nil, fmt.Errorf("error while getting user id from token: %w", err)
 	}
 
-	return userID, nil
+	return claims, nil
 }
 
-func SetUserIDToAuthHeaderFormat(userID entity.UserID) (string, error) {
-	token, err := crypto.BuildJWTString(userID)
+func SetUserIDToAuthHeaderFormat(userID entity.UserID, role entity.UserRole) (string, error) {
+	token, err := crypto.BuildJWTString(userID, role)
 	if err != nil {
 		return "", fmt.Errorf("error while creating jwt token: %w", err)
 	}
@@ -0,0 +1,46 @@
+package usecase
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+
+	"github.com/avGenie/go-loyalty-system/internal/app/model"
+	"github.com/avGenie/go-loyalty-system/internal/app/usecase/crypto"
+)
+
+const (
+	keyUseSignature = "sig"
+)
+
+func ConvertKeysToJWKS(keys []crypto.Key) model.JSONWebKeySet {
+	set := model.JSONWebKeySet{
+		Keys: make([]model.JSONWebKey, 0, len(keys)),
+	}
+
+	for _, key := range keys {
+		jwk := model.JSONWebKey{
+			KeyID:     key.ID,
+			Use:       keyUseSignature,
+			Algorithm: key.Method.Alg(),
+		}
+
+		switch publicKey := key.VerifyKey.(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.Modulus = base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes())
+			jwk.Exponent = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(publicKey)
+		default:
+			continue
+		}
+
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	return set
+}
@@ -0,0 +1,152 @@
+package crypto
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrUnsupportedKey = errors.New("unsupported jwt key type")
+)
+
+// Key is a token signing key. Keys without SignKey only verify tokens.
+type Key struct {
+	ID        string
+	Method    jwt.SigningMethod
+	SignKey   interface{}
+	VerifyKey interface{}
+}
+
+// NewHMACKey creates an HS256 key. The id is derived from the secret when empty.
+func NewHMACKey(id string, secret []byte) Key {
+	if len(id) == 0 {
+		id = keyID(secret)
+	}
+
+	return Key{
+		ID:        id,
+		Method:    jwt.SigningMethodHS256,
+		SignKey:   secret,
+		VerifyKey: secret,
+	}
+}
+
+// ParsePEMKey creates an RS256 or EdDSA key from a PEM block with a private
+// or a public key. The id is derived from the public key when empty.
+func ParsePEMKey(id string, data []byte) (Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return Key{}, fmt.Errorf("no PEM block found")
+	}
+
+	var key Key
+	switch block.Type {
+	case "PRIVATE KEY":
+		privateKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return Key{}, fmt.Errorf("cannot parse PKCS8 private key: %w", err)
+		}
+
+		key, err = createPrivateKey(privateKey)
+		if err != nil {
+			return Key{}, err
+		}
+	case "RSA PRIVATE KEY":
+		privateKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return Key{}, fmt.Errorf("cannot parse PKCS1 private key: %w", err)
+		}
+
+		key, err = createPrivateKey(privateKey)
+		if err != nil {
+			return Key{}, err
+		}
+	case "PUBLIC KEY":
+		publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return Key{}, fmt.Errorf("cannot parse PKIX public key: %w", err)
+		}
+
+		key, err = createPublicKey(publicKey)
+		if err != nil {
+			return Key{}, err
+		}
+	default:
+		return Key{}, fmt.Errorf("%w: PEM block %q", ErrUnsupportedKey, block.Type)
+	}
+
+	key.ID = id
+	if len(key.ID) == 0 {
+		der, err := x509.MarshalPKIXPublicKey(key.VerifyKey)
+		if err != nil {
+			return Key{}, fmt.Errorf("cannot marshal public key: %w", err)
+		}
+		key.ID = keyID(der)
+	}
+
+	return key, nil
+}
+
+func (k Key) CanSign() bool {
+	return k.SignKey != nil
+}
+
+// IsPublic reports whether the verification key can be published.
+func (k Key) IsPublic() bool {
+	switch k.VerifyKey.(type) {
+	case *rsa.PublicKey, ed25519.PublicKey:
+		return true
+	default:
+		return false
+	}
+}
+
+func createPrivateKey(privateKey any) (Key, error) {
+	switch privateKey := privateKey.(type) {
+	case *rsa.PrivateKey:
+		return Key{
+			Method:    jwt.SigningMethodRS256,
+			SignKey:   privateKey,
+			VerifyKey: &privateKey.PublicKey,
+		}, nil
+	case ed25519.PrivateKey:
+		return Key{
+			Method:    jwt.SigningMethodEdDSA,
+			SignKey:   privateKey,
+			VerifyKey: privateKey.Public(),
+		}, nil
+	default:
+		return Key{}, fmt.Errorf("%w: %T", ErrUnsupportedKey, privateKey)
+	}
+}
+
+func createPublicKey(publicKey any) (Key, error) {
+	switch publicKey := publicKey.(type) {
+	case *rsa.PublicKey:
+		return Key{
+			Method:    jwt.SigningMethodRS256,
+			VerifyKey: publicKey,
+		}, nil
+	case ed25519.PublicKey:
+		return Key{
+			Method:    jwt.SigningMethodEdDSA,
+			VerifyKey: publicKey,
+		}, nil
+	default:
+		return Key{}, fmt.Errorf("%w: %T", ErrUnsupportedKey, publicKey)
+	}
+}
+
+func keyID(data []byte) string {
+	hash := sha256.Sum256(data)
+
+	return hex.EncodeToString(hash[:4])
+}
@@ -0,0 +1,240 @@
+package crypto
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/avGenie/go-loyalty-system/internal/app/config"
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultTokenTTL = time.Hour * 3
+
+	ephemeralSecretLen = 32
+
+	pemKeyPrefix = "file:"
+)
+
+var (
+	ErrEmptyKeyRing = errors.New("key ring doesn't contain keys")
+	ErrNoSigningKey = errors.New("jwt secret or key file isn't configured")
+	ErrUnknownKeyID = errors.New("unknown token key id")
+)
+
+var keyRing atomic.Pointer[KeyRing]
+
+func init() {
+	keyRing.Store(newEphemeralKeyRing(defaultTokenTTL))
+}
+
+// KeyRing signs new tokens with the first key and verifies tokens signed
+// with any of its keys, so old tokens stay valid while keys are rotated.
+type KeyRing struct {
+	keys []Key
+	ttl  time.Duration
+}
+
+func NewKeyRing(keys []Key, ttl time.Duration) (*KeyRing, error) {
+	if len(keys) == 0 {
+		return nil, ErrEmptyKeyRing
+	}
+
+	if !keys[0].CanSign() {
+		return nil, fmt.Errorf("signing key %q has no private part", keys[0].ID)
+	}
+
+	ids := make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		if len(key.ID) == 0 || key.Method == nil || key.VerifyKey == nil {
+			return nil, fmt.Errorf("key id, signing method and verification key must be set")
+		}
+
+		if _, ok := ids[key.ID]; ok {
+			return nil, fmt.Errorf("duplicate key id %q", key.ID)
+		}
+		ids[key.ID] = struct{}{}
+	}
+
+	return &KeyRing{
+		keys: keys,
+		ttl:  ttl,
+	}, nil
+}
+
+// LoadKeyRing creates the key ring from the keys file, the private key PEM
+// file or the single secret, whichever is configured first. Without them
+// ErrNoSigningKey is returned unless the random secret is explicitly
+// allowed, tokens signed with it don't survive restart.
+func LoadKeyRing(config config.Config) (*KeyRing, error) {
+	ttl := config.JWTTokenTTL
+	if ttl <= 0 {
+		ttl = defaultTokenTTL
+	}
+
+	if len(config.JWTKeysFile) != 0 {
+		keys, err := readKeysFile(config.JWTKeysFile)
+		if err != nil {
+			return nil, err
+		}
+
+		return NewKeyRing(keys, ttl)
+	}
+
+	if len(config.JWTPrivateKeyFile) != 0 {
+		key, err := readPEMKey("", config.JWTPrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		return NewKeyRing([]Key{key}, ttl)
+	}
+
+	if len(config.JWTSecret) != 0 {
+		return NewKeyRing([]Key{NewHMACKey("", []byte(config.JWTSecret))}, ttl)
+	}
+
+	if !config.JWTRandomSecret {
+		return nil, ErrNoSigningKey
+	}
+
+	zap.L().Warn("jwt secret isn't configured, tokens are signed with a random secret")
+
+	return newEphemeralKeyRing(ttl), nil
+}
+
+func SetKeyRing(ring *KeyRing) {
+	keyRing.Store(ring)
+}
+
+// PublicKeys returns the verification keys that can be published.
+func PublicKeys() []Key {
+	return keyRing.Load().PublicKeys()
+}
+
+func (r *KeyRing) SigningKey() Key {
+	return r.keys[0]
+}
+
+func (r *KeyRing) Key(id string) (Key, error) {
+	for _, key := range r.keys {
+		if key.ID == id {
+			return key, nil
+		}
+	}
+
+	return Key{}, fmt.Errorf("%w: %q", ErrUnknownKeyID, id)
+}
+
+func (r *KeyRing) TTL() time.Duration {
+	return r.ttl
+}
+
+func (r *KeyRing) PublicKeys() []Key {
+	var keys []Key
+	for _, key := range r.keys {
+		if key.IsPublic() {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
+
+// Keyfunc picks the verification key by the token kid header and checks
+// that the token is signed with the algorithm of that key.
+func (r *KeyRing) Keyfunc(token *jwt.Token) (interface{}, error) {
+	key := r.SigningKey()
+
+	// токены без kid выпущены до появления ротации ключей
+	if keyID, ok := token.Header[keyIDHeader].(string); ok {
+		var err error
+		key, err = r.Key(keyID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method while parsing jwt: %v", token.Header["alg"])
+	}
+
+	return key.VerifyKey, nil
+}
+
+// readKeysFile reads "kid=secret" and "kid=file:/path/to/key.pem" lines.
+// The first key is used for signing.
+func readKeysFile(path string) ([]Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read jwt keys file: %w", err)
+	}
+
+	var keys []Key
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		id, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("jwt keys file line must be in kid=secret format")
+		}
+		id = strings.TrimSpace(id)
+		value = strings.TrimSpace(value)
+
+		if pemPath, ok := strings.CutPrefix(value, pemKeyPrefix); ok {
+			key, err := readPEMKey(id, pemPath)
+			if err != nil {
+				return nil, err
+			}
+
+			keys = append(keys, key)
+			continue
+		}
+
+		keys = append(keys, NewHMACKey(id, []byte(value)))
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read jwt keys file: %w", err)
+	}
+
+	return keys, nil
+}
+
+func readPEMKey(id, path string) (Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Key{}, fmt.Errorf("cannot read jwt key PEM file: %w", err)
+	}
+
+	key, err := ParsePEMKey(id, data)
+	if err != nil {
+		return Key{}, fmt.Errorf("cannot parse jwt key PEM file %s: %w", path, err)
+	}
+
+	return key, nil
+}
+
+func newEphemeralKeyRing(ttl time.Duration) *KeyRing {
+	secret := make([]byte, ephemeralSecretLen)
+	if _, err := rand.Read(secret); err != nil {
+		panic(fmt.Errorf("cannot generate jwt secret: %w", err))
+	}
+
+	return &KeyRing{
+		keys: []Key{NewHMACKey("", secret)},
+		ttl:  ttl,
+	}
+}
@@ -1,22 +1,127 @@
 package crypto
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"math"
+	"strings"
+	"sync/atomic"
 
+	"github.com/avGenie/go-loyalty-system/internal/app/config"
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/bcrypt"
 )
 
 const (
-	bcryptCost = 14
+	AlgorithmBcrypt   = "bcrypt"
+	AlgorithmArgon2id = "argon2id"
+
+	defaultBcryptCost = 14
+
+	defaultArgon2Time    = 2
+	defaultArgon2Memory  = 19 * 1024
+	defaultArgon2Threads = 1
+	argon2SaltLen        = 16
+	argon2KeyLen         = 32
+
+	argon2Prefix = "$argon2id$"
 )
 
 var (
-	ErrWrongPassword = errors.New("wrong password")
+	ErrWrongPassword       = errors.New("wrong password")
+	ErrUnknownPasswordHash = errors.New("unknown password hash format")
 )
 
+var bcryptPrefixes = []string{"$2a$", "$2b$", "$2y$"}
+
+var passwordHasher atomic.Pointer[PasswordHasher]
+
+func init() {
+	SetPasswordHasher(NewArgon2idHasher(defaultArgon2Time, defaultArgon2Memory, defaultArgon2Threads))
+}
+
+// PasswordHasher hashes passwords with one algorithm. Hashes carry
+// the algorithm prefix and parameters, so they are verified by
+// the hasher that created them.
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	Verify(password, hash string) error
+	Matches(hash string) bool
+	// IsCurrent reports whether the hash uses the parameters of the hasher.
+	IsCurrent(hash string) bool
+}
+
+// LoadPasswordHasher creates the hasher new passwords are hashed with.
+func LoadPasswordHasher(config config.Config) (PasswordHasher, error) {
+	switch config.PasswordHashAlgorithm {
+	case "", AlgorithmArgon2id:
+		if config.Argon2Memory > math.MaxUint32 || config.Argon2Time > math.MaxUint32 || config.Argon2Threads > math.MaxUint8 {
+			return nil, errors.New("argon2 parameters are out of range")
+		}
+
+		return NewArgon2idHasher(
+			uint32(uintOrDefault(config.Argon2Time, defaultArgon2Time)),
+			uint32(uintOrDefault(config.Argon2Memory, defaultArgon2Memory)),
+			uint8(uintOrDefault(config.Argon2Threads, defaultArgon2Threads)),
+		), nil
+	case AlgorithmBcrypt:
+		cost := config.BcryptCost
+		if cost == 0 {
+			cost = defaultBcryptCost
+		}
+
+		if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("bcrypt cost must be from %d to %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+
+		return NewBcryptHasher(cost), nil
+	default:
+		return nil, fmt.Errorf("unknown password hash algorithm %q", config.PasswordHashAlgorithm)
+	}
+}
+
+func SetPasswordHasher(hasher PasswordHasher) {
+	passwordHasher.Store(&hasher)
+}
+
 func HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
+	return (*passwordHasher.Load()).Hash(password)
+}
+
+// CheckPasswordHash verifies the password with the hasher the hash was
+// created by, which may differ from the current one.
+func CheckPasswordHash(password, hash string) error {
+	current := *passwordHasher.Load()
+	for _, hasher := range []PasswordHasher{current, bcryptHasher{}, argon2idHasher{}} {
+		if hasher.Matches(hash) {
+			return hasher.Verify(password, hash)
+		}
+	}
+
+	return ErrUnknownPasswordHash
+}
+
+// NeedsRehash reports whether the hash was created with another algorithm
+// or other parameters than the current hasher uses.
+func NeedsRehash(hash string) bool {
+	return !(*passwordHasher.Load()).IsCurrent(hash)
+}
+
+type bcryptHasher struct {
+	cost int
+}
+
+func NewBcryptHasher(cost int) PasswordHasher {
+	return bcryptHasher{
+		cost: cost,
+	}
+}
+
+func (h bcryptHasher) Hash(password string) (string, error) {
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), h.cost)
 	if err != nil {
 		return "", fmt.Errorf("error while hashing password: %w", err)
 	}
@@ -24,15 +129,137 @@ func HashPassword(password string) (string, error) {
 	return string(bytes), nil
 }
 
-func CheckPasswordHash(password, hash string) error {
+func (h bcryptHasher) Verify(password, hash string) error {
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 	if err != nil {
 		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
 			return ErrWrongPassword
 		}
-		
+
 		return fmt.Errorf("error while checking password hash: %w", err)
 	}
 
 	return nil
 }
+
+func (h bcryptHasher) Matches(hash string) bool {
+	for _, prefix := range bcryptPrefixes {
+		if strings.HasPrefix(hash, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (h bcryptHasher) IsCurrent(hash string) bool {
+	if !h.Matches(hash) {
+		return false
+	}
+
+	cost, err := bcrypt.Cost([]byte(hash))
+
+	return err == nil && cost == h.cost
+}
+
+type argon2idParams struct {
+	time    uint32
+	memory  uint32
+	threads uint8
+}
+
+type argon2idHasher struct {
+	params argon2idParams
+}
+
+func NewArgon2idHasher(time, memory uint32, threads uint8) PasswordHasher {
+	return argon2idHasher{
+		params: argon2idParams{
+			time:    time,
+			memory:  memory,
+			threads: threads,
+		},
+	}
+}
+
+// Hash returns the hash in the PHC string format:
+// $argon2id$v=19$m=19456,t=2,p=1$<salt>$<key>.
+func (h argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, argon2SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("error while generating password salt: %w", err)
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.params.time, h.params.memory, h.params.threads, argon2KeyLen)
+
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2Prefix, argon2.Version,
+		h.params.memory, h.params.time, h.params.threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (h argon2idHasher) Verify(password, hash string) error {
+	params, salt, key, err := parseArgon2idHash(hash)
+	if err != nil {
+		return fmt.Errorf("error while checking password hash: %w", err)
+	}
+
+	otherKey := argon2.IDKey([]byte(password), salt, params.time, params.memory, params.threads, uint32(len(key)))
+	if subtle.ConstantTimeCompare(key, otherKey) != 1 {
+		return ErrWrongPassword
+	}
+
+	return nil
+}
+
+func (h argon2idHasher) Matches(hash string) bool {
+	return strings.HasPrefix(hash, argon2Prefix)
+}
+
+func (h argon2idHasher) IsCurrent(hash string) bool {
+	if !h.Matches(hash) {
+		return false
+	}
+
+	params, _, _, err := parseArgon2idHash(hash)
+
+	return err == nil && params == h.params
+}
+
+func parseArgon2idHash(hash string) (argon2idParams, []byte, []byte, error) {
+	parts := strings.Split(strings.TrimPrefix(hash, argon2Prefix), "$")
+	if len(parts) != 4 {
+		return argon2idParams{}, nil, nil, ErrUnknownPasswordHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[0], "v=%d", &version); err != nil || version != argon2.Version {
+		return argon2idParams{}, nil, nil, fmt.Errorf("unsupported argon2 version %q", parts[0])
+	}
+
+	var params argon2idParams
+	if _, err := fmt.Sscanf(parts[1], "m=%d,t=%d,p=%d", &params.memory, &params.time, &params.threads); err != nil {
+		return argon2idParams{}, nil, nil, fmt.Errorf("invalid argon2 parameters %q: %w", parts[1], err)
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return argon2idParams{}, nil, nil, fmt.Errorf("invalid argon2 salt: %w", err)
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return argon2idParams{}, nil, nil, fmt.Errorf("invalid argon2 key: %w", err)
+	}
+
+	return params, salt, key, nil
+}
+
+func uintOrDefault(value, defaultValue uint) uint {
+	if value == 0 {
+		return defaultValue
+	}
+
+	return value
+}
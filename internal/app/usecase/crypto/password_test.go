@@ -0,0 +1,139 @@
+package crypto
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/avGenie/go-loyalty-system/internal/app/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPasswordHashers(t *testing.T) {
+	tests := []struct {
+		name   string
+		hasher PasswordHasher
+		prefix string
+	}{
+		{
+			name:   "bcrypt",
+			hasher: NewBcryptHasher(4),
+			prefix: "$2a$04$",
+		},
+		{
+			name:   "argon2id",
+			hasher: NewArgon2idHasher(1, 1024, 1),
+			prefix: "$argon2id$v=19$m=1024,t=1,p=1$",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hash, err := test.hasher.Hash("password")
+			require.NoError(t, err)
+			assert.True(t, strings.HasPrefix(hash, test.prefix), hash)
+
+			assert.NoError(t, test.hasher.Verify("password", hash))
+			assert.ErrorIs(t, test.hasher.Verify("wrong password", hash), ErrWrongPassword)
+			assert.True(t, test.hasher.IsCurrent(hash))
+
+			SetPasswordHasher(test.hasher)
+			defer SetPasswordHasher(NewArgon2idHasher(defaultArgon2Time, defaultArgon2Memory, defaultArgon2Threads))
+
+			assert.NoError(t, CheckPasswordHash("password", hash))
+			assert.False(t, NeedsRehash(hash))
+		})
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	bcryptHash, err := NewBcryptHasher(4).Hash("password")
+	require.NoError(t, err)
+
+	argon2Hash, err := NewArgon2idHasher(1, 1024, 1).Hash("password")
+	require.NoError(t, err)
+
+	SetPasswordHasher(NewArgon2idHasher(1, 2048, 1))
+	defer SetPasswordHasher(NewArgon2idHasher(defaultArgon2Time, defaultArgon2Memory, defaultArgon2Threads))
+
+	assert.True(t, NeedsRehash(bcryptHash))
+	assert.True(t, NeedsRehash(argon2Hash))
+
+	assert.NoError(t, CheckPasswordHash("password", bcryptHash))
+	assert.NoError(t, CheckPasswordHash("password", argon2Hash))
+	assert.ErrorIs(t, CheckPasswordHash("password", "plain"), ErrUnknownPasswordHash)
+
+	SetPasswordHasher(NewBcryptHasher(5))
+	assert.True(t, NeedsRehash(bcryptHash))
+	assert.True(t, NeedsRehash(argon2Hash))
+}
+
+func TestLoadPasswordHasher(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  config.Config
+		hash    string
+		isError bool
+	}{
+		{
+			name:   "default",
+			config: config.Config{},
+			hash:   "$argon2id$v=19$m=19456,t=2,p=1$",
+		},
+		{
+			name: "argon2id params",
+			config: config.Config{
+				PasswordHashAlgorithm: AlgorithmArgon2id,
+				Argon2Time:            1,
+				Argon2Memory:          1024,
+				Argon2Threads:         2,
+			},
+			hash: "$argon2id$v=19$m=1024,t=1,p=2$",
+		},
+		{
+			name: "bcrypt",
+			config: config.Config{
+				PasswordHashAlgorithm: AlgorithmBcrypt,
+				BcryptCost:            4,
+			},
+			hash: "$2a$04$",
+		},
+		{
+			name: "bcrypt cost out of range",
+			config: config.Config{
+				PasswordHashAlgorithm: AlgorithmBcrypt,
+				BcryptCost:            32,
+			},
+			isError: true,
+		},
+		{
+			name: "argon2id threads out of range",
+			config: config.Config{
+				Argon2Threads: 256,
+			},
+			isError: true,
+		},
+		{
+			name: "unknown algorithm",
+			config: config.Config{
+				PasswordHashAlgorithm: "md5",
+			},
+			isError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hasher, err := LoadPasswordHasher(test.config)
+			if test.isError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			hash, err := hasher.Hash("password")
+			require.NoError(t, err)
+			assert.True(t, strings.HasPrefix(hash, test.hash), hash)
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+)
+
+const (
+	refreshTokenLen = 32
+)
+
+// GenerateRefreshToken returns an opaque refresh token. Only its hash
+// is stored, so a leaked table can't be used to refresh sessions.
+func GenerateRefreshToken() (string, error) {
+	token := make([]byte, refreshTokenLen)
+	if _, err := rand.Read(token); err != nil {
+		return "", fmt.Errorf("cannot generate refresh token: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(token), nil
+}
+
+func HashRefreshToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,23 @@
+package crypto
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// SignPayload returns hex encoded HMAC-SHA256 of "timestamp.body".
+func SignPayload(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func VerifyPayloadSignature(secret []byte, timestamp string, body []byte, signature string) bool {
+	expected := SignPayload(secret, timestamp, body)
+
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
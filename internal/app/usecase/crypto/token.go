@@ -8,27 +8,36 @@ import (
 	"github.com/avGenie/go-loyalty-system/internal/app/entity"
 	err_usecase "github.com/avGenie/go-loyalty-system/internal/app/usecase/errors"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
 const (
-	tokenTimeout = time.Hour * 3
-	secretKey    = "5269889d400bbf2dc66216f37b2839bb"
+	keyIDHeader = "kid"
 )
 
 type Claims struct {
 	jwt.RegisteredClaims
 	UserID entity.UserID
+	Role   entity.UserRole `json:",omitempty"`
 }
 
-func BuildJWTString(userID entity.UserID) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+func BuildJWTString(userID entity.UserID, role entity.UserRole) (string, error) {
+	ring := keyRing.Load()
+	key := ring.SigningKey()
+
+	now := time.Now()
+	token := jwt.NewWithClaims(key.Method, Claims{
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(tokenTimeout)),
+			ID:        uuid.NewString(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ring.TTL())),
 		},
 		UserID: userID,
+		Role:   role,
 	})
+	token.Header[keyIDHeader] = key.ID
 
-	tokenString, err := token.SignedString([]byte(secretKey))
+	tokenString, err := token.SignedString(key.SignKey)
 	if err != nil {
 		return "", err
 	}
@@ -37,26 +46,31 @@ func BuildJWTString(userID entity.UserID) (string, error) {
 }
 
 func GetUserID(tokenString string) (entity.UserID, error) {
+	claims, err := ParseToken(tokenString)
+	if err != nil {
+		return entity.UserID(""), err
+	}
+
+	return claims.UserID, nil
+}
+
+func ParseToken(tokenString string) (*Claims, error) {
+	ring := keyRing.Load()
+
 	claims := &Claims{}
-	token, err := jwt.ParseWithClaims(tokenString, claims,
-		func(t *jwt.Token) (interface{}, error) {
-			if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method while parsing jwt: %v", t.Header["alg"])
-			}
-			return []byte(secretKey), nil
-		})
+	token, err := jwt.ParseWithClaims(tokenString, claims, ring.Keyfunc)
 
 	if err != nil {
 		if errors.Is(err, jwt.ErrTokenExpired) {
-			return entity.UserID(""), err_usecase.ErrTokenExpired
+			return nil, err_usecase.ErrTokenExpired
 		}
 
-		return entity.UserID(""), fmt.Errorf("error while getting user id from token: %w", err)
+		return nil, fmt.Errorf("error while getting user id from token: %w", err)
 	}
 
 	if !token.Valid {
-		return entity.UserID(""), err_usecase.ErrTokenNotValid
+		return nil, err_usecase.ErrTokenNotValid
 	}
 
-	return claims.UserID, nil
+	return claims, nil
 }
@@ -0,0 +1,247 @@
+package crypto
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/avGenie/go-loyalty-system/internal/app/config"
+	"github.com/avGenie/go-loyalty-system/internal/app/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testUserID = entity.UserID("0b98bf79-833c-44e0-b979-2dae19dda46c")
+)
+
+func TestKeyRotation(t *testing.T) {
+	oldKey := NewHMACKey("old", []byte("old secret"))
+	newKey := NewHMACKey("new", []byte("new secret"))
+
+	ring, err := NewKeyRing([]Key{oldKey}, time.Hour)
+	require.NoError(t, err)
+	SetKeyRing(ring)
+
+	oldToken, err := BuildJWTString(testUserID, entity.UserRoleUser)
+	require.NoError(t, err)
+
+	ring, err = NewKeyRing([]Key{newKey, oldKey}, time.Hour)
+	require.NoError(t, err)
+	SetKeyRing(ring)
+
+	newToken, err := BuildJWTString(testUserID, entity.UserRoleUser)
+	require.NoError(t, err)
+
+	userID, err := GetUserID(oldToken)
+	require.NoError(t, err)
+	assert.Equal(t, testUserID, userID)
+
+	userID, err = GetUserID(newToken)
+	require.NoError(t, err)
+	assert.Equal(t, testUserID, userID)
+
+	ring, err = NewKeyRing([]Key{newKey}, time.Hour)
+	require.NoError(t, err)
+	SetKeyRing(ring)
+
+	_, err = GetUserID(oldToken)
+	assert.ErrorIs(t, err, ErrUnknownKeyID)
+}
+
+func TestAsymmetricKeys(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		privateKey any
+		alg        string
+	}{
+		{
+			name:       "RS256",
+			privateKey: rsaKey,
+			alg:        "RS256",
+		},
+		{
+			name:       "Ed25519",
+			privateKey: ed25519Key,
+			alg:        "EdDSA",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			key, err := ParsePEMKey("", encodePrivateKey(t, test.privateKey))
+			require.NoError(t, err)
+			assert.Equal(t, test.alg, key.Method.Alg())
+			assert.True(t, key.CanSign())
+			assert.True(t, key.IsPublic())
+
+			ring, err := NewKeyRing([]Key{key}, time.Hour)
+			require.NoError(t, err)
+			SetKeyRing(ring)
+
+			token, err := BuildJWTString(testUserID, entity.UserRoleUser)
+			require.NoError(t, err)
+
+			userID, err := GetUserID(token)
+			require.NoError(t, err)
+			assert.Equal(t, testUserID, userID)
+
+			publicKeys := PublicKeys()
+			require.Len(t, publicKeys, 1)
+			assert.Equal(t, key.ID, publicKeys[0].ID)
+
+			// подпись HMAC с тем же kid не должна проходить проверку
+			forgedRing, err := NewKeyRing([]Key{NewHMACKey(key.ID, []byte("secret"))}, time.Hour)
+			require.NoError(t, err)
+			SetKeyRing(forgedRing)
+
+			forgedToken, err := BuildJWTString(testUserID, entity.UserRoleUser)
+			require.NoError(t, err)
+
+			SetKeyRing(ring)
+			_, err = GetUserID(forgedToken)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestVerifyOnlyKey(t *testing.T) {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	der, err := x509.MarshalPKIXPublicKey(privateKey.Public())
+	require.NoError(t, err)
+
+	key, err := ParsePEMKey("public", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	require.NoError(t, err)
+	assert.False(t, key.CanSign())
+
+	_, err = NewKeyRing([]Key{key}, time.Hour)
+	assert.Error(t, err)
+
+	_, err = NewKeyRing([]Key{NewHMACKey("current", []byte("secret")), key}, time.Hour)
+	assert.NoError(t, err)
+}
+
+func TestLoadKeyRing(t *testing.T) {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	privateKeyFile := filepath.Join(t.TempDir(), "key.pem")
+	err = os.WriteFile(privateKeyFile, encodePrivateKey(t, privateKey), 0o600)
+	require.NoError(t, err)
+
+	keysFile := filepath.Join(t.TempDir(), "keys")
+	err = os.WriteFile(keysFile, []byte("# current key goes first\n2024-05=new secret\n2024-04=old secret\n"), 0o600)
+	require.NoError(t, err)
+
+	mixedKeysFile := filepath.Join(t.TempDir(), "mixed-keys")
+	err = os.WriteFile(mixedKeysFile, []byte("2024-06=file:"+privateKeyFile+"\n2024-05=new secret\n"), 0o600)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		config  config.Config
+		keyID   string
+		ttl     time.Duration
+		isError bool
+	}{
+		{
+			name: "keys file",
+			config: config.Config{
+				JWTSecret:   "ignored",
+				JWTKeysFile: keysFile,
+				JWTTokenTTL: time.Minute,
+			},
+			keyID: "2024-05",
+			ttl:   time.Minute,
+		},
+		{
+			name: "keys file with PEM key",
+			config: config.Config{
+				JWTKeysFile: mixedKeysFile,
+			},
+			keyID: "2024-06",
+			ttl:   defaultTokenTTL,
+		},
+		{
+			name: "private key file",
+			config: config.Config{
+				JWTSecret:         "ignored",
+				JWTPrivateKeyFile: privateKeyFile,
+			},
+			keyID: mustParsePEMKey(t, privateKeyFile).ID,
+			ttl:   defaultTokenTTL,
+		},
+		{
+			name: "single secret",
+			config: config.Config{
+				JWTSecret: "secret",
+			},
+			keyID: "2bb80d53",
+			ttl:   defaultTokenTTL,
+		},
+		{
+			name: "random secret",
+			config: config.Config{
+				JWTRandomSecret: true,
+			},
+			ttl: defaultTokenTTL,
+		},
+		{
+			name:    "no keys",
+			config:  config.Config{},
+			isError: true,
+		},
+		{
+			name: "missing keys file",
+			config: config.Config{
+				JWTKeysFile: filepath.Join(t.TempDir(), "missing"),
+			},
+			isError: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ring, err := LoadKeyRing(test.config)
+			if test.isError {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			// id of the random secret is random too
+			if len(test.keyID) != 0 {
+				assert.Equal(t, test.keyID, ring.SigningKey().ID)
+			}
+			assert.Equal(t, test.ttl, ring.TTL())
+		})
+	}
+}
+
+func encodePrivateKey(t *testing.T, privateKey any) []byte {
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	require.NoError(t, err)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func mustParsePEMKey(t *testing.T, path string) Key {
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	key, err := ParsePEMKey("", data)
+	require.NoError(t, err)
+
+	return key
+}
@@ -0,0 +1,148 @@
+package order
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/avGenie/go-loyalty-system/internal/app/entity"
+	"go.uber.org/zap"
+)
+
+const (
+	subscriberBufLen = 16
+)
+
+type OrderEventsListener interface {
+	ListenOrderEvents(ctx context.Context) (<-chan entity.OrderEvent, error)
+}
+
+// EventBroker shares one storage listener between the order event streams
+// of the instance. The listener is started by the first subscriber, so the
+// instances without streams don't hold a storage connection.
+type EventBroker struct {
+	listener    OrderEventsListener
+	mu          sync.Mutex
+	subscribers map[entity.UserID]map[chan entity.OrderEvent]struct{}
+	cancel      context.CancelFunc
+	stopped     bool
+}
+
+func CreateEventBroker(listener OrderEventsListener) *EventBroker {
+	return &EventBroker{
+		listener:    listener,
+		subscribers: make(map[entity.UserID]map[chan entity.OrderEvent]struct{}),
+	}
+}
+
+// Subscribe returns the channel of the user order events and the function
+// cancelling the subscription. The channel is closed when the subscriber
+// falls behind or the broker stops, the missed events can be read from the
+// storage then.
+func (b *EventBroker) Subscribe(userID entity.UserID) (<-chan entity.OrderEvent, func(), error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.stopped {
+		return nil, nil, fmt.Errorf("event broker has been stopped")
+	}
+
+	if b.cancel == nil {
+		err := b.start()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	events := make(chan entity.OrderEvent, subscriberBufLen)
+	if b.subscribers[userID] == nil {
+		b.subscribers[userID] = make(map[chan entity.OrderEvent]struct{})
+	}
+	b.subscribers[userID][events] = struct{}{}
+
+	unsubscribe := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		b.remove(userID, events, false)
+	}
+
+	return events, unsubscribe, nil
+}
+
+// Stop closes all subscriptions and the storage listener.
+func (b *EventBroker) Stop() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.stopped = true
+	if b.cancel != nil {
+		b.cancel()
+	}
+}
+
+func (b *EventBroker) start() error {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	events, err := b.listener.ListenOrderEvents(ctx)
+	if err != nil {
+		cancel()
+		return fmt.Errorf("error while listening order events: %w", err)
+	}
+
+	b.cancel = cancel
+	go b.dispatch(events)
+
+	return nil
+}
+
+func (b *EventBroker) dispatch(events <-chan entity.OrderEvent) {
+	for event := range events {
+		b.publish(event)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for userID, subscribers := range b.subscribers {
+		for subscriber := range subscribers {
+			b.remove(userID, subscriber, true)
+		}
+	}
+
+	b.cancel()
+	b.cancel = nil
+
+	zap.L().Info("order events listener has been stopped")
+}
+
+func (b *EventBroker) publish(event entity.OrderEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for subscriber := range b.subscribers[event.UserID] {
+		select {
+		case subscriber <- event:
+		default:
+			zap.L().Warn("order events subscriber falls behind", zap.String("user_id", event.UserID.String()))
+			b.remove(event.UserID, subscriber, true)
+		}
+	}
+}
+
+// remove must be called under the lock, channels are closed only here, so a
+// subscription can't be closed twice.
+func (b *EventBroker) remove(userID entity.UserID, subscriber chan entity.OrderEvent, closeChannel bool) {
+	if _, ok := b.subscribers[userID][subscriber]; !ok {
+		return
+	}
+
+	delete(b.subscribers[userID], subscriber)
+	if len(b.subscribers[userID]) == 0 {
+		delete(b.subscribers, userID)
+	}
+
+	if closeChannel {
+		close(subscriber)
+	}
+}
@@ -0,0 +1,65 @@
+package order
+
+import (
+	"context"
+	"time"
+
+	"github.com/avGenie/go-loyalty-system/internal/app/config"
+	"go.uber.org/zap"
+)
+
+const (
+	cleanupTimeout = 30 * time.Second
+
+	defaultEventsRetention = 24 * time.Hour
+	eventsCleanupInterval  = 10 * time.Minute
+)
+
+type OrderEventsCleaner interface {
+	DeleteExpiredOrderEvents(ctx context.Context, retention time.Duration) error
+}
+
+// EventCleaner periodically deletes the order events which are too old to
+// resume a stream from, out of the order update transactions.
+type EventCleaner struct {
+	cleaner   OrderEventsCleaner
+	retention time.Duration
+	done      chan struct{}
+}
+
+func CreateEventCleaner(cleaner OrderEventsCleaner, config config.Config) *EventCleaner {
+	return &EventCleaner{
+		cleaner:   cleaner,
+		retention: durationOrDefault(config.OrderEventsRetention, defaultEventsRetention),
+		done:      make(chan struct{}),
+	}
+}
+
+func (c *EventCleaner) Start() {
+	ticker := time.NewTicker(eventsCleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			zap.L().Info("order events cleaner work has finished")
+			return
+		case <-ticker.C:
+			c.deleteExpiredEvents()
+		}
+	}
+}
+
+func (c *EventCleaner) Stop() {
+	close(c.done)
+}
+
+func (c *EventCleaner) deleteExpiredEvents() {
+	ctx, cancel := context.WithTimeout(context.Background(), cleanupTimeout)
+	defer cancel()
+
+	err := c.cleaner.DeleteExpiredOrderEvents(ctx, c.retention)
+	if err != nil {
+		zap.L().Error("error while deleting expired order events", zap.Error(err))
+	}
+}
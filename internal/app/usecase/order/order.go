@@ -5,21 +5,44 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"time"
 
 	httputils "github.com/avGenie/go-loyalty-system/internal/app/usecase/utils"
 	"github.com/avGenie/go-loyalty-system/internal/app/entity"
 	err_storage "github.com/avGenie/go-loyalty-system/internal/app/storage/api/errors"
+	"github.com/avGenie/go-loyalty-system/internal/app/usecase/problem"
+	"github.com/avGenie/go-loyalty-system/internal/app/usecase/validator"
 	"go.uber.org/zap"
 )
 
+const (
+	ErrOrderConflict          = "order has been uploaded by another user"
+	ErrNotEnoughPoints        = "not enough points on the balance for withdrawal"
+	ErrIdempotencyInProgress  = "request with the same idempotency key is being processed"
+	ErrIdempotencyKeyMismatch = "idempotency key has been used with another request"
+)
+
+const (
+	orderNumberMaxLen = 16
+)
+
 type OrderProcessor interface {
 	UploadOrder(ctx context.Context, userID entity.UserID, orderNumber entity.OrderNumber) (entity.UserID, error)
-	GetOrdersForUpdate(ctx context.Context, count, offset int) (entity.UpdateUserOrders, error)
-	GetUserOrders(ctx context.Context, userID entity.UserID) (entity.Orders, error)
+	UploadOrders(ctx context.Context, userID entity.UserID, numbers []entity.OrderNumber) (entity.OrderUploadResults, error)
+	GetOrdersForUpdate(ctx context.Context, count int, lease time.Duration) (entity.UpdateUserOrders, error)
+	GetUserOrders(ctx context.Context, userID entity.UserID, query entity.ListQuery) (entity.Orders, error)
 	UpdateOrders(ctx context.Context, orders entity.UpdateUserOrders) error
+	ScheduleOrderChecks(ctx context.Context, checks entity.OrderChecks) error
 	GetUserBalance(ctx context.Context, userID entity.UserID) (entity.UserBalance, error)
 	WithdrawUser(ctx context.Context, userID entity.UserID, withdraw entity.Withdraw) error
-	GetUserWithdrawals(ctx context.Context, userID entity.UserID) (entity.Withdrawals, error)
+	WithdrawUserIdempotent(ctx context.Context, userID entity.UserID, withdraw entity.Withdraw, key entity.IdempotencyKey) error
+	GetIdempotencyKey(ctx context.Context, userID entity.UserID, key string) (entity.IdempotencyKey, error)
+	StoreIdempotencyKey(ctx context.Context, userID entity.UserID, key entity.IdempotencyKey) error
+	GetUserWithdrawals(ctx context.Context, userID entity.UserID, query entity.ListQuery) (entity.Withdrawals, error)
+	GetUserLedger(ctx context.Context, userID entity.UserID) (entity.LedgerEntries, error)
+	GetOrderEvents(ctx context.Context, userID entity.UserID, afterID int64) (entity.OrderEvents, error)
+	ListenOrderEvents(ctx context.Context) (<-chan entity.OrderEvent, error)
+	DeleteExpiredOrderEvents(ctx context.Context, retention time.Duration) error
 }
 
 func UploadOrder(userID entity.UserID, orderNumber entity.OrderNumber, processor OrderProcessor, w http.ResponseWriter) (entity.UserID, error) {
@@ -32,37 +55,99 @@ func UploadOrder(userID entity.UserID, orderNumber entity.OrderNumber, processor
 			if userID == storageUserID {
 				w.WriteHeader(http.StatusOK)
 			} else {
-				w.WriteHeader(http.StatusConflict)
+				problem.WriteCode(w, problem.CodeOrderConflict, ErrOrderConflict)
 			}
 
 			return storageUserID, err
 		}
 
-		w.WriteHeader(http.StatusInternalServerError)
+		problem.Write(w, err)
 		return entity.UserID(""), err
 	}
 
 	return storageUserID, nil
 }
 
-func GetUserOrders(userID entity.UserID, processor OrderProcessor, w http.ResponseWriter) (entity.Orders, error) {
+// UploadOrders uploads the batch of order numbers and returns the result of
+// each number in the batch order. Numbers failing the Luhn check aren't sent
+// to the storage, repeated numbers get the result of their first occurrence.
+func UploadOrders(userID entity.UserID, numbers []entity.OrderNumber, processor OrderProcessor, w http.ResponseWriter) (entity.OrderUploadResults, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), httputils.RequestTimeout)
+	defer cancel()
+
+	var uniqueNumbers []entity.OrderNumber
+	seen := make(map[entity.OrderNumber]bool, len(numbers))
+	for _, number := range numbers {
+		if seen[number] || !isUploadableOrderNumber(number) {
+			continue
+		}
+
+		seen[number] = true
+		uniqueNumbers = append(uniqueNumbers, number)
+	}
+
+	statuses := make(map[entity.OrderNumber]entity.OrderUploadStatus, len(uniqueNumbers))
+	if len(uniqueNumbers) != 0 {
+		storageResults, err := processor.UploadOrders(ctx, userID, uniqueNumbers)
+		if err != nil {
+			problem.Write(w, err)
+			return nil, err
+		}
+
+		for _, result := range storageResults {
+			statuses[result.Number] = result.Status
+		}
+	}
+
+	results := make(entity.OrderUploadResults, 0, len(numbers))
+	reported := make(map[entity.OrderNumber]bool, len(uniqueNumbers))
+	for _, number := range numbers {
+		status, ok := statuses[number]
+		if !ok {
+			status = entity.UploadInvalidOrder
+		} else if status == entity.UploadAcceptedOrder && reported[number] {
+			status = entity.UploadExistsOrder
+		}
+
+		reported[number] = true
+		results = append(results, entity.CreateOrderUploadResult(number, status))
+	}
+
+	return results, nil
+}
+
+// GetUserOrders returns a page of the user orders and the cursor of the next
+// page, which is nil for the last one.
+func GetUserOrders(userID entity.UserID, query entity.ListQuery, processor OrderProcessor, w http.ResponseWriter) (entity.Orders, *entity.Cursor, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), httputils.RequestTimeout)
 	defer cancel()
 
-	orders, err := processor.GetUserOrders(ctx, userID)
+	orders, err := processor.GetUserOrders(ctx, userID, pageQuery(query))
 	if err != nil {
 		if errors.Is(err, err_storage.ErrOrderForUserNotFound) {
 			zap.L().Info("orders for given user not found", zap.String("user_id", userID.String()))
 			w.WriteHeader(http.StatusNoContent)
 		} else {
 			zap.L().Error("error while getting user orders", zap.Error(err))
-			w.WriteHeader(http.StatusInternalServerError)
+			problem.Write(w, err)
 		}
 
-		return entity.Orders{}, err
+		return entity.Orders{}, nil, err
 	}
 
-	return orders, nil
+	if !hasNextPage(len(orders), query) {
+		return orders, nil, nil
+	}
+
+	orders = orders[:query.Limit]
+	cursor, err := entity.CreateOrderCursor(orders[len(orders)-1])
+	if err != nil {
+		zap.L().Error("error while creating user orders cursor", zap.Error(err))
+		problem.Write(w, err)
+		return entity.Orders{}, nil, err
+	}
+
+	return orders, &cursor, nil
 }
 
 func GetUserBalance(userID entity.UserID, processor OrderProcessor, w http.ResponseWriter) (entity.UserBalance, error) {
@@ -71,7 +156,7 @@ func GetUserBalance(userID entity.UserID, processor OrderProcessor, w http.Respo
 
 	balance, err := processor.GetUserBalance(ctx, userID)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		problem.Write(w, err)
 		return entity.UserBalance{}, fmt.Errorf("error while getting user balance: %w", err)
 	}
 
@@ -86,10 +171,13 @@ func WithdrawUserBonuses(userID entity.UserID, withdraw entity.Withdraw, process
 	if err != nil {
 		if errors.Is(err, err_storage.ErrNotEnoughSum) {
 			zap.L().Info("not enough money for withdrawing")
-			w.WriteHeader(http.StatusPaymentRequired)
+			problem.WriteCode(w, problem.CodeNotEnoughPoints, ErrNotEnoughPoints)
+		} else if errors.Is(err, err_storage.ErrUserDeleted) {
+			zap.L().Info("withdrawing for deleted user", zap.String("user_id", userID.String()))
+			problem.Write(w, err)
 		} else {
 			zap.L().Error("error while withdrawing user to storage", zap.Error(err))
-			w.WriteHeader(http.StatusInternalServerError)
+			problem.Write(w, err)
 		}
 
 		return
@@ -98,21 +186,208 @@ func WithdrawUserBonuses(userID entity.UserID, withdraw entity.Withdraw, process
 	w.WriteHeader(http.StatusOK)
 }
 
-func GetUserWithdrawals(userID entity.UserID, processor OrderProcessor, w http.ResponseWriter) (entity.Withdrawals, error) {
+func WithdrawUserBonusesIdempotent(userID entity.UserID, withdraw entity.Withdraw, key entity.IdempotencyKey, processor OrderProcessor, w http.ResponseWriter) {
 	ctx, cancel := context.WithTimeout(context.Background(), httputils.RequestTimeout)
 	defer cancel()
 
-	withdrawals, err := processor.GetUserWithdrawals(ctx, userID)
+	storedKey, err := processor.GetIdempotencyKey(ctx, userID, key.Key)
+	if err == nil {
+		replayIdempotentResponse(storedKey, key, w)
+		return
+	}
+
+	if !errors.Is(err, err_storage.ErrIdempotencyKeyNotFound) {
+		zap.L().Error("error while getting idempotency key from storage", zap.Error(err))
+		problem.Write(w, err)
+		return
+	}
+
+	key.StatusCode = http.StatusOK
+	err = processor.WithdrawUserIdempotent(ctx, userID, withdraw, key)
+	if err != nil {
+		if errors.Is(err, err_storage.ErrIdempotencyKeyExists) {
+			zap.L().Info("idempotency key has been stored by concurrent request", zap.String("key", key.Key))
+			replayConcurrentResponse(ctx, userID, key, processor, w)
+			return
+		}
+
+		var problemErr *problem.Error
+		if errors.Is(err, err_storage.ErrNotEnoughSum) {
+			zap.L().Info("not enough money for withdrawing")
+			problemErr = problem.New(problem.CodeNotEnoughPoints, ErrNotEnoughPoints)
+		} else {
+			problemErr = problem.FromError(err)
+		}
+
+		if problemErr.Status() >= http.StatusInternalServerError {
+			zap.L().Error("error while idempotent withdrawing user to storage", zap.Error(err))
+			problem.Write(w, err)
+			return
+		}
+
+		zap.L().Info("idempotent withdrawing has been rejected", zap.String("key", key.Key), zap.Error(err))
+		storeRejectedResponse(ctx, userID, key, problemErr, processor, w)
+		return
+	}
+
+	writeIdempotentResponse(key, w)
+}
+
+// storeRejectedResponse stores the rejected withdrawal response under the
+// key, so a retry gets the same response instead of withdrawing once the
+// balance allows it.
+func storeRejectedResponse(ctx context.Context, userID entity.UserID, key entity.IdempotencyKey, problemErr *problem.Error, processor OrderProcessor, w http.ResponseWriter) {
+	var err error
+	key.StatusCode, key.Body, err = problem.Encode(problemErr, w.Header().Get(problem.RequestIDHeader))
+	if err != nil {
+		zap.L().Error("error while encoding rejected withdrawal response", zap.Error(err))
+		problem.Write(w, err)
+		return
+	}
+
+	err = processor.StoreIdempotencyKey(ctx, userID, key)
+	if err != nil {
+		if errors.Is(err, err_storage.ErrIdempotencyKeyExists) {
+			zap.L().Info("idempotency key has been stored by concurrent request", zap.String("key", key.Key))
+			replayConcurrentResponse(ctx, userID, key, processor, w)
+			return
+		}
+
+		// без сохранённого ответа повтор может списать баллы, поэтому
+		// клиент получает ошибку и повторяет запрос
+		zap.L().Error("error while storing rejected withdrawal response", zap.Error(err))
+		problem.Write(w, err)
+		return
+	}
+
+	writeIdempotentResponse(key, w)
+}
+
+func replayConcurrentResponse(ctx context.Context, userID entity.UserID, key entity.IdempotencyKey, processor OrderProcessor, w http.ResponseWriter) {
+	storedKey, err := processor.GetIdempotencyKey(ctx, userID, key.Key)
+	if err != nil {
+		zap.L().Error("error while getting concurrently stored idempotency key", zap.Error(err))
+		problem.WriteCode(w, problem.CodeIdempotencyInProgress, ErrIdempotencyInProgress)
+		return
+	}
+
+	replayIdempotentResponse(storedKey, key, w)
+}
+
+// GetUserWithdrawals returns a page of the user withdrawals and the cursor of
+// the next page, which is nil for the last one.
+func GetUserWithdrawals(userID entity.UserID, query entity.ListQuery, processor OrderProcessor, w http.ResponseWriter) (entity.Withdrawals, *entity.Cursor, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), httputils.RequestTimeout)
+	defer cancel()
+
+	withdrawals, err := processor.GetUserWithdrawals(ctx, userID, pageQuery(query))
 	if err != nil {
 		if errors.Is(err, err_storage.ErrWithdrawalsForUserNotFound) {
 			zap.L().Info("withdrawals for given user not found", zap.String("user_id", userID.String()))
 			w.WriteHeader(http.StatusNoContent)
 		} else {
 			zap.L().Error("error while getting user withdrawals", zap.Error(err))
-			w.WriteHeader(http.StatusInternalServerError)
+			problem.Write(w, err)
 		}
+		return nil, nil, err
+	}
+
+	if !hasNextPage(len(withdrawals), query) {
+		return withdrawals, nil, nil
+	}
+
+	withdrawals = withdrawals[:query.Limit]
+	cursor, err := entity.CreateWithdrawCursor(withdrawals[len(withdrawals)-1])
+	if err != nil {
+		zap.L().Error("error while creating user withdrawals cursor", zap.Error(err))
+		problem.Write(w, err)
+		return nil, nil, err
+	}
+
+	return withdrawals, &cursor, nil
+}
+
+func GetUserLedger(userID entity.UserID, processor OrderProcessor, w http.ResponseWriter) (entity.LedgerEntries, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), httputils.RequestTimeout)
+	defer cancel()
+
+	entries, err := processor.GetUserLedger(ctx, userID)
+	if err != nil {
+		if errors.Is(err, err_storage.ErrLedgerForUserNotFound) {
+			zap.L().Info("balance history for given user not found", zap.String("user_id", userID.String()))
+			w.WriteHeader(http.StatusNoContent)
+		} else {
+			zap.L().Error("error while getting user balance history", zap.Error(err))
+			problem.Write(w, err)
+		}
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// GetMissedOrderEvents returns the user order events committed after the
+// last event received by a resumed stream.
+func GetMissedOrderEvents(userID entity.UserID, afterID int64, processor OrderProcessor, w http.ResponseWriter) (entity.OrderEvents, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), httputils.RequestTimeout)
+	defer cancel()
+
+	events, err := processor.GetOrderEvents(ctx, userID, afterID)
+	if err != nil {
+		zap.L().Error("error while getting missed order events", zap.Error(err))
+		problem.Write(w, err)
 		return nil, err
 	}
 
-	return withdrawals, nil
+	return events, nil
+}
+
+// isUploadableOrderNumber checks the number fits the orders table besides
+// the Luhn check, so one malformed number doesn't fail the whole batch.
+func isUploadableOrderNumber(number entity.OrderNumber) bool {
+	if len(number) == 0 || len(number) > orderNumberMaxLen {
+		return false
+	}
+
+	for _, digit := range number {
+		if digit < '0' || digit > '9' {
+			return false
+		}
+	}
+
+	return validator.OrderNumberValidation(number)
+}
+
+// pageQuery requests one extra item to find out whether the next page exists.
+func pageQuery(query entity.ListQuery) entity.ListQuery {
+	if query.Limit > 0 {
+		query.Limit++
+	}
+
+	return query
+}
+
+func hasNextPage(count int, query entity.ListQuery) bool {
+	return query.Limit > 0 && count > query.Limit
+}
+
+func replayIdempotentResponse(storedKey, key entity.IdempotencyKey, w http.ResponseWriter) {
+	if storedKey.RequestHash != key.RequestHash {
+		zap.L().Info("idempotency key is reused with different payload", zap.String("key", key.Key))
+		problem.WriteCode(w, problem.CodeIdempotencyKeyMismatch, ErrIdempotencyKeyMismatch)
+		return
+	}
+
+	zap.L().Info("replay stored response for idempotency key", zap.String("key", key.Key))
+	writeIdempotentResponse(storedKey, w)
+}
+
+func writeIdempotentResponse(key entity.IdempotencyKey, w http.ResponseWriter) {
+	if key.StatusCode >= http.StatusBadRequest {
+		problem.WriteEncoded(w, key.StatusCode, key.Body)
+		return
+	}
+
+	w.WriteHeader(key.StatusCode)
+	w.Write(key.Body)
 }
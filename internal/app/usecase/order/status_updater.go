@@ -9,6 +9,7 @@ import (
 	"github.com/avGenie/go-loyalty-system/internal/app/entity"
 	err_storage "github.com/avGenie/go-loyalty-system/internal/app/storage/api/errors"
 	"github.com/avGenie/go-loyalty-system/internal/app/usecase/accrual"
+	storage_utils "github.com/avGenie/go-loyalty-system/internal/app/usecase/order/storage_utils"
 	"go.uber.org/zap"
 )
 
@@ -17,30 +18,62 @@ const (
 
 	requestTimeout = 3 * time.Second
 	updateTimeout  = 5 * time.Second
+
+	defaultLeaseTimeout = 2 * time.Minute
+	defaultBackoffBase  = time.Second
+	defaultBackoffMax   = 10 * time.Minute
+	defaultIdleInterval = time.Second
 )
 
 type OrdersUpdater interface {
-	GetOrdersForUpdate(ctx context.Context, count, offset int) (entity.UpdateUserOrders, error)
+	GetOrdersForUpdate(ctx context.Context, count int, lease time.Duration) (entity.UpdateUserOrders, error)
 	UpdateOrders(ctx context.Context, orders entity.UpdateUserOrders) error
+	ScheduleOrderChecks(ctx context.Context, checks entity.OrderChecks) error
+}
+
+// AccrualAvailability is implemented by accrual clients that know when the
+// accrual system is down, e.g. accrual.Breaker.
+type AccrualAvailability interface {
+	IsAvailable() bool
+}
+
+type AccrualOrderConnector interface {
+	SetInput(number entity.AccrualOrderRequest)
+	CloseInput()
+	GetOutput() (entity.AccrualOrder, bool)
 }
 
 type StatusUpdater struct {
-	updater         OrdersUpdater
-	accrual         *accrual.Accrual
-	batchOrders     map[entity.OrderNumber]entity.UpdateUserOrder
-	done            chan struct{}
-	countForUpdate  int
-	offsetForUpdate int
+	updater        OrdersUpdater
+	connector      AccrualOrderConnector
+	availability   AccrualAvailability
+	batchOrders    map[entity.OrderNumber]entity.UpdateUserOrder
+	batchChecks    map[entity.OrderNumber]entity.OrderCheck
+	done           chan struct{}
+	countForUpdate int
+	leaseForUpdate time.Duration
+	backoffBase    time.Duration
+	backoffMax     time.Duration
+	maxAttempts    int
+	idleInterval   time.Duration
 }
 
-func CreateStatusUpdater(updater OrdersUpdater, config config.Config) *StatusUpdater {
+func CreateStatusUpdater(updater OrdersUpdater, client accrual.AccrualClient, config config.Config) *StatusUpdater {
+	availability, _ := client.(AccrualAvailability)
+
 	return &StatusUpdater{
-		updater:         updater,
-		accrual:         accrual.New(config),
-		batchOrders:     make(map[entity.OrderNumber]entity.UpdateUserOrder, flushBufLen),
-		done:            make(chan struct{}),
-		countForUpdate:  flushBufLen,
-		offsetForUpdate: 0,
+		updater:        updater,
+		connector:      accrual.NewPool(client, config),
+		availability:   availability,
+		batchOrders:    make(map[entity.OrderNumber]entity.UpdateUserOrder, flushBufLen),
+		batchChecks:    make(map[entity.OrderNumber]entity.OrderCheck, flushBufLen),
+		done:           make(chan struct{}),
+		countForUpdate: flushBufLen,
+		leaseForUpdate: durationOrDefault(config.AccrualLeaseTimeout, defaultLeaseTimeout),
+		backoffBase:    durationOrDefault(config.AccrualBackoffBase, defaultBackoffBase),
+		backoffMax:     durationOrDefault(config.AccrualBackoffMax, defaultBackoffMax),
+		maxAttempts:    config.AccrualMaxAttempts,
+		idleInterval:   durationOrDefault(config.AccrualIdleInterval, defaultIdleInterval),
 	}
 }
 
@@ -51,8 +84,14 @@ func (u *StatusUpdater) Start() {
 			zap.L().Info("status updater work has finished")
 			return
 		default:
+			if !u.isAccrualAvailable() {
+				u.idle()
+				continue
+			}
+
 			orders := u.getOrdersForUpdate()
 			if len(orders) == 0 {
+				u.idle()
 				continue
 			}
 
@@ -64,16 +103,27 @@ func (u *StatusUpdater) Start() {
 
 func (u *StatusUpdater) Stop() {
 	close(u.done)
+	u.connector.CloseInput()
+}
+
+func (u *StatusUpdater) isAccrualAvailable() bool {
+	return u.availability == nil || u.availability.IsAvailable()
+}
+
+func (u *StatusUpdater) idle() {
+	select {
+	case <-u.done:
+	case <-time.After(u.idleInterval):
+	}
 }
 
 func (u *StatusUpdater) getOrdersForUpdate() entity.UpdateUserOrders {
 	ctx, close := context.WithTimeout(context.Background(), requestTimeout)
 	defer close()
 
-	orders, err := u.updater.GetOrdersForUpdate(ctx, u.countForUpdate, u.offsetForUpdate)
+	orders, err := u.updater.GetOrdersForUpdate(ctx, u.countForUpdate, u.leaseForUpdate)
 	if err != nil {
 		if errors.Is(err, err_storage.ErrOrdersForUpdateNotFound) {
-			u.offsetForUpdate = 0
 			return entity.UpdateUserOrders{}
 		}
 
@@ -85,26 +135,60 @@ func (u *StatusUpdater) getOrdersForUpdate() entity.UpdateUserOrders {
 }
 
 func (u *StatusUpdater) requestForUpdate(orders entity.UpdateUserOrders) {
+	go func() {
+		for _, order := range orders {
+			u.connector.SetInput(entity.CreateAccrualRequest(order.UserID, order.Order.Number))
+		}
+	}()
+
+	requested := make(map[entity.OrderNumber]entity.UpdateUserOrder, len(orders))
 	for _, order := range orders {
-		accrualOrder, err := u.accrual.MakeRequest(order.UserID, order.Order.Number)
-		if err != nil {
-			zap.L().Error("error while getting accrual update", zap.Error(err))
+		requested[order.Order.Number] = order
+	}
+
+	for range orders {
+		accrualOrder, ok := u.connector.GetOutput()
+		if !ok {
+			return
+		}
+
+		order := requested[accrualOrder.Order.Number]
+
+		if entity.StatusError == accrualOrder.Status {
+			u.scheduleRetry(order)
+			continue
+		}
+
+		if entity.StatusUnavailable == accrualOrder.Status {
+			u.batchChecks[order.Order.Number] = entity.OrderCheck{
+				Number:   order.Order.Number,
+				Attempts: order.Attempts,
+				Delay:    u.idleInterval,
+			}
+
 			continue
 		}
 
 		if entity.StatusPause == accrualOrder.Status {
-			zap.L().Info("accrual update request returns retry after value", zap.Int("retry after", int(accrualOrder.RetryAfter)))
-			u.flushUpdates()
-			<-time.After(accrualOrder.RetryAfter)
+			u.batchChecks[order.Order.Number] = entity.OrderCheck{
+				Number:   order.Order.Number,
+				Attempts: order.Attempts,
+				Delay:    accrualOrder.RetryAfter,
+			}
 
 			continue
 		}
 
 		if entity.StatusOrderNotRegistered == accrualOrder.Status {
 			zap.L().Info("order not registered while getting accrual update")
+			u.scheduleRetry(order)
 			continue
 		}
 
+		if !storage_utils.IsFinalOrderStatus(accrualOrder.Order.Status) {
+			u.scheduleProgress(order)
+		}
+
 		updatedOrder := entity.UpdateUserOrder{
 			UserID: accrualOrder.UserID,
 			Order:  accrualOrder.Order,
@@ -114,7 +198,50 @@ func (u *StatusUpdater) requestForUpdate(orders entity.UpdateUserOrders) {
 	}
 }
 
+// scheduleProgress postpones the check of the order the accrual system is
+// still processing. It isn't counted as a failed attempt, since only the
+// orders stuck without progress are parked.
+func (u *StatusUpdater) scheduleProgress(order entity.UpdateUserOrder) {
+	u.batchChecks[order.Order.Number] = entity.OrderCheck{
+		Number:   order.Order.Number,
+		Attempts: order.Attempts,
+		Delay:    u.backoff(max(order.Attempts, 1)),
+	}
+}
+
+// scheduleRetry counts the check without progress: accrual errors,
+// transport failures and orders unknown to the accrual system.
+func (u *StatusUpdater) scheduleRetry(order entity.UpdateUserOrder) {
+	attempts := order.Attempts + 1
+	check := entity.OrderCheck{
+		Number:   order.Order.Number,
+		Attempts: attempts,
+		Delay:    u.backoff(attempts),
+	}
+
+	if u.maxAttempts > 0 && attempts >= u.maxAttempts {
+		zap.L().Warn("order parked for manual review after reaching max accrual check attempts",
+			zap.String("order", string(order.Order.Number)),
+			zap.Int("attempts", attempts))
+		check.Parked = true
+	}
+
+	u.batchChecks[order.Order.Number] = check
+}
+
+// backoff doubles the delay before the next check with every attempt.
+func (u *StatusUpdater) backoff(attempts int) time.Duration {
+	delay := u.backoffBase
+	for i := 1; i < attempts && delay < u.backoffMax; i++ {
+		delay *= 2
+	}
+
+	return min(delay, u.backoffMax)
+}
+
 func (u *StatusUpdater) flushUpdates() {
+	u.flushChecks()
+
 	if len(u.batchOrders) == 0 {
 		return
 	}
@@ -134,3 +261,34 @@ func (u *StatusUpdater) flushUpdates() {
 
 	clear(u.batchOrders)
 }
+
+// flushChecks runs before the orders update, so the released leases of
+// unfinished orders are already postponed.
+func (u *StatusUpdater) flushChecks() {
+	if len(u.batchChecks) == 0 {
+		return
+	}
+
+	checks := make(entity.OrderChecks, 0, len(u.batchChecks))
+	for _, check := range u.batchChecks {
+		checks = append(checks, check)
+	}
+
+	ctx, close := context.WithTimeout(context.Background(), updateTimeout)
+	defer close()
+
+	err := u.updater.ScheduleOrderChecks(ctx, checks)
+	if err != nil {
+		zap.L().Error("error while scheduling order checks", zap.Error(err))
+	}
+
+	clear(u.batchChecks)
+}
+
+func durationOrDefault(value, defaultValue time.Duration) time.Duration {
+	if value <= 0 {
+		return defaultValue
+	}
+
+	return value
+}
@@ -0,0 +1,104 @@
+package order
+
+import (
+	"testing"
+	"time"
+
+	"github.com/avGenie/go-loyalty-system/internal/app/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+type testConnector struct {
+	responses map[entity.OrderNumber]entity.AccrualOrder
+	output    chan entity.AccrualOrder
+}
+
+func (c *testConnector) SetInput(request entity.AccrualOrderRequest) {
+	c.output <- c.responses[request.Number]
+}
+
+func (c *testConnector) CloseInput() {
+	close(c.output)
+}
+
+func (c *testConnector) GetOutput() (entity.AccrualOrder, bool) {
+	order, ok := <-c.output
+	return order, ok
+}
+
+func TestScheduleOrderChecks(t *testing.T) {
+	const maxAttempts = 5
+
+	tests := []struct {
+		name     string
+		attempts int
+		response entity.AccrualOrder
+
+		want entity.OrderCheck
+	}{
+		{
+			name:     "processing order",
+			attempts: 2,
+			response: entity.AccrualOrder{
+				Order:  entity.Order{Number: "1", Status: entity.StatusProcessingOrder},
+				Status: entity.StatusProcessing,
+			},
+
+			want: entity.OrderCheck{Number: "1", Attempts: 2, Delay: 2 * time.Second},
+		},
+		{
+			name:     "processing order at max attempts",
+			attempts: maxAttempts - 1,
+			response: entity.AccrualOrder{
+				Order:  entity.Order{Number: "1", Status: entity.StatusProcessingOrder},
+				Status: entity.StatusProcessing,
+			},
+
+			want: entity.OrderCheck{Number: "1", Attempts: maxAttempts - 1, Delay: 8 * time.Second},
+		},
+		{
+			name:     "accrual error",
+			attempts: 2,
+			response: entity.AccrualOrder{
+				Order:  entity.Order{Number: "1"},
+				Status: entity.StatusError,
+			},
+
+			want: entity.OrderCheck{Number: "1", Attempts: 3, Delay: 4 * time.Second},
+		},
+		{
+			name:     "not registered order at max attempts",
+			attempts: maxAttempts - 1,
+			response: entity.AccrualOrder{
+				Order:  entity.Order{Number: "1"},
+				Status: entity.StatusOrderNotRegistered,
+			},
+
+			want: entity.OrderCheck{Number: "1", Attempts: maxAttempts, Delay: 10 * time.Second, Parked: true},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			updater := &StatusUpdater{
+				connector: &testConnector{
+					responses: map[entity.OrderNumber]entity.AccrualOrder{"1": test.response},
+					output:    make(chan entity.AccrualOrder, 1),
+				},
+				batchOrders: make(map[entity.OrderNumber]entity.UpdateUserOrder),
+				batchChecks: make(map[entity.OrderNumber]entity.OrderCheck),
+				backoffBase: time.Second,
+				backoffMax:  10 * time.Second,
+				maxAttempts: maxAttempts,
+			}
+
+			updater.requestForUpdate(entity.UpdateUserOrders{
+				{
+					Order:    entity.Order{Number: "1", Status: entity.StatusNewOrder},
+					Attempts: test.attempts,
+				},
+			})
+
+			assert.Equal(t, test.want, updater.batchChecks["1"])
+		})
+	}
+}
@@ -1,13 +1,16 @@
 package order
 
-import "github.com/avGenie/go-loyalty-system/internal/app/entity"
+import (
+	"github.com/avGenie/go-loyalty-system/internal/app/entity"
+	"github.com/avGenie/go-loyalty-system/internal/app/money"
+)
 
 func IsUpdatableAccrualStatus(newStatus, currentStatus entity.OrderStatus) bool {
-	if entity.StatusNewOrder == newStatus {
+	if entity.StatusNewOrder == newStatus || IsFinalOrderStatus(currentStatus) {
 		return false
 	}
 
-	if entity.StatusInvalidOrder == newStatus && entity.StatusInvalidOrder != currentStatus {
+	if entity.StatusInvalidOrder == newStatus {
 		return true
 	}
 
@@ -23,6 +26,12 @@ func IsUpdatableAccrualStatus(newStatus, currentStatus entity.OrderStatus) bool
 	return false
 }
 
-func IsUpdateDBBalance(accrual float64, status entity.OrderStatus) bool {
+// IsFinalOrderStatus reports whether the accrual system has finished with
+// the order, its status can't change after that.
+func IsFinalOrderStatus(status entity.OrderStatus) bool {
+	return entity.StatusProcessedOrder == status || entity.StatusInvalidOrder == status
+}
+
+func IsUpdateDBBalance(accrual money.Points, status entity.OrderStatus) bool {
 	return accrual == 0 && entity.StatusProcessedOrder == status
 }
@@ -0,0 +1,237 @@
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	err_storage "github.com/avGenie/go-loyalty-system/internal/app/storage/api/errors"
+	usecase "github.com/avGenie/go-loyalty-system/internal/app/usecase/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	ContentType     = "application/problem+json"
+	RequestIDHeader = "X-Request-ID"
+
+	typePrefix = "urn:gophermart:problem:"
+)
+
+// Code is a stable machine readable error code. Every code always comes with
+// the same HTTP status, so clients may rely on the code only.
+type Code string
+
+const (
+	CodeInternal               Code = `internal_error`
+	CodeBadRequest             Code = `bad_request`
+	CodeInvalidQuery           Code = `invalid_query`
+	CodeValidationFailed       Code = `validation_failed`
+	CodeInvalidIdempotencyKey  Code = `invalid_idempotency_key`
+	CodeUnauthorized           Code = `unauthorized`
+	CodeTokenExpired           Code = `token_expired`
+	CodeTokenRevoked           Code = `token_revoked`
+	CodeInvalidCredentials     Code = `invalid_credentials`
+	CodeInvalidRefreshToken    Code = `invalid_refresh_token`
+	CodeInvalidSignature       Code = `invalid_signature`
+	CodeNotEnoughPoints        Code = `not_enough_points`
+	CodeForbidden              Code = `forbidden`
+	CodeWrongPassword          Code = `wrong_password`
+	CodeUserDeleted            Code = `user_deleted`
+	CodeNotFound               Code = `not_found`
+	CodeUserNotFound           Code = `user_not_found`
+	CodeOrderNotFound          Code = `order_not_found`
+	CodeMethodNotAllowed       Code = `method_not_allowed`
+	CodeLoginExists            Code = `login_exists`
+	CodeOrderConflict          Code = `order_conflict`
+	CodeOrderProcessed         Code = `order_processed`
+	CodeTargetUserDeleted      Code = `target_user_deleted`
+	CodeNegativeBalance        Code = `negative_balance`
+	CodeIdempotencyInProgress  Code = `idempotency_key_in_progress`
+	CodeCallbackReplayed       Code = `callback_replayed`
+	CodeBatchTooLarge          Code = `batch_too_large`
+	CodeInvalidOrderNumber     Code = `invalid_order_number`
+	CodeInvalidAmount          Code = `invalid_amount`
+	CodeInvalidAdjustment      Code = `invalid_adjustment`
+	CodeIdempotencyKeyMismatch Code = `idempotency_key_mismatch`
+	CodeTooManyAttempts        Code = `too_many_attempts`
+)
+
+type definition struct {
+	status int
+	title  string
+}
+
+var definitions = map[Code]definition{
+	CodeInternal:               {http.StatusInternalServerError, "Internal server error"},
+	CodeBadRequest:             {http.StatusBadRequest, "Malformed request"},
+	CodeInvalidQuery:           {http.StatusBadRequest, "Invalid query parameters"},
+	CodeValidationFailed:       {http.StatusBadRequest, "Validation failed"},
+	CodeInvalidIdempotencyKey:  {http.StatusBadRequest, "Invalid idempotency key"},
+	CodeUnauthorized:           {http.StatusUnauthorized, "Authentication required"},
+	CodeTokenExpired:           {http.StatusUnauthorized, "Token expired"},
+	CodeTokenRevoked:           {http.StatusUnauthorized, "Token revoked"},
+	CodeInvalidCredentials:     {http.StatusUnauthorized, "Invalid login or password"},
+	CodeInvalidRefreshToken:    {http.StatusUnauthorized, "Invalid refresh token"},
+	CodeInvalidSignature:       {http.StatusUnauthorized, "Invalid signature"},
+	CodeNotEnoughPoints:        {http.StatusPaymentRequired, "Not enough points"},
+	CodeForbidden:              {http.StatusForbidden, "Access denied"},
+	CodeWrongPassword:          {http.StatusForbidden, "Wrong password"},
+	CodeUserDeleted:            {http.StatusForbidden, "User deleted"},
+	CodeNotFound:               {http.StatusNotFound, "Resource not found"},
+	CodeUserNotFound:           {http.StatusNotFound, "User not found"},
+	CodeOrderNotFound:          {http.StatusNotFound, "Order not found"},
+	CodeMethodNotAllowed:       {http.StatusMethodNotAllowed, "Method not allowed"},
+	CodeLoginExists:            {http.StatusConflict, "Login already exists"},
+	CodeOrderConflict:          {http.StatusConflict, "Order uploaded by another user"},
+	CodeOrderProcessed:         {http.StatusConflict, "Order already processed"},
+	CodeTargetUserDeleted:      {http.StatusConflict, "Target user deleted"},
+	CodeNegativeBalance:        {http.StatusConflict, "Balance would become negative"},
+	CodeIdempotencyInProgress:  {http.StatusConflict, "Request with idempotency key in progress"},
+	CodeCallbackReplayed:       {http.StatusConflict, "Callback already processed"},
+	CodeBatchTooLarge:          {http.StatusRequestEntityTooLarge, "Batch too large"},
+	CodeInvalidOrderNumber:     {http.StatusUnprocessableEntity, "Invalid order number"},
+	CodeInvalidAmount:          {http.StatusUnprocessableEntity, "Invalid amount"},
+	CodeInvalidAdjustment:      {http.StatusUnprocessableEntity, "Invalid balance adjustment"},
+	CodeIdempotencyKeyMismatch: {http.StatusUnprocessableEntity, "Idempotency key reused with another request"},
+	CodeTooManyAttempts:        {http.StatusTooManyRequests, "Too many attempts"},
+}
+
+// sentinels maps storage and usecase errors to codes for the callers which
+// don't need a more specific code.
+var sentinels = []struct {
+	err  error
+	code Code
+}{
+	{err_storage.ErrLoginExists, CodeLoginExists},
+	{err_storage.ErrUserDeleted, CodeUserDeleted},
+	{err_storage.ErrUserNotFoundTable, CodeUserNotFound},
+	{err_storage.ErrNotEnoughSum, CodeNotEnoughPoints},
+	{err_storage.ErrOrderNumberExists, CodeOrderConflict},
+	{err_storage.ErrOrderNumberNotFound, CodeOrderNotFound},
+	{err_storage.ErrOrderProcessed, CodeOrderProcessed},
+	{err_storage.ErrIdempotencyKeyExists, CodeIdempotencyInProgress},
+	{err_storage.ErrAccrualCallbackExists, CodeCallbackReplayed},
+	{err_storage.ErrRefreshTokenNotFound, CodeInvalidRefreshToken},
+	{usecase.ErrTokenExpired, CodeTokenExpired},
+	{usecase.ErrTokenNotValid, CodeUnauthorized},
+}
+
+// Problem is the RFC 7807 body of an error response.
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Code      Code   `json:"code"`
+	RequestID string `json:"request_id,omitempty"`
+	Errors    any    `json:"errors,omitempty"`
+}
+
+// Error is an error reported to the client as a problem. Errors holds
+// optional per-field validation errors.
+type Error struct {
+	Code   Code
+	Detail string
+	Errors any
+}
+
+func New(code Code, detail string) *Error {
+	return &Error{
+		Code:   code,
+		Detail: detail,
+	}
+}
+
+func (e *Error) WithErrors(errs any) *Error {
+	e.Errors = errs
+
+	return e
+}
+
+func (e *Error) Error() string {
+	if len(e.Detail) == 0 {
+		return string(e.Code)
+	}
+
+	return string(e.Code) + ": " + e.Detail
+}
+
+func (e *Error) Status() int {
+	return lookup(e.Code).status
+}
+
+// FromError converts the error to a problem. Unknown errors become internal
+// ones without details, so storage messages don't leak to clients.
+func FromError(err error) *Error {
+	var problemErr *Error
+	if errors.As(err, &problemErr) {
+		return problemErr
+	}
+
+	for _, sentinel := range sentinels {
+		if errors.Is(err, sentinel.err) {
+			return New(sentinel.code, sentinel.err.Error())
+		}
+	}
+
+	return New(CodeInternal, "")
+}
+
+// Write writes the error as a problem response. The request ID is taken from
+// the response header set by the request ID middleware.
+func Write(w http.ResponseWriter, err error) {
+	status, out, err := Encode(err, w.Header().Get(RequestIDHeader))
+	if err != nil {
+		zap.L().Error("error while marshalling problem response", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	WriteEncoded(w, status, out)
+}
+
+// Encode returns the status and the body of the problem response, so the
+// response can be stored and replayed later by WriteEncoded.
+func Encode(err error, requestID string) (int, []byte, error) {
+	problemErr := FromError(err)
+	def := lookup(problemErr.Code)
+
+	problem := Problem{
+		Type:      typePrefix + string(problemErr.Code),
+		Title:     def.title,
+		Status:    def.status,
+		Detail:    problemErr.Detail,
+		Code:      problemErr.Code,
+		RequestID: requestID,
+		Errors:    problemErr.Errors,
+	}
+
+	out, err := json.Marshal(problem)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return def.status, out, nil
+}
+
+// WriteEncoded writes the problem response returned by Encode.
+func WriteEncoded(w http.ResponseWriter, status int, body []byte) {
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// WriteCode writes the problem with the given code and detail.
+func WriteCode(w http.ResponseWriter, code Code, detail string) {
+	Write(w, New(code, detail))
+}
+
+func lookup(code Code) definition {
+	def, ok := definitions[code]
+	if !ok {
+		return definitions[CodeInternal]
+	}
+
+	return def
+}
@@ -0,0 +1,131 @@
+package problem
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	err_storage "github.com/avGenie/go-loyalty-system/internal/app/storage/api/errors"
+	usecase "github.com/avGenie/go-loyalty-system/internal/app/usecase/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrite(t *testing.T) {
+	type want struct {
+		statusCode int
+		body       string
+	}
+	tests := []struct {
+		name      string
+		err       error
+		requestID string
+		want      want
+	}{
+		{
+			name:      "problem error",
+			err:       New(CodeInvalidOrderNumber, "order number is invalid"),
+			requestID: "request-1",
+			want: want{
+				statusCode: http.StatusUnprocessableEntity,
+				body: `{
+					"type": "urn:gophermart:problem:invalid_order_number",
+					"title": "Invalid order number",
+					"status": 422,
+					"detail": "order number is invalid",
+					"code": "invalid_order_number",
+					"request_id": "request-1"
+				}`,
+			},
+		},
+		{
+			name: "wrapped storage error",
+			err:  fmt.Errorf("error while withdrawing: %w", err_storage.ErrNotEnoughSum),
+			want: want{
+				statusCode: http.StatusPaymentRequired,
+				body: `{
+					"type": "urn:gophermart:problem:not_enough_points",
+					"title": "Not enough points",
+					"status": 402,
+					"detail": "` + err_storage.ErrNotEnoughSum.Error() + `",
+					"code": "not_enough_points"
+				}`,
+			},
+		},
+		{
+			name: "usecase error",
+			err:  usecase.ErrTokenExpired,
+			want: want{
+				statusCode: http.StatusUnauthorized,
+				body: `{
+					"type": "urn:gophermart:problem:token_expired",
+					"title": "Token expired",
+					"status": 401,
+					"detail": "` + usecase.ErrTokenExpired.Error() + `",
+					"code": "token_expired"
+				}`,
+			},
+		},
+		{
+			name: "unknown error",
+			err:  errors.New("connection refused"),
+			want: want{
+				statusCode: http.StatusInternalServerError,
+				body: `{
+					"type": "urn:gophermart:problem:internal_error",
+					"title": "Internal server error",
+					"status": 500,
+					"code": "internal_error"
+				}`,
+			},
+		},
+		{
+			name: "validation errors",
+			err:  New(CodeValidationFailed, "").WithErrors([]string{"login is empty"}),
+			want: want{
+				statusCode: http.StatusBadRequest,
+				body: `{
+					"type": "urn:gophermart:problem:validation_failed",
+					"title": "Validation failed",
+					"status": 400,
+					"code": "validation_failed",
+					"errors": ["login is empty"]
+				}`,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			writer := httptest.NewRecorder()
+			if len(test.requestID) != 0 {
+				writer.Header().Set(RequestIDHeader, test.requestID)
+			}
+
+			Write(writer, test.err)
+
+			res := writer.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, test.want.statusCode, res.StatusCode)
+			assert.Equal(t, ContentType, res.Header.Get("Content-Type"))
+			assert.JSONEq(t, test.want.body, writer.Body.String())
+		})
+	}
+}
+
+func TestDefinitions(t *testing.T) {
+	for code, def := range definitions {
+		require.NotEmpty(t, def.title, code)
+		assert.Equal(t, def.status, New(code, "").Status(), code)
+	}
+
+	for _, sentinel := range sentinels {
+		_, ok := definitions[sentinel.code]
+		assert.True(t, ok, sentinel.code)
+	}
+
+	assert.Equal(t, http.StatusInternalServerError, New(Code("unknown"), "").Status())
+}
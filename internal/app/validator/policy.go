@@ -0,0 +1,192 @@
+package validator
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/avGenie/go-loyalty-system/internal/app/config"
+	"github.com/avGenie/go-loyalty-system/internal/app/model"
+)
+
+const (
+	FieldLogin    = "login"
+	FieldPassword = "password"
+
+	RuleLoginLength      = "login_length"
+	RuleLoginCharset     = "login_charset"
+	RulePasswordLength   = "password_length"
+	RulePasswordClasses  = "password_classes"
+	RulePasswordBreached = "password_breached"
+
+	defaultLoginMinLength    = 1
+	defaultLoginMaxLength    = 50
+	defaultLoginCharset      = `^[\p{L}\p{N}._@+-]+$`
+	defaultPasswordMinLength = 8
+	defaultPasswordMaxLength = 72
+	defaultPasswordClasses   = 1
+
+	maxPasswordClasses = 4
+)
+
+// CredentialsPolicy validates logins and passwords of new users. Password
+// max length is counted in bytes, since bcrypt ignores bytes after the 72th.
+type CredentialsPolicy struct {
+	loginMinLength    int
+	loginMaxLength    int
+	loginCharset      *regexp.Regexp
+	passwordMinLength int
+	passwordMaxLength int
+	passwordClasses   int
+	blocklist         map[string]struct{}
+}
+
+func NewCredentialsPolicy(config config.Config) (CredentialsPolicy, error) {
+	charset := config.LoginCharset
+	if len(charset) == 0 {
+		charset = defaultLoginCharset
+	}
+
+	loginCharset, err := regexp.Compile(charset)
+	if err != nil {
+		return CredentialsPolicy{}, fmt.Errorf("invalid login charset pattern: %w", err)
+	}
+
+	policy := CredentialsPolicy{
+		loginMinLength:    intOrDefault(config.LoginMinLength, defaultLoginMinLength),
+		loginMaxLength:    min(intOrDefault(config.LoginMaxLength, defaultLoginMaxLength), defaultLoginMaxLength),
+		loginCharset:      loginCharset,
+		passwordMinLength: intOrDefault(config.PasswordMinLength, defaultPasswordMinLength),
+		passwordMaxLength: min(intOrDefault(config.PasswordMaxLength, defaultPasswordMaxLength), defaultPasswordMaxLength),
+		passwordClasses:   min(intOrDefault(config.PasswordClasses, defaultPasswordClasses), maxPasswordClasses),
+	}
+
+	if len(config.PasswordBlocklistFile) != 0 {
+		policy.blocklist, err = readBlocklist(config.PasswordBlocklistFile)
+		if err != nil {
+			return CredentialsPolicy{}, err
+		}
+	}
+
+	return policy, nil
+}
+
+func (p CredentialsPolicy) ValidateCredentials(user model.UserCredentialsRequest) []model.ValidationError {
+	return append(p.ValidateLogin(user.Login), p.ValidatePassword(user.Password)...)
+}
+
+func (p CredentialsPolicy) ValidateLogin(login string) []model.ValidationError {
+	var errs []model.ValidationError
+
+	length := utf8.RuneCountInString(login)
+	if length < p.loginMinLength || length > p.loginMaxLength {
+		errs = append(errs, model.ValidationError{
+			Field:   FieldLogin,
+			Rule:    RuleLoginLength,
+			Message: fmt.Sprintf("login must be from %d to %d characters long", p.loginMinLength, p.loginMaxLength),
+		})
+	}
+
+	if length != 0 && !p.loginCharset.MatchString(login) {
+		errs = append(errs, model.ValidationError{
+			Field:   FieldLogin,
+			Rule:    RuleLoginCharset,
+			Message: fmt.Sprintf("login must match %s", p.loginCharset.String()),
+		})
+	}
+
+	return errs
+}
+
+func (p CredentialsPolicy) ValidatePassword(password string) []model.ValidationError {
+	var errs []model.ValidationError
+
+	if utf8.RuneCountInString(password) < p.passwordMinLength || len(password) > p.passwordMaxLength {
+		errs = append(errs, model.ValidationError{
+			Field:   FieldPassword,
+			Rule:    RulePasswordLength,
+			Message: fmt.Sprintf("password must be at least %d characters and at most %d bytes long", p.passwordMinLength, p.passwordMaxLength),
+		})
+	}
+
+	if countPasswordClasses(password) < p.passwordClasses {
+		errs = append(errs, model.ValidationError{
+			Field:   FieldPassword,
+			Rule:    RulePasswordClasses,
+			Message: fmt.Sprintf("password must contain at least %d of lowercase letters, uppercase letters, digits and other symbols", p.passwordClasses),
+		})
+	}
+
+	if _, ok := p.blocklist[strings.ToLower(password)]; ok {
+		errs = append(errs, model.ValidationError{
+			Field:   FieldPassword,
+			Rule:    RulePasswordBreached,
+			Message: "password has been found in a data breach",
+		})
+	}
+
+	return errs
+}
+
+func countPasswordClasses(password string) int {
+	var lower, upper, digit, other bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			other = true
+		}
+	}
+
+	var classes int
+	for _, ok := range []bool{lower, upper, digit, other} {
+		if ok {
+			classes++
+		}
+	}
+
+	return classes
+}
+
+// readBlocklist reads a password per line, lines starting with # are skipped.
+func readBlocklist(path string) (map[string]struct{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read password blocklist: %w", err)
+	}
+
+	blocklist := make(map[string]struct{})
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		blocklist[strings.ToLower(line)] = struct{}{}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read password blocklist: %w", err)
+	}
+
+	return blocklist, nil
+}
+
+func intOrDefault(value, defaultValue int) int {
+	if value <= 0 {
+		return defaultValue
+	}
+
+	return value
+}
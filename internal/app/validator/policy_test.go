@@ -0,0 +1,94 @@
+package validator
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/avGenie/go-loyalty-system/internal/app/config"
+	"github.com/avGenie/go-loyalty-system/internal/app/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCredentialsPolicy(t *testing.T) {
+	blocklistFile := filepath.Join(t.TempDir(), "blocklist")
+	err := os.WriteFile(blocklistFile, []byte("# top passwords\nqwerty123\nPassword1!\n"), 0o600)
+	require.NoError(t, err)
+
+	policy, err := NewCredentialsPolicy(config.Config{
+		PasswordClasses:       3,
+		PasswordBlocklistFile: blocklistFile,
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name  string
+		user  model.UserCredentialsRequest
+		rules []string
+	}{
+		{
+			name: "valid credentials",
+			user: model.UserCredentialsRequest{Login: "user.name@mail", Password: "Secret-pass1"},
+		},
+		{
+			name:  "empty login",
+			user:  model.UserCredentialsRequest{Login: "", Password: "Secret-pass1"},
+			rules: []string{RuleLoginLength},
+		},
+		{
+			name:  "too long login",
+			user:  model.UserCredentialsRequest{Login: strings.Repeat("л", 51), Password: "Secret-pass1"},
+			rules: []string{RuleLoginLength},
+		},
+		{
+			name:  "login with spaces",
+			user:  model.UserCredentialsRequest{Login: "user name", Password: "Secret-pass1"},
+			rules: []string{RuleLoginCharset},
+		},
+		{
+			name:  "short password",
+			user:  model.UserCredentialsRequest{Login: "user", Password: "Sec-1"},
+			rules: []string{RulePasswordLength},
+		},
+		{
+			name:  "too long password",
+			user:  model.UserCredentialsRequest{Login: "user", Password: "Secret-pass1" + strings.Repeat("a", 72)},
+			rules: []string{RulePasswordLength},
+		},
+		{
+			name:  "not enough character classes",
+			user:  model.UserCredentialsRequest{Login: "user", Password: "secretpass1"},
+			rules: []string{RulePasswordClasses},
+		},
+		{
+			name:  "breached password",
+			user:  model.UserCredentialsRequest{Login: "user", Password: "PASSWORD1!"},
+			rules: []string{RulePasswordBreached},
+		},
+		{
+			name:  "several violations",
+			user:  model.UserCredentialsRequest{Login: "user name", Password: "qwerty"},
+			rules: []string{RuleLoginCharset, RulePasswordLength, RulePasswordClasses},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var rules []string
+			for _, err := range policy.ValidateCredentials(test.user) {
+				rules = append(rules, err.Rule)
+			}
+
+			assert.Equal(t, test.rules, rules)
+		})
+	}
+}
+
+func TestNewCredentialsPolicy(t *testing.T) {
+	_, err := NewCredentialsPolicy(config.Config{LoginCharset: "["})
+	assert.Error(t, err)
+
+	_, err = NewCredentialsPolicy(config.Config{PasswordBlocklistFile: filepath.Join(t.TempDir(), "missing")})
+	assert.Error(t, err)
+}